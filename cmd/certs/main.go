@@ -0,0 +1,168 @@
+// Command certs generates a local CA with server and client certificates
+// for testing TLS and mutual TLS offline:
+//
+//	go run ./cmd/certs -out certs -hosts localhost,127.0.0.1 -clients stream-client,ops
+//
+// An existing CA in the output directory is reused, so running it again
+// issues fresh leaf certificates that servers pick up without a restart.
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"flag"
+	"fmt"
+	"log"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+func main() {
+	out := flag.String("out", "certs", "output directory")
+	hosts := flag.String("hosts", "localhost,127.0.0.1,::1", "comma-separated DNS names and IPs for the server certificate")
+	clients := flag.String("clients", "stream-client,ops", "comma-separated common names of client certificates")
+	org := flag.String("org", "awesomeproject dev", "organization of issued certificates")
+	validFor := flag.Duration("valid", 90*24*time.Hour, "validity of leaf certificates")
+	flag.Parse()
+
+	if err := os.MkdirAll(*out, 0o700); err != nil {
+		log.Fatal(err)
+	}
+	ca, caKey, err := loadOrCreateCA(*out, *org)
+	if err != nil {
+		log.Fatalf("CA: %v", err)
+	}
+
+	server := &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "server", Organization: []string{*org}},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	for _, h := range split(*hosts) {
+		if ip := net.ParseIP(h); ip != nil {
+			server.IPAddresses = append(server.IPAddresses, ip)
+		} else {
+			server.DNSNames = append(server.DNSNames, h)
+		}
+	}
+	if err := issue(*out, "server", server, ca, caKey, *validFor); err != nil {
+		log.Fatalf("server certificate: %v", err)
+	}
+
+	for _, name := range split(*clients) {
+		client := &x509.Certificate{
+			Subject:     pkix.Name{CommonName: name, Organization: []string{*org}},
+			ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		}
+		if err := issue(*out, name, client, ca, caKey, *validFor); err != nil {
+			log.Fatalf("client certificate %s: %v", name, err)
+		}
+	}
+}
+
+func split(s string) []string {
+	var out []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func loadOrCreateCA(dir, org string) (*x509.Certificate, crypto.Signer, error) {
+	certFile, keyFile := filepath.Join(dir, "ca.pem"), filepath.Join(dir, "ca-key.pem")
+	if pair, err := tls.LoadX509KeyPair(certFile, keyFile); err == nil {
+		ca, err := x509.ParseCertificate(pair.Certificate[0])
+		if err != nil {
+			return nil, nil, err
+		}
+		log.Printf("reusing CA %s", certFile)
+		return ca, pair.PrivateKey.(crypto.Signer), nil
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial(),
+		Subject:               pkix.Name{CommonName: "awesomeproject dev CA", Organization: []string{org}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().AddDate(10, 0, 0),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := write(dir, "ca", der, key); err != nil {
+		return nil, nil, err
+	}
+	ca, err := x509.ParseCertificate(der)
+	return ca, key, err
+}
+
+func issue(dir, name string, tmpl, ca *x509.Certificate, caKey crypto.Signer, validFor time.Duration) error {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	tmpl.SerialNumber = serial()
+	tmpl.NotBefore = time.Now().Add(-time.Hour)
+	tmpl.NotAfter = time.Now().Add(validFor)
+	tmpl.KeyUsage = x509.KeyUsageDigitalSignature
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca, key.Public(), caKey)
+	if err != nil {
+		return err
+	}
+	return write(dir, name, der, key)
+}
+
+// write stores the certificate as <name>.pem and its key as <name>-key.pem.
+// Both are written to temporary files and renamed into place so a server
+// watching them never reads a half-written pair.
+func write(dir, name string, der []byte, key *ecdsa.PrivateKey) error {
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return err
+	}
+	files := []struct {
+		path  string
+		block *pem.Block
+	}{
+		{filepath.Join(dir, name+"-key.pem"), &pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}},
+		{filepath.Join(dir, name+".pem"), &pem.Block{Type: "CERTIFICATE", Bytes: der}},
+	}
+	for _, f := range files {
+		tmp := f.path + ".tmp"
+		if err := os.WriteFile(tmp, pem.EncodeToMemory(f.block), 0o600); err != nil {
+			return err
+		}
+		if err := os.Rename(tmp, f.path); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("wrote %s and %s\n", files[1].path, files[0].path)
+	return nil
+}
+
+func serial() *big.Int {
+	n, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 127))
+	if err != nil {
+		log.Fatal(err)
+	}
+	return n
+}
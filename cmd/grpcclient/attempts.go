@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/stats"
+	"google.golang.org/grpc/status"
+)
+
+// callLog collects the attempts of one call: gRPC retries, transparent
+// retries and hedges each start one.
+type callLog struct {
+	start time.Time
+
+	mu       sync.Mutex
+	attempts []*attempt
+}
+
+type attempt struct {
+	offset      time.Duration // since the call started
+	took        time.Duration
+	code        codes.Code
+	backend     string // remote address, once the attempt has a transport
+	done        bool
+	transparent bool
+}
+
+type callLogKey struct{}
+type attemptKey struct{}
+
+// summarize logs one line per call naming the backend that answered, e.g.
+// "/Greeter/SayHello: OK from 127.0.0.1:50052 after 3 attempts in 212ms
+// [#1 +0s Unavailable 1ms @127.0.0.1:50051, ...]".
+func (l *callLog) summarize(method string, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	code := status.Code(err)
+	parts := make([]string, len(l.attempts))
+	served := "no backend"
+	for i, a := range l.attempts {
+		result := "cancelled"
+		if a.done {
+			result = a.code.String() + " " + a.took.Round(time.Millisecond).String()
+		}
+		if a.transparent {
+			result += " (transparent)"
+		}
+		if a.backend != "" {
+			result += " @" + a.backend
+			if a.done && a.code == code {
+				served = a.backend
+			}
+		}
+		parts[i] = fmt.Sprintf("#%d +%v %s", i+1, a.offset.Round(time.Millisecond), result)
+	}
+	noun := "attempts"
+	if len(l.attempts) == 1 {
+		noun = "attempt"
+	}
+	log.Printf("%s: %s from %s after %d %s in %v [%s]", method, code, served, len(l.attempts), noun,
+		time.Since(l.start).Round(time.Millisecond), strings.Join(parts, ", "))
+}
+
+// attemptStats is a stats.Handler recording each attempt in the callLog
+// of its call.
+type attemptStats struct{}
+
+func (attemptStats) TagRPC(ctx context.Context, _ *stats.RPCTagInfo) context.Context {
+	l, ok := ctx.Value(callLogKey{}).(*callLog)
+	if !ok {
+		return ctx
+	}
+	a := &attempt{offset: time.Since(l.start)}
+	l.mu.Lock()
+	l.attempts = append(l.attempts, a)
+	l.mu.Unlock()
+	return context.WithValue(ctx, attemptKey{}, a)
+}
+
+func (attemptStats) HandleRPC(ctx context.Context, s stats.RPCStats) {
+	l, ok := ctx.Value(callLogKey{}).(*callLog)
+	if !ok {
+		return
+	}
+	a, ok := ctx.Value(attemptKey{}).(*attempt)
+	if !ok {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	switch s := s.(type) {
+	case *stats.Begin:
+		a.transparent = s.IsTransparentRetryAttempt
+	case *stats.OutHeader:
+		if s.RemoteAddr != nil {
+			a.backend = s.RemoteAddr.String()
+		}
+	case *stats.End:
+		a.done, a.code = true, status.Code(s.Error)
+		a.took = s.EndTime.Sub(s.BeginTime)
+	}
+}
+
+func (attemptStats) TagConn(ctx context.Context, _ *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+func (attemptStats) HandleConn(context.Context, stats.ConnStats) {}
+
+// summaryUnaryInterceptor starts a callLog for each call and prints it when
+// the call returns. It must be the outermost interceptor so that hedges
+// share one log.
+func summaryUnaryInterceptor(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	l := &callLog{start: time.Now()}
+	err := invoker(context.WithValue(ctx, callLogKey{}, l), method, req, reply, cc, opts...)
+	l.summarize(method, err)
+	return err
+}
+
+// summaryStreamInterceptor prints the callLog once the stream ends. Only
+// the first attempt of a stream can be retried, before any reply arrives.
+func summaryStreamInterceptor(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	l := &callLog{start: time.Now()}
+	cs, err := streamer(context.WithValue(ctx, callLogKey{}, l), desc, cc, method, opts...)
+	if err != nil {
+		l.summarize(method, err)
+		return nil, err
+	}
+	return &summaryStream{ClientStream: cs, log: l, method: method, single: !desc.ServerStreams}, nil
+}
+
+type summaryStream struct {
+	grpc.ClientStream
+	log    *callLog
+	method string
+	single bool // one reply: the stream ends with the first RecvMsg
+	once   sync.Once
+}
+
+func (s *summaryStream) RecvMsg(m any) error {
+	err := s.ClientStream.RecvMsg(m)
+	if err != nil || s.single {
+		s.once.Do(func() {
+			if err == io.EOF {
+				s.log.summarize(s.method, nil)
+			} else {
+				s.log.summarize(s.method, err)
+			}
+		})
+	}
+	return err
+}
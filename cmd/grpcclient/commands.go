@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	pb "awesomeproject/proto"
+
+	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// command is one grpcclient subcommand. name is the -name flag.
+type command struct {
+	usage string
+	run   func(ctx context.Context, conn *grpc.ClientConn, name string, args []string) error
+}
+
+var commands = map[string]command{
+	"hello":  {"hello             call SayHello", runHello},
+	"bye":    {"bye [message]     call SayBye", runBye},
+	"get":    {"get <data>        call GetStream and print each reply", runGet},
+	"put":    {"put [items...]    call PutStream with items, or stdin lines", runPut},
+	"all":    {"all [items...]    call AllStream with items, or stdin lines", runAll},
+	"health": {"health [-watch] [service]  query grpc.health.v1; the whole server if no service", runHealth},
+}
+
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "usage: grpcclient [flags] [command] [args...]\n\ncommands:\n")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(out, "  %s\n", commands[name].usage)
+	}
+	fmt.Fprintf(out, "\nflags:\n")
+	flag.PrintDefaults()
+}
+
+func runHello(ctx context.Context, conn *grpc.ClientConn, name string, _ []string) error {
+	hello, err := pb.NewGreeterClient(conn).SayHello(ctx, &pb.HelloRequest{Name: name})
+	if err != nil {
+		return fmt.Errorf("could not greet: %w", err)
+	}
+	log.Printf("Greeting: %s", hello.GetMessage())
+	return nil
+}
+
+func runBye(ctx context.Context, conn *grpc.ClientConn, name string, args []string) error {
+	message := "Goodbye"
+	if len(args) > 0 {
+		message = strings.Join(args, " ")
+	}
+	bye, err := pb.NewGreeterClient(conn).SayBye(ctx, &pb.ByeRequest{Name: name, Message: message})
+	if err != nil {
+		return fmt.Errorf("could not say bye: %w", err)
+	}
+	log.Printf("Farewell: %s", bye.GetMessage())
+	return nil
+}
+
+// runGet 服务端流: 一个请求, 多个响应
+func runGet(ctx context.Context, conn *grpc.ClientConn, name string, args []string) error {
+	data := name
+	if len(args) > 0 {
+		data = strings.Join(args, " ")
+	}
+	stream, err := pb.NewGreeterClient(conn).GetStream(ctx, &pb.StreamReqData{Data: data})
+	if err != nil {
+		return err
+	}
+	for {
+		res, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		log.Printf("GetStream: %s", res.GetData())
+	}
+}
+
+// runPut 客户端流: 多个请求, 一个汇总响应
+func runPut(ctx context.Context, conn *grpc.ClientConn, _ string, args []string) error {
+	stream, err := pb.NewGreeterClient(conn).PutStream(ctx)
+	if err != nil {
+		return err
+	}
+	if err := eachItem(args, func(item string) error {
+		return stream.Send(&pb.StreamReqData{Data: item})
+	}); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	// Send returns io.EOF when the server has already ended the call; the
+	// real status comes from CloseAndRecv.
+	res, err := stream.CloseAndRecv()
+	if err != nil {
+		return err
+	}
+	log.Printf("PutStream: %s", res.GetData())
+	return nil
+}
+
+// runAll 双向流: 边发边收
+func runAll(ctx context.Context, conn *grpc.ClientConn, _ string, args []string) error {
+	stream, err := pb.NewGreeterClient(conn).AllStream(ctx)
+	if err != nil {
+		return err
+	}
+	done := make(chan error, 1)
+	go func() {
+		for {
+			res, err := stream.Recv()
+			if errors.Is(err, io.EOF) {
+				done <- nil
+				return
+			}
+			if err != nil {
+				done <- err
+				return
+			}
+			log.Printf("AllStream: %s", res.GetData())
+		}
+	}()
+	// The server may end the stream while stdin is still open, e.g. at its
+	// deadline; its status is the result either way
+	sent := make(chan error, 1)
+	go func() {
+		if err := eachItem(args, func(item string) error {
+			return stream.Send(&pb.StreamReqData{Data: item})
+		}); err != nil && !errors.Is(err, io.EOF) {
+			sent <- err
+			return
+		}
+		sent <- stream.CloseSend()
+	}()
+	select {
+	case err := <-done:
+		return err
+	case err := <-sent:
+		if err != nil {
+			return err
+		}
+		return <-done
+	}
+}
+
+// eachItem calls fn for every arg, or for every line of stdin when there
+// are no args.
+func eachItem(args []string, fn func(string) error) error {
+	if len(args) > 0 {
+		for _, a := range args {
+			if err := fn(a); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		if err := fn(scanner.Text()); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// runHealth checks the server's health once, or with -watch prints every
+// status change until the deadline.
+func runHealth(ctx context.Context, conn *grpc.ClientConn, _ string, args []string) error {
+	fs := flag.NewFlagSet("health", flag.ExitOnError)
+	watch := fs.Bool("watch", false, "stream status changes")
+	_ = fs.Parse(args)
+	req := &healthpb.HealthCheckRequest{Service: fs.Arg(0)}
+	client := healthpb.NewHealthClient(conn)
+
+	if !*watch {
+		res, err := client.Check(ctx, req)
+		if err != nil {
+			return err
+		}
+		if res.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+			return fmt.Errorf("%q is %s", req.Service, res.GetStatus())
+		}
+		log.Printf("health %q: %s", req.Service, res.GetStatus())
+		return nil
+	}
+	stream, err := client.Watch(ctx, req)
+	if err != nil {
+		return err
+	}
+	for {
+		res, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+		log.Printf("health %q: %s", req.Service, res.GetStatus())
+	}
+}
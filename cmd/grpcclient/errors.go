@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/status"
+)
+
+// logDetails prints the google.rpc error details carried by err, one per
+// line, so the reason for a failure is visible beyond its message.
+func logDetails(err error) {
+	st, ok := status.FromError(err)
+	if !ok {
+		return
+	}
+	for _, d := range st.Details() {
+		for _, line := range describeDetail(d) {
+			log.Printf("  %s", line)
+		}
+	}
+}
+
+func describeDetail(d any) []string {
+	switch d := d.(type) {
+	case *errdetails.BadRequest:
+		lines := make([]string, 0, len(d.GetFieldViolations()))
+		for _, v := range d.GetFieldViolations() {
+			lines = append(lines, fmt.Sprintf("bad request: %s: %s", v.GetField(), v.GetDescription()))
+		}
+		return lines
+	case *errdetails.ErrorInfo:
+		line := fmt.Sprintf("error info: reason=%s domain=%s", d.GetReason(), d.GetDomain())
+		keys := make([]string, 0, len(d.GetMetadata()))
+		for k := range d.GetMetadata() {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			line += fmt.Sprintf(" %s=%s", k, d.GetMetadata()[k])
+		}
+		return []string{line}
+	case *errdetails.RetryInfo:
+		return []string{fmt.Sprintf("retry info: retry after %v", d.GetRetryDelay().AsDuration())}
+	case error:
+		// Details whose type is not linked into the client
+		return []string{"undecodable detail: " + d.Error()}
+	}
+	return []string{fmt.Sprintf("detail: %v", d)}
+}
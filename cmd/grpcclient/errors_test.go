@@ -0,0 +1,64 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestDescribeDetail(t *testing.T) {
+	tests := []struct {
+		name   string
+		detail any
+		want   []string
+	}{
+		{
+			name: "bad request",
+			detail: &errdetails.BadRequest{FieldViolations: []*errdetails.BadRequest_FieldViolation{
+				{Field: "name", Description: "must not be empty"},
+				{Field: "age", Description: "must be positive"},
+			}},
+			want: []string{"bad request: name: must not be empty", "bad request: age: must be positive"},
+		},
+		{
+			name:   "empty bad request",
+			detail: &errdetails.BadRequest{},
+			want:   []string{},
+		},
+		{
+			name: "error info",
+			detail: &errdetails.ErrorInfo{Reason: "INVALID_ARGUMENT", Domain: "greeter.awesomeproject",
+				Metadata: map[string]string{"method": "SayHello", "attempt": "2"}},
+			want: []string{"error info: reason=INVALID_ARGUMENT domain=greeter.awesomeproject attempt=2 method=SayHello"},
+		},
+		{
+			name:   "retry info",
+			detail: &errdetails.RetryInfo{RetryDelay: durationpb.New(1500 * time.Millisecond)},
+			want:   []string{"retry info: retry after 1.5s"},
+		},
+		{
+			name:   "undecodable",
+			detail: errors.New("unknown type"),
+			want:   []string{"undecodable detail: unknown type"},
+		},
+		{
+			name:   "other",
+			detail: wrapperspb.String("x"),
+			// The text format of a message is deliberately unstable.
+			want: []string{fmt.Sprintf("detail: %v", wrapperspb.String("x"))},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := describeDetail(tt.detail); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("describeDetail = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// hedgeInterceptor implements the hedgingPolicy of the service config:
+// it sends the call again every hedgingDelay, or at once after a
+// non-fatal failure, up to maxAttempts copies in flight, and returns the
+// first success or fatal error. The others are cancelled. The method's
+// timeout bounds all hedges together.
+func hedgeInterceptor(sc *serviceConfig) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		p := sc.policy(method)
+		hp := p.hedging
+		if hp == nil {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+		if p.timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, p.timeout)
+			defer cancel()
+		}
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel() // stops the losing hedges
+
+		type result struct {
+			reply proto.Message
+			err   error
+		}
+		results := make(chan result, hp.MaxAttempts)
+		sent, pending := 0, 0
+		send := func() {
+			r := proto.Clone(reply.(proto.Message))
+			proto.Reset(r)
+			sent++
+			pending++
+			go func() {
+				err := invoker(ctx, method, req, r, cc, opts...)
+				results <- result{r, err}
+			}()
+		}
+
+		send()
+		timer := time.NewTimer(hp.delay)
+		defer timer.Stop()
+		for {
+			select {
+			case <-timer.C:
+				if sent < hp.MaxAttempts {
+					send()
+					timer.Reset(hp.delay)
+				}
+			case r := <-results:
+				pending--
+				if r.err == nil {
+					proto.Merge(reply.(proto.Message), r.reply)
+					return nil
+				}
+				if !hp.nonFatal(status.Code(r.err)) {
+					return r.err
+				}
+				if sent < hp.MaxAttempts {
+					send()
+					timer.Reset(hp.delay)
+				} else if pending == 0 {
+					return r.err
+				}
+			}
+		}
+	}
+}
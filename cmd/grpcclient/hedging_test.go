@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	pb "awesomeproject/proto"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// step is how one hedge behaves: it waits for delay, or forever if delay
+// is negative, and then fails with code or succeeds if code is OK.
+type step struct {
+	delay time.Duration
+	code  codes.Code
+}
+
+// scriptedInvoker plays steps in the order the hedges are sent and
+// records how many were sent and how many were cancelled.
+type scriptedInvoker struct {
+	steps []step
+
+	mu        sync.Mutex
+	sent      int
+	cancelled int
+	done      sync.WaitGroup
+}
+
+func (s *scriptedInvoker) invoke(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+	s.mu.Lock()
+	n := s.sent
+	s.sent++
+	s.done.Add(1)
+	s.mu.Unlock()
+	defer s.done.Done()
+
+	st := s.steps[n]
+	var wait <-chan time.Time
+	if st.delay >= 0 {
+		wait = time.After(st.delay)
+	}
+	select {
+	case <-ctx.Done():
+		s.mu.Lock()
+		s.cancelled++
+		s.mu.Unlock()
+		return status.FromContextError(ctx.Err()).Err()
+	case <-wait:
+	}
+	if st.code != codes.OK {
+		return status.Errorf(st.code, "hedge %d failed", n)
+	}
+	reply.(*pb.HelloReply).Message = fmt.Sprintf("hedge %d", n)
+	return nil
+}
+
+func TestHedgeInterceptor(t *testing.T) {
+	_, sc, err := loadServiceConfig(writeServiceConfig(t, `{"methodConfig":[
+		{"name":[{"service":"Greeter","method":"SayHello"}],"timeout":"300ms",
+		 "hedgingPolicy":{"maxAttempts":3,"hedgingDelay":"0.05s","nonFatalStatusCodes":["UNAVAILABLE"]}}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	const never = -1
+	tests := []struct {
+		name          string
+		method        string
+		steps         []step
+		wantCode      codes.Code
+		wantReply     string
+		wantSent      int
+		wantCancelled int
+	}{
+		{name: "first wins", steps: []step{{0, codes.OK}},
+			wantReply: "hedge 0", wantSent: 1},
+		{name: "hedge after delay", steps: []step{{never, 0}, {0, codes.OK}},
+			wantReply: "hedge 1", wantSent: 2, wantCancelled: 1},
+		{name: "hedge after non-fatal failure", steps: []step{{0, codes.Unavailable}, {0, codes.OK}},
+			wantReply: "hedge 1", wantSent: 2},
+		{name: "fatal failure", steps: []step{{0, codes.InvalidArgument}},
+			wantCode: codes.InvalidArgument, wantSent: 1},
+		{name: "fatal failure cancels the others", steps: []step{{never, 0}, {0, codes.PermissionDenied}},
+			wantCode: codes.PermissionDenied, wantSent: 2, wantCancelled: 1},
+		{name: "all non-fatal", steps: []step{{0, codes.Unavailable}, {0, codes.Unavailable}, {0, codes.Unavailable}},
+			wantCode: codes.Unavailable, wantSent: 3},
+		{name: "timeout bounds all hedges", steps: []step{{never, 0}, {never, 0}, {never, 0}},
+			wantCode: codes.DeadlineExceeded, wantSent: 3, wantCancelled: 3},
+		{name: "no policy", method: "/Greeter/SayBye", steps: []step{{0, codes.Unavailable}},
+			wantCode: codes.Unavailable, wantSent: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inv := &scriptedInvoker{steps: tt.steps}
+			method := tt.method
+			if method == "" {
+				method = "/Greeter/SayHello"
+			}
+			reply := &pb.HelloReply{}
+			err := hedgeInterceptor(sc)(context.Background(), method, &pb.HelloRequest{Name: "a"}, reply, nil, inv.invoke)
+			inv.done.Wait()
+			if status.Code(err) != tt.wantCode || reply.GetMessage() != tt.wantReply {
+				t.Fatalf("got %q, %v; want %q, %s", reply.GetMessage(), err, tt.wantReply, tt.wantCode)
+			}
+			if inv.sent != tt.wantSent || inv.cancelled != tt.wantCancelled {
+				t.Fatalf("sent %d, cancelled %d; want %d, %d", inv.sent, inv.cancelled, tt.wantSent, tt.wantCancelled)
+			}
+		})
+	}
+}
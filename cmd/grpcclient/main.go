@@ -4,23 +4,100 @@ import (
 	"context"
 	"flag"
 	"log"
+	"os"
 	"time"
 
+	"awesomeproject/pkg/breaker"
+	"awesomeproject/pkg/grpclb"
+	"awesomeproject/pkg/rpccreds"
+	"awesomeproject/pkg/tlsutil"
 	pb "awesomeproject/proto"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
 )
 
 func main() {
 	// 定义命令行参数
-	addr := flag.String("addr", "127.0.0.1:50051", "server address")
+	addr := flag.String("addr", "127.0.0.1:50051", "server address, comma-separated addresses, or a static:/// or file:/// target")
 	name := flag.String("name", "world", "name to greet")
+	timeout := flag.Duration("timeout", 10*time.Second, "deadline for each run of the command")
+	transform := flag.String("transform", "", "AllStream transform: echo, upper, lower or reverse")
+	serviceConfigFile := flag.String("service-config", "", "gRPC service config JSON with per-method timeout, retryPolicy or hedgingPolicy")
+	attempts := flag.Bool("attempts", false, "log the attempts behind every call and the backend that served it")
+	lbPolicy := flag.String("lb", grpclb.RoundRobin, "balancing policy across backends: pick_first, round_robin or least_request")
+	healthService := flag.String("health-service", pb.Greeter_ServiceDesc.ServiceName, "grpc.health.v1 service backends must report SERVING for (empty disables)")
+	repeat := flag.Int("repeat", 1, "run the command this many times")
+	interval := flag.Duration("interval", 0, "pause between runs with -repeat")
+	flag.Usage = usage
+	var credsCfg rpccreds.Config
+	credsCfg.RegisterFlags(flag.CommandLine)
+	var tlsCfg tlsutil.Config
+	tlsCfg.RegisterClientFlags(flag.CommandLine)
+	breakerCfg := breaker.DefaultConfig()
+	breakerCfg.FailureRate = 0 // off unless asked for
+	breakerCfg.RegisterFlags(flag.CommandLine)
 	flag.Parse()
 
+	transport := insecure.NewCredentials()
+	if tlsCfg.ClientEnabled() {
+		tlsCfg.DefaultServerName(*addr)
+		tc, err := tlsutil.Client(context.Background(), tlsCfg)
+		if err != nil {
+			log.Fatalf("tls: %v", err)
+		}
+		transport = credentials.NewTLS(tc)
+	}
+	dialOpts := []grpc.DialOption{grpc.WithTransportCredentials(transport)}
+	if credsCfg.Enabled() {
+		creds, err := rpccreds.FromConfig(context.Background(), credsCfg)
+		if err != nil {
+			log.Fatalf("credentials: %v", err)
+		}
+		if creds.RequireTransportSecurity() && !tlsCfg.ClientEnabled() {
+			log.Fatalf("refusing to send a token over a plaintext connection; use -tls-ca or pass -insecure-token")
+		}
+		dialOpts = append(dialOpts, grpc.WithPerRPCCredentials(creds))
+	}
+	// The summary goes outermost so that every hedge lands in one log
+	var unary []grpc.UnaryClientInterceptor
+	if *attempts {
+		unary = append(unary, summaryUnaryInterceptor)
+		dialOpts = append(dialOpts,
+			grpc.WithStatsHandler(attemptStats{}),
+			grpc.WithChainStreamInterceptor(summaryStreamInterceptor))
+	}
+	// The breaker sees a call once, after all its retries or hedges
+	if breakerCfg.Enabled() {
+		breakerCfg.OnStateChange = breaker.LogEvents(nil)
+		breakers := breaker.New(breakerCfg)
+		unary = append(unary, breaker.UnaryClientInterceptor(breakers))
+		dialOpts = append(dialOpts, grpc.WithChainStreamInterceptor(breaker.StreamClientInterceptor(breakers)))
+	}
+	raw := "{}"
+	if *serviceConfigFile != "" {
+		var sc *serviceConfig
+		var err error
+		if raw, sc, err = loadServiceConfig(*serviceConfigFile); err != nil {
+			log.Fatalf("service config: %v", err)
+		}
+		// gRPC applies timeout and retryPolicy itself; hedging is ours
+		unary = append(unary, hedgeInterceptor(sc))
+	}
+	raw, err := grpclb.WithBalancing(raw, *lbPolicy, *healthService)
+	if err != nil {
+		log.Fatalf("service config: %v", err)
+	}
+	dialOpts = append(dialOpts,
+		grpc.WithChainUnaryInterceptor(unary...),
+		grpc.WithDefaultServiceConfig(raw),
+		grpclb.Resolvers())
+
 	// 【优化】使用 grpc.NewClient 替换已弃用的 grpc.Dial
 	// NewClient 是现代 gRPC-Go 推荐的连接方式
-	conn, err := grpc.NewClient(*addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	conn, err := grpc.NewClient(grpclb.Target(*addr), dialOpts...)
 	if err != nil {
 		log.Fatalf("did not connect: %v", err)
 	}
@@ -31,24 +108,54 @@ func main() {
 		}
 	}(conn) // 确保程序退出时关闭连接
 
-	// 创建服务客户端
-	client := pb.NewGreeterClient(conn)
+	// 不带子命令时保持原来的行为: 依次调用 SayHello 和 SayBye
+	args := flag.Args()
+	runs := []func(context.Context, *grpc.ClientConn, string, []string) error{runHello, runBye}
+	prefix := ""
+	if len(args) > 0 {
+		cmd, ok := commands[args[0]]
+		if !ok {
+			flag.Usage()
+			os.Exit(2)
+		}
+		runs = []func(context.Context, *grpc.ClientConn, string, []string) error{cmd.run}
+		prefix, args = args[0]+": ", args[1:]
+	}
 
+	// With -repeat a failed run is logged and the next one goes ahead, so
+	// that failover between backends can be watched
+	failed := 0
+	for i := 0; i < *repeat; i++ {
+		if i > 0 {
+			time.Sleep(*interval)
+		}
+		if err := runOnce(conn, *timeout, *transform, *name, args, runs); err != nil {
+			if *repeat == 1 {
+				log.Printf("%s%v", prefix, err)
+				logDetails(err)
+				os.Exit(1)
+			}
+			log.Printf("run %d: %s%v", i+1, prefix, err)
+			logDetails(err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		log.Fatalf("%d of %d runs failed", failed, *repeat)
+	}
+}
+
+func runOnce(conn *grpc.ClientConn, timeout time.Duration, transform, name string, args []string, runs []func(context.Context, *grpc.ClientConn, string, []string) error) error {
 	// 设置超时上下文，防止请求长时间阻塞
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
-
-	// 调用 SayHello
-	hello, err := client.SayHello(ctx, &pb.HelloRequest{Name: *name})
-	if err != nil {
-		log.Fatalf("could not greet: %v", err)
+	if transform != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "transform", transform)
 	}
-	log.Printf("Greeting: %s", hello.GetMessage())
-
-	// 调用 SayBye
-	bye, err := client.SayBye(ctx, &pb.ByeRequest{Name: *name, Message: "Goodbye"})
-	if err != nil {
-		log.Fatalf("could not say bye: %v", err)
+	for _, run := range runs {
+		if err := run(ctx, conn, name, args); err != nil {
+			return err
+		}
 	}
-	log.Printf("Farewell: %s", bye.GetMessage())
+	return nil
 }
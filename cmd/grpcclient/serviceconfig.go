@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+)
+
+// serviceConfig is the part of a gRPC service config this client reads
+// itself. gRPC-Go applies retryPolicy and timeout on its own but ignores
+// hedgingPolicy, which hedgeInterceptor implements.
+type serviceConfig struct {
+	MethodConfig []methodConfig `json:"methodConfig"`
+}
+
+type methodConfig struct {
+	Name []struct {
+		Service string `json:"service"`
+		Method  string `json:"method"`
+	} `json:"name"`
+	Timeout       string         `json:"timeout"`
+	HedgingPolicy *hedgingPolicy `json:"hedgingPolicy"`
+	RetryPolicy   any            `json:"retryPolicy"`
+}
+
+type hedgingPolicy struct {
+	MaxAttempts         int          `json:"maxAttempts"`
+	HedgingDelay        string       `json:"hedgingDelay"`
+	NonFatalStatusCodes []codes.Code `json:"nonFatalStatusCodes"`
+
+	delay time.Duration
+}
+
+// nonFatal reports whether an attempt failing with code lets the other
+// hedges carry on.
+func (p *hedgingPolicy) nonFatal(code codes.Code) bool {
+	for _, c := range p.NonFatalStatusCodes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// methodPolicy is what applies to one method after resolving the
+// method, service and default entries of a service config.
+type methodPolicy struct {
+	timeout time.Duration
+	hedging *hedgingPolicy
+}
+
+// loadServiceConfig reads a service config file and returns its JSON for
+// grpc.WithDefaultServiceConfig along with the parsed config.
+func loadServiceConfig(path string) (string, *serviceConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", nil, err
+	}
+	var sc serviceConfig
+	if err := json.Unmarshal(data, &sc); err != nil {
+		return "", nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for i, mc := range sc.MethodConfig {
+		if mc.Timeout != "" {
+			if _, err := time.ParseDuration(mc.Timeout); err != nil {
+				return "", nil, fmt.Errorf("%s: methodConfig[%d]: timeout: %w", path, i, err)
+			}
+		}
+		if hp := mc.HedgingPolicy; hp != nil {
+			if mc.RetryPolicy != nil {
+				return "", nil, fmt.Errorf("%s: methodConfig[%d]: retryPolicy and hedgingPolicy are mutually exclusive", path, i)
+			}
+			if hp.MaxAttempts < 2 {
+				return "", nil, fmt.Errorf("%s: methodConfig[%d]: hedgingPolicy.maxAttempts must be at least 2", path, i)
+			}
+			if hp.HedgingDelay != "" {
+				if hp.delay, err = time.ParseDuration(hp.HedgingDelay); err != nil {
+					return "", nil, fmt.Errorf("%s: methodConfig[%d]: hedgingDelay: %w", path, i, err)
+				}
+			}
+		}
+	}
+	return string(data), &sc, nil
+}
+
+// policy returns the settings for fullMethod ("/Greeter/SayHello"). As in
+// gRPC, an entry naming the method wins over one naming its service, which
+// wins over the default entry with an empty name.
+func (sc *serviceConfig) policy(fullMethod string) methodPolicy {
+	service, method, _ := strings.Cut(strings.TrimPrefix(fullMethod, "/"), "/")
+	var best *methodConfig
+	bestRank := 0
+	for i := range sc.MethodConfig {
+		mc := &sc.MethodConfig[i]
+		for _, n := range mc.Name {
+			rank := 0
+			switch {
+			case n.Service == service && n.Method == method:
+				rank = 3
+			case n.Service == service && n.Method == "":
+				rank = 2
+			case n.Service == "" && n.Method == "":
+				rank = 1
+			}
+			if rank > bestRank {
+				best, bestRank = mc, rank
+			}
+		}
+	}
+	var p methodPolicy
+	if best != nil {
+		p.timeout, _ = time.ParseDuration(best.Timeout)
+		p.hedging = best.HedgingPolicy
+	}
+	return p
+}
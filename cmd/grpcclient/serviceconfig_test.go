@@ -0,0 +1,88 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeServiceConfig(t *testing.T, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "service.json")
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadServiceConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr string
+	}{
+		{"example", "", ""},
+		{"not JSON", `{`, "unexpected end"},
+		{"bad timeout", `{"methodConfig":[{"timeout":"soon"}]}`, "methodConfig[0]: timeout"},
+		{"both policies", `{"methodConfig":[{"hedgingPolicy":{"maxAttempts":2},"retryPolicy":{}}]}`, "mutually exclusive"},
+		{"one attempt", `{"methodConfig":[{"hedgingPolicy":{"maxAttempts":1}}]}`, "at least 2"},
+		{"bad delay", `{"methodConfig":[{"hedgingPolicy":{"maxAttempts":2,"hedgingDelay":"x"}}]}`, "hedgingDelay"},
+		{"bad code", `{"methodConfig":[{"hedgingPolicy":{"maxAttempts":2,"nonFatalStatusCodes":["NOPE"]}}]}`, "NOPE"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join("..", "..", "config", "grpcclient.service.json")
+			if tt.data != "" {
+				path = writeServiceConfig(t, tt.data)
+			}
+			raw, sc, err := loadServiceConfig(path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || raw == "" || sc == nil {
+				t.Fatalf("loadServiceConfig = %q, %v, %v", raw, sc, err)
+			}
+		})
+	}
+}
+
+func TestServiceConfigPolicy(t *testing.T) {
+	_, sc, err := loadServiceConfig(writeServiceConfig(t, `{"methodConfig":[
+		{"name":[{}],"timeout":"1s"},
+		{"name":[{"service":"Greeter"}],"timeout":"2s"},
+		{"name":[{"service":"Greeter","method":"SayHello"},{"service":"Other","method":"Get"}],"timeout":"3s",
+		 "hedgingPolicy":{"maxAttempts":3,"hedgingDelay":"0.2s","nonFatalStatusCodes":["UNAVAILABLE"]}}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		method      string
+		wantTimeout time.Duration
+		wantHedging bool
+	}{
+		{"/Greeter/SayHello", 3 * time.Second, true},
+		{"/Other/Get", 3 * time.Second, true},
+		{"/Greeter/SayBye", 2 * time.Second, false},
+		{"/Other/Put", time.Second, false},
+		{"/grpc.health.v1.Health/Check", time.Second, false},
+	}
+	for _, tt := range tests {
+		p := sc.policy(tt.method)
+		if p.timeout != tt.wantTimeout || (p.hedging != nil) != tt.wantHedging {
+			t.Errorf("policy(%q) = %v, %v; want %v, hedging %v", tt.method, p.timeout, p.hedging, tt.wantTimeout, tt.wantHedging)
+		}
+	}
+	if hp := sc.policy("/Greeter/SayHello").hedging; hp.delay != 200*time.Millisecond || hp.MaxAttempts != 3 {
+		t.Fatalf("hedging policy %+v", hp)
+	}
+
+	if p := (&serviceConfig{}).policy("/Greeter/SayHello"); p.timeout != 0 || p.hedging != nil {
+		t.Fatalf("empty config gave %+v", p)
+	}
+}
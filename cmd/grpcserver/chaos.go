@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"math/rand/v2"
+	"strings"
+	"time"
+
+	pb "awesomeproject/proto"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// chaosConfig injects failures and latency into unary Greeter calls, so
+// client retries and hedging can be tried against a local server.
+type chaosConfig struct {
+	failRate  float64
+	slowRate  float64
+	slowDelay time.Duration
+}
+
+func (c *chaosConfig) registerFlags(fs *flag.FlagSet) {
+	fs.Float64Var(&c.failRate, "chaos-fail", 0, "fraction of unary Greeter calls that fail with Unavailable")
+	fs.Float64Var(&c.slowRate, "chaos-slow", 0, "fraction of unary Greeter calls delayed by -chaos-delay")
+	fs.DurationVar(&c.slowDelay, "chaos-delay", 500*time.Millisecond, "delay added to slow calls")
+}
+
+func (c *chaosConfig) enabled() bool {
+	return c.failRate > 0 || c.slowRate > 0
+}
+
+func (c *chaosConfig) unaryInterceptor() grpc.UnaryServerInterceptor {
+	prefix := "/" + pb.Greeter_ServiceDesc.ServiceName + "/"
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if !strings.HasPrefix(info.FullMethod, prefix) {
+			return handler(ctx, req)
+		}
+		if rand.Float64() < c.failRate {
+			return nil, unavailable("chaos: injected failure", reasonChaos, 100*time.Millisecond)
+		}
+		if rand.Float64() < c.slowRate {
+			select {
+			case <-ctx.Done():
+				return nil, status.FromContextError(ctx.Err()).Err()
+			case <-time.After(c.slowDelay):
+			}
+		}
+		return handler(ctx, req)
+	}
+}
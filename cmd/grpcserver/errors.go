@@ -0,0 +1,50 @@
+package main
+
+import (
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// errorDomain names this server in the ErrorInfo details it returns.
+const errorDomain = "greeter.awesomeproject"
+
+// Reasons carried by ErrorInfo details; clients may switch on them.
+const (
+	reasonInvalidArgument = "INVALID_ARGUMENT"
+	reasonChaos           = "CHAOS_INJECTED"
+)
+
+// detailedError returns a status error with details attached. Details that
+// cannot be marshaled are dropped rather than hiding the error itself.
+func detailedError(code codes.Code, msg string, details ...protoadapt.MessageV1) error {
+	st := status.New(code, msg)
+	if withDetails, err := st.WithDetails(details...); err == nil {
+		st = withDetails
+	}
+	return st.Err()
+}
+
+// invalidArgument reports field violations in a request to method.
+func invalidArgument(method string, violations ...*errdetails.BadRequest_FieldViolation) error {
+	msg := "invalid " + method + " request"
+	if len(violations) == 1 {
+		msg += ": " + violations[0].GetField() + " " + violations[0].GetDescription()
+	}
+	return detailedError(codes.InvalidArgument, msg,
+		&errdetails.BadRequest{FieldViolations: violations},
+		&errdetails.ErrorInfo{Reason: reasonInvalidArgument, Domain: errorDomain, Metadata: map[string]string{"method": method}},
+	)
+}
+
+// unavailable tells the client to try again after retryAfter.
+func unavailable(msg, reason string, retryAfter time.Duration) error {
+	return detailedError(codes.Unavailable, msg,
+		&errdetails.RetryInfo{RetryDelay: durationpb.New(retryAfter)},
+		&errdetails.ErrorInfo{Reason: reason, Domain: errorDomain},
+	)
+}
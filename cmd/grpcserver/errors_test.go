@@ -0,0 +1,89 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/protoadapt"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+func TestErrors(t *testing.T) {
+	nameViolation := &errdetails.BadRequest_FieldViolation{Field: "name", Description: "must not be empty"}
+	ageViolation := &errdetails.BadRequest_FieldViolation{Field: "age", Description: "must be positive"}
+	tests := []struct {
+		name        string
+		err         error
+		wantCode    codes.Code
+		wantMsg     string
+		wantDetails []proto.Message
+	}{
+		{
+			name:     "one violation",
+			err:      invalidArgument("SayHello", nameViolation),
+			wantCode: codes.InvalidArgument,
+			wantMsg:  "invalid SayHello request: name must not be empty",
+			wantDetails: []proto.Message{
+				&errdetails.BadRequest{FieldViolations: []*errdetails.BadRequest_FieldViolation{nameViolation}},
+				&errdetails.ErrorInfo{Reason: reasonInvalidArgument, Domain: errorDomain, Metadata: map[string]string{"method": "SayHello"}},
+			},
+		},
+		{
+			name:     "several violations",
+			err:      invalidArgument("SayHello", nameViolation, ageViolation),
+			wantCode: codes.InvalidArgument,
+			wantMsg:  "invalid SayHello request",
+			wantDetails: []proto.Message{
+				&errdetails.BadRequest{FieldViolations: []*errdetails.BadRequest_FieldViolation{nameViolation, ageViolation}},
+				&errdetails.ErrorInfo{Reason: reasonInvalidArgument, Domain: errorDomain, Metadata: map[string]string{"method": "SayHello"}},
+			},
+		},
+		{
+			name:     "unavailable",
+			err:      unavailable("overloaded", reasonChaos, 250*time.Millisecond),
+			wantCode: codes.Unavailable,
+			wantMsg:  "overloaded",
+			wantDetails: []proto.Message{
+				&errdetails.RetryInfo{RetryDelay: durationpb.New(250 * time.Millisecond)},
+				&errdetails.ErrorInfo{Reason: reasonChaos, Domain: errorDomain},
+			},
+		},
+		{
+			name:     "no details",
+			err:      detailedError(codes.NotFound, "gone"),
+			wantCode: codes.NotFound,
+			wantMsg:  "gone",
+		},
+		{
+			// A nil detail cannot be marshaled; the error survives without it.
+			name:     "bad detail",
+			err:      detailedError(codes.Internal, "broken", protoadapt.MessageV1(nil)),
+			wantCode: codes.Internal,
+			wantMsg:  "broken",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := status.Convert(tt.err)
+			if st.Code() != tt.wantCode || st.Message() != tt.wantMsg {
+				t.Fatalf("status = %s %q, want %s %q", st.Code(), st.Message(), tt.wantCode, tt.wantMsg)
+			}
+			details := st.Details()
+			if len(details) != len(tt.wantDetails) {
+				t.Fatalf("got %d details %v, want %d", len(details), details, len(tt.wantDetails))
+			}
+			for i, d := range details {
+				if err, ok := d.(error); ok {
+					t.Fatalf("detail %d: %v", i, err)
+				}
+				if !proto.Equal(d.(proto.Message), tt.wantDetails[i]) {
+					t.Errorf("detail %d = %v, want %v", i, d, tt.wantDetails[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"awesomeproject/pkg/tlsutil"
+	pb "awesomeproject/proto"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// greeterServer implements every Greeter RPC.
+type greeterServer struct {
+	pb.UnimplementedGreeterServer
+
+	// streamCount and streamInterval pace GetStream replies.
+	streamCount    int
+	streamInterval time.Duration
+	// putMaxBytes caps the data one PutStream may send (0 = unlimited).
+	putMaxBytes int
+}
+
+func (s *greeterServer) SayHello(ctx context.Context, req *pb.HelloRequest) (*pb.HelloReply, error) {
+	if id, ok := tlsutil.PeerFromContext(ctx); ok {
+		log.Printf("SayHello from client certificate %s", id)
+	}
+	if strings.TrimSpace(req.GetName()) == "" {
+		return nil, invalidArgument("SayHello", &errdetails.BadRequest_FieldViolation{
+			Field:       "name",
+			Description: "must not be empty",
+		})
+	}
+	return &pb.HelloReply{Message: "Hello " + req.GetName()}, nil
+}
+
+func (s *greeterServer) SayBye(_ context.Context, req *pb.ByeRequest) (*pb.HelloReply, error) {
+	message := req.GetMessage()
+	if message == "" {
+		message = "Bye"
+	}
+	return &pb.HelloReply{Message: message + " " + req.GetName()}, nil
+}
+
+// GetStream sends streamCount numbered replies to req, one every
+// streamInterval, stopping early if the client goes away.
+func (s *greeterServer) GetStream(req *pb.StreamReqData, stream pb.Greeter_GetStreamServer) error {
+	ctx := stream.Context()
+	ticker := time.NewTicker(s.streamInterval)
+	defer ticker.Stop()
+	for i := 1; i <= s.streamCount; i++ {
+		if err := stream.Send(&pb.StreamResData{Data: fmt.Sprintf("%s #%d/%d", req.GetData(), i, s.streamCount)}); err != nil {
+			return err
+		}
+		if i == s.streamCount {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+// putPreview is how many PutStream items the reply quotes; the rest are
+// only counted.
+const putPreview = 10
+
+// PutStream counts the messages the client sends and replies with a
+// summary once the client closes its side. A stream carrying more than
+// putMaxBytes of data fails with ResourceExhausted.
+func (s *greeterServer) PutStream(stream pb.Greeter_PutStreamServer) error {
+	var preview []string
+	count, size := 0, 0
+	for {
+		req, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		count++
+		size += len(req.GetData())
+		if s.putMaxBytes > 0 && size > s.putMaxBytes {
+			return status.Errorf(codes.ResourceExhausted, "PutStream data exceeds %d bytes", s.putMaxBytes)
+		}
+		if len(preview) < putPreview {
+			preview = append(preview, req.GetData())
+		}
+	}
+	summary := strings.Join(preview, ", ")
+	if count > len(preview) {
+		summary += fmt.Sprintf(", ... (%d more)", count-len(preview))
+	}
+	return stream.SendAndClose(&pb.StreamResData{
+		Data: fmt.Sprintf("received %d messages (%d bytes): %s", count, size, summary),
+	})
+}
+
+// transforms are the AllStream modes selectable with the "transform"
+// request metadata.
+var transforms = map[string]func(string) string{
+	"echo":  func(s string) string { return s },
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	"reverse": func(s string) string {
+		r := []rune(s)
+		for i, j := 0, len(r)-1; i < j; i, j = i+1, j-1 {
+			r[i], r[j] = r[j], r[i]
+		}
+		return string(r)
+	},
+}
+
+// AllStream answers each message as it arrives, transformed according to
+// the "transform" metadata (echo by default).
+func (s *greeterServer) AllStream(stream pb.Greeter_AllStreamServer) error {
+	mode := "echo"
+	if v := metadata.ValueFromIncomingContext(stream.Context(), "transform"); len(v) > 0 {
+		mode = v[0]
+	}
+	transform, ok := transforms[mode]
+	if !ok {
+		return status.Errorf(codes.InvalidArgument, "unknown transform %q", mode)
+	}
+	for {
+		req, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := stream.Send(&pb.StreamResData{Data: transform(req.GetData())}); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	pb "awesomeproject/proto"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// dialGreeter serves s in process and returns a client for it.
+func dialGreeter(t *testing.T, s *greeterServer) pb.GreeterClient {
+	t.Helper()
+	l := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	pb.RegisterGreeterServer(srv, s)
+	go srv.Serve(l)
+	t.Cleanup(srv.Stop)
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return l.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return pb.NewGreeterClient(conn)
+}
+
+func testContext(t *testing.T) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+func TestSayHelloAndBye(t *testing.T) {
+	c := dialGreeter(t, &greeterServer{})
+	ctx := testContext(t)
+	tests := []struct {
+		name     string
+		call     func() (*pb.HelloReply, error)
+		want     string
+		wantCode codes.Code
+	}{
+		{"hello", func() (*pb.HelloReply, error) { return c.SayHello(ctx, &pb.HelloRequest{Name: "a"}) }, "Hello a", codes.OK},
+		{"hello blank", func() (*pb.HelloReply, error) { return c.SayHello(ctx, &pb.HelloRequest{Name: " "}) }, "", codes.InvalidArgument},
+		{"bye", func() (*pb.HelloReply, error) { return c.SayBye(ctx, &pb.ByeRequest{Name: "a"}) }, "Bye a", codes.OK},
+		{"bye message", func() (*pb.HelloReply, error) { return c.SayBye(ctx, &pb.ByeRequest{Name: "a", Message: "Later"}) }, "Later a", codes.OK},
+	}
+	for _, tt := range tests {
+		reply, err := tt.call()
+		if status.Code(err) != tt.wantCode || reply.GetMessage() != tt.want {
+			t.Errorf("%s: %q, %v; want %q, %s", tt.name, reply.GetMessage(), err, tt.want, tt.wantCode)
+		}
+	}
+}
+
+func TestGetStream(t *testing.T) {
+	c := dialGreeter(t, &greeterServer{streamCount: 3, streamInterval: time.Millisecond})
+	stream, err := c.GetStream(testContext(t), &pb.StreamReqData{Data: "x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for {
+		res, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, res.GetData())
+	}
+	if strings.Join(got, "|") != "x #1/3|x #2/3|x #3/3" {
+		t.Fatalf("got %q", got)
+	}
+}
+
+func TestPutStream(t *testing.T) {
+	items := func(n int, data string) []string {
+		out := make([]string, n)
+		for i := range out {
+			out[i] = data
+		}
+		return out
+	}
+	tests := []struct {
+		name     string
+		maxBytes int
+		send     []string
+		want     string
+		wantCode codes.Code
+	}{
+		{"empty", 0, nil, "received 0 messages (0 bytes): ", codes.OK},
+		{"few", 0, []string{"a", "bb"}, "received 2 messages (3 bytes): a, bb", codes.OK},
+		{"preview", 0, items(12, "x"), "received 12 messages (12 bytes): x, x, x, x, x, x, x, x, x, x, ... (2 more)", codes.OK},
+		{"at the bound", 4, []string{"ab", "cd"}, "received 2 messages (4 bytes): ab, cd", codes.OK},
+		{"over the bound", 4, []string{"ab", "cd", "e"}, "", codes.ResourceExhausted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := dialGreeter(t, &greeterServer{putMaxBytes: tt.maxBytes})
+			stream, err := c.PutStream(testContext(t))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, d := range tt.send {
+				if err := stream.Send(&pb.StreamReqData{Data: d}); err != nil {
+					break // the server gave up; CloseAndRecv has its status
+				}
+			}
+			res, err := stream.CloseAndRecv()
+			if status.Code(err) != tt.wantCode || res.GetData() != tt.want {
+				t.Fatalf("got %q, %v; want %q, %s", res.GetData(), err, tt.want, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestAllStream(t *testing.T) {
+	c := dialGreeter(t, &greeterServer{})
+	tests := []struct {
+		transform string
+		want      string
+		wantCode  codes.Code
+	}{
+		{"", "Héllo", codes.OK},
+		{"echo", "Héllo", codes.OK},
+		{"upper", "HÉLLO", codes.OK},
+		{"lower", "héllo", codes.OK},
+		{"reverse", "olléH", codes.OK},
+		{"rot13", "", codes.InvalidArgument},
+	}
+	for _, tt := range tests {
+		t.Run(tt.transform, func(t *testing.T) {
+			ctx := testContext(t)
+			if tt.transform != "" {
+				ctx = metadata.AppendToOutgoingContext(ctx, "transform", tt.transform)
+			}
+			stream, err := c.AllStream(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			stream.Send(&pb.StreamReqData{Data: "Héllo"})
+			res, err := stream.Recv()
+			if status.Code(err) != tt.wantCode || res.GetData() != tt.want {
+				t.Fatalf("got %q, %v; want %q, %s", res.GetData(), err, tt.want, tt.wantCode)
+			}
+			if err == nil {
+				stream.CloseSend()
+				if _, err := stream.Recv(); err != io.EOF {
+					t.Fatalf("after CloseSend: %v", err)
+				}
+			}
+		})
+	}
+}
@@ -5,39 +5,141 @@ import (
 	"flag"
 	"log"
 	"net"
+	"time"
 
+	"awesomeproject/handler"
+	"awesomeproject/pkg/auth"
+	"awesomeproject/pkg/authz"
+	"awesomeproject/pkg/health"
+	"awesomeproject/pkg/ratelimit"
+	"awesomeproject/pkg/shutdown"
+	"awesomeproject/pkg/tlsutil"
 	pb "awesomeproject/proto"
+	"awesomeproject/serverStub"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
 )
 
-type greeterServer struct {
-	pb.UnimplementedGreeterServer
-}
-
-func (s *greeterServer) SayHello(_ context.Context, req *pb.HelloRequest) (*pb.HelloReply, error) {
-	return &pb.HelloReply{Message: "Hello " + req.GetName()}, nil
-}
-
-func (s *greeterServer) SayBye(_ context.Context, req *pb.ByeRequest) (*pb.HelloReply, error) {
-	message := req.GetMessage()
-	if message == "" {
-		message = "Bye"
-	}
-	return &pb.HelloReply{Message: message + " " + req.GetName()}, nil
-}
-
 func main() {
 	addr := flag.String("addr", "127.0.0.1:50051", "listen address")
+	streamCount := flag.Int("stream-count", 5, "number of replies GetStream sends")
+	streamInterval := flag.Duration("stream-interval", 500*time.Millisecond, "pause between GetStream replies")
+	putMaxBytes := flag.Int("put-max-bytes", 1<<20, "most data one PutStream may send (0 = unlimited)")
+	rate := flag.Float64("rate", 0, "default calls per second per client (0 = unlimited)")
+	burst := flag.Int("burst", 10, "default rate limit burst")
+	rateKey := flag.String("ratelimit-key", "principal", "rate limit clients by: principal, ip or metadata:<key>")
+	rateMaxClients := flag.Int("ratelimit-max-clients", ratelimit.DefaultMaxClients, "most clients to keep rate limit buckets for")
+	rateConfig := flag.String("ratelimit-config", "", "JSON file with per-method rate limits (overrides -rate/-burst/-ratelimit-key)")
+	var authCfg auth.Config
+	authCfg.RegisterFlags(flag.CommandLine)
+	var authzCfg authz.Config
+	authzCfg.RegisterFlags(flag.CommandLine)
+	var tlsCfg tlsutil.Config
+	tlsCfg.RegisterServerFlags(flag.CommandLine)
+	var healthCfg health.Config
+	healthCfg.RegisterFlags(flag.CommandLine)
+	var chaos chaosConfig
+	chaos.registerFlags(flag.CommandLine)
+	shutdownTimeout := flag.Duration("shutdown-timeout", shutdown.DefaultTimeout, "how long to wait for in-flight calls on shutdown")
 	flag.Parse()
+	if *streamInterval <= 0 {
+		log.Fatal("-stream-interval must be positive")
+	}
+
+	rlCfg := ratelimit.Config{Default: ratelimit.Rule{Rate: *rate, Burst: *burst}, Key: *rateKey, MaxClients: *rateMaxClients}
+	if *rateConfig != "" {
+		var err error
+		if rlCfg, err = ratelimit.LoadConfig(*rateConfig); err != nil {
+			log.Fatalf("load rate limit config: %v", err)
+		}
+	}
+	rlKey, err := ratelimit.GRPCKey(rlCfg.Key)
+	if err != nil {
+		log.Fatal(err)
+	}
+	limiter := ratelimit.New(rlCfg)
+	var unary []grpc.UnaryServerInterceptor
+	var stream []grpc.StreamServerInterceptor
+	var policy *authz.Engine
+	if authzCfg.Enabled() {
+		if policy, err = authz.Start(context.Background(), authzCfg); err != nil {
+			log.Fatalf("authz: %v", err)
+		}
+	}
+	if authCfg.Enabled() {
+		// Probes from load balancers carry no token
+		authCfg.Public = append(authCfg.Public, health.Methods)
+		authenticator, err := auth.New(authCfg)
+		if err != nil {
+			log.Fatalf("auth: %v", err)
+		}
+		if policy != nil {
+			authenticator.AllowAnonymous(policy.IsPublic)
+		}
+		unary = append(unary, auth.UnaryServerInterceptor(authenticator))
+		stream = append(stream, auth.StreamServerInterceptor(authenticator))
+	}
+	// After authentication, so buckets are keyed on verified callers and
+	// unauthenticated calls cost nothing
+	unary = append(unary, ratelimit.UnaryServerInterceptor(limiter, rlKey))
+	stream = append(stream, ratelimit.StreamServerInterceptor(limiter, rlKey))
+	if policy != nil {
+		unary = append(unary, authz.UnaryServerInterceptor(policy))
+		stream = append(stream, authz.StreamServerInterceptor(policy))
+	}
+	if chaos.enabled() {
+		log.Printf("chaos: failing %.0f%% and delaying %.0f%% of unary Greeter calls", 100*chaos.failRate, 100*chaos.slowRate)
+		unary = append(unary, chaos.unaryInterceptor())
+	}
 
 	listener, err := net.Listen("tcp", *addr)
 	if err != nil {
 		log.Fatalf("listen %s: %v", *addr, err)
 	}
 
-	server := grpc.NewServer()
-	pb.RegisterGreeterServer(server, &greeterServer{})
+	opts := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(unary...),
+		grpc.ChainStreamInterceptor(stream...),
+	}
+	if tlsCfg.ServerEnabled() {
+		tc, err := tlsutil.Server(context.Background(), tlsCfg)
+		if err != nil {
+			log.Fatalf("tls: %v", err)
+		}
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tc)))
+	}
+	server := grpc.NewServer(opts...)
+	pb.RegisterGreeterServer(server, &greeterServer{streamCount: *streamCount, streamInterval: *streamInterval, putMaxBytes: *putMaxBytes})
+	// The net/rpc HelloService, served through the adapter rpcproto generated
+	if err := serverStub.RegisterHelloServiceGRPC(server, &handler.HelloService{}); err != nil {
+		log.Fatalf("HelloService: %v", err)
+	}
+	monitor := health.Start(context.Background(), healthCfg, pb.Greeter_ServiceDesc.ServiceName, serverStub.HelloServiceGRPCName)
+	healthpb.RegisterHealthServer(server, monitor.GRPC())
+	reflection.Register(server)
 
-	log.Printf("gRPC server listening on %s", *addr)
-	log.Fatal(server.Serve(listener))
+	// Stop accepting, report NOT_SERVING, then give in-flight calls until
+	// the deadline before cancelling them
+	ctx, stop := shutdown.OnSignal()
+	defer stop()
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		<-ctx.Done()
+		log.Printf("shutting down, waiting up to %s for in-flight calls", *shutdownTimeout)
+		monitor.Shutdown()
+		drainCtx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+		defer cancel()
+		if err := shutdown.Graceful(drainCtx, server.GracefulStop, server.Stop); err != nil {
+			log.Printf("cancelled calls still running at the deadline: %v", err)
+		}
+	}()
+
+	log.Printf("gRPC server listening on %s (tls=%t, mtls=%t)", *addr, tlsCfg.ServerEnabled(), tlsCfg.CAFile != "")
+	if err := server.Serve(listener); err != nil {
+		log.Fatal(err)
+	}
+	<-stopped
 }
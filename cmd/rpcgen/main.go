@@ -0,0 +1,81 @@
+// Command rpcgen generates transport code for net/rpc style service
+// interfaces. Mark an interface with a directive naming the service and
+// run it from go generate:
+//
+//	//go:generate go run awesomeproject/cmd/rpcgen
+//
+//	//rpcgen:service HelloService
+//	type HelloServicer interface {
+//		Hello(request string, reply *string) error
+//	}
+//
+// For each marked interface it writes, next to the source file, the
+// service and method name constants, registration functions for net/rpc,
+// which turn panics in methods into errors, and for hertzrpc, a typed
+// net/rpc client built on rpcclient and a typed hertzrpc client. Methods taking a leading context.Context are served
+// only by hertzrpc, so they are left out of the net/rpc client.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("rpcgen: ")
+	out := flag.String("out", "", "output file (default <source>_rpcgen.go)")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: rpcgen [-out file] [source.go]\n\nThe source defaults to $GOFILE when run by go generate.\n\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	src := os.Getenv("GOFILE")
+	if flag.NArg() > 0 {
+		src = flag.Arg(0)
+	}
+	if src == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *out == "" {
+		*out = strings.TrimSuffix(src, ".go") + "_rpcgen.go"
+	}
+
+	code, err := generate(src)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(*out, code, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// generate returns the formatted code for the interfaces marked in src.
+func generate(src string) ([]byte, error) {
+	f, err := parseFile(src)
+	if err != nil {
+		return nil, err
+	}
+	if len(f.Services) == 0 {
+		return nil, fmt.Errorf("%s: no interface is marked %s", src, directive)
+	}
+	f.Source = filepath.Base(src)
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, f); err != nil {
+		return nil, err
+	}
+	code, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("formatting generated code: %v\n%s", err, buf.Bytes())
+	}
+	return code, nil
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// directive marks an interface to generate code for. The name after it is
+// the service name used on the wire.
+const directive = "//rpcgen:service"
+
+type service struct {
+	Iface   string // Go interface name, e.g. HelloServicer
+	Name    string // wire name, e.g. HelloService
+	Methods []method
+}
+
+type method struct {
+	Name    string
+	Arg     string // parameter name in generated clients
+	ArgType string
+	// ReplyType is the type the reply pointer points at.
+	ReplyType string
+	// WithCtx marks Method(ctx, args, reply) methods, which only hertzrpc
+	// serves; net/rpc skips them.
+	WithCtx bool
+}
+
+// NetRPC reports whether net/rpc can serve m.
+func (m method) NetRPC() bool { return !m.WithCtx }
+
+type file struct {
+	Package  string
+	Source   string
+	Services []service
+	// StdImports and Imports list the standard library and other import
+	// specs of the generated file: its own plus those of the source file
+	// that the method signatures use.
+	StdImports, Imports []string
+}
+
+func parseFile(path string) (*file, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, path, nil, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	out := &file{
+		Package:    f.Name.Name,
+		StdImports: []string{`"context"`, `"net/rpc"`},
+		Imports:    []string{`"awesomeproject/pkg/hertzrpc"`, `"awesomeproject/pkg/rpcclient"`, `"awesomeproject/pkg/rpchook"`},
+	}
+	used := make(map[string]bool) // package names referenced by signatures
+	for _, decl := range f.Decls {
+		gd, ok := decl.(*ast.GenDecl)
+		if !ok || gd.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gd.Specs {
+			ts := spec.(*ast.TypeSpec)
+			it, ok := ts.Type.(*ast.InterfaceType)
+			if !ok {
+				continue
+			}
+			doc := ts.Doc
+			if doc == nil && len(gd.Specs) == 1 {
+				doc = gd.Doc
+			}
+			name, ok := serviceName(doc)
+			if !ok {
+				continue
+			}
+			if name == "" {
+				name = strings.TrimSuffix(ts.Name.Name, "er")
+			}
+			svc := service{Iface: ts.Name.Name, Name: name}
+			for _, field := range it.Methods.List {
+				ft, ok := field.Type.(*ast.FuncType)
+				if !ok {
+					return nil, fmt.Errorf("%s: %s embeds an interface; list the methods instead", fset.Position(field.Pos()), ts.Name.Name)
+				}
+				m, err := parseMethod(fset, field.Names[0].Name, ft, used)
+				if err != nil {
+					return nil, fmt.Errorf("%s: %s.%s: %w", fset.Position(field.Pos()), ts.Name.Name, field.Names[0].Name, err)
+				}
+				svc.Methods = append(svc.Methods, m)
+			}
+			if len(svc.Methods) == 0 {
+				return nil, fmt.Errorf("%s: %s has no methods", fset.Position(ts.Pos()), ts.Name.Name)
+			}
+			out.Services = append(out.Services, svc)
+		}
+	}
+	for _, imp := range f.Imports {
+		p, _ := strconv.Unquote(imp.Path.Value)
+		name := p[strings.LastIndex(p, "/")+1:]
+		if imp.Name != nil {
+			name = imp.Name.Name
+		}
+		if !used[name] {
+			continue
+		}
+		spec := importSpec(imp)
+		switch {
+		case slices.Contains(out.StdImports, spec) || slices.Contains(out.Imports, spec):
+		case isStd(p):
+			out.StdImports = append(out.StdImports, spec)
+		default:
+			out.Imports = append(out.Imports, spec)
+		}
+	}
+	sortImports(out.StdImports)
+	sortImports(out.Imports)
+	return out, nil
+}
+
+// serviceName finds the directive in doc. The name may be empty.
+func serviceName(doc *ast.CommentGroup) (string, bool) {
+	if doc == nil {
+		return "", false
+	}
+	for _, c := range doc.List {
+		if rest, ok := strings.CutPrefix(c.Text, directive); ok {
+			if rest != "" && rest[0] != ' ' {
+				continue // e.g. //rpcgen:services
+			}
+			return strings.TrimSpace(rest), true
+		}
+	}
+	return "", false
+}
+
+// parseMethod accepts the two signatures hertzrpc.Dispatcher registers:
+// M(args T, reply *R) error and M(ctx context.Context, args T, reply *R) error.
+func parseMethod(fset *token.FileSet, name string, ft *ast.FuncType, used map[string]bool) (method, error) {
+	m := method{Name: name}
+	type param struct {
+		name string
+		typ  ast.Expr
+	}
+	var params []param
+	for _, f := range ft.Params.List {
+		if len(f.Names) == 0 {
+			params = append(params, param{"", f.Type})
+		}
+		for _, n := range f.Names {
+			params = append(params, param{n.Name, f.Type})
+		}
+	}
+	if len(params) == 3 {
+		if expr(fset, params[0].typ) != "context.Context" {
+			return m, fmt.Errorf("first of three parameters must be a context.Context")
+		}
+		m.WithCtx = true
+		params = params[1:]
+	}
+	if len(params) != 2 {
+		return m, fmt.Errorf("want (args T, reply *R) or (ctx context.Context, args T, reply *R)")
+	}
+	star, ok := params[1].typ.(*ast.StarExpr)
+	if !ok {
+		return m, fmt.Errorf("reply must be a pointer")
+	}
+	if ft.Results == nil || len(ft.Results.List) != 1 || len(ft.Results.List[0].Names) > 1 || expr(fset, ft.Results.List[0].Type) != "error" {
+		return m, fmt.Errorf("must return exactly one error")
+	}
+	m.Arg = params[0].name
+	switch m.Arg {
+	case "", "_", "ctx", "reply", "err", "c":
+		m.Arg = "args"
+	}
+	m.ArgType = expr(fset, params[0].typ)
+	m.ReplyType = expr(fset, star.X)
+	for _, e := range []ast.Expr{params[0].typ, star.X} {
+		ast.Inspect(e, func(n ast.Node) bool {
+			if sel, ok := n.(*ast.SelectorExpr); ok {
+				if id, ok := sel.X.(*ast.Ident); ok {
+					used[id.Name] = true
+				}
+			}
+			return true
+		})
+	}
+	return m, nil
+}
+
+func expr(fset *token.FileSet, e ast.Expr) string {
+	var buf bytes.Buffer
+	_ = printer.Fprint(&buf, fset, e)
+	return buf.String()
+}
+
+func isStd(path string) bool {
+	pkg, err := build.Default.Import(path, "", build.FindOnly)
+	return err == nil && pkg.Goroot
+}
+
+// sortImports orders specs by path, as gofmt does.
+func sortImports(specs []string) {
+	path := func(spec string) string { return spec[strings.Index(spec, `"`):] }
+	slices.SortFunc(specs, func(a, b string) int { return strings.Compare(path(a), path(b)) })
+}
+
+func importSpec(imp *ast.ImportSpec) string {
+	if imp.Name != nil {
+		return imp.Name.Name + " " + imp.Path.Value
+	}
+	return imp.Path.Value
+}
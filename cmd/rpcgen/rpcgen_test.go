@@ -0,0 +1,111 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestGeneratedUpToDate regenerates the checked-in HelloService code.
+func TestGeneratedUpToDate(t *testing.T) {
+	got, err := generate(filepath.Join("..", "..", "serverStub", "serverStub.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := os.ReadFile(filepath.Join("..", "..", "serverStub", "serverStub_rpcgen.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(want) {
+		t.Fatal("serverStub_rpcgen.go is stale; run go generate ./serverStub")
+	}
+}
+
+func writeSource(t *testing.T, src string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "svc.go")
+	if err := os.WriteFile(path, []byte("package svc\n\n"+src), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestParseFile(t *testing.T) {
+	f, err := parseFile(writeSource(t, `import (
+	"context"
+	"net/http"
+	stdtime "time"
+
+	"example.com/types"
+	"example.com/unused"
+)
+
+var _ = http.MethodGet
+var _ unused.T
+
+// Clock tells the time.
+//
+//rpcgen:service
+type Clocker interface {
+	Now(_ struct{}, reply *stdtime.Time) error
+	Sleep(ctx context.Context, d stdtime.Duration, reply *types.Ack) error
+	Echo(reply string, out *[]byte) error
+}
+
+//rpcgen:services NotThis
+type Ignored interface {
+	M(a int, b *int) error
+}
+
+type Unmarked interface {
+	M(a int, b *int) error
+}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &file{
+		Package: "svc",
+		Services: []service{{
+			Iface: "Clocker",
+			Name:  "Clock",
+			Methods: []method{
+				{Name: "Now", Arg: "args", ArgType: "struct{}", ReplyType: "stdtime.Time"},
+				{Name: "Sleep", Arg: "d", ArgType: "stdtime.Duration", ReplyType: "types.Ack", WithCtx: true},
+				{Name: "Echo", Arg: "args", ArgType: "string", ReplyType: "[]byte"},
+			},
+		}},
+		StdImports: []string{`"context"`, `"net/rpc"`, `stdtime "time"`},
+		Imports:    []string{`"awesomeproject/pkg/hertzrpc"`, `"awesomeproject/pkg/rpcclient"`, `"awesomeproject/pkg/rpchook"`, `"example.com/types"`},
+	}
+	if !reflect.DeepEqual(f, want) {
+		t.Fatalf("parseFile =\n%+v\nwant\n%+v", f, want)
+	}
+}
+
+func TestParseFileErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"embedded", "//rpcgen:service S\ntype S interface{ error }", "embeds an interface"},
+		{"no methods", "//rpcgen:service S\ntype S interface{}", "has no methods"},
+		{"reply by value", "//rpcgen:service S\ntype S interface{ M(a int, b int) error }", "reply must be a pointer"},
+		{"one param", "//rpcgen:service S\ntype S interface{ M(b *int) error }", "want (args T, reply *R)"},
+		{"no context", "//rpcgen:service S\ntype S interface{ M(x, a int, b *int) error }", "must be a context.Context"},
+		{"two results", "//rpcgen:service S\ntype S interface{ M(a int, b *int) (int, error) }", "exactly one error"},
+		{"no error", "//rpcgen:service S\ntype S interface{ M(a int, b *int) }", "exactly one error"},
+		{"syntax", "type S interface{", "expected"},
+	}
+	for _, tt := range tests {
+		if _, err := parseFile(writeSource(t, tt.src)); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: err = %v, want %q", tt.name, err, tt.want)
+		}
+	}
+	if _, err := generate(writeSource(t, "type S interface{ M(a int, b *int) error }")); err == nil || !strings.Contains(err.Error(), "no interface is marked") {
+		t.Errorf("generate without a marked interface = %v", err)
+	}
+}
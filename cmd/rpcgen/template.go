@@ -0,0 +1,88 @@
+package main
+
+import "text/template"
+
+var tmpl = template.Must(template.New("rpcgen").Parse(`// Code generated by rpcgen from {{.Source}}; DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+{{- range .StdImports}}
+	{{.}}
+{{- end}}
+{{range .Imports}}
+	{{.}}
+{{- end}}
+)
+{{range $s := .Services}}
+// Service and method names of {{$s.Iface}}.
+const (
+	{{$s.Name}}Name = "{{$s.Name}}"
+{{- range .Methods}}
+	{{$s.Name}}_{{.Name}}_FullMethodName = "{{$s.Name}}.{{.Name}}"
+{{- end}}
+)
+
+// Register{{$s.Name}} registers service with rpc.DefaultServer as {{$s.Name}}Name.
+// A panic in one of its methods fails the call instead of the server.
+func Register{{$s.Name}}(service {{$s.Iface}}) error {
+	return rpc.RegisterName({{$s.Name}}Name, recover{{$s.Name}}{service})
+}
+
+// Register{{$s.Name}}On registers service with srv as {{$s.Name}}Name.
+// A panic in one of its methods fails the call instead of the server.
+func Register{{$s.Name}}On(srv *rpc.Server, service {{$s.Iface}}) error {
+	return srv.RegisterName({{$s.Name}}Name, recover{{$s.Name}}{service})
+}
+
+// recover{{$s.Name}} serves the net/rpc methods of a {{$s.Iface}}, turning
+// their panics into errors. net/rpc calls methods on goroutines of its
+// own, out of reach of an rpchook codec.
+type recover{{$s.Name}} struct {
+	service {{$s.Iface}}
+}
+{{range .Methods}}{{if .NetRPC}}
+func (c recover{{$s.Name}}) {{.Name}}({{.Arg}} {{.ArgType}}, reply *{{.ReplyType}}) (err error) {
+	defer rpchook.Recover(&err)
+	return c.service.{{.Name}}({{.Arg}}, reply)
+}
+{{end}}{{end}}
+// Register{{$s.Name}}Hertz registers service with a hertzrpc Dispatcher as {{$s.Name}}Name.
+func Register{{$s.Name}}Hertz(d *hertzrpc.Dispatcher, service {{$s.Iface}}) error {
+	return d.RegisterName({{$s.Name}}Name, service)
+}
+
+// {{$s.Name}}Client is a typed net/rpc client for {{$s.Name}}.
+type {{$s.Name}}Client struct {
+	*rpcclient.Client
+}
+
+// New{{$s.Name}}Client returns a client that dials on its first call.
+func New{{$s.Name}}Client(dial rpcclient.Dialer) *{{$s.Name}}Client {
+	return &{{$s.Name}}Client{rpcclient.New(dial)}
+}
+{{range .Methods}}{{if .NetRPC}}
+// {{.Name}} calls {{$s.Name}}.{{.Name}}.
+func (c *{{$s.Name}}Client) {{.Name}}(ctx context.Context, {{.Arg}} {{.ArgType}}) ({{.ReplyType}}, error) {
+	var reply {{.ReplyType}}
+	err := c.Call(ctx, {{$s.Name}}_{{.Name}}_FullMethodName, {{.Arg}}, &reply)
+	return reply, err
+}
+{{end}}{{end}}
+// {{$s.Name}}HertzClient is a typed hertzrpc client for {{$s.Name}}.
+type {{$s.Name}}HertzClient struct {
+	*hertzrpc.Client
+}
+
+// New{{$s.Name}}HertzClient returns a client for the Dispatcher at url.
+func New{{$s.Name}}HertzClient(url string) *{{$s.Name}}HertzClient {
+	return &{{$s.Name}}HertzClient{hertzrpc.NewClient(url)}
+}
+{{range .Methods}}
+// {{.Name}} calls {{$s.Name}}.{{.Name}}.
+func (c *{{$s.Name}}HertzClient) {{.Name}}(ctx context.Context, {{.Arg}} {{.ArgType}}) ({{.ReplyType}}, error) {
+	var reply {{.ReplyType}}
+	err := c.Call(ctx, {{$s.Name}}_{{.Name}}_FullMethodName, {{.Arg}}, &reply)
+	return reply, err
+}
+{{end}}{{end}}`))
@@ -0,0 +1,345 @@
+package main
+
+import (
+	"fmt"
+	"go/token"
+	"go/types"
+	"log"
+	"regexp"
+	"strings"
+
+	"awesomeproject/pkg/rpcproto"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+var identRE = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// builder turns the methods of a Go type into a FileDescriptorProto.
+type builder struct {
+	file *descriptorpb.FileDescriptorProto
+	// messages maps each message name to the Go type it was made from,
+	// so that distinct types with the same name are reported.
+	messages map[string]types.Type
+}
+
+func newBuilder(path, pkg string) *builder {
+	f := &descriptorpb.FileDescriptorProto{
+		Name:   proto.String(path),
+		Syntax: proto.String("proto3"),
+	}
+	if pkg != "" {
+		f.Package = proto.String(pkg)
+	}
+	return &builder{file: f, messages: make(map[string]types.Type)}
+}
+
+// methods lists the methods of the named type T the way
+// hertzrpc.Dispatcher.RegisterName would see them for a *T receiver, or
+// for an interface T.
+func methods(named *types.Named) []*types.Func {
+	var fns []*types.Func
+	if iface, ok := named.Underlying().(*types.Interface); ok {
+		for i := 0; i < iface.NumMethods(); i++ {
+			fns = append(fns, iface.Method(i))
+		}
+		return fns
+	}
+	ms := types.NewMethodSet(types.NewPointer(named))
+	for i := 0; i < ms.Len(); i++ {
+		fns = append(fns, ms.At(i).Obj().(*types.Func))
+	}
+	return fns
+}
+
+// addService adds the service and the messages of every suitable method.
+// Methods RegisterName would skip are reported and left out.
+func (b *builder) addService(name string, named *types.Named) error {
+	svc := &descriptorpb.ServiceDescriptorProto{Name: proto.String(name)}
+	for _, fn := range methods(named) {
+		arg, reply, reason := suitable(fn)
+		if reason != "" {
+			log.Printf("skipping %s.%s: %s", named.Obj().Name(), fn.Name(), reason)
+			continue
+		}
+		in, err := b.topMessage(arg, fn.Name()+"Args")
+		if err != nil {
+			return fmt.Errorf("%s args: %w", fn.Name(), err)
+		}
+		out, err := b.topMessage(reply, fn.Name()+"Reply")
+		if err != nil {
+			return fmt.Errorf("%s reply: %w", fn.Name(), err)
+		}
+		svc.Method = append(svc.Method, &descriptorpb.MethodDescriptorProto{
+			Name:       proto.String(fn.Name()),
+			InputType:  proto.String(b.ref(in)),
+			OutputType: proto.String(b.ref(out)),
+		})
+	}
+	if len(svc.Method) == 0 {
+		return fmt.Errorf("%s has no exported methods of suitable type", named.Obj().Name())
+	}
+	b.file.Service = append(b.file.Service, svc)
+	return nil
+}
+
+// suitable applies RegisterName's rules to fn and returns the argument
+// type and the type the reply points at, or why fn is not suitable.
+func suitable(fn *types.Func) (arg, reply types.Type, reason string) {
+	if !fn.Exported() {
+		return nil, nil, "not exported"
+	}
+	sig := fn.Type().(*types.Signature)
+	params := sig.Params()
+	first := 0
+	if params.Len() == 3 && isContext(params.At(0).Type()) {
+		first = 1
+	}
+	if params.Len() != first+2 {
+		return nil, nil, "want (args T, reply *R) with an optional leading context.Context"
+	}
+	arg = params.At(first).Type()
+	if !isExportedOrBuiltin(arg) {
+		return nil, nil, "argument type is not exported"
+	}
+	ptr, ok := params.At(first + 1).Type().(*types.Pointer)
+	if !ok {
+		return nil, nil, "reply is not a pointer"
+	}
+	if !isExportedOrBuiltin(ptr) {
+		return nil, nil, "reply type is not exported"
+	}
+	if sig.Results().Len() != 1 || sig.Results().At(0).Type().String() != "error" {
+		return nil, nil, "must return exactly one error"
+	}
+	return arg, ptr.Elem(), ""
+}
+
+func isContext(t types.Type) bool {
+	return t.String() == "context.Context"
+}
+
+func isExportedOrBuiltin(t types.Type) bool {
+	for {
+		p, ok := t.(*types.Pointer)
+		if !ok {
+			break
+		}
+		t = p.Elem()
+	}
+	if n, ok := t.(*types.Named); ok {
+		return n.Obj().Pkg() == nil || token.IsExported(n.Obj().Name())
+	}
+	return true
+}
+
+// ref returns the fully qualified reference to a top-level message.
+func (b *builder) ref(name string) string {
+	if pkg := b.file.GetPackage(); pkg != "" {
+		return "." + pkg + "." + name
+	}
+	return "." + name
+}
+
+// topMessage returns the message an argument or reply travels in: the
+// struct's own message, or a wrapper named wrapper for other types.
+func (b *builder) topMessage(t types.Type, wrapper string) (string, error) {
+	if p, ok := t.(*types.Pointer); ok {
+		t = p.Elem()
+	}
+	if _, ok := t.Underlying().(*types.Struct); ok {
+		return b.structMessage(t, wrapper)
+	}
+	msg := &descriptorpb.DescriptorProto{Name: proto.String(wrapper)}
+	if err := b.claim(wrapper, t); err != nil {
+		return "", err
+	}
+	b.file.MessageType = append(b.file.MessageType, msg)
+	if err := b.addField(msg, rpcproto.WrapperField, 1, t); err != nil {
+		return "", err
+	}
+	return wrapper, nil
+}
+
+func (b *builder) claim(name string, t types.Type) error {
+	if prev, ok := b.messages[name]; ok && !types.Identical(prev, t) {
+		return fmt.Errorf("message %s would stand for both %s and %s", name, prev, t)
+	}
+	b.messages[name] = t
+	return nil
+}
+
+// structMessage returns the message for a struct type, adding it on first
+// use. Named structs keep their name; anonymous ones take fallback.
+func (b *builder) structMessage(t types.Type, fallback string) (string, error) {
+	name := fallback
+	if n, ok := t.(*types.Named); ok {
+		name = n.Obj().Name()
+	}
+	if prev, ok := b.messages[name]; ok && types.Identical(prev, t) {
+		return name, nil
+	}
+	if err := b.claim(name, t); err != nil {
+		return "", err
+	}
+	msg := &descriptorpb.DescriptorProto{Name: proto.String(name)}
+	b.file.MessageType = append(b.file.MessageType, msg)
+	st := t.Underlying().(*types.Struct)
+	number := int32(0)
+	for i := 0; i < st.NumFields(); i++ {
+		f := st.Field(i)
+		if !f.Exported() {
+			continue
+		}
+		if f.Embedded() {
+			return "", fmt.Errorf("%s: embedded field %s is not supported", name, f.Name())
+		}
+		fname := rpcproto.FieldName(f.Name(), st.Tag(i))
+		if fname == "" {
+			continue
+		}
+		number++
+		if err := b.addField(msg, fname, number, f.Type()); err != nil {
+			return "", fmt.Errorf("%s.%s: %w", name, f.Name(), err)
+		}
+	}
+	return name, nil
+}
+
+func (b *builder) addField(msg *descriptorpb.DescriptorProto, name string, number int32, t types.Type) error {
+	if !identRE.MatchString(name) {
+		return fmt.Errorf("field name %q is not a proto identifier", name)
+	}
+	field := &descriptorpb.FieldDescriptorProto{
+		Name:     proto.String(name),
+		Number:   proto.Int32(number),
+		Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		JsonName: proto.String(name),
+	}
+	msg.Field = append(msg.Field, field)
+
+	switch u := t.Underlying().(type) {
+	case *types.Slice:
+		if isByte(u.Elem()) {
+			return b.setType(field, t, msg.GetName()+camel(name))
+		}
+		field.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+		return b.setElemType(field, u.Elem(), msg.GetName()+camel(name))
+	case *types.Array:
+		field.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+		return b.setElemType(field, u.Elem(), msg.GetName()+camel(name))
+	case *types.Map:
+		entry := &descriptorpb.DescriptorProto{
+			Name:    proto.String(camel(name) + "Entry"),
+			Options: &descriptorpb.MessageOptions{MapEntry: proto.Bool(true)},
+		}
+		key := &descriptorpb.FieldDescriptorProto{Name: proto.String("key"), Number: proto.Int32(1), JsonName: proto.String("key"), Label: descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum()}
+		val := &descriptorpb.FieldDescriptorProto{Name: proto.String("value"), Number: proto.Int32(2), JsonName: proto.String("value"), Label: descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum()}
+		if err := b.setType(key, u.Key(), ""); err != nil {
+			return err
+		}
+		if key.GetType() == descriptorpb.FieldDescriptorProto_TYPE_MESSAGE || key.GetType() == descriptorpb.FieldDescriptorProto_TYPE_BYTES ||
+			key.GetType() == descriptorpb.FieldDescriptorProto_TYPE_FLOAT || key.GetType() == descriptorpb.FieldDescriptorProto_TYPE_DOUBLE {
+			return fmt.Errorf("map key type %s is not supported", u.Key())
+		}
+		if err := b.setElemType(val, u.Elem(), msg.GetName()+camel(name)); err != nil {
+			return err
+		}
+		entry.Field = []*descriptorpb.FieldDescriptorProto{key, val}
+		msg.NestedType = append(msg.NestedType, entry)
+		field.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+		field.Type = descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum()
+		field.TypeName = proto.String(b.ref(msg.GetName()) + "." + entry.GetName())
+		return nil
+	}
+	return b.setType(field, t, msg.GetName()+camel(name))
+}
+
+// setElemType sets the type of a repeated field or map value, which may
+// not itself be repeated.
+func (b *builder) setElemType(field *descriptorpb.FieldDescriptorProto, t types.Type, anon string) error {
+	switch u := t.Underlying().(type) {
+	case *types.Slice:
+		if !isByte(u.Elem()) {
+			return fmt.Errorf("nested repeated type %s is not supported", t)
+		}
+	case *types.Array, *types.Map:
+		return fmt.Errorf("nested repeated type %s is not supported", t)
+	}
+	return b.setType(field, t, anon)
+}
+
+// setType sets the scalar or message type of field. anon names the
+// message made for an anonymous struct.
+func (b *builder) setType(field *descriptorpb.FieldDescriptorProto, t types.Type, anon string) error {
+	if p, ok := t.(*types.Pointer); ok {
+		if _, ok := p.Elem().Underlying().(*types.Struct); !ok {
+			return fmt.Errorf("pointer to %s is not supported", p.Elem())
+		}
+		t = p.Elem()
+	}
+	switch u := t.Underlying().(type) {
+	case *types.Struct:
+		name, err := b.structMessage(t, anon)
+		if err != nil {
+			return err
+		}
+		field.Type = descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum()
+		field.TypeName = proto.String(b.ref(name))
+		return nil
+	case *types.Slice:
+		if isByte(u.Elem()) {
+			field.Type = descriptorpb.FieldDescriptorProto_TYPE_BYTES.Enum()
+			return nil
+		}
+	case *types.Basic:
+		if typ, ok := scalars[u.Kind()]; ok {
+			field.Type = typ.Enum()
+			return nil
+		}
+	}
+	return fmt.Errorf("type %s is not supported", t)
+}
+
+var scalars = map[types.BasicKind]descriptorpb.FieldDescriptorProto_Type{
+	types.Bool:    descriptorpb.FieldDescriptorProto_TYPE_BOOL,
+	types.Int:     descriptorpb.FieldDescriptorProto_TYPE_INT64,
+	types.Int8:    descriptorpb.FieldDescriptorProto_TYPE_INT32,
+	types.Int16:   descriptorpb.FieldDescriptorProto_TYPE_INT32,
+	types.Int32:   descriptorpb.FieldDescriptorProto_TYPE_INT32,
+	types.Int64:   descriptorpb.FieldDescriptorProto_TYPE_INT64,
+	types.Uint:    descriptorpb.FieldDescriptorProto_TYPE_UINT64,
+	types.Uint8:   descriptorpb.FieldDescriptorProto_TYPE_UINT32,
+	types.Uint16:  descriptorpb.FieldDescriptorProto_TYPE_UINT32,
+	types.Uint32:  descriptorpb.FieldDescriptorProto_TYPE_UINT32,
+	types.Uint64:  descriptorpb.FieldDescriptorProto_TYPE_UINT64,
+	types.Float32: descriptorpb.FieldDescriptorProto_TYPE_FLOAT,
+	types.Float64: descriptorpb.FieldDescriptorProto_TYPE_DOUBLE,
+	types.String:  descriptorpb.FieldDescriptorProto_TYPE_STRING,
+}
+
+func isByte(t types.Type) bool {
+	b, ok := t.Underlying().(*types.Basic)
+	return ok && b.Kind() == types.Uint8
+}
+
+// camel turns a field name into the CamelCase protoc uses for map entry
+// messages: "user_labels" becomes "UserLabels".
+func camel(s string) string {
+	var b strings.Builder
+	up := true
+	for _, r := range s {
+		if r == '_' {
+			up = true
+			continue
+		}
+		if up {
+			b.WriteString(strings.ToUpper(string(r)))
+			up = false
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"go/importer"
+	"go/token"
+	"go/types"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// load returns the type-checked package matching pattern, read from the
+// export data the go command builds, so the source need not be parsed.
+func load(pattern string) (*types.Package, error) {
+	target, err := goList(pattern, "{{.ImportPath}}")
+	if err != nil {
+		return nil, err
+	}
+	if len(target) != 1 {
+		return nil, fmt.Errorf("%s matches %d packages; want one", pattern, len(target))
+	}
+	lines, err := goList(pattern, "{{.ImportPath}}\t{{.Export}}", "-export", "-deps")
+	if err != nil {
+		return nil, err
+	}
+	exports := make(map[string]string, len(lines))
+	for _, l := range lines {
+		if path, file, ok := strings.Cut(l, "\t"); ok && file != "" {
+			exports[path] = file
+		}
+	}
+	imp := importer.ForCompiler(token.NewFileSet(), "gc", func(path string) (io.ReadCloser, error) {
+		file, ok := exports[path]
+		if !ok {
+			return nil, fmt.Errorf("no export data for %s", path)
+		}
+		return os.Open(file)
+	})
+	return imp.Import(target[0])
+}
+
+func goList(pattern, format string, flags ...string) ([]string, error) {
+	args := append([]string{"list", "-f", format}, flags...)
+	cmd := exec.Command("go", append(args, pattern)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("go list %s: %v\n%s", pattern, err, stderr.Bytes())
+	}
+	var lines []string
+	sc := bufio.NewScanner(bytes.NewReader(out))
+	for sc.Scan() {
+		if l := strings.TrimSpace(sc.Text()); l != "" {
+			lines = append(lines, l)
+		}
+	}
+	return lines, nil
+}
@@ -0,0 +1,140 @@
+// Command rpcproto exposes a net/rpc style Go service over gRPC without
+// rewriting it. It inspects the methods of a type with the rules
+// hertzrpc.Dispatcher.RegisterName uses, writes a .proto file with a
+// message per argument and reply, wrapping non-struct types, and writes
+// a Go adapter whose Register<Service>GRPC function serves the type
+// through pkg/rpcproto:
+//
+//	//go:generate go run awesomeproject/cmd/rpcproto -type HelloServicer -service HelloService -package helloservice -proto ../proto/helloservice.proto
+//
+// The type may be an interface or a concrete type, whose pointer method
+// set is used. The package must compile, since its types are read from
+// the export data the go command builds.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"go/types"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("rpcproto: ")
+	typeName := flag.String("type", "", "Go type whose methods form the service (required)")
+	service := flag.String("service", "", "gRPC service name (default the type name)")
+	pkg := flag.String("package", "", "proto package")
+	goPackage := flag.String("go_package", "", "go_package option written to the .proto")
+	protoOut := flag.String("proto", "", "output .proto file (default <service>.proto, lower case)")
+	goOut := flag.String("out", "", "output Go adapter (default <service>_grpc.go, lower case, in the package directory)")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: rpcproto -type T [flags] [package]\n\nThe package defaults to the current directory.\n\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if *typeName == "" || flag.NArg() > 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	pattern := "."
+	if flag.NArg() == 1 {
+		pattern = flag.Arg(0)
+	}
+	if *service == "" {
+		*service = *typeName
+	}
+	base := strings.ToLower(*service)
+	if *protoOut == "" {
+		*protoOut = base + ".proto"
+	}
+	if *goOut == "" {
+		dir, err := goList(pattern, "{{.Dir}}")
+		if err != nil {
+			log.Fatal(err)
+		}
+		*goOut = filepath.Join(dir[0], base+"_grpc.go")
+	}
+
+	p, err := load(pattern)
+	if err != nil {
+		log.Fatal(err)
+	}
+	obj, ok := p.Scope().Lookup(*typeName).(*types.TypeName)
+	if !ok {
+		log.Fatalf("%s has no type %s", p.Path(), *typeName)
+	}
+	named, ok := obj.Type().(*types.Named)
+	if !ok {
+		log.Fatalf("%s is not a named type", *typeName)
+	}
+
+	b := newBuilder(filepath.Base(*protoOut), *pkg)
+	if *goPackage != "" {
+		b.file.Options = &descriptorpb.FileOptions{GoPackage: proto.String(*goPackage)}
+	}
+	if err := b.addService(*service, named); err != nil {
+		log.Fatal(err)
+	}
+	raw, err := proto.MarshalOptions{Deterministic: true}.Marshal(b.file)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var src bytes.Buffer
+	printProto(&src, b.file, p.Path()+"."+*typeName)
+	if err := os.WriteFile(*protoOut, src.Bytes(), 0o644); err != nil {
+		log.Fatal(err)
+	}
+
+	rcvr := *typeName
+	if !types.IsInterface(named) {
+		rcvr = "*" + rcvr
+	}
+	code, err := adapter(p.Name(), p.Path()+"."+*typeName, *service, rcvr, b.file, raw)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(*goOut, code, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// adapter returns the Go source of the Register<Service>GRPC function.
+func adapter(pkgName, source, service, rcvr string, f *descriptorpb.FileDescriptorProto, raw []byte) ([]byte, error) {
+	full := service
+	if f.GetPackage() != "" {
+		full = f.GetPackage() + "." + service
+	}
+	varName := "file_" + strings.NewReplacer(".", "_", "-", "_", "/", "_").Replace(f.GetName()) + "_rawDesc"
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by rpcproto from %s; DO NOT EDIT.\n\n", source)
+	fmt.Fprintf(&b, "package %s\n\n", pkgName)
+	fmt.Fprintf(&b, "import (\n\t\"awesomeproject/pkg/rpcproto\"\n\n\t\"google.golang.org/grpc\"\n)\n\n")
+	fmt.Fprintf(&b, "// %sGRPCName is the full gRPC service name defined in %s.\n", service, f.GetName())
+	fmt.Fprintf(&b, "const %sGRPCName = %q\n\n", service, full)
+	fmt.Fprintf(&b, "// Register%sGRPC exposes service over gRPC as %s describes it.\n", service, f.GetName())
+	fmt.Fprintf(&b, "func Register%sGRPC(s grpc.ServiceRegistrar, service %s) error {\n", service, rcvr)
+	fmt.Fprintf(&b, "\treturn rpcproto.Register(s, %s, %q, service)\n}\n\n", varName, service)
+	fmt.Fprintf(&b, "// %s is the serialized FileDescriptorProto of %s.\n", varName, f.GetName())
+	fmt.Fprintf(&b, "var %s = []byte{", varName)
+	for i, c := range raw {
+		if i%16 == 0 {
+			b.WriteString("\n\t")
+		} else {
+			b.WriteByte(' ')
+		}
+		fmt.Fprintf(&b, "0x%02x,", c)
+	}
+	b.WriteString("\n}\n")
+	return format.Source(b.Bytes())
+}
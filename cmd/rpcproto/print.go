@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// printProto writes f as .proto source.
+func printProto(w io.Writer, f *descriptorpb.FileDescriptorProto, source string) {
+	fmt.Fprintf(w, "// Code generated by rpcproto from %s; DO NOT EDIT.\n\n", source)
+	fmt.Fprintf(w, "syntax = \"proto3\";\n")
+	if f.GetPackage() != "" {
+		fmt.Fprintf(w, "\npackage %s;\n", f.GetPackage())
+	}
+	if gp := f.GetOptions().GetGoPackage(); gp != "" {
+		fmt.Fprintf(w, "\noption go_package = %q;\n", gp)
+	}
+	for _, svc := range f.Service {
+		fmt.Fprintf(w, "\nservice %s {\n", svc.GetName())
+		for _, m := range svc.Method {
+			fmt.Fprintf(w, "  rpc %s (%s) returns (%s);\n", m.GetName(), localName(f, m.GetInputType()), localName(f, m.GetOutputType()))
+		}
+		fmt.Fprintf(w, "}\n")
+	}
+	for _, msg := range f.MessageType {
+		fmt.Fprintf(w, "\nmessage %s {\n", msg.GetName())
+		for _, field := range msg.Field {
+			fmt.Fprintf(w, "  %s %s = %d;\n", fieldType(f, msg, field), field.GetName(), field.GetNumber())
+		}
+		fmt.Fprintf(w, "}\n")
+	}
+}
+
+// localName strips the leading dot and package from a type reference.
+func localName(f *descriptorpb.FileDescriptorProto, ref string) string {
+	ref = strings.TrimPrefix(ref, ".")
+	if pkg := f.GetPackage(); pkg != "" {
+		ref = strings.TrimPrefix(ref, pkg+".")
+	}
+	return ref
+}
+
+func fieldType(f *descriptorpb.FileDescriptorProto, msg *descriptorpb.DescriptorProto, field *descriptorpb.FieldDescriptorProto) string {
+	if field.GetType() == descriptorpb.FieldDescriptorProto_TYPE_MESSAGE {
+		for _, nested := range msg.NestedType {
+			if nested.GetOptions().GetMapEntry() && strings.HasSuffix(field.GetTypeName(), "."+nested.GetName()) {
+				return fmt.Sprintf("map<%s, %s>", scalarType(f, nested.Field[0]), scalarType(f, nested.Field[1]))
+			}
+		}
+	}
+	t := scalarType(f, field)
+	if field.GetLabel() == descriptorpb.FieldDescriptorProto_LABEL_REPEATED {
+		return "repeated " + t
+	}
+	return t
+}
+
+func scalarType(f *descriptorpb.FileDescriptorProto, field *descriptorpb.FieldDescriptorProto) string {
+	if field.GetType() == descriptorpb.FieldDescriptorProto_TYPE_MESSAGE {
+		return localName(f, field.GetTypeName())
+	}
+	// TYPE_INT64 -> int64
+	return strings.ToLower(strings.TrimPrefix(field.GetType().String(), "TYPE_"))
+}
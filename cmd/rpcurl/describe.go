@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// describe renders d in proto source syntax.
+func describe(d protoreflect.Descriptor) string {
+	var b strings.Builder
+	switch d := d.(type) {
+	case protoreflect.ServiceDescriptor:
+		fmt.Fprintf(&b, "service %s {\n", d.FullName())
+		for i := 0; i < d.Methods().Len(); i++ {
+			fmt.Fprintf(&b, "  %s\n", rpcLine(d.Methods().Get(i)))
+		}
+		b.WriteString("}\n")
+	case protoreflect.MethodDescriptor:
+		fmt.Fprintf(&b, "%s\n\n", rpcLine(d))
+		b.WriteString(describe(d.Input()))
+		if d.Output().FullName() != d.Input().FullName() {
+			b.WriteString("\n" + describe(d.Output()))
+		}
+	case protoreflect.MessageDescriptor:
+		fmt.Fprintf(&b, "message %s {\n", d.FullName())
+		for i := 0; i < d.Fields().Len(); i++ {
+			f := d.Fields().Get(i)
+			fmt.Fprintf(&b, "  %s %s = %d;\n", fieldType(f), f.Name(), f.Number())
+		}
+		b.WriteString("}\n")
+	case protoreflect.EnumDescriptor:
+		fmt.Fprintf(&b, "enum %s {\n", d.FullName())
+		for i := 0; i < d.Values().Len(); i++ {
+			v := d.Values().Get(i)
+			fmt.Fprintf(&b, "  %s = %d;\n", v.Name(), v.Number())
+		}
+		b.WriteString("}\n")
+	default:
+		fmt.Fprintf(&b, "%s\n", d.FullName())
+	}
+	return b.String()
+}
+
+func rpcLine(m protoreflect.MethodDescriptor) string {
+	in, out := string(m.Input().FullName()), string(m.Output().FullName())
+	if m.IsStreamingClient() {
+		in = "stream " + in
+	}
+	if m.IsStreamingServer() {
+		out = "stream " + out
+	}
+	return fmt.Sprintf("rpc %s(%s) returns (%s);", m.Name(), in, out)
+}
+
+func fieldType(f protoreflect.FieldDescriptor) string {
+	if f.IsMap() {
+		return fmt.Sprintf("map<%s, %s>", kindName(f.MapKey()), kindName(f.MapValue()))
+	}
+	name := kindName(f)
+	switch {
+	case f.IsList():
+		return "repeated " + name
+	case f.HasOptionalKeyword():
+		return "optional " + name
+	}
+	return name
+}
+
+func kindName(f protoreflect.FieldDescriptor) string {
+	switch f.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return string(f.Message().FullName())
+	case protoreflect.EnumKind:
+		return string(f.Enum().FullName())
+	}
+	return f.Kind().String()
+}
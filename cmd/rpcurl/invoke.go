@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// findMethod resolves "Service/Method" or "Service.Method".
+func findMethod(ctx context.Context, src source, name string) (protoreflect.MethodDescriptor, error) {
+	name = strings.TrimPrefix(name, "/")
+	i := strings.LastIndexAny(name, "/.")
+	if i < 0 {
+		return nil, fmt.Errorf("want Service/Method, got %q", name)
+	}
+	d, err := src.FindSymbol(ctx, name[:i])
+	if err != nil {
+		return nil, err
+	}
+	svc, ok := d.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a service", name[:i])
+	}
+	m := svc.Methods().ByName(protoreflect.Name(name[i+1:]))
+	if m == nil {
+		return nil, fmt.Errorf("service %s has no method %s", svc.FullName(), name[i+1:])
+	}
+	return m, nil
+}
+
+var jsonOut = protojson.MarshalOptions{EmitUnpopulated: true}
+
+// invoke calls m with the JSON messages read from in, one per value, and
+// writes each reply to out as JSON. Unary and server-streaming methods
+// take exactly one message; an empty input sends the empty message.
+func invoke(ctx context.Context, conn *grpc.ClientConn, m protoreflect.MethodDescriptor, in io.Reader, out io.Writer, verbose bool) error {
+	path := fmt.Sprintf("/%s/%s", m.Parent().FullName(), m.Name())
+	desc := &grpc.StreamDesc{
+		StreamName:    string(m.Name()),
+		ServerStreams: m.IsStreamingServer(),
+		ClientStreams: m.IsStreamingClient(),
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var header, trailer metadata.MD
+	stream, err := conn.NewStream(ctx, desc, path, grpc.Header(&header), grpc.Trailer(&trailer))
+	if err != nil {
+		return err
+	}
+
+	sendErr := make(chan error, 1)
+	go func() {
+		err := sendAll(stream, m, in)
+		if err != nil {
+			cancel() // unblock RecvMsg; the send error is the one to report
+		}
+		sendErr <- err
+	}()
+
+	for {
+		reply := dynamicpb.NewMessage(m.Output())
+		err := stream.RecvMsg(reply)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			select {
+			case e := <-sendErr:
+				if e != nil {
+					return e
+				}
+			default:
+			}
+			return err
+		}
+		data, err := jsonOut.Marshal(reply)
+		if err != nil {
+			return err
+		}
+		// protojson varies its whitespace on purpose; re-indent for
+		// stable output
+		var buf bytes.Buffer
+		if err := json.Indent(&buf, data, "", "  "); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "%s\n", buf.Bytes())
+	}
+	if verbose {
+		fmt.Fprintf(os.Stderr, "header: %v\ntrailer: %v\n", header, trailer)
+	}
+	return <-sendErr
+}
+
+func sendAll(stream grpc.ClientStream, m protoreflect.MethodDescriptor, in io.Reader) error {
+	dec := json.NewDecoder(in)
+	sent := 0
+	for {
+		var raw json.RawMessage
+		err := dec.Decode(&raw)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("read request: %w", err)
+		}
+		if sent > 0 && !m.IsStreamingClient() {
+			return fmt.Errorf("%s takes a single request message", m.FullName())
+		}
+		req := dynamicpb.NewMessage(m.Input())
+		if err := protojson.Unmarshal(raw, req); err != nil {
+			return fmt.Errorf("request %d: %w", sent+1, err)
+		}
+		if err := stream.SendMsg(req); err != nil {
+			if errors.Is(err, io.EOF) {
+				break // the server ended the call; RecvMsg reports why
+			}
+			return err
+		}
+		sent++
+	}
+	if sent == 0 && !m.IsStreamingClient() {
+		if err := stream.SendMsg(dynamicpb.NewMessage(m.Input())); err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+	}
+	return stream.CloseSend()
+}
@@ -0,0 +1,175 @@
+// Command rpcurl lists, describes and calls gRPC methods without generated
+// stubs, in the style of grpcurl. Descriptors come from the server's
+// reflection service, or from a FileDescriptorSet given with -protoset.
+//
+//	rpcurl list
+//	rpcurl list Greeter
+//	rpcurl describe Greeter.SayHello
+//	rpcurl -d '{"name":"bob"}' invoke Greeter/SayHello
+//	printf '{"data":"a"}{"data":"b"}' | rpcurl -d @ invoke Greeter/PutStream
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"awesomeproject/pkg/rpccreds"
+	"awesomeproject/pkg/rpcerr"
+	"awesomeproject/pkg/tlsutil"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+func main() {
+	addr := flag.String("addr", "127.0.0.1:50051", "server address")
+	protoset := flag.String("protoset", "", "FileDescriptorSet to use instead of server reflection")
+	data := flag.String("d", "", "JSON request body; @ reads a stream of JSON values from stdin")
+	timeout := flag.Duration("timeout", 10*time.Second, "deadline for the whole command")
+	verbose := flag.Bool("v", false, "print response headers and trailers")
+	var headers []string
+	flag.Func("H", `request metadata "key: value" (repeatable)`, func(s string) error {
+		k, v, ok := strings.Cut(s, ":")
+		if !ok {
+			return fmt.Errorf("want key: value")
+		}
+		headers = append(headers, strings.ToLower(strings.TrimSpace(k)), strings.TrimSpace(v))
+		return nil
+	})
+	var credsCfg rpccreds.Config
+	credsCfg.RegisterFlags(flag.CommandLine)
+	var tlsCfg tlsutil.Config
+	tlsCfg.RegisterClientFlags(flag.CommandLine)
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: rpcurl [flags] list [service] | describe <symbol> | invoke <service/method>\n\nflags:\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	ctx = metadata.AppendToOutgoingContext(ctx, headers...)
+
+	conn, err := dial(*addr, tlsCfg, credsCfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer conn.Close()
+
+	var src source
+	if *protoset != "" {
+		if src, err = newFileSource(*protoset); err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		src = newReflectionSource(conn)
+	}
+
+	if err := run(ctx, conn, src, flag.Args(), *data, *verbose); err != nil {
+		if st, ok := status.FromError(err); ok {
+			log.Printf("ERROR: code = %s message = %s", st.Code(), st.Message())
+			if details, ok := rpcerr.Data(st)["details"]; ok {
+				out, _ := json.MarshalIndent(details, "", "  ")
+				log.Printf("details: %s", out)
+			}
+			os.Exit(1)
+		}
+		log.Fatal(err)
+	}
+}
+
+func dial(addr string, tlsCfg tlsutil.Config, credsCfg rpccreds.Config) (*grpc.ClientConn, error) {
+	transport := insecure.NewCredentials()
+	if tlsCfg.ClientEnabled() {
+		tlsCfg.DefaultServerName(addr)
+		tc, err := tlsutil.Client(context.Background(), tlsCfg)
+		if err != nil {
+			return nil, err
+		}
+		transport = credentials.NewTLS(tc)
+	}
+	opts := []grpc.DialOption{grpc.WithTransportCredentials(transport)}
+	if credsCfg.Enabled() {
+		creds, err := rpccreds.FromConfig(context.Background(), credsCfg)
+		if err != nil {
+			return nil, err
+		}
+		if creds.RequireTransportSecurity() && !tlsCfg.ClientEnabled() {
+			return nil, fmt.Errorf("refusing to send a token over a plaintext connection; use -tls-ca or pass -insecure-token")
+		}
+		opts = append(opts, grpc.WithPerRPCCredentials(creds))
+	}
+	return grpc.NewClient(addr, opts...)
+}
+
+func run(ctx context.Context, conn *grpc.ClientConn, src source, args []string, data string, verbose bool) error {
+	switch args[0] {
+	case "list":
+		if len(args) == 1 {
+			services, err := src.Services(ctx)
+			if err != nil {
+				return err
+			}
+			fmt.Println(strings.Join(services, "\n"))
+			return nil
+		}
+		d, err := src.FindSymbol(ctx, args[1])
+		if err != nil {
+			return err
+		}
+		svc, ok := d.(protoreflect.ServiceDescriptor)
+		if !ok {
+			return fmt.Errorf("%s is not a service", args[1])
+		}
+		for i := 0; i < svc.Methods().Len(); i++ {
+			fmt.Println(svc.Methods().Get(i).FullName())
+		}
+		return nil
+
+	case "describe":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: describe <symbol>")
+		}
+		d, err := src.FindSymbol(ctx, args[1])
+		if err != nil {
+			// Methods are not symbols of their own in every source
+			m, merr := findMethod(ctx, src, args[1])
+			if merr != nil {
+				return err
+			}
+			d = m
+		}
+		fmt.Print(describe(d))
+		return nil
+
+	case "invoke":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: invoke <service/method>")
+		}
+		m, err := findMethod(ctx, src, args[1])
+		if err != nil {
+			return err
+		}
+		var in io.Reader = strings.NewReader(data)
+		if data == "@" {
+			in = os.Stdin
+		}
+		return invoke(ctx, conn, m, in, os.Stdout, verbose)
+	}
+	return fmt.Errorf("unknown command %q", args[0])
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	pb "awesomeproject/proto"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// greeter answers SayHello, GetStream and PutStream for the tests.
+type greeter struct {
+	pb.UnimplementedGreeterServer
+}
+
+func (greeter) SayHello(_ context.Context, req *pb.HelloRequest) (*pb.HelloReply, error) {
+	if req.GetName() == "" {
+		return nil, status.Error(codes.InvalidArgument, "name is required")
+	}
+	return &pb.HelloReply{Message: "Hello " + req.GetName()}, nil
+}
+
+func (greeter) GetStream(req *pb.StreamReqData, stream pb.Greeter_GetStreamServer) error {
+	for i := 0; i < 2; i++ {
+		if err := stream.Send(&pb.StreamResData{Data: req.GetData()}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (greeter) PutStream(stream pb.Greeter_PutStreamServer) error {
+	var all []string
+	for {
+		req, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return stream.SendAndClose(&pb.StreamResData{Data: strings.Join(all, ",")})
+		}
+		if err != nil {
+			return err
+		}
+		all = append(all, req.GetData())
+	}
+}
+
+func dialGreeter(t *testing.T) *grpc.ClientConn {
+	t.Helper()
+	l := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	pb.RegisterGreeterServer(srv, greeter{})
+	reflection.Register(srv)
+	go srv.Serve(l)
+	t.Cleanup(srv.Stop)
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return l.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// writeDescriptorSet writes the Greeter's FileDescriptorSet, as protoc
+// --descriptor_set_out would.
+func writeDescriptorSet(t *testing.T) string {
+	t.Helper()
+	set := &descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{
+		protodesc.ToFileDescriptorProto(pb.File_helloworld_proto),
+	}}
+	data, err := proto.Marshal(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "greeter.protoset")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func testContext(t *testing.T) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+func TestSources(t *testing.T) {
+	ctx := testContext(t)
+	files, err := newFileSource(writeDescriptorSet(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sources := map[string]source{
+		"reflection": newReflectionSource(dialGreeter(t)),
+		"file":       files,
+	}
+	for name, src := range sources {
+		t.Run(name, func(t *testing.T) {
+			services, err := src.Services(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(strings.Join(services, " "), "Greeter") {
+				t.Fatalf("services %q lack Greeter", services)
+			}
+			tests := []struct {
+				name    string
+				want    string // rpcLine of the method, or an error substring
+				wantErr bool
+			}{
+				{"Greeter/SayHello", "rpc SayHello(HelloRequest) returns (HelloReply);", false},
+				{"/Greeter/PutStream", "rpc PutStream(stream StreamReqData) returns (StreamResData);", false},
+				{"Greeter.GetStream", "rpc GetStream(StreamReqData) returns (stream StreamResData);", false},
+				{"SayHello", "want Service/Method", true},
+				{"Greeter/Shout", "no method Shout", true},
+				{"HelloRequest/Name", "not a service", true},
+				{"Nope/SayHello", "", true},
+			}
+			for _, tt := range tests {
+				m, err := findMethod(ctx, src, tt.name)
+				if tt.wantErr {
+					if err == nil || !strings.Contains(err.Error(), tt.want) {
+						t.Errorf("findMethod(%q) = %v, want error %q", tt.name, err, tt.want)
+					}
+					continue
+				}
+				if err != nil || rpcLine(m) != tt.want {
+					t.Errorf("findMethod(%q) = %v, %v; want %s", tt.name, m, err, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	files, err := newFileSource(writeDescriptorSet(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := findMethod(context.Background(), files, "Greeter/SayHello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `rpc SayHello(HelloRequest) returns (HelloReply);
+
+message HelloRequest {
+  string name = 1;
+}
+
+message HelloReply {
+  string message = 1;
+}
+`
+	if got := describe(m); got != want {
+		t.Fatalf("describe = %q, want %q", got, want)
+	}
+}
+
+func TestInvoke(t *testing.T) {
+	conn := dialGreeter(t)
+	src := newReflectionSource(conn)
+	tests := []struct {
+		method   string
+		in       string
+		want     string
+		wantCode codes.Code
+		wantErr  string
+	}{
+		{"Greeter/SayHello", `{"name":"a"}`, "{\n  \"message\": \"Hello a\"\n}\n", codes.OK, ""},
+		{"Greeter/SayHello", ``, "", codes.InvalidArgument, ""},
+		{"Greeter/SayHello", `{"name":"a"} {"name":"b"}`, "", codes.Unknown, "takes a single request message"},
+		{"Greeter/SayHello", `{"nom":"a"}`, "", codes.Unknown, "request 1"},
+		{"Greeter/SayHello", `{"name":`, "", codes.Unknown, "read request"},
+		{"Greeter/GetStream", `{"data":"x"}`, "{\n  \"data\": \"x\"\n}\n{\n  \"data\": \"x\"\n}\n", codes.OK, ""},
+		{"Greeter/PutStream", `{"data":"a"}{"data":"b"} {"data":"c"}`, "{\n  \"data\": \"a,b,c\"\n}\n", codes.OK, ""},
+		{"Greeter/PutStream", ``, "{\n  \"data\": \"\"\n}\n", codes.OK, ""},
+		{"Greeter/SayBye", `{}`, "", codes.Unimplemented, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.in, func(t *testing.T) {
+			ctx := testContext(t)
+			m, err := findMethod(ctx, src, tt.method)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var out bytes.Buffer
+			err = invoke(ctx, conn, m, strings.NewReader(tt.in), &out, false)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("invoke = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if status.Code(err) != tt.wantCode || out.String() != tt.want {
+				t.Fatalf("invoke wrote %q, %v; want %q, %s", out.String(), err, tt.want, tt.wantCode)
+			}
+		})
+	}
+}
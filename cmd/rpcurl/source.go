@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+
+	"google.golang.org/grpc"
+	rpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// source resolves service and message descriptors, either from the
+// server's reflection service or from a local FileDescriptorSet.
+type source interface {
+	Services(ctx context.Context) ([]string, error)
+	FindSymbol(ctx context.Context, name string) (protoreflect.Descriptor, error)
+}
+
+// fileSource serves descriptors from a FileDescriptorSet, as written by
+// protoc --descriptor_set_out --include_imports.
+type fileSource struct {
+	files *protoregistry.Files
+}
+
+func newFileSource(path string) (*fileSource, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var set descriptorpb.FileDescriptorSet
+	if err := proto.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	files, err := protodesc.NewFiles(&set)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &fileSource{files: files}, nil
+}
+
+func (s *fileSource) Services(context.Context) ([]string, error) {
+	var names []string
+	s.files.RangeFiles(func(fd protoreflect.FileDescriptor) bool {
+		for i := 0; i < fd.Services().Len(); i++ {
+			names = append(names, string(fd.Services().Get(i).FullName()))
+		}
+		return true
+	})
+	sort.Strings(names)
+	return names, nil
+}
+
+func (s *fileSource) FindSymbol(_ context.Context, name string) (protoreflect.Descriptor, error) {
+	return s.files.FindDescriptorByName(protoreflect.FullName(name))
+}
+
+// reflectionSource asks the server over grpc.reflection.v1. Files are
+// cached, so each one is fetched at most once.
+type reflectionSource struct {
+	client rpb.ServerReflectionClient
+	protos map[string]*descriptorpb.FileDescriptorProto // by file name
+}
+
+func newReflectionSource(conn *grpc.ClientConn) *reflectionSource {
+	return &reflectionSource{
+		client: rpb.NewServerReflectionClient(conn),
+		protos: make(map[string]*descriptorpb.FileDescriptorProto),
+	}
+}
+
+func (s *reflectionSource) call(ctx context.Context, req *rpb.ServerReflectionRequest) (*rpb.ServerReflectionResponse, error) {
+	stream, err := s.client.ServerReflectionInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.CloseSend()
+	if err := stream.Send(req); err != nil {
+		return nil, err
+	}
+	res, err := stream.Recv()
+	if err != nil {
+		return nil, err
+	}
+	if e := res.GetErrorResponse(); e != nil {
+		return nil, fmt.Errorf("reflection: %s", e.GetErrorMessage())
+	}
+	return res, nil
+}
+
+func (s *reflectionSource) Services(ctx context.Context) ([]string, error) {
+	res, err := s.call(ctx, &rpb.ServerReflectionRequest{
+		MessageRequest: &rpb.ServerReflectionRequest_ListServices{},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, svc := range res.GetListServicesResponse().GetService() {
+		names = append(names, svc.GetName())
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (s *reflectionSource) FindSymbol(ctx context.Context, name string) (protoreflect.Descriptor, error) {
+	res, err := s.call(ctx, &rpb.ServerReflectionRequest{
+		MessageRequest: &rpb.ServerReflectionRequest_FileContainingSymbol{FileContainingSymbol: name},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := s.add(res); err != nil {
+		return nil, err
+	}
+	files, err := s.resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return files.FindDescriptorByName(protoreflect.FullName(name))
+}
+
+func (s *reflectionSource) add(res *rpb.ServerReflectionResponse) error {
+	for _, raw := range res.GetFileDescriptorResponse().GetFileDescriptorProto() {
+		fd := new(descriptorpb.FileDescriptorProto)
+		if err := proto.Unmarshal(raw, fd); err != nil {
+			return err
+		}
+		s.protos[fd.GetName()] = fd
+	}
+	return nil
+}
+
+// resolve fetches any missing dependencies and builds a registry of every
+// file seen so far. Well-known types the server omits are taken from the
+// ones linked into this binary.
+func (s *reflectionSource) resolve(ctx context.Context) (*protoregistry.Files, error) {
+	for {
+		var missing string
+		for _, fd := range s.protos {
+			for _, dep := range fd.GetDependency() {
+				if _, ok := s.protos[dep]; !ok {
+					missing = dep
+					break
+				}
+			}
+		}
+		if missing == "" {
+			break
+		}
+		if d, err := protoregistry.GlobalFiles.FindFileByPath(missing); err == nil {
+			s.protos[missing] = protodesc.ToFileDescriptorProto(d)
+			continue
+		}
+		res, err := s.call(ctx, &rpb.ServerReflectionRequest{
+			MessageRequest: &rpb.ServerReflectionRequest_FileByFilename{FileByFilename: missing},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("fetch %s: %w", missing, err)
+		}
+		if err := s.add(res); err != nil {
+			return nil, err
+		}
+		if _, ok := s.protos[missing]; !ok {
+			return nil, fmt.Errorf("server did not return %s", missing)
+		}
+	}
+	set := &descriptorpb.FileDescriptorSet{}
+	for _, fd := range s.protos {
+		set.File = append(set.File, fd)
+	}
+	return protodesc.NewFiles(set)
+}
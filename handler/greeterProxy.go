@@ -0,0 +1,34 @@
+package handler
+
+import (
+	"context"
+
+	pb "awesomeproject/proto"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// GreeterProxy exposes the unary methods of a gRPC Greeter to JSON-RPC
+// clients. Errors are returned as gRPC status errors, which hertzrpc turns
+// into JSON-RPC errors whose data holds the status details.
+type GreeterProxy struct {
+	Client pb.GreeterClient
+}
+
+func (g *GreeterProxy) SayHello(ctx context.Context, req *pb.HelloRequest, reply *pb.HelloReply) error {
+	res, err := g.Client.SayHello(ctx, req)
+	if err != nil {
+		return err
+	}
+	proto.Merge(reply, res)
+	return nil
+}
+
+func (g *GreeterProxy) SayBye(ctx context.Context, req *pb.ByeRequest, reply *pb.HelloReply) error {
+	res, err := g.Client.SayBye(ctx, req)
+	if err != nil {
+		return err
+	}
+	proto.Merge(reply, res)
+	return nil
+}
@@ -1,6 +1,13 @@
 package handler
 
-const HelloServiceName = "HelloService"
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"awesomeproject/pkg/auth"
+	"awesomeproject/pkg/tlsutil"
+)
 
 type HelloService struct{}
 
@@ -8,3 +15,35 @@ func (h *HelloService) Hello(request string, reply *string) error {
 	*reply = "Hello " + request + " from ppp"
 	return nil
 }
+
+// GreetArgs is checked by hertzrpc before Greet is called.
+type GreetArgs struct {
+	Name  string `json:"name" validate:"required,min=1,max=64"`
+	Times int    `json:"times" validate:"min=0,max=10"`
+}
+
+func (a *GreetArgs) Validate() error {
+	if strings.TrimSpace(a.Name) == "" {
+		return errors.New("name must not be blank")
+	}
+	return nil
+}
+
+func (h *HelloService) Greet(args GreetArgs, reply *string) error {
+	*reply = "Hello" + strings.Repeat(" "+args.Name, max(args.Times, 1))
+	return nil
+}
+
+// WhoAmI reports the caller verified by the auth interceptor and the
+// client certificate on mutual TLS connections. net/rpc does not pass a
+// context, so it is only reachable through hertzrpc.
+func (h *HelloService) WhoAmI(ctx context.Context, _ struct{}, reply *string) error {
+	*reply = "anonymous"
+	if p, ok := auth.FromContext(ctx); ok {
+		*reply = p.Subject + " (" + p.Via + ")"
+	}
+	if id, ok := tlsutil.PeerFromContext(ctx); ok {
+		*reply += ", certificate " + id.String()
+	}
+	return nil
+}
@@ -2,16 +2,30 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"awesomeproject/handler"
+	"awesomeproject/pkg/auth"
+	"awesomeproject/pkg/authz"
+	"awesomeproject/pkg/health"
 	"awesomeproject/pkg/hertzrpc"
+	"awesomeproject/pkg/ratelimit"
+	"awesomeproject/pkg/shutdown"
+	"awesomeproject/pkg/tlsutil"
+	pb "awesomeproject/proto"
+	"awesomeproject/serverStub"
 
 	"github.com/cloudwego/hertz/pkg/app"
 	"github.com/cloudwego/hertz/pkg/app/server"
+	"github.com/cloudwego/hertz/pkg/common/config"
+	"github.com/cloudwego/hertz/pkg/protocol/consts"
 	"github.com/hertz-contrib/websocket"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
 )
 
 var upgrader = websocket.HertzUpgrader{
@@ -20,43 +34,223 @@ var upgrader = websocket.HertzUpgrader{
 	},
 }
 
+// streams tracks /stream connections so shutdown can close them cleanly
+var streams shutdown.Group
+
+// goingAway tells a /stream peer the server is shutting down. The read
+// loop in handleStream ends when the peer answers the close frame.
+func goingAway(conn *websocket.Conn) {
+	msg := websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down")
+	_ = conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
+}
+
+// wsMetrics tracks permessage-deflate savings on /stream
+var wsMetrics hertzrpc.CompressionMetrics
+
 func main() {
+	compressMin := flag.Int("compress-min", hertzrpc.DefaultMinCompressSize, "minimum JSON-RPC reply size in bytes to compress")
+	wsDeflate := flag.Bool("ws-deflate", true, "negotiate permessage-deflate on /stream")
+	wsDeflateLevel := flag.Int("ws-deflate-level", 1, "flate level for /stream messages (-2..9)")
+	maxBody := flag.Int("max-body", hertzrpc.DefaultLimits.MaxBodySize, "maximum JSON-RPC body size in bytes")
+	maxDepth := flag.Int("max-depth", hertzrpc.DefaultLimits.MaxDepth, "maximum JSON-RPC nesting depth")
+	maxBatch := flag.Int("max-batch", hertzrpc.DefaultLimits.MaxBatch, "maximum JSON-RPC batch length")
+	maxParams := flag.Int("max-params", hertzrpc.DefaultLimits.MaxParamsSize, "maximum JSON-RPC params size in bytes")
+	wsMaxMessage := flag.Int64("ws-max-message", 64<<10, "maximum /stream message size in bytes")
+	wsReadTimeout := flag.Duration("ws-read-timeout", time.Minute, "close /stream peers that send nothing for this long")
+	rate := flag.Float64("rate", 0, "default JSON-RPC calls per second per client (0 = unlimited)")
+	burst := flag.Int("burst", 10, "default rate limit burst")
+	rateKey := flag.String("ratelimit-key", "principal", "rate limit clients by: principal, ip or metadata:<header>")
+	rateMaxClients := flag.Int("ratelimit-max-clients", ratelimit.DefaultMaxClients, "most clients to keep rate limit buckets for")
+	rateConfig := flag.String("ratelimit-config", "", "JSON file with per-method rate limits (overrides -rate/-burst/-ratelimit-key)")
+	var authCfg auth.Config
+	authCfg.RegisterFlags(flag.CommandLine)
+	var authzCfg authz.Config
+	authzCfg.RegisterFlags(flag.CommandLine)
+	var tlsCfg tlsutil.Config
+	tlsCfg.RegisterServerFlags(flag.CommandLine)
+	var healthCfg health.Config
+	healthCfg.RegisterFlags(flag.CommandLine)
+	grpcBackend := flag.String("grpc-backend", "", "proxy the Greeter service to this gRPC server (empty disables)")
+	shutdownTimeout := flag.Duration("shutdown-timeout", shutdown.DefaultTimeout, "how long to wait for in-flight calls and streams on shutdown")
+	flag.Parse()
+
+	rlCfg := ratelimit.Config{Default: ratelimit.Rule{Rate: *rate, Burst: *burst}, Key: *rateKey, MaxClients: *rateMaxClients}
+	if *rateConfig != "" {
+		var err error
+		if rlCfg, err = ratelimit.LoadConfig(*rateConfig); err != nil {
+			log.Fatal("load rate limit config:", err)
+		}
+	}
+	rlKey, err := hertzrpc.RateLimitKey(rlCfg.Key)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var interceptors []hertzrpc.Interceptor
+	var policy *authz.Engine
+	if authzCfg.Enabled() {
+		if policy, err = authz.Start(context.Background(), authzCfg); err != nil {
+			log.Fatal("authz:", err)
+		}
+	}
+	if authCfg.Enabled() {
+		authenticator, err := auth.New(authCfg)
+		if err != nil {
+			log.Fatal("auth:", err)
+		}
+		if policy != nil {
+			authenticator.AllowAnonymous(policy.IsPublic)
+		}
+		interceptors = append(interceptors, hertzrpc.Authenticate(authenticator))
+	}
+	// After authentication, so buckets are keyed on verified callers and
+	// unauthenticated calls cost nothing
+	interceptors = append(interceptors, hertzrpc.RateLimit(ratelimit.New(rlCfg), rlKey))
+	if policy != nil {
+		interceptors = append(interceptors, hertzrpc.Authorize(policy))
+	}
+
 	// 1. Initialize Hertz Server
-	h := server.Default(server.WithHostPorts(":8082"))
+	// Hertz rejects bodies over its own limit before the dispatcher runs, so
+	// leave headroom for the dispatcher to answer with a JSON-RPC error
+	opts := []config.Option{
+		server.WithHostPorts(":8082"),
+		server.WithMaxRequestBodySize(2 * *maxBody),
+		// Spin shuts down on SIGINT/SIGTERM and waits this long for the
+		// OnShutdown hooks below
+		server.WithExitWaitTime(*shutdownTimeout),
+	}
+	if tlsCfg.ServerEnabled() {
+		// TLS switches Hertz to the standard transport; netpoll has no TLS
+		tc, err := tlsutil.Server(context.Background(), tlsCfg)
+		if err != nil {
+			log.Fatal("tls:", err)
+		}
+		opts = append(opts, server.WithTLS(tc))
+	}
+	h := server.Default(opts...)
 
 	// 2. Initialize JSON-RPC Dispatcher
 	// This replaces the manual switch-case logic with a reflection-based dispatcher
-	dispatcher := hertzrpc.NewDispatcher()
+	dispatcher := hertzrpc.NewDispatcher(
+		hertzrpc.WithCompression(*compressMin),
+		hertzrpc.WithLimits(hertzrpc.Limits{
+			MaxBodySize:   *maxBody,
+			MaxDepth:      *maxDepth,
+			MaxBatch:      *maxBatch,
+			MaxParamsSize: *maxParams,
+		}),
+		hertzrpc.WithInterceptors(interceptors...),
+	)
 	
 	// Register the service (just like net/rpc)
 	// This solves the scalability issue: you can register as many services as you want
-	if err := dispatcher.RegisterName("HelloService", new(handler.HelloService)); err != nil {
+	if err := serverStub.RegisterHelloServiceHertz(dispatcher, new(handler.HelloService)); err != nil {
 		log.Fatal("Failed to register HelloService:", err)
 	}
+	// Greeter.SayHello and Greeter.SayBye are forwarded to a gRPC server;
+	// its status codes and error details come back in error.data
+	if *grpcBackend != "" {
+		conn, err := grpc.NewClient(*grpcBackend, grpc.WithTransportCredentials(insecure.NewCredentials()))
+		if err != nil {
+			log.Fatal("grpc backend:", err)
+		}
+		defer conn.Close()
+		if err := dispatcher.RegisterName(pb.Greeter_ServiceDesc.ServiceName, &handler.GreeterProxy{Client: pb.NewGreeterClient(conn)}); err != nil {
+			log.Fatal("Failed to register Greeter proxy:", err)
+		}
+	}
 
 	// 3. Register JSON-RPC Route
 	// All JSON-RPC requests go here
 	h.POST("/jsonRpc", dispatcher.Handle)
 
+	// JSON-RPC over WebSocket: text frames carry JSON, binary frames carry
+	// MessagePack or CBOR depending on the negotiated subprotocol
+	upgrader.EnableCompression = *wsDeflate
+	rpcUpgrader := upgrader
+	rpcUpgrader.Subprotocols = dispatcher.Subprotocols()
+	h.GET("/ws", dispatcher.HandleWS(&rpcUpgrader))
+
 	// 4. Register WebSocket Route (Streaming)
 	// This handles the streaming requirement natively in Hertz
 	h.GET("/stream", func(c context.Context, ctx *app.RequestContext) {
+		deflate := upgrader.EnableCompression &&
+			strings.Contains(string(ctx.GetHeader("Sec-WebSocket-Extensions")), "permessage-deflate")
 		if err := upgrader.Upgrade(ctx, func(conn *websocket.Conn) {
-			handleStream(conn)
+			done, err := streams.Add(func() { goingAway(conn) }, func() { conn.Close() })
+			if err != nil {
+				goingAway(conn)
+				return
+			}
+			defer done()
+			level := *wsDeflateLevel
+			if deflate {
+				if err := conn.SetCompressionLevel(level); err != nil {
+					log.Print("compression level:", err)
+					level = 1
+				}
+			}
+			conn.SetReadLimit(*wsMaxMessage)
+			handleStream(conn, deflate, level, *wsReadTimeout)
 		}); err != nil {
 			log.Print("upgrade:", err)
 			return
 		}
 	})
 
+	// 5. Compression metrics for /jsonRpc replies and /stream messages
+	h.GET("/metrics/compression", func(c context.Context, ctx *app.RequestContext) {
+		ctx.JSON(consts.StatusOK, map[string]interface{}{
+			"jsonRpc": dispatcher.CompressionMetrics().Snapshot(),
+			"stream":  wsMetrics.Snapshot(),
+		})
+	})
+
+	// Liveness only says the process answers; readiness also reflects
+	// dependency checks and turns 503 once shutdown begins
+	monitor := health.Start(context.Background(), healthCfg, serverStub.HelloServiceName)
+	h.GET("/healthz", func(c context.Context, ctx *app.RequestContext) {
+		ctx.JSON(consts.StatusOK, map[string]string{"status": "ok"})
+	})
+	h.GET("/readyz", func(c context.Context, ctx *app.RequestContext) {
+		report := monitor.Report()
+		code := consts.StatusOK
+		if !report.Ready {
+			code = consts.StatusServiceUnavailable
+		}
+		ctx.JSON(code, report)
+	})
+
+	// On shutdown: report not ready, let JSON-RPC calls finish and close
+	// WebSocket peers with a going-away frame. Hertz runs the hooks while
+	// it stops accepting and drains HTTP connections.
+	h.OnShutdown = append(h.OnShutdown,
+		func(context.Context) { monitor.Shutdown() },
+		func(ctx context.Context) {
+			if err := dispatcher.Shutdown(ctx); err != nil {
+				log.Print("jsonrpc shutdown: ", err)
+			}
+		},
+		func(ctx context.Context) {
+			if err := streams.Shutdown(ctx); err != nil {
+				log.Print("stream shutdown: ", err)
+			}
+		},
+	)
+
 	fmt.Println("Hertz server is running on :8082")
-	fmt.Println(" - JSON-RPC: http://127.0.0.1:8082/jsonRpc")
+	fmt.Println(" - JSON-RPC: http://127.0.0.1:8082/jsonRpc (json, msgpack, cbor)")
+	fmt.Println(" - JSON-RPC over WebSocket: ws://127.0.0.1:8082/ws")
 	fmt.Println(" - Streaming: ws://127.0.0.1:8082/stream")
+	fmt.Println(" - Compression metrics: http://127.0.0.1:8082/metrics/compression")
+	fmt.Println(" - Health: http://127.0.0.1:8082/healthz, http://127.0.0.1:8082/readyz")
 	h.Spin()
 }
 
-// handleStream mimics the bidirectional streaming logic from your gRPC example
-func handleStream(conn *websocket.Conn) {
+// handleStream mimics the bidirectional streaming logic from your gRPC example.
+// deflate reports whether the peer agreed to permessage-deflate at level.
+// A peer that stays silent for readTimeout is disconnected.
+func handleStream(conn *websocket.Conn, deflate bool, level int, readTimeout time.Duration) {
 	defer conn.Close()
 
 	// Channel to signal completion
@@ -66,6 +260,7 @@ func handleStream(conn *websocket.Conn) {
 	go func() {
 		defer close(done)
 		for {
+			_ = conn.SetReadDeadline(time.Now().Add(readTimeout))
 			_, message, err := conn.ReadMessage()
 			if err != nil {
 				log.Println("[Server] Read error:", err)
@@ -88,6 +283,9 @@ func handleStream(conn *websocket.Conn) {
 				log.Println("[Server] Write error:", err)
 				return
 			}
+			if deflate {
+				wsMetrics.ObserveDeflate([]byte(msg), level)
+			}
 			time.Sleep(1 * time.Second)
 		}
 	}
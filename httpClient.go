@@ -1,19 +1,28 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
 	"log"
-	"net/rpc"
+	"time"
+
+	"awesomeproject/pkg/rpcclient"
+	"awesomeproject/serverStub"
 )
 
 func main() {
-	client, err := rpc.DialHTTP("tcp", "127.0.0.1:8081")
-	if err != nil {
-		log.Fatal("dialing:", err)
-	}
+	addr := flag.String("addr", "127.0.0.1:8081", "server address")
+	timeout := flag.Duration("timeout", 5*time.Second, "call timeout")
+	flag.Parse()
 
-	var reply string
-	if err := client.Call("HelloService.Hello", "Xc", &reply); err != nil {
+	client := serverStub.NewHelloServiceClient(rpcclient.DialHTTP(*addr))
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	reply, err := client.Hello(ctx, "Xc")
+	if err != nil {
 		log.Fatal("call failed:", err)
 	}
 	fmt.Println(reply)
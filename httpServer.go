@@ -1,20 +1,40 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"io"
+	"log"
 	"net/http"
 	"net/rpc"
 	"net/rpc/jsonrpc"
 
 	"awesomeproject/handler"
+	"awesomeproject/pkg/auth"
+	"awesomeproject/pkg/rpchook"
+	"awesomeproject/pkg/shutdown"
 	"awesomeproject/serverStub"
 )
 
 func main() {
+	var authCfg auth.Config
+	authCfg.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+
 	err := serverStub.RegisterHelloService(&handler.HelloService{})
 	if err != nil {
 		panic("failed to register hello service: " + err.Error())
 	}
+	// 钩子: 日志, 以及配置了密钥时校验 Authorization / X-API-Key 请求头
+	hooks := []rpchook.Hooks{rpchook.Logging(log.Default())}
+	if authCfg.Enabled() {
+		authenticator, err := auth.New(authCfg)
+		if err != nil {
+			log.Fatalf("auth: %v", err)
+		}
+		hooks = append(hooks, rpchook.Auth(authenticator))
+	}
 	http.HandleFunc("/jsonRpc", func(w http.ResponseWriter, r *http.Request) {
 		var conn io.ReadWriteCloser = struct {
 			io.Writer
@@ -23,13 +43,29 @@ func main() {
 			ReadCloser: r.Body,
 			Writer:     w,
 		}
-		err := rpc.ServeRequest(jsonrpc.NewServerCodec(conn))
+		err := rpchook.ServeRequest(rpc.DefaultServer, r, jsonrpc.NewServerCodec(conn), hooks...)
 		if err != nil {
 			return
 		}
 	})
-	err = http.ListenAndServe(":8082", nil)
-	if err != nil {
+
+	// 收到退出信号后 Shutdown: 停止监听, 等待进行中的请求处理完
+	srv := &http.Server{Addr: ":8082"}
+	ctx, stop := shutdown.OnSignal()
+	defer stop()
+	drained := make(chan struct{})
+	go func() {
+		defer close(drained)
+		<-ctx.Done()
+		drainCtx, cancel := context.WithTimeout(context.Background(), shutdown.DefaultTimeout)
+		defer cancel()
+		if err := srv.Shutdown(drainCtx); err != nil {
+			log.Println("shutdown:", err)
+		}
+	}()
+	err = srv.ListenAndServe()
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
 		panic("failed to listen: " + err.Error())
 	}
+	<-drained // ListenAndServe 立即返回, Shutdown 还在等待请求完成
 }
@@ -1,22 +1,28 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
-	"net"
-	"net/rpc"
-	"net/rpc/jsonrpc"
+	"time"
+
+	"awesomeproject/pkg/rpcclient"
+	"awesomeproject/serverStub"
 )
 
 func main() {
-	conn, err := net.Dial("tcp", "localhost:1234")
-	if err != nil {
-		panic("连接错误")
-	}
-	client := rpc.NewClientWithCodec(jsonrpc.NewClientCodec(conn))
-	var reply string
-	err = client.Call("HelloService.Hello", "CHENG LIANG", &reply)
+	addr := flag.String("addr", "localhost:1234", "server address")
+	timeout := flag.Duration("timeout", 5*time.Second, "call timeout")
+	flag.Parse()
+
+	client := serverStub.NewHelloServiceClient(rpcclient.DialJSON(*addr))
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	reply, err := client.Hello(ctx, "CHENG LIANG")
 	if err != nil {
-		panic("调用错误")
+		panic("调用错误: " + err.Error())
 	}
 	fmt.Println(reply)
 }
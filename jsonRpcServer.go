@@ -1,29 +1,42 @@
 package main
 
 import (
-	"net"
-	"net/rpc/jsonrpc"
-)
-import "net/rpc"
-
-type HelloService2 struct{}
+	"context"
+	"flag"
+	"log"
 
-func (s *HelloService2) Hello(request string, reply *string) error {
-	*reply = "Hello " + request
-	return nil
-}
+	"awesomeproject/handler"
+	"awesomeproject/pkg/rpcserver"
+	"awesomeproject/pkg/shutdown"
+	"awesomeproject/serverStub"
+)
 
 func main() {
-	_ = rpc.RegisterName("HelloService", &HelloService2{})
-	listener, err := net.Listen("tcp", ":1234")
+	// 与 server.go 相同, 只是默认使用 JSON-RPC 编解码
+	cfg := rpcserver.DefaultConfig()
+	cfg.Codec = rpcserver.CodecJSON
+	cfg.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+
+	if err := serverStub.RegisterHelloService(&handler.HelloService{}); err != nil {
+		log.Fatal(err)
+	}
+	srv, err := rpcserver.New(cfg)
 	if err != nil {
-		panic("failed to listen: " + err.Error())
+		log.Fatal(err)
+	}
+
+	// Accept 出错不再 panic; 收到退出信号后等待进行中的调用完成
+	ctx, stop := shutdown.OnSignal()
+	defer stop()
+	if err := srv.ListenAndServe(ctx); err != nil {
+		log.Fatal(err)
 	}
-	for {
-		conn, err := listener.Accept()
-		if err != nil {
-			panic("接收")
-		}
-		go rpc.ServeCodec(jsonrpc.NewServerCodec(conn))
+
+	log.Printf("shutting down, draining %d connections", srv.Stats().Active)
+	drainCtx, cancel := context.WithTimeout(context.Background(), shutdown.DefaultTimeout)
+	defer cancel()
+	if err := srv.Shutdown(drainCtx); err != nil {
+		log.Println("drain:", err)
 	}
 }
@@ -0,0 +1,220 @@
+// Package auth verifies bearer tokens, either static API keys or JWTs, and
+// carries the verified Principal through the request context. It is shared
+// by the gRPC interceptors in this package and the hertzrpc Dispatcher.
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"awesomeproject/pkg/rpcname"
+)
+
+// Principal is the verified identity of a caller.
+type Principal struct {
+	Subject string   `json:"subject"`
+	Roles   []string `json:"roles,omitempty"`
+	Scopes  []string `json:"scopes,omitempty"`
+	// Via names the mechanism that verified the caller: "api-key" or "jwt".
+	Via string `json:"via"`
+}
+
+// HasRole reports whether p has role.
+func (p *Principal) HasRole(role string) bool {
+	return p != nil && slices.Contains(p.Roles, role)
+}
+
+// HasScope reports whether p was granted scope.
+func (p *Principal) HasScope(scope string) bool {
+	return p != nil && slices.Contains(p.Scopes, scope)
+}
+
+type principalKey struct{}
+
+// NewContext returns a copy of ctx carrying p.
+func NewContext(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// FromContext returns the Principal stored in ctx, if any.
+func FromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok && p != nil
+}
+
+var (
+	// ErrNoToken is returned when the caller presented no credentials.
+	ErrNoToken = errors.New("auth: missing bearer token")
+	// ErrInvalidToken is returned for unknown API keys and bad JWTs.
+	ErrInvalidToken = errors.New("auth: invalid token")
+)
+
+// Verifier checks a bearer token and returns the caller it identifies.
+type Verifier interface {
+	Verify(ctx context.Context, token string) (*Principal, error)
+}
+
+// Authenticator tries API keys first and then JWTs.
+type Authenticator struct {
+	keys      *APIKeys
+	jwt       *JWTVerifier
+	public    []string
+	anonymous func(method string) bool
+}
+
+// AllowAnonymous lets fn admit further methods without a token, e.g. those
+// an authorization policy marks public. Call it before serving.
+func (a *Authenticator) AllowAnonymous(fn func(method string) bool) {
+	a.anonymous = fn
+}
+
+// IsPublic reports whether method may be called without a token. A token
+// that is presented is still verified.
+func (a *Authenticator) IsPublic(method string) bool {
+	for _, pattern := range a.public {
+		if rpcname.Match(pattern, method) {
+			return true
+		}
+	}
+	return a.anonymous != nil && a.anonymous(method)
+}
+
+// Verify implements Verifier.
+func (a *Authenticator) Verify(ctx context.Context, token string) (*Principal, error) {
+	if token == "" {
+		return nil, ErrNoToken
+	}
+	if a.keys != nil {
+		if p, err := a.keys.Verify(ctx, token); err == nil {
+			return p, nil
+		}
+	}
+	if a.jwt != nil && strings.Count(token, ".") == 2 {
+		return a.jwt.Verify(ctx, token)
+	}
+	return nil, ErrInvalidToken
+}
+
+// Config selects the key material used by an Authenticator. Authentication
+// is disabled when every field is empty.
+type Config struct {
+	APIKeysFile  string // JSON list of APIKey entries
+	HS256Secret  string // file holding the shared HMAC secret
+	RS256KeyFile string // PEM-encoded RSA public key
+	JWKSFile     string // JSON Web Key Set with RSA keys
+	Issuer       string // required "iss" claim, if set
+	Audience     string // required "aud" claim, if set
+	// Public lists methods callable without a token, as rpcname.Match
+	// patterns such as "/Greeter/SayHello" or "HelloService.*".
+	Public []string
+}
+
+// RegisterFlags binds the Config fields to command-line flags on fs.
+func (c *Config) RegisterFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.APIKeysFile, "auth-keys", "", "JSON file of static API keys")
+	fs.StringVar(&c.HS256Secret, "auth-hs256-secret", "", "file holding the HS256 JWT secret")
+	fs.StringVar(&c.RS256KeyFile, "auth-rs256-key", "", "PEM RSA public key for RS256 JWTs")
+	fs.StringVar(&c.JWKSFile, "auth-jwks", "", "JWKS file with RS256 JWT keys")
+	fs.StringVar(&c.Issuer, "auth-issuer", "", "required JWT issuer")
+	fs.StringVar(&c.Audience, "auth-audience", "", "required JWT audience")
+	fs.Func("auth-public", "comma-separated methods that need no token", func(s string) error {
+		for _, m := range strings.Split(s, ",") {
+			if m = strings.TrimSpace(m); m != "" {
+				c.Public = append(c.Public, m)
+			}
+		}
+		return nil
+	})
+}
+
+// Enabled reports whether any key material was configured.
+func (c *Config) Enabled() bool {
+	return c.APIKeysFile != "" || c.HS256Secret != "" || c.RS256KeyFile != "" || c.JWKSFile != ""
+}
+
+// New builds an Authenticator from cfg.
+func New(cfg Config) (*Authenticator, error) {
+	a := &Authenticator{public: cfg.Public}
+	if cfg.APIKeysFile != "" {
+		keys, err := LoadAPIKeys(cfg.APIKeysFile)
+		if err != nil {
+			return nil, err
+		}
+		a.keys = keys
+	}
+	if cfg.HS256Secret != "" || cfg.RS256KeyFile != "" || cfg.JWKSFile != "" {
+		v, err := NewJWTVerifier(cfg)
+		if err != nil {
+			return nil, err
+		}
+		a.jwt = v
+	}
+	return a, nil
+}
+
+// APIKey is one entry of an API keys file.
+type APIKey struct {
+	Key     string   `json:"key"`
+	Subject string   `json:"subject"`
+	Roles   []string `json:"roles"`
+	Scopes  []string `json:"scopes"`
+}
+
+// APIKeys verifies static keys. Keys are held only as SHA-256 digests.
+type APIKeys struct {
+	byDigest map[[sha256.Size]byte]*Principal
+}
+
+// LoadAPIKeys reads a JSON array of APIKey entries.
+func LoadAPIKeys(path string) (*APIKeys, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var entries []APIKey
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("auth: parse %s: %w", path, err)
+	}
+	keys := &APIKeys{byDigest: make(map[[sha256.Size]byte]*Principal, len(entries))}
+	for _, e := range entries {
+		if e.Key == "" || e.Subject == "" {
+			return nil, fmt.Errorf("auth: %s: every key needs a key and a subject", path)
+		}
+		keys.byDigest[sha256.Sum256([]byte(e.Key))] = &Principal{
+			Subject: e.Subject,
+			Roles:   e.Roles,
+			Scopes:  e.Scopes,
+			Via:     "api-key",
+		}
+	}
+	return keys, nil
+}
+
+// Verify implements Verifier.
+func (k *APIKeys) Verify(_ context.Context, token string) (*Principal, error) {
+	if p, ok := k.byDigest[sha256.Sum256([]byte(token))]; ok {
+		return p, nil
+	}
+	return nil, ErrInvalidToken
+}
+
+// APIKeyHeader is the HTTP header that may carry an API key instead of
+// Authorization.
+const APIKeyHeader = "X-API-Key"
+
+// BearerToken extracts the token from an Authorization header value.
+// Values without the "Bearer " prefix are returned as they are.
+func BearerToken(authorization string) string {
+	const prefix = "bearer "
+	if len(authorization) >= len(prefix) && strings.EqualFold(authorization[:len(prefix)], prefix) {
+		return strings.TrimSpace(authorization[len(prefix):])
+	}
+	return strings.TrimSpace(authorization)
+}
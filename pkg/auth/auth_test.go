@@ -0,0 +1,383 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func writeFile(t *testing.T, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestBearerToken(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"Bearer abc", "abc"},
+		{"bearer abc ", "abc"},
+		{"BEARER  abc", "abc"},
+		{"abc", "abc"},
+		{"Basic abc", "Basic abc"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := BearerToken(tt.in); got != tt.want {
+			t.Errorf("BearerToken(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestTokenFromMetadata(t *testing.T) {
+	tests := []struct {
+		md   metadata.MD
+		want string
+	}{
+		{metadata.Pairs("authorization", "Bearer a", "x-api-key", "b", "token", "c"), "a"},
+		{metadata.Pairs("x-api-key", "b", "token", "c"), "b"},
+		{metadata.Pairs("token", "c"), "c"},
+		{metadata.MD{}, ""},
+		{nil, ""},
+	}
+	for _, tt := range tests {
+		if got := TokenFromMetadata(tt.md); got != tt.want {
+			t.Errorf("TokenFromMetadata(%v) = %q, want %q", tt.md, got, tt.want)
+		}
+	}
+}
+
+func TestLoadAPIKeys(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr bool
+	}{
+		{"valid", `[{"key":"k1","subject":"alice","roles":["admin"],"scopes":["s"]}]`, false},
+		{"missing subject", `[{"key":"k1"}]`, true},
+		{"missing key", `[{"subject":"alice"}]`, true},
+		{"not JSON", `{`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys, err := LoadAPIKeys(writeFile(t, "keys.json", []byte(tt.data)))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("no error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			p, err := keys.Verify(context.Background(), "k1")
+			want := &Principal{Subject: "alice", Roles: []string{"admin"}, Scopes: []string{"s"}, Via: "api-key"}
+			if err != nil || !reflect.DeepEqual(p, want) {
+				t.Fatalf("Verify = %+v, %v", p, err)
+			}
+			if _, err := keys.Verify(context.Background(), "k2"); !errors.Is(err, ErrInvalidToken) {
+				t.Fatalf("Verify(unknown) = %v", err)
+			}
+		})
+	}
+}
+
+// jwtKeys is the key material for signing test tokens and the Config
+// that verifies them.
+type jwtKeys struct {
+	secret []byte
+	rsa    *rsa.PrivateKey
+	cfg    Config
+}
+
+func newJWTKeys(t *testing.T) *jwtKeys {
+	t.Helper()
+	k := &jwtKeys{secret: []byte("0123456789abcdef0123456789abcdef")}
+	var err error
+	if k.rsa, err = rsa.GenerateKey(rand.Reader, 2048); err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&k.rsa.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	k.cfg = Config{
+		HS256Secret:  writeFile(t, "secret", append(k.secret, '\n')),
+		RS256KeyFile: writeFile(t, "key.pem", pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})),
+		Issuer:       "test-issuer",
+		Audience:     "greeter",
+	}
+	return k
+}
+
+func (k *jwtKeys) sign(t *testing.T, method jwt.SigningMethod, claims jwt.Claims, kid string) string {
+	t.Helper()
+	tok := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		tok.Header["kid"] = kid
+	}
+	var key interface{} = k.secret
+	if method == jwt.SigningMethodRS256 {
+		key = k.rsa
+	}
+	s, err := tok.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func claims(edit func(*Claims)) *Claims {
+	c := &Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   "bob",
+			Issuer:    "test-issuer",
+			Audience:  jwt.ClaimStrings{"greeter"},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+		Roles: []string{"user"},
+		Scope: "greeter:read greeter:write",
+	}
+	if edit != nil {
+		edit(c)
+	}
+	return c
+}
+
+func TestJWTVerifier(t *testing.T) {
+	k := newJWTKeys(t)
+	v, err := NewJWTVerifier(k.cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		token   string
+		want    *Principal
+		wantErr bool
+	}{
+		{name: "HS256", token: k.sign(t, jwt.SigningMethodHS256, claims(nil), ""),
+			want: &Principal{Subject: "bob", Roles: []string{"user"}, Scopes: []string{"greeter:read", "greeter:write"}, Via: "jwt"}},
+		{name: "RS256", token: k.sign(t, jwt.SigningMethodRS256, claims(nil), ""),
+			want: &Principal{Subject: "bob", Roles: []string{"user"}, Scopes: []string{"greeter:read", "greeter:write"}, Via: "jwt"}},
+		{name: "scp array", token: k.sign(t, jwt.SigningMethodHS256, claims(func(c *Claims) { c.Scope, c.Scp = "", []string{"a", "b"} }), ""),
+			want: &Principal{Subject: "bob", Roles: []string{"user"}, Scopes: []string{"a", "b"}, Via: "jwt"}},
+		{name: "expired", token: k.sign(t, jwt.SigningMethodHS256, claims(func(c *Claims) {
+			c.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+		}), ""), wantErr: true},
+		{name: "no expiry", token: k.sign(t, jwt.SigningMethodHS256, claims(func(c *Claims) { c.ExpiresAt = nil }), ""), wantErr: true},
+		{name: "wrong issuer", token: k.sign(t, jwt.SigningMethodHS256, claims(func(c *Claims) { c.Issuer = "evil" }), ""), wantErr: true},
+		{name: "wrong audience", token: k.sign(t, jwt.SigningMethodHS256, claims(func(c *Claims) { c.Audience = jwt.ClaimStrings{"other"} }), ""), wantErr: true},
+		{name: "no subject", token: k.sign(t, jwt.SigningMethodHS256, claims(func(c *Claims) { c.Subject = "" }), ""), wantErr: true},
+		{name: "HS384 not allowed", token: k.sign(t, jwt.SigningMethodHS384, claims(nil), ""), wantErr: true},
+		{name: "tampered", token: k.sign(t, jwt.SigningMethodHS256, claims(nil), "") + "x", wantErr: true},
+		{name: "garbage", token: "a.b.c", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := v.Verify(context.Background(), tt.token)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidToken) {
+					t.Fatalf("Verify = %+v, %v; want ErrInvalidToken", p, err)
+				}
+				return
+			}
+			if err != nil || !reflect.DeepEqual(p, tt.want) {
+				t.Fatalf("Verify = %+v, %v; want %+v", p, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadHS256Secret(t *testing.T) {
+	long := strings.Repeat("k", MinHS256SecretLen)
+	tests := []struct {
+		name    string
+		data    string
+		want    string
+		wantErr bool
+	}{
+		{"trimmed", " " + long + "\n", long, false},
+		{"empty", "", "", true},
+		{"whitespace", " \n\t\n", "", true},
+		{"short", long[1:], "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeFile(t, "secret", []byte(tt.data))
+			got, err := LoadHS256Secret(path)
+			if (err != nil) != tt.wantErr || string(got) != tt.want {
+				t.Fatalf("LoadHS256Secret = %q, %v; want %q, error %v", got, err, tt.want, tt.wantErr)
+			}
+			if _, err := NewJWTVerifier(Config{HS256Secret: path}); (err != nil) != tt.wantErr {
+				t.Fatalf("NewJWTVerifier err %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestJWKS(t *testing.T) {
+	k := newJWTKeys(t)
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc := func(pub *rsa.PublicKey, kid string) map[string]string {
+		return map[string]string{
+			"kty": "RSA", "kid": kid, "use": "sig",
+			"n": base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}
+	}
+	set, _ := json.Marshal(map[string]interface{}{"keys": []interface{}{
+		enc(&k.rsa.PublicKey, "k1"),
+		enc(&other.PublicKey, "k2"),
+		map[string]string{"kty": "EC", "kid": "ec"},
+	}})
+	v, err := NewJWTVerifier(Config{JWKSFile: writeFile(t, "jwks.json", set)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		kid     string
+		wantErr bool
+	}{
+		{"k1", false},
+		{"k2", true}, // signed with k1's key
+		{"k9", true},
+		{"", true}, // two keys, so a kid is needed
+	}
+	for _, tt := range tests {
+		_, err := v.Verify(context.Background(), k.sign(t, jwt.SigningMethodRS256, claims(nil), tt.kid))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("kid %q: err %v, want error %v", tt.kid, err, tt.wantErr)
+		}
+	}
+	if _, err := LoadJWKS(writeFile(t, "empty.json", []byte(`{"keys":[{"kty":"EC"}]}`))); err == nil {
+		t.Error("LoadJWKS accepted a set without RSA keys")
+	}
+}
+
+func newAuthenticator(t *testing.T, k *jwtKeys) *Authenticator {
+	t.Helper()
+	cfg := k.cfg
+	cfg.APIKeysFile = writeFile(t, "keys.json", []byte(`[{"key":"k1","subject":"alice"}]`))
+	cfg.Public = []string{"/Greeter/SayHello", "Health.*"}
+	a, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func TestAuthenticator(t *testing.T) {
+	k := newJWTKeys(t)
+	a := newAuthenticator(t, k)
+	tests := []struct {
+		name, token, subject string
+		want                 error
+	}{
+		{"API key", "k1", "alice", nil},
+		{"JWT", k.sign(t, jwt.SigningMethodHS256, claims(nil), ""), "bob", nil},
+		{"no token", "", "", ErrNoToken},
+		{"unknown key", "k2", "", ErrInvalidToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := a.Verify(context.Background(), tt.token)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("Verify = %v, want %v", err, tt.want)
+			}
+			if err == nil && p.Subject != tt.subject {
+				t.Fatalf("subject %q, want %q", p.Subject, tt.subject)
+			}
+		})
+	}
+}
+
+func TestIsPublic(t *testing.T) {
+	a := newAuthenticator(t, newJWTKeys(t))
+	a.AllowAnonymous(func(method string) bool { return method == "/Greeter/SayBye" })
+	tests := []struct {
+		method string
+		want   bool
+	}{
+		{"/Greeter/SayHello", true},
+		{"Greeter.SayHello", true},
+		{"/Health/Check", true},
+		{"/Greeter/SayBye", true},
+		{"/Greeter/AllStream", false},
+	}
+	for _, tt := range tests {
+		if got := a.IsPublic(tt.method); got != tt.want {
+			t.Errorf("IsPublic(%q) = %v, want %v", tt.method, got, tt.want)
+		}
+	}
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	a := newAuthenticator(t, newJWTKeys(t))
+	tests := []struct {
+		name, method string
+		md           metadata.MD
+		wantCode     codes.Code
+		wantSubject  string
+	}{
+		{"API key", "/Greeter/AllStream", metadata.Pairs("x-api-key", "k1"), codes.OK, "alice"},
+		{"legacy token key", "/Greeter/AllStream", metadata.Pairs("token", "k1"), codes.OK, "alice"},
+		{"missing token", "/Greeter/AllStream", nil, codes.Unauthenticated, ""},
+		{"bad token", "/Greeter/AllStream", metadata.Pairs("authorization", "Bearer nope"), codes.Unauthenticated, ""},
+		{"public without token", "/Greeter/SayHello", nil, codes.OK, ""},
+		{"public with a bad token", "/Greeter/SayHello", metadata.Pairs("x-api-key", "nope"), codes.Unauthenticated, ""},
+		{"public with a token", "/Greeter/SayHello", metadata.Pairs("x-api-key", "k1"), codes.OK, "alice"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.md != nil {
+				ctx = metadata.NewIncomingContext(ctx, tt.md)
+			}
+			var subject string
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				if p, ok := FromContext(ctx); ok {
+					subject = p.Subject
+				}
+				return "ok", nil
+			}
+			_, err := UnaryServerInterceptor(a)(ctx, nil, &grpc.UnaryServerInfo{FullMethod: tt.method}, handler)
+			if status.Code(err) != tt.wantCode || subject != tt.wantSubject {
+				t.Fatalf("err %v, subject %q; want %s, %q", err, subject, tt.wantCode, tt.wantSubject)
+			}
+		})
+	}
+}
+
+func TestPrincipal(t *testing.T) {
+	p := &Principal{Roles: []string{"user"}, Scopes: []string{"a"}}
+	if !p.HasRole("user") || p.HasRole("admin") || !p.HasScope("a") || p.HasScope("b") {
+		t.Fatalf("HasRole/HasScope wrong for %+v", p)
+	}
+	if _, ok := FromContext(context.Background()); ok {
+		t.Fatal("FromContext found a Principal in an empty context")
+	}
+}
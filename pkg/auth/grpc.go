@@ -0,0 +1,75 @@
+package auth
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// TokenFromMetadata returns the bearer token from the "authorization"
+// metadata key, falling back to "x-api-key" and to the plain "token" key
+// used by older clients.
+func TokenFromMetadata(md metadata.MD) string {
+	if v := md.Get("authorization"); len(v) > 0 {
+		return BearerToken(v[0])
+	}
+	if v := md.Get(APIKeyHeader); len(v) > 0 {
+		return v[0]
+	}
+	if v := md.Get("token"); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+// authenticate verifies the caller of method and returns ctx carrying the
+// Principal, or an Unauthenticated status.
+func (a *Authenticator) authenticate(ctx context.Context, method string) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	token := TokenFromMetadata(md)
+	if token == "" && a.IsPublic(method) {
+		return ctx, nil
+	}
+	p, err := a.Verify(ctx, token)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	return NewContext(ctx, p), nil
+}
+
+// UnaryServerInterceptor rejects unauthenticated calls and stores the
+// Principal in the handler's context.
+func UnaryServerInterceptor(a *Authenticator) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := a.authenticate(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor is the streaming counterpart of
+// UnaryServerInterceptor.
+func StreamServerInterceptor(a *Authenticator) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := a.authenticate(ss.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &contextStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// contextStream overrides the context of a grpc.ServerStream.
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
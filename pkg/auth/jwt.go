@@ -0,0 +1,179 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Claims are the JWT claims understood by JWTVerifier. Scopes may be given
+// either as a space-separated "scope" string or a "scp" array.
+type Claims struct {
+	jwt.RegisteredClaims
+	Roles []string `json:"roles,omitempty"`
+	Scope string   `json:"scope,omitempty"`
+	Scp   []string `json:"scp,omitempty"`
+}
+
+// JWTVerifier checks HS256 and RS256 tokens against local keys.
+type JWTVerifier struct {
+	secret  []byte
+	rsaKey  *rsa.PublicKey
+	jwks    map[string]*rsa.PublicKey // by kid
+	options []jwt.ParserOption
+}
+
+// NewJWTVerifier loads the key material named in cfg.
+func NewJWTVerifier(cfg Config) (*JWTVerifier, error) {
+	v := &JWTVerifier{
+		options: []jwt.ParserOption{
+			jwt.WithValidMethods([]string{"HS256", "RS256"}),
+			jwt.WithExpirationRequired(),
+		},
+	}
+	if cfg.Issuer != "" {
+		v.options = append(v.options, jwt.WithIssuer(cfg.Issuer))
+	}
+	if cfg.Audience != "" {
+		v.options = append(v.options, jwt.WithAudience(cfg.Audience))
+	}
+	if cfg.HS256Secret != "" {
+		secret, err := LoadHS256Secret(cfg.HS256Secret)
+		if err != nil {
+			return nil, err
+		}
+		v.secret = secret
+	}
+	if cfg.RS256KeyFile != "" {
+		pem, err := os.ReadFile(cfg.RS256KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		if v.rsaKey, err = jwt.ParseRSAPublicKeyFromPEM(pem); err != nil {
+			return nil, fmt.Errorf("auth: %s: %w", cfg.RS256KeyFile, err)
+		}
+	}
+	if cfg.JWKSFile != "" {
+		keys, err := LoadJWKS(cfg.JWKSFile)
+		if err != nil {
+			return nil, err
+		}
+		v.jwks = keys
+	}
+	return v, nil
+}
+
+// MinHS256SecretLen is the length in bytes of the shortest HS256 secret
+// LoadHS256Secret accepts.
+const MinHS256SecretLen = 32
+
+// LoadHS256Secret reads an HS256 secret from path, ignoring surrounding
+// whitespace. An empty or short secret is an error: anyone could sign
+// tokens with it.
+func LoadHS256Secret(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	secret := bytes.TrimSpace(data)
+	if len(secret) < MinHS256SecretLen {
+		return nil, fmt.Errorf("auth: %s: HS256 secret is %d bytes, need at least %d", path, len(secret), MinHS256SecretLen)
+	}
+	return secret, nil
+}
+
+// Verify implements Verifier.
+func (v *JWTVerifier) Verify(_ context.Context, token string) (*Principal, error) {
+	var claims Claims
+	if _, err := jwt.ParseWithClaims(token, &claims, v.key, v.options...); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("%w: missing sub claim", ErrInvalidToken)
+	}
+	scopes := claims.Scp
+	if claims.Scope != "" {
+		scopes = append(scopes, strings.Fields(claims.Scope)...)
+	}
+	return &Principal{Subject: claims.Subject, Roles: claims.Roles, Scopes: scopes, Via: "jwt"}, nil
+}
+
+func (v *JWTVerifier) key(t *jwt.Token) (interface{}, error) {
+	switch t.Method.Alg() {
+	case "HS256":
+		if v.secret == nil {
+			return nil, fmt.Errorf("no HS256 secret configured")
+		}
+		return v.secret, nil
+	case "RS256":
+		if kid, _ := t.Header["kid"].(string); kid != "" && v.jwks != nil {
+			if k, ok := v.jwks[kid]; ok {
+				return k, nil
+			}
+			return nil, fmt.Errorf("unknown key id %q", kid)
+		}
+		if v.rsaKey != nil {
+			return v.rsaKey, nil
+		}
+		if len(v.jwks) == 1 {
+			for _, k := range v.jwks {
+				return k, nil
+			}
+		}
+		return nil, fmt.Errorf("no RS256 key configured")
+	}
+	return nil, fmt.Errorf("unexpected signing method %s", t.Method.Alg())
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// LoadJWKS reads the RSA signing keys from a JSON Web Key Set file.
+// Keys of other types are ignored.
+func LoadJWKS(path string) (map[string]*rsa.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("auth: parse %s: %w", path, err)
+	}
+	keys := make(map[string]*rsa.PublicKey)
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("auth: %s: key %q: bad modulus: %w", path, k.Kid, err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("auth: %s: key %q: bad exponent: %w", path, k.Kid, err)
+		}
+		keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("auth: %s: no RSA signing keys", path)
+	}
+	return keys, nil
+}
@@ -0,0 +1,287 @@
+// Package authz decides whether a verified caller may invoke a method,
+// based on a role and scope policy loaded from a YAML or JSON file. It is
+// enforced by the gRPC interceptors in this package and by the hertzrpc
+// Dispatcher.
+package authz
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"awesomeproject/pkg/auth"
+	"awesomeproject/pkg/filewatch"
+	"awesomeproject/pkg/rpcname"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Default actions for methods that no rule matches.
+const (
+	Deny          = "deny"
+	Allow         = "allow"
+	Authenticated = "authenticated" // any verified caller
+)
+
+// Rule grants access to the methods matching Method.
+type Rule struct {
+	// Method is an rpcname.Match pattern, e.g. "/Greeter/SayBye",
+	// "HelloService.Hello" or "Greeter.*".
+	Method string `yaml:"method" json:"method"`
+	// Public admits anonymous callers.
+	Public bool `yaml:"public" json:"public"`
+	// Roles admits callers holding any one of them.
+	Roles []string `yaml:"roles" json:"roles"`
+	// Scopes must all have been granted to the caller.
+	Scopes []string `yaml:"scopes" json:"scopes"`
+}
+
+// Policy is the content of a policy file. Rules are tried in order and
+// the first one whose Method matches decides.
+type Policy struct {
+	Default string `yaml:"default" json:"default"`
+	Rules   []Rule `yaml:"rules" json:"rules"`
+}
+
+// Parse decodes a policy from YAML; JSON is accepted as a subset of YAML.
+func Parse(data []byte) (*Policy, error) {
+	var p Policy
+	if err := yaml.Unmarshal(data, &p); err != nil {
+		return nil, err
+	}
+	switch p.Default {
+	case "":
+		p.Default = Deny
+	case Deny, Allow, Authenticated:
+	default:
+		return nil, fmt.Errorf("authz: unknown default %q", p.Default)
+	}
+	for i, r := range p.Rules {
+		if r.Method == "" {
+			return nil, fmt.Errorf("authz: rule %d has no method", i)
+		}
+	}
+	return &p, nil
+}
+
+// Outcome of an authorization decision.
+const (
+	OutcomeAllow           = "allow"
+	OutcomeUnauthenticated = "unauthenticated"
+	OutcomeDenied          = "permission_denied"
+)
+
+// Decision is the result of Authorize.
+type Decision struct {
+	Outcome string
+	Reason  string
+	Rule    string // Method pattern of the deciding rule, or "default"
+}
+
+// Allowed reports whether the call may proceed.
+func (d Decision) Allowed() bool { return d.Outcome == OutcomeAllow }
+
+// Engine evaluates the current Policy and writes an audit record for
+// every decision. It is safe for concurrent use.
+type Engine struct {
+	mu     sync.RWMutex
+	policy *Policy
+	path   string
+	audit  *log.Logger
+}
+
+// New returns an Engine for a fixed policy.
+func New(p *Policy) *Engine {
+	return &Engine{policy: p}
+}
+
+// Load reads the policy at path. Call Watch to pick up later edits.
+func Load(path string) (*Engine, error) {
+	e := &Engine{path: path}
+	if err := e.Reload(); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Reload re-reads the policy file. On error the current policy is kept.
+func (e *Engine) Reload() error {
+	data, err := os.ReadFile(e.path)
+	if err != nil {
+		return err
+	}
+	p, err := Parse(data)
+	if err != nil {
+		return fmt.Errorf("authz: %s: %w", e.path, err)
+	}
+	e.mu.Lock()
+	e.policy = p
+	e.mu.Unlock()
+	log.Printf("authz: loaded %d rules from %s", len(p.Rules), e.path)
+	return nil
+}
+
+// Watch reloads the policy whenever its file changes, until ctx is done.
+func (e *Engine) Watch(ctx context.Context, interval time.Duration) {
+	if e.path == "" {
+		return
+	}
+	filewatch.Watch(ctx, interval, e.Reload, e.path)
+}
+
+// SetAuditLog sends one JSON line per decision to w. A nil w disables
+// auditing.
+func (e *Engine) SetAuditLog(w io.Writer) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if w == nil {
+		e.audit = nil
+		return
+	}
+	e.audit = log.New(w, "", 0)
+}
+
+// IsPublic reports whether the first rule matching method admits
+// anonymous callers. It suits auth.Authenticator.AllowAnonymous.
+func (e *Engine) IsPublic(method string) bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if r := e.policy.match(method); r != nil {
+		return r.Public
+	}
+	return e.policy.Default == Allow
+}
+
+func (p *Policy) match(method string) *Rule {
+	for i := range p.Rules {
+		if rpcname.Match(p.Rules[i].Method, method) {
+			return &p.Rules[i]
+		}
+	}
+	return nil
+}
+
+// Authorize decides whether caller, which is nil for anonymous calls, may
+// invoke method.
+func (e *Engine) Authorize(method string, caller *auth.Principal) Decision {
+	e.mu.RLock()
+	policy, audit := e.policy, e.audit
+	e.mu.RUnlock()
+
+	d := policy.decide(method, caller)
+	if audit != nil {
+		rec := auditRecord{
+			Time:    time.Now().UTC().Format(time.RFC3339Nano),
+			Method:  rpcname.Normalize(method),
+			Outcome: d.Outcome,
+			Rule:    d.Rule,
+			Reason:  d.Reason,
+		}
+		if caller != nil {
+			rec.Subject, rec.Roles = caller.Subject, caller.Roles
+		}
+		if line, err := json.Marshal(rec); err == nil {
+			audit.Print(string(line))
+		}
+	}
+	return d
+}
+
+type auditRecord struct {
+	Time    string   `json:"time"`
+	Method  string   `json:"method"`
+	Subject string   `json:"subject,omitempty"`
+	Roles   []string `json:"roles,omitempty"`
+	Outcome string   `json:"outcome"`
+	Rule    string   `json:"rule"`
+	Reason  string   `json:"reason,omitempty"`
+}
+
+func (p *Policy) decide(method string, caller *auth.Principal) Decision {
+	r := p.match(method)
+	if r == nil {
+		switch {
+		case p.Default == Allow:
+			return Decision{Outcome: OutcomeAllow, Rule: "default"}
+		case p.Default == Authenticated && caller != nil:
+			return Decision{Outcome: OutcomeAllow, Rule: "default"}
+		case caller == nil:
+			return Decision{Outcome: OutcomeUnauthenticated, Rule: "default", Reason: "authentication required"}
+		}
+		return Decision{Outcome: OutcomeDenied, Rule: "default", Reason: "no rule matches " + method}
+	}
+
+	if r.Public {
+		return Decision{Outcome: OutcomeAllow, Rule: r.Method}
+	}
+	if caller == nil {
+		return Decision{Outcome: OutcomeUnauthenticated, Rule: r.Method, Reason: "authentication required"}
+	}
+	if len(r.Roles) > 0 {
+		ok := false
+		for _, role := range r.Roles {
+			if caller.HasRole(role) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return Decision{Outcome: OutcomeDenied, Rule: r.Method, Reason: "requires role " + strings.Join(r.Roles, " or ")}
+		}
+	}
+	for _, scope := range r.Scopes {
+		if !caller.HasScope(scope) {
+			return Decision{Outcome: OutcomeDenied, Rule: r.Method, Reason: "requires scope " + scope}
+		}
+	}
+	return Decision{Outcome: OutcomeAllow, Rule: r.Method}
+}
+
+// Config names the policy file and audit log for Start.
+type Config struct {
+	PolicyFile     string
+	AuditLog       string // file to append to, "-" for stderr, empty to disable
+	ReloadInterval time.Duration
+}
+
+// RegisterFlags binds the Config fields to command-line flags on fs.
+func (c *Config) RegisterFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.PolicyFile, "authz-policy", "", "YAML or JSON method authorization policy")
+	fs.StringVar(&c.AuditLog, "authz-audit", "-", "authorization audit log file, - for stderr, empty to disable")
+	fs.DurationVar(&c.ReloadInterval, "authz-reload", filewatch.DefaultInterval, "how often to check the policy file for changes")
+}
+
+// Enabled reports whether a policy file was configured.
+func (c *Config) Enabled() bool {
+	return c.PolicyFile != ""
+}
+
+// Start loads the policy, opens the audit log and reloads the policy on
+// change until ctx is done.
+func Start(ctx context.Context, cfg Config) (*Engine, error) {
+	e, err := Load(cfg.PolicyFile)
+	if err != nil {
+		return nil, err
+	}
+	switch cfg.AuditLog {
+	case "":
+	case "-":
+		e.SetAuditLog(os.Stderr)
+	default:
+		f, err := os.OpenFile(cfg.AuditLog, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+		if err != nil {
+			return nil, err
+		}
+		context.AfterFunc(ctx, func() { _ = f.Close() })
+		e.SetAuditLog(f)
+	}
+	go e.Watch(ctx, cfg.ReloadInterval)
+	return e, nil
+}
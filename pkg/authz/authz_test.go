@@ -0,0 +1,118 @@
+package authz
+
+import (
+	"testing"
+
+	"awesomeproject/pkg/auth"
+)
+
+const testPolicy = `
+default: deny
+rules:
+  - method: Greeter.SayHello
+    public: true
+  - method: /Greeter/SayBye
+    roles: [admin]
+  - method: Greeter.*
+    roles: [user, admin]
+  - method: "*.Stats"
+    scopes: [stats:read]
+  - method: Admin.*
+    roles: [admin]
+    scopes: [admin:write]
+`
+
+func TestAuthorize(t *testing.T) {
+	p, err := Parse([]byte(testPolicy))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := New(p)
+	user := &auth.Principal{Subject: "u", Roles: []string{"user"}}
+	admin := &auth.Principal{Subject: "a", Roles: []string{"admin"}, Scopes: []string{"admin:write"}}
+	reader := &auth.Principal{Subject: "r", Scopes: []string{"stats:read"}}
+
+	tests := []struct {
+		name    string
+		method  string
+		caller  *auth.Principal
+		outcome string
+		rule    string
+	}{
+		{"public rule admits anonymous", "/Greeter/SayHello", nil, OutcomeAllow, "Greeter.SayHello"},
+		{"first match wins over wildcard", "Greeter.SayBye", user, OutcomeDenied, "/Greeter/SayBye"},
+		{"first match admits its role", "Greeter.SayBye", admin, OutcomeAllow, "/Greeter/SayBye"},
+		{"service wildcard", "/Greeter/AllStream", user, OutcomeAllow, "Greeter.*"},
+		{"anonymous needs authentication", "Greeter.AllStream", nil, OutcomeUnauthenticated, "Greeter.*"},
+		{"service wildcard shadows method wildcard", "Greeter.Stats", reader, OutcomeDenied, "Greeter.*"},
+		{"method wildcard with scope", "Metrics.Stats", reader, OutcomeAllow, "*.Stats"},
+		{"method wildcard missing scope", "Metrics.Stats", user, OutcomeDenied, "*.Stats"},
+		{"role and scope both required", "Admin.Reset", &auth.Principal{Roles: []string{"admin"}}, OutcomeDenied, "Admin.*"},
+		{"role and scope granted", "Admin.Reset", admin, OutcomeAllow, "Admin.*"},
+		{"default deny", "Other.Call", admin, OutcomeDenied, "default"},
+		{"default deny anonymous", "Other.Call", nil, OutcomeUnauthenticated, "default"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := e.Authorize(tt.method, tt.caller)
+			if d.Outcome != tt.outcome || d.Rule != tt.rule {
+				t.Fatalf("Authorize(%q) = %s by %q (%s), want %s by %q", tt.method, d.Outcome, d.Rule, d.Reason, tt.outcome, tt.rule)
+			}
+		})
+	}
+}
+
+func TestDefaults(t *testing.T) {
+	caller := &auth.Principal{Subject: "u"}
+	tests := []struct {
+		def     string
+		caller  *auth.Principal
+		outcome string
+	}{
+		{Allow, nil, OutcomeAllow},
+		{Authenticated, nil, OutcomeUnauthenticated},
+		{Authenticated, caller, OutcomeAllow},
+		{Deny, caller, OutcomeDenied},
+	}
+	for _, tt := range tests {
+		e := New(&Policy{Default: tt.def})
+		if d := e.Authorize("Greeter.SayHello", tt.caller); d.Outcome != tt.outcome {
+			t.Errorf("default %s, caller %v: outcome %s, want %s", tt.def, tt.caller != nil, d.Outcome, tt.outcome)
+		}
+	}
+}
+
+func TestIsPublic(t *testing.T) {
+	p, err := Parse([]byte(testPolicy))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := New(p)
+	tests := []struct {
+		method string
+		want   bool
+	}{
+		{"Greeter.SayHello", true},
+		{"/Greeter/SayHello", true},
+		{"Greeter.SayBye", false},
+		{"Other.Call", false},
+	}
+	for _, tt := range tests {
+		if got := e.IsPublic(tt.method); got != tt.want {
+			t.Errorf("IsPublic(%q) = %v, want %v", tt.method, got, tt.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []string{
+		"default: maybe",
+		"rules:\n  - roles: [admin]",
+		"rules: [",
+	}
+	for _, in := range tests {
+		if _, err := Parse([]byte(in)); err == nil {
+			t.Errorf("Parse(%q) succeeded", in)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package authz
+
+import (
+	"context"
+
+	"awesomeproject/pkg/auth"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// check maps a Decision onto a gRPC status. It must run after the auth
+// interceptor so the Principal is in ctx.
+func (e *Engine) check(ctx context.Context, method string) error {
+	caller, _ := auth.FromContext(ctx)
+	d := e.Authorize(method, caller)
+	switch d.Outcome {
+	case OutcomeAllow:
+		return nil
+	case OutcomeUnauthenticated:
+		return status.Error(codes.Unauthenticated, d.Reason)
+	}
+	return status.Errorf(codes.PermissionDenied, "%s: %s", method, d.Reason)
+}
+
+// UnaryServerInterceptor enforces the policy on unary calls.
+func UnaryServerInterceptor(e *Engine) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := e.check(ctx, info.FullMethod); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor enforces the policy when a stream is opened.
+func StreamServerInterceptor(e *Engine) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := e.check(ss.Context(), info.FullMethod); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
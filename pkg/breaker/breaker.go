@@ -0,0 +1,345 @@
+// Package breaker implements per-method circuit breakers shared by the
+// gRPC client interceptors in this package and the hertzrpc Client
+// (hertzrpc.BreakerInterceptor).
+//
+// A breaker starts closed. When, within Window, at least MinRequests calls
+// were made and the share of failed (or, with SlowThreshold, slow) calls
+// reaches its limit, it opens and fails calls at once with
+// codes.Unavailable. After OpenTimeout it lets HalfOpenRequests trial
+// calls through: if all succeed it closes, if any fails it opens again.
+// Trials that have not reported within another OpenTimeout are written
+// off and new ones let through, so a lost call cannot hold it half-open.
+package breaker
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// State is the position of a breaker.
+type State int
+
+const (
+	Closed State = iota
+	Open
+	HalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half-open"
+	}
+	return fmt.Sprintf("State(%d)", int(s))
+}
+
+// Event reports that the breaker of Method moved from From to To.
+type Event struct {
+	Method   string
+	From, To State
+	// Reason says what caused the change, e.g. "failure rate 60% over 20 calls".
+	Reason string
+	At     time.Time
+}
+
+func (e Event) String() string {
+	return fmt.Sprintf("breaker %s: %s -> %s (%s)", e.Method, e.From, e.To, e.Reason)
+}
+
+// Config sets the thresholds of every breaker in a Group.
+type Config struct {
+	// Window is the span over which call outcomes are counted.
+	Window time.Duration
+	// MinRequests is how many calls Window must hold before the breaker
+	// may trip, so that a single early failure does not open it.
+	MinRequests int
+	// FailureRate trips the breaker when this share of calls failed
+	// (0 disables).
+	FailureRate float64
+	// SlowThreshold marks calls taking longer as slow (0 disables), and
+	// SlowRate trips the breaker when this share of calls was slow.
+	SlowThreshold time.Duration
+	SlowRate      float64
+	// OpenTimeout is how long the breaker stays open before trying calls.
+	OpenTimeout time.Duration
+	// HalfOpenRequests is how many trial calls must succeed to close.
+	HalfOpenRequests int
+	// OnStateChange, if set, is called on every transition. It must not
+	// block; the breaker's lock is not held.
+	OnStateChange func(Event)
+}
+
+// DefaultConfig returns the thresholds used by the command-line flags.
+func DefaultConfig() Config {
+	return Config{
+		Window:           10 * time.Second,
+		MinRequests:      10,
+		FailureRate:      0.5,
+		SlowRate:         0.5,
+		OpenTimeout:      5 * time.Second,
+		HalfOpenRequests: 3,
+	}
+}
+
+// RegisterFlags binds the Config fields to command-line flags on fs, with
+// the current values as defaults.
+func (c *Config) RegisterFlags(fs *flag.FlagSet) {
+	fs.Float64Var(&c.FailureRate, "breaker-failure-rate", c.FailureRate, "share of failed calls that opens the circuit (0 disables the breaker)")
+	fs.DurationVar(&c.SlowThreshold, "breaker-slow", c.SlowThreshold, "calls slower than this count as slow (0 disables)")
+	fs.Float64Var(&c.SlowRate, "breaker-slow-rate", c.SlowRate, "share of slow calls that opens the circuit")
+	fs.DurationVar(&c.Window, "breaker-window", c.Window, "span over which call outcomes are counted")
+	fs.IntVar(&c.MinRequests, "breaker-min-requests", c.MinRequests, "calls needed in the window before the circuit may open")
+	fs.DurationVar(&c.OpenTimeout, "breaker-open-timeout", c.OpenTimeout, "how long the circuit stays open before trial calls")
+	fs.IntVar(&c.HalfOpenRequests, "breaker-half-open", c.HalfOpenRequests, "trial calls that must succeed to close the circuit")
+}
+
+// Enabled reports whether any threshold can trip a breaker.
+func (c *Config) Enabled() bool {
+	return c.FailureRate > 0 || c.SlowThreshold > 0
+}
+
+// LogEvents returns an OnStateChange func writing each Event to logger, or
+// to the standard logger if logger is nil.
+func LogEvents(logger *log.Logger) func(Event) {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return func(e Event) { logger.Print(e) }
+}
+
+// OpenError is returned for calls refused by an open breaker. Its gRPC
+// status is Unavailable with a RetryInfo detail.
+type OpenError struct {
+	Method     string
+	RetryAfter time.Duration
+}
+
+func (e *OpenError) Error() string {
+	return fmt.Sprintf("breaker: circuit open for %s, retry after %v", e.Method, e.RetryAfter.Round(time.Millisecond))
+}
+
+// GRPCStatus lets status.Code and status.FromError see the error as
+// codes.Unavailable.
+func (e *OpenError) GRPCStatus() *status.Status {
+	st := status.New(codes.Unavailable, e.Error())
+	if withInfo, err := st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(e.RetryAfter)}); err == nil {
+		st = withInfo
+	}
+	return st
+}
+
+// buckets is how many slices Window is counted in; outcomes expire one
+// slice at a time.
+const buckets = 10
+
+type bucket struct {
+	start               time.Time
+	calls, failed, slow int
+}
+
+// Breaker guards one method. It is safe for concurrent use.
+type Breaker struct {
+	method string
+	cfg    *Config
+
+	mu       sync.Mutex
+	state    State
+	window   [buckets]bucket
+	openedAt time.Time // when it opened, or when the current trials began
+	trials   int       // half-open calls let through
+	passed   int       // half-open calls that succeeded
+	gen      uint64    // counts transitions, to ignore calls admitted before one
+	now      func() time.Time
+}
+
+// Allow asks to make a call. If the breaker refuses, it returns an
+// *OpenError; otherwise the caller must report the outcome with done.
+func (b *Breaker) Allow() (done func(failed bool, elapsed time.Duration), err error) {
+	var ev *Event
+	defer func() { b.emit(ev) }()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.now()
+	if b.state == Open {
+		wait := b.cfg.OpenTimeout - now.Sub(b.openedAt)
+		if wait > 0 {
+			return nil, &OpenError{Method: b.method, RetryAfter: wait}
+		}
+		ev = b.setState(HalfOpen, now, "open timeout elapsed")
+	}
+	if b.state == HalfOpen {
+		if b.trials >= b.cfg.HalfOpenRequests {
+			wait := b.cfg.OpenTimeout - now.Sub(b.openedAt)
+			if wait > 0 {
+				return nil, &OpenError{Method: b.method, RetryAfter: wait}
+			}
+			// The trials never reported, e.g. abandoned streams
+			b.gen++
+			b.trials, b.passed = 0, 0
+			b.openedAt = now
+		}
+		b.trials++
+	}
+	gen := b.gen
+	return func(failed bool, elapsed time.Duration) { b.done(gen, failed, elapsed) }, nil
+}
+
+func (b *Breaker) done(gen uint64, failed bool, elapsed time.Duration) {
+	var ev *Event
+	defer func() { b.emit(ev) }()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.now()
+	slow := b.cfg.SlowThreshold > 0 && elapsed > b.cfg.SlowThreshold
+	if gen != b.gen {
+		return // admitted before the last transition
+	}
+	switch b.state {
+	case HalfOpen:
+		if failed || slow {
+			ev = b.setState(Open, now, "trial call failed")
+			return
+		}
+		if b.passed++; b.passed >= b.cfg.HalfOpenRequests {
+			ev = b.setState(Closed, now, fmt.Sprintf("%d trial calls succeeded", b.passed))
+		}
+	case Closed:
+		bk := b.current(now)
+		bk.calls++
+		if failed {
+			bk.failed++
+		}
+		if slow {
+			bk.slow++
+		}
+		if reason := b.tripped(now); reason != "" {
+			ev = b.setState(Open, now, reason)
+		}
+	}
+}
+
+// current returns the bucket for now, reusing the oldest slot once its
+// slice of the window has passed.
+func (b *Breaker) current(now time.Time) *bucket {
+	slice := b.cfg.Window / buckets
+	if slice <= 0 {
+		slice = time.Millisecond
+	}
+	start := now.Truncate(slice)
+	bk := &b.window[int(start.UnixNano()/int64(slice))%buckets]
+	if !bk.start.Equal(start) {
+		*bk = bucket{start: start}
+	}
+	return bk
+}
+
+// tripped returns why the closed breaker should open, or "".
+func (b *Breaker) tripped(now time.Time) string {
+	var calls, failed, slow int
+	for _, bk := range b.window {
+		if now.Sub(bk.start) < b.cfg.Window {
+			calls, failed, slow = calls+bk.calls, failed+bk.failed, slow+bk.slow
+		}
+	}
+	if calls == 0 || calls < b.cfg.MinRequests {
+		return ""
+	}
+	if rate := float64(failed) / float64(calls); b.cfg.FailureRate > 0 && rate >= b.cfg.FailureRate {
+		return fmt.Sprintf("failure rate %.0f%% over %d calls", 100*rate, calls)
+	}
+	if rate := float64(slow) / float64(calls); b.cfg.SlowThreshold > 0 && rate >= b.cfg.SlowRate {
+		return fmt.Sprintf("slow call rate %.0f%% over %d calls", 100*rate, calls)
+	}
+	return ""
+}
+
+// setState moves to s and returns the Event to emit once b.mu is
+// released. The caller must hold b.mu.
+func (b *Breaker) setState(s State, now time.Time, reason string) *Event {
+	ev := &Event{Method: b.method, From: b.state, To: s, Reason: reason, At: now}
+	b.state = s
+	b.gen++
+	b.trials, b.passed = 0, 0
+	switch s {
+	case Open, HalfOpen:
+		b.openedAt = now
+	case Closed:
+		b.window = [buckets]bucket{}
+	}
+	return ev
+}
+
+func (b *Breaker) emit(ev *Event) {
+	if ev != nil && b.cfg.OnStateChange != nil {
+		b.cfg.OnStateChange(*ev)
+	}
+}
+
+// State returns the current state, without moving an open breaker whose
+// timeout has elapsed to half-open; the next Allow does that.
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// Group holds one Breaker per method. It is safe for concurrent use.
+type Group struct {
+	cfg Config
+	now func() time.Time
+
+	mu       sync.Mutex
+	breakers map[string]*Breaker
+}
+
+// New returns a Group whose breakers use cfg.
+func New(cfg Config) *Group {
+	if cfg.Window <= 0 {
+		cfg.Window = DefaultConfig().Window
+	}
+	if cfg.HalfOpenRequests <= 0 {
+		cfg.HalfOpenRequests = 1
+	}
+	return &Group{cfg: cfg, now: time.Now, breakers: make(map[string]*Breaker)}
+}
+
+// Get returns the Breaker for method, creating it closed.
+func (g *Group) Get(method string) *Breaker {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	b, ok := g.breakers[method]
+	if !ok {
+		b = &Breaker{method: method, cfg: &g.cfg, now: g.now}
+		g.breakers[method] = b
+	}
+	return b
+}
+
+// States returns the state of every breaker created so far.
+func (g *Group) States() map[string]State {
+	g.mu.Lock()
+	breakers := make([]*Breaker, 0, len(g.breakers))
+	for _, b := range g.breakers {
+		breakers = append(breakers, b)
+	}
+	g.mu.Unlock()
+	states := make(map[string]State, len(breakers))
+	for _, b := range breakers {
+		states[b.method] = b.State()
+	}
+	return states
+}
@@ -0,0 +1,190 @@
+package breaker
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+type clock struct{ t time.Time }
+
+func (c *clock) now() time.Time { return c.t }
+
+func newTestGroup(cfg Config) (*Group, *clock) {
+	c := &clock{t: time.Unix(1700000000, 0)}
+	g := New(cfg)
+	g.now = c.now
+	return g, c
+}
+
+var testConfig = Config{
+	Window:           10 * time.Second,
+	MinRequests:      4,
+	FailureRate:      0.5,
+	SlowThreshold:    time.Second,
+	SlowRate:         0.75,
+	OpenTimeout:      5 * time.Second,
+	HalfOpenRequests: 2,
+}
+
+// op is one step of a breaker scenario.
+type op struct {
+	advance time.Duration
+	// calls to make, each reporting failed and elapsed; a refused call
+	// fails the test unless refused is set
+	calls   int
+	failed  bool
+	elapsed time.Duration
+	refused bool
+	// pending admits calls and keeps their done funcs for a later op
+	pending int
+	// finish reports the outcome of the pending calls
+	finish bool
+	want   State
+}
+
+func TestBreaker(t *testing.T) {
+	tests := []struct {
+		name string
+		ops  []op
+	}{
+		{"stays closed below min requests", []op{
+			{calls: 3, failed: true, want: Closed},
+		}},
+		{"opens at the failure rate", []op{
+			{calls: 2, want: Closed},
+			{calls: 2, failed: true, want: Open},
+			{refused: true, calls: 1, want: Open},
+		}},
+		{"stays closed under the failure rate", []op{
+			{calls: 3, want: Closed},
+			{calls: 2, failed: true, want: Closed},
+		}},
+		{"opens at the slow rate", []op{
+			{calls: 1, want: Closed},
+			{calls: 3, elapsed: 2 * time.Second, want: Open},
+		}},
+		{"old outcomes leave the window", []op{
+			{calls: 2, failed: true, want: Closed},
+			{advance: 11 * time.Second, calls: 2, want: Closed},
+			{calls: 1, failed: true, want: Closed},
+		}},
+		{"half-open closes after trials succeed", []op{
+			{calls: 4, failed: true, want: Open},
+			{advance: 5 * time.Second, calls: 1, want: HalfOpen},
+			{calls: 1, want: Closed},
+		}},
+		{"half-open reopens on a failed trial", []op{
+			{calls: 4, failed: true, want: Open},
+			{advance: 5 * time.Second, calls: 1, failed: true, want: Open},
+			{refused: true, calls: 1, want: Open},
+		}},
+		{"half-open reopens on a slow trial", []op{
+			{calls: 4, failed: true, want: Open},
+			{advance: 5 * time.Second, calls: 1, elapsed: 2 * time.Second, want: Open},
+		}},
+		{"half-open admits only its trials", []op{
+			{calls: 4, failed: true, want: Open},
+			{advance: 5 * time.Second, pending: 2, want: HalfOpen},
+			{refused: true, calls: 1, want: HalfOpen},
+			{finish: true, want: Closed},
+		}},
+		{"lost trials expire after the open timeout", []op{
+			{calls: 4, failed: true, want: Open},
+			{advance: 5 * time.Second, pending: 2, want: HalfOpen},
+			{advance: 4 * time.Second, refused: true, calls: 1, want: HalfOpen},
+			{advance: time.Second, calls: 2, want: Closed},
+			// the written-off trials report too late to count
+			{finish: true, want: Closed},
+		}},
+		{"calls admitted before opening are ignored", []op{
+			{pending: 2, want: Closed},
+			{calls: 4, failed: true, want: Open},
+			{finish: true, want: Open},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, c := newTestGroup(testConfig)
+			b := g.Get("Svc.M")
+			var pending []func(bool, time.Duration)
+			for i, o := range tt.ops {
+				c.t = c.t.Add(o.advance)
+				for j := 0; j < o.calls; j++ {
+					done, err := b.Allow()
+					if o.refused {
+						var open *OpenError
+						if !errors.As(err, &open) {
+							t.Fatalf("op %d: Allow = %v, want *OpenError", i, err)
+						}
+						continue
+					}
+					if err != nil {
+						t.Fatalf("op %d call %d: %v", i, j, err)
+					}
+					done(o.failed, o.elapsed)
+				}
+				for j := 0; j < o.pending; j++ {
+					done, err := b.Allow()
+					if err != nil {
+						t.Fatalf("op %d pending %d: %v", i, j, err)
+					}
+					pending = append(pending, done)
+				}
+				if o.finish {
+					for _, done := range pending {
+						done(false, 0)
+					}
+					pending = nil
+				}
+				if got := b.State(); got != o.want {
+					t.Fatalf("op %d: state %s, want %s", i, got, o.want)
+				}
+			}
+		})
+	}
+}
+
+func TestOpenErrorRetryAfter(t *testing.T) {
+	g, c := newTestGroup(testConfig)
+	b := g.Get("Svc.M")
+	for i := 0; i < 4; i++ {
+		done, _ := b.Allow()
+		done(true, 0)
+	}
+	c.t = c.t.Add(2 * time.Second)
+	_, err := b.Allow()
+	var open *OpenError
+	if !errors.As(err, &open) || open.RetryAfter != 3*time.Second {
+		t.Fatalf("Allow = %v, want retry after 3s", err)
+	}
+}
+
+func TestEvents(t *testing.T) {
+	var events []Event
+	cfg := testConfig
+	cfg.OnStateChange = func(e Event) { events = append(events, e) }
+	g, c := newTestGroup(cfg)
+	b := g.Get("Svc.M")
+	for i := 0; i < 4; i++ {
+		done, _ := b.Allow()
+		done(true, 0)
+	}
+	c.t = c.t.Add(5 * time.Second)
+	for i := 0; i < 2; i++ {
+		done, _ := b.Allow()
+		done(false, 0)
+	}
+	want := []struct{ from, to State }{{Closed, Open}, {Open, HalfOpen}, {HalfOpen, Closed}}
+	if len(events) != len(want) {
+		t.Fatalf("events %v, want %d", events, len(want))
+	}
+	for i, w := range want {
+		if events[i].From != w.from || events[i].To != w.to || events[i].Method != "Svc.M" {
+			t.Errorf("event %d = %v, want %s -> %s", i, events[i], w.from, w.to)
+		}
+	}
+	if s := g.States()["Svc.M"]; s != Closed {
+		t.Errorf("States = %s, want closed", s)
+	}
+}
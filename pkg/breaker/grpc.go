@@ -0,0 +1,89 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// GRPCFailure reports whether err counts against the breaker: codes that
+// suggest the server or the network is in trouble. Errors the caller
+// caused, such as InvalidArgument or its own cancellation, do not.
+func GRPCFailure(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted,
+		codes.Internal, codes.Unknown, codes.DataLoss:
+		return true
+	}
+	return false
+}
+
+// UnaryClientInterceptor guards each method with its breaker in g,
+// failing fast with codes.Unavailable while the circuit is open.
+func UnaryClientInterceptor(g *Group) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		done, err := g.Get(method).Allow()
+		if err != nil {
+			return err
+		}
+		start := time.Now()
+		err = invoker(ctx, method, req, reply, cc, opts...)
+		done(GRPCFailure(err), time.Since(start))
+		return err
+	}
+}
+
+// StreamClientInterceptor guards opening a stream and counts the status it
+// ends with. Streams live as long as their caller wants, so they are never
+// counted as slow. A stream the caller abandons is counted when the
+// caller's context ends, so that it cannot hold a half-open trial.
+func StreamClientInterceptor(g *Group) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		done, err := g.Get(method).Allow()
+		if err != nil {
+			return nil, err
+		}
+		cs, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			done(GRPCFailure(err), 0)
+			return nil, err
+		}
+		s := &guardedStream{ClientStream: cs, done: done, single: !desc.ServerStreams}
+		// The caller's context rather than cs.Context(): gRPC cancels the
+		// latter as the stream ends, before RecvMsg returns its status
+		s.stop = context.AfterFunc(ctx, func() {
+			s.finish(status.FromContextError(ctx.Err()).Err())
+		})
+		return s, nil
+	}
+}
+
+type guardedStream struct {
+	grpc.ClientStream
+	done   func(failed bool, elapsed time.Duration)
+	single bool // one reply: the stream ends with the first RecvMsg
+	once   sync.Once
+	stop   func() bool // cancels the context.AfterFunc
+}
+
+func (s *guardedStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	if err != nil || s.single {
+		s.stop()
+		s.finish(err)
+	}
+	return err
+}
+
+// finish reports the stream's outcome once, however it ended.
+func (s *guardedStream) finish(err error) {
+	s.once.Do(func() {
+		s.done(!errors.Is(err, io.EOF) && GRPCFailure(err), 0)
+	})
+}
@@ -0,0 +1,126 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestGRPCFailure(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{status.Error(codes.Unavailable, ""), true},
+		{status.Error(codes.DeadlineExceeded, ""), true},
+		{status.Error(codes.ResourceExhausted, ""), true},
+		{status.Error(codes.Internal, ""), true},
+		{errors.New("plain"), true}, // codes.Unknown
+		{status.Error(codes.InvalidArgument, ""), false},
+		{status.Error(codes.NotFound, ""), false},
+		{status.Error(codes.Canceled, ""), false},
+		{status.Error(codes.PermissionDenied, ""), false},
+	}
+	for _, tt := range tests {
+		if got := GRPCFailure(tt.err); got != tt.want {
+			t.Errorf("GRPCFailure(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+// fakeStream ends with err on the first RecvMsg.
+type fakeStream struct {
+	grpc.ClientStream
+	err error
+}
+
+func (s *fakeStream) RecvMsg(interface{}) error { return s.err }
+
+// halfOpen returns a breaker for "/Svc/M" waiting for its one trial call.
+func halfOpen(t *testing.T) (*Group, *Breaker) {
+	t.Helper()
+	cfg := testConfig
+	cfg.HalfOpenRequests = 1
+	g, c := newTestGroup(cfg)
+	b := g.Get("/Svc/M")
+	for i := 0; i < 4; i++ {
+		done, _ := b.Allow()
+		done(true, 0)
+	}
+	c.t = c.t.Add(cfg.OpenTimeout)
+	return g, b
+}
+
+func TestStreamClientInterceptor(t *testing.T) {
+	tests := []struct {
+		name string
+		// recv ends the stream by reading it; otherwise the caller
+		// abandons it and cancels its context
+		recv bool
+		err  error
+		want State
+	}{
+		{"read to EOF", true, io.EOF, Closed},
+		{"ends with a failure", true, status.Error(codes.Unavailable, "down"), Open},
+		{"ends with a caller error", true, status.Error(codes.InvalidArgument, "bad"), Closed},
+		{"abandoned", false, nil, Closed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, b := halfOpen(t)
+			streamer := func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+				return &fakeStream{err: tt.err}, nil
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			cs, err := StreamClientInterceptor(g)(ctx, &grpc.StreamDesc{ServerStreams: true}, nil, "/Svc/M", streamer)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := b.Allow(); err == nil {
+				t.Fatal("second trial admitted while the stream is open")
+			}
+			if tt.recv {
+				_ = cs.RecvMsg(nil)
+			} else {
+				cancel()
+			}
+			deadline := time.Now().Add(time.Second)
+			for b.State() != tt.want {
+				if time.Now().After(deadline) {
+					t.Fatalf("state %s, want %s", b.State(), tt.want)
+				}
+				time.Sleep(time.Millisecond)
+			}
+			// Cancelling later must not count the stream twice
+			cancel()
+			time.Sleep(5 * time.Millisecond)
+			if got := b.State(); got != tt.want {
+				t.Fatalf("state moved to %s after cancel", got)
+			}
+		})
+	}
+}
+
+func TestUnaryClientInterceptor(t *testing.T) {
+	g, b := halfOpen(t)
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		return status.Error(codes.Unavailable, "down")
+	}
+	err := UnaryClientInterceptor(g)(context.Background(), "/Svc/M", nil, nil, nil, invoker)
+	if status.Code(err) != codes.Unavailable || b.State() != Open {
+		t.Fatalf("err %v, state %s; want Unavailable and open", err, b.State())
+	}
+	err = UnaryClientInterceptor(g)(context.Background(), "/Svc/M", nil, nil, nil, invoker)
+	var open *OpenError
+	if !errors.As(err, &open) || status.Code(err) != codes.Unavailable {
+		t.Fatalf("err %v, want *OpenError with codes.Unavailable", err)
+	}
+}
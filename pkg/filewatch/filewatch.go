@@ -0,0 +1,65 @@
+// Package filewatch polls files for changes. Polling keeps working when
+// editors or secret managers replace a file by renaming a new one over it.
+package filewatch
+
+import (
+	"context"
+	"log"
+	"os"
+	"time"
+)
+
+// DefaultInterval is used when Watch is given a non-positive interval.
+const DefaultInterval = 2 * time.Second
+
+type stamp struct {
+	mod  time.Time
+	size int64
+}
+
+func stat(path string) (stamp, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return stamp{}, err
+	}
+	return stamp{fi.ModTime(), fi.Size()}, nil
+}
+
+// Watch calls onChange each time the modification time or size of any of
+// paths changes, until ctx is done. It blocks, so run it in a goroutine.
+// Errors returned by onChange are logged and the previous state is kept
+// by the caller.
+func Watch(ctx context.Context, interval time.Duration, onChange func() error, paths ...string) {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	last := make([]stamp, len(paths))
+	for i, p := range paths {
+		last[i], _ = stat(p)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		changed := false
+		for i, p := range paths {
+			st, err := stat(p)
+			if err != nil {
+				continue // mid-replace; try again next tick
+			}
+			if st != last[i] {
+				last[i], changed = st, true
+			}
+		}
+		if changed {
+			if err := onChange(); err != nil {
+				log.Printf("filewatch: reload %v: %v", paths, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package filewatch
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatch(t *testing.T) {
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a"), filepath.Join(dir, "b")
+	write := func(path, data string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(a, "1")
+	write(b, "1")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	changes := make(chan struct{}, 10)
+	stopped := make(chan struct{})
+	go func() {
+		Watch(ctx, 5*time.Millisecond, func() error {
+			changes <- struct{}{}
+			return errors.New("bad file") // logged; watching goes on
+		}, a, b)
+		close(stopped)
+	}()
+	expect := func(want bool) {
+		t.Helper()
+		select {
+		case <-changes:
+			if !want {
+				t.Fatal("onChange ran without a change")
+			}
+		case <-time.After(100 * time.Millisecond):
+			if want {
+				t.Fatal("onChange did not run")
+			}
+		}
+	}
+
+	steps := []struct {
+		name   string
+		edit   func()
+		change bool
+	}{
+		{"nothing", func() {}, false},
+		{"grow a", func() { write(a, "22") }, true},
+		{"grow b", func() { write(b, "22") }, true},
+		{"remove a", func() { os.Remove(a) }, false},
+		{"replace a", func() {
+			tmp := filepath.Join(dir, "a.tmp")
+			write(tmp, "333")
+			os.Rename(tmp, a)
+		}, true},
+	}
+	for _, s := range steps {
+		s.edit()
+		t.Log(s.name)
+		expect(s.change)
+	}
+
+	cancel()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("Watch did not return after cancel")
+	}
+}
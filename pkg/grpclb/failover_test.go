@@ -0,0 +1,140 @@
+package grpclb
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	pb "awesomeproject/proto"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/peer"
+)
+
+// replica is an in-process Greeter backend with its own health server.
+type replica struct {
+	pb.UnimplementedGreeterServer
+	addr   string
+	server *grpc.Server
+	health *health.Server
+}
+
+func (r *replica) SayHello(_ context.Context, req *pb.HelloRequest) (*pb.HelloReply, error) {
+	return &pb.HelloReply{Message: "Hello " + req.GetName()}, nil
+}
+
+func startReplica(t *testing.T) *replica {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &replica{addr: l.Addr().String(), server: grpc.NewServer(), health: health.NewServer()}
+	pb.RegisterGreeterServer(r.server, r)
+	healthpb.RegisterHealthServer(r.server, r.health)
+	r.health.SetServingStatus(pb.Greeter_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	go r.server.Serve(l)
+	t.Cleanup(r.server.Stop)
+	return r
+}
+
+// call makes one SayHello and returns the address that served it.
+func call(t *testing.T, client pb.GreeterClient) string {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	var p peer.Peer
+	if _, err := client.SayHello(ctx, &pb.HelloRequest{Name: "lb"}, grpc.Peer(&p)); err != nil {
+		t.Fatalf("SayHello: %v", err)
+	}
+	return p.Addr.String()
+}
+
+// settle calls until n calls in a row are served by want alone, so the
+// client has seen a change in health or connectivity.
+func settle(t *testing.T, client pb.GreeterClient, n int, want map[string]bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for run := 0; run < n; {
+		if time.Now().After(deadline) {
+			t.Fatalf("calls still reach backends outside %v", want)
+		}
+		if want[call(t, client)] {
+			run++
+		} else {
+			run = 0
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+}
+
+const retryConfig = `{"methodConfig": [{
+	"name": [{"service": "Greeter"}],
+	"retryPolicy": {
+		"maxAttempts": 3,
+		"initialBackoff": "0.01s",
+		"maxBackoff": "0.1s",
+		"backoffMultiplier": 2,
+		"retryableStatusCodes": ["UNAVAILABLE"]
+	}
+}]}`
+
+func TestFailover(t *testing.T) {
+	for _, policy := range []string{RoundRobin, LeastRequest} {
+		t.Run(policy, func(t *testing.T) {
+			r1, r2, r3 := startReplica(t), startReplica(t), startReplica(t)
+			// Calls caught on a replica as it dies are retried elsewhere,
+			// as the retryPolicy in config/grpcclient.service.json does.
+			sc, err := WithBalancing(retryConfig, policy, pb.Greeter_ServiceDesc.ServiceName)
+			if err != nil {
+				t.Fatal(err)
+			}
+			conn, err := grpc.NewClient(Target(r1.addr+","+r2.addr+","+r3.addr),
+				grpc.WithTransportCredentials(insecure.NewCredentials()),
+				grpc.WithDefaultServiceConfig(sc),
+				Resolvers())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			client := pb.NewGreeterClient(conn)
+
+			// Every replica takes calls while all are healthy.
+			all := map[string]bool{r1.addr: true, r2.addr: true, r3.addr: true}
+			seen := map[string]bool{}
+			deadline := time.Now().Add(5 * time.Second)
+			for len(seen) < len(all) {
+				if time.Now().After(deadline) {
+					t.Fatalf("only %v served calls", seen)
+				}
+				seen[call(t, client)] = true
+			}
+
+			// A NOT_SERVING replica gets no more calls.
+			r2.health.SetServingStatus(pb.Greeter_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_NOT_SERVING)
+			settle(t, client, 10, map[string]bool{r1.addr: true, r3.addr: true})
+
+			// A killed replica's calls move to the one left; none fail.
+			r3.server.Stop()
+			settle(t, client, 10, map[string]bool{r1.addr: true})
+			for i := 0; i < 20; i++ {
+				if got := call(t, client); got != r1.addr {
+					t.Fatalf("call %d served by %s, want %s", i, got, r1.addr)
+				}
+			}
+
+			// A replica that recovers takes calls again.
+			r2.health.SetServingStatus(pb.Greeter_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+			deadline = time.Now().Add(5 * time.Second)
+			for call(t, client) != r2.addr {
+				if time.Now().After(deadline) {
+					t.Fatal("recovered replica gets no calls")
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+// Package grpclb spreads gRPC client calls over several backends: a
+// least-request balancer, resolvers for fixed and file-listed addresses,
+// and the service config that ties them to health checking.
+package grpclb
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/balancer/pickfirst"
+	"google.golang.org/grpc/balancer/roundrobin"
+	_ "google.golang.org/grpc/health" // client-side health checking
+)
+
+// Balancing policies accepted by Target and ServiceConfig.
+const (
+	PickFirst    = pickfirst.Name
+	RoundRobin   = roundrobin.Name
+	LeastRequest = "least_request"
+)
+
+func init() {
+	balancer.Register(leastRequestBalancer{})
+}
+
+// Target turns addr into a gRPC target. A comma-separated list of
+// host:port becomes a static:/// target; anything with a scheme, or a
+// single address, is returned unchanged.
+func Target(addr string) string {
+	if strings.Contains(addr, "://") || !strings.Contains(addr, ",") {
+		return addr
+	}
+	return StaticScheme + ":///" + addr
+}
+
+// ServiceConfig returns a service config selecting policy and, unless
+// healthService is empty, checking grpc.health.v1 for healthService on
+// every backend so that NOT_SERVING ones get no calls.
+func ServiceConfig(policy, healthService string) (string, error) {
+	return WithBalancing("{}", policy, healthService)
+}
+
+// WithBalancing adds policy and health checking to the service config
+// JSON sc, keeping any loadBalancingConfig or healthCheckConfig it already
+// has.
+func WithBalancing(sc, policy, healthService string) (string, error) {
+	switch policy {
+	case PickFirst, RoundRobin, LeastRequest:
+	default:
+		return "", fmt.Errorf("unknown balancing policy %q: want %s, %s or %s", policy, PickFirst, RoundRobin, LeastRequest)
+	}
+	var m map[string]any
+	if err := json.Unmarshal([]byte(sc), &m); err != nil {
+		return "", err
+	}
+	if _, ok := m["loadBalancingConfig"]; !ok {
+		m["loadBalancingConfig"] = []any{map[string]any{policy: map[string]any{}}}
+	}
+	if _, ok := m["healthCheckConfig"]; !ok && healthService != "" {
+		m["healthCheckConfig"] = map[string]any{"serviceName": healthService}
+	}
+	out, err := json.Marshal(m)
+	return string(out), err
+}
+
+// DialOptions returns the options a client needs to dial any target
+// accepted by Target with policy.
+func DialOptions(policy, healthService string) ([]grpc.DialOption, error) {
+	sc, err := ServiceConfig(policy, healthService)
+	if err != nil {
+		return nil, err
+	}
+	return []grpc.DialOption{grpc.WithDefaultServiceConfig(sc), Resolvers()}, nil
+}
@@ -0,0 +1,84 @@
+package grpclb
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/balancer/base"
+)
+
+// leastRequestBalancer builds the least_request balancer of one
+// ClientConn, each with its own pickerBuilder and so its own counters.
+type leastRequestBalancer struct{}
+
+func (leastRequestBalancer) Name() string { return LeastRequest }
+
+func (leastRequestBalancer) Build(cc balancer.ClientConn, opts balancer.BuildOptions) balancer.Balancer {
+	b := base.NewBalancerBuilder(LeastRequest, &leastRequestBuilder{}, base.Config{HealthCheck: true})
+	return b.Build(cc, opts)
+}
+
+// leastRequestBuilder builds pickers sending each call to the ready
+// backend with the fewest calls in flight. The counters live in the
+// builder so that they survive the new picker built on every change in
+// connectivity or health; one ClientConn's builder never sees another's
+// SubConns.
+type leastRequestBuilder struct {
+	mu          sync.Mutex
+	outstanding map[balancer.SubConn]*int64
+}
+
+func (b *leastRequestBuilder) Build(info base.PickerBuildInfo) balancer.Picker {
+	if len(info.ReadySCs) == 0 {
+		return base.NewErrPicker(balancer.ErrNoSubConnAvailable)
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.outstanding == nil {
+		b.outstanding = make(map[balancer.SubConn]*int64)
+	}
+	p := &leastRequestPicker{}
+	for sc := range info.ReadySCs {
+		n, ok := b.outstanding[sc]
+		if !ok {
+			n = new(int64)
+			b.outstanding[sc] = n
+		}
+		p.backends = append(p.backends, leastRequestBackend{sc, n})
+	}
+	// Calls still running on a dropped backend keep their own counter
+	for sc := range b.outstanding {
+		if _, ok := info.ReadySCs[sc]; !ok {
+			delete(b.outstanding, sc)
+		}
+	}
+	return p
+}
+
+type leastRequestBackend struct {
+	sc          balancer.SubConn
+	outstanding *int64
+}
+
+type leastRequestPicker struct {
+	backends []leastRequestBackend
+	next     atomic.Uint32 // rotates the starting point so ties spread
+}
+
+func (p *leastRequestPicker) Pick(balancer.PickInfo) (balancer.PickResult, error) {
+	n := len(p.backends)
+	start := int(p.next.Add(1)) % n
+	best := p.backends[start]
+	for i := 1; i < n; i++ {
+		b := p.backends[(start+i)%n]
+		if atomic.LoadInt64(b.outstanding) < atomic.LoadInt64(best.outstanding) {
+			best = b
+		}
+	}
+	atomic.AddInt64(best.outstanding, 1)
+	return balancer.PickResult{
+		SubConn: best.sc,
+		Done:    func(balancer.DoneInfo) { atomic.AddInt64(best.outstanding, -1) },
+	}, nil
+}
@@ -0,0 +1,163 @@
+package grpclb
+
+import (
+	"testing"
+
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/balancer/base"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/resolver"
+)
+
+type fakeSubConn struct {
+	balancer.SubConn
+	name     string
+	listener func(balancer.SubConnState)
+}
+
+func (*fakeSubConn) Connect()  {}
+func (*fakeSubConn) Shutdown() {}
+
+func readyInfo(scs ...*fakeSubConn) base.PickerBuildInfo {
+	info := base.PickerBuildInfo{ReadySCs: make(map[balancer.SubConn]base.SubConnInfo)}
+	for _, sc := range scs {
+		info.ReadySCs[sc] = base.SubConnInfo{Address: resolver.Address{Addr: sc.name}}
+	}
+	return info
+}
+
+func pick(t *testing.T, p balancer.Picker) (string, func()) {
+	t.Helper()
+	res, err := p.Pick(balancer.PickInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res.SubConn.(*fakeSubConn).name, func() { res.Done(balancer.DoneInfo{}) }
+}
+
+func TestLeastRequestPicker(t *testing.T) {
+	a, b, c := &fakeSubConn{name: "a"}, &fakeSubConn{name: "b"}, &fakeSubConn{name: "c"}
+	tests := []struct {
+		name string
+		run  func(t *testing.T, builder *leastRequestBuilder)
+	}{
+		{"no ready backends", func(t *testing.T, builder *leastRequestBuilder) {
+			if _, err := builder.Build(readyInfo()).Pick(balancer.PickInfo{}); err != balancer.ErrNoSubConnAvailable {
+				t.Fatalf("Pick = %v, want ErrNoSubConnAvailable", err)
+			}
+		}},
+		{"spreads calls in flight", func(t *testing.T, builder *leastRequestBuilder) {
+			p := builder.Build(readyInfo(a, b, c))
+			seen := map[string]int{}
+			for i := 0; i < 6; i++ {
+				name, _ := pick(t, p)
+				seen[name]++
+			}
+			for _, name := range []string{"a", "b", "c"} {
+				if seen[name] != 2 {
+					t.Fatalf("picks %v, want 2 each", seen)
+				}
+			}
+		}},
+		{"prefers the least loaded", func(t *testing.T, builder *leastRequestBuilder) {
+			p := builder.Build(readyInfo(a, b))
+			first, done := pick(t, p)
+			_, _ = pick(t, p)
+			done()
+			for i := 0; i < 4; i++ {
+				name, done := pick(t, p)
+				if name != first {
+					t.Fatalf("pick %d went to %s, want the idle %s", i, name, first)
+				}
+				done()
+			}
+		}},
+		{"counters survive a rebuild", func(t *testing.T, builder *leastRequestBuilder) {
+			p := builder.Build(readyInfo(a, b))
+			busy, _ := pick(t, p)
+			p = builder.Build(readyInfo(a, b, c))
+			for i := 0; i < 2; i++ {
+				if name, _ := pick(t, p); name == busy {
+					t.Fatalf("pick %d went to busy backend %s", i, busy)
+				}
+			}
+		}},
+		{"dropped backends are forgotten", func(t *testing.T, builder *leastRequestBuilder) {
+			p := builder.Build(readyInfo(a, b))
+			_, _ = pick(t, p)
+			_, _ = pick(t, p)
+			builder.Build(readyInfo(b))
+			if _, ok := builder.outstanding[a]; ok || len(builder.outstanding) != 1 {
+				t.Fatalf("outstanding = %v, want only b", builder.outstanding)
+			}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.run(t, &leastRequestBuilder{})
+		})
+	}
+}
+
+// fakeClientConn records the SubConns and pickers of one balancer.
+type fakeClientConn struct {
+	balancer.ClientConn
+	subConns map[string]*fakeSubConn
+	picker   balancer.Picker
+}
+
+func (cc *fakeClientConn) NewSubConn(addrs []resolver.Address, opts balancer.NewSubConnOptions) (balancer.SubConn, error) {
+	sc := &fakeSubConn{name: addrs[0].Addr, listener: opts.StateListener}
+	cc.subConns[sc.name] = sc
+	return sc, nil
+}
+
+func (cc *fakeClientConn) UpdateState(s balancer.State) { cc.picker = s.Picker }
+
+// resolve hands addrs to b and reports each new SubConn ready.
+func (cc *fakeClientConn) resolve(t *testing.T, b balancer.Balancer, addrs ...string) {
+	t.Helper()
+	var state resolver.State
+	for _, a := range addrs {
+		state.Addresses = append(state.Addresses, resolver.Address{Addr: a})
+	}
+	known := make(map[string]bool)
+	for name := range cc.subConns {
+		known[name] = true
+	}
+	if err := b.UpdateClientConnState(balancer.ClientConnState{ResolverState: state}); err != nil {
+		t.Fatal(err)
+	}
+	for name, sc := range cc.subConns {
+		if !known[name] {
+			sc.listener(balancer.SubConnState{ConnectivityState: connectivity.Ready})
+		}
+	}
+}
+
+// TestLeastRequestPerClientConn checks that building a balancer for a
+// second ClientConn leaves the counters of the first alone.
+func TestLeastRequestPerClientConn(t *testing.T) {
+	builder := balancer.Get(LeastRequest)
+	cc1 := &fakeClientConn{subConns: make(map[string]*fakeSubConn)}
+	b1 := builder.Build(cc1, balancer.BuildOptions{})
+	defer b1.Close()
+	cc1.resolve(t, b1, "a", "b")
+	for i := 0; i < 4; i++ {
+		pick(t, cc1.picker) // two calls left in flight on each of a and b
+	}
+
+	cc2 := &fakeClientConn{subConns: make(map[string]*fakeSubConn)}
+	b2 := builder.Build(cc2, balancer.BuildOptions{})
+	defer b2.Close()
+	cc2.resolve(t, b2, "a", "b")
+
+	// A new backend on the first conn rebuilds its picker; the calls in
+	// flight must still count, so the idle backend takes the next two.
+	cc1.resolve(t, b1, "a", "b", "c")
+	for i := 0; i < 2; i++ {
+		if name, _ := pick(t, cc1.picker); name != "c" {
+			t.Fatalf("pick %d went to %s, want the idle c", i, name)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package grpclb
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"awesomeproject/pkg/filewatch"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/resolver"
+)
+
+// Resolver schemes understood by the builders Resolvers returns.
+const (
+	// StaticScheme lists the addresses in the target itself:
+	// "static:///127.0.0.1:50051,127.0.0.1:50052".
+	StaticScheme = "static"
+	// FileScheme reads one address per line from a file and follows
+	// changes to it: "file:///etc/greeter/backends". Blank lines and
+	// lines starting with # are ignored.
+	FileScheme = "file"
+)
+
+// FilePollInterval is how often file:/// targets are checked for changes.
+var FilePollInterval = time.Second
+
+// Resolvers returns a dial option registering the static and file
+// resolvers for one ClientConn.
+func Resolvers() grpc.DialOption {
+	return grpc.WithResolvers(staticBuilder{}, fileBuilder{})
+}
+
+type staticBuilder struct{}
+
+func (staticBuilder) Scheme() string { return StaticScheme }
+
+func (staticBuilder) Build(target resolver.Target, cc resolver.ClientConn, _ resolver.BuildOptions) (resolver.Resolver, error) {
+	var addrs []string
+	for _, a := range strings.Split(target.Endpoint(), ",") {
+		if a = strings.TrimSpace(a); a != "" {
+			addrs = append(addrs, a)
+		}
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("grpclb: no addresses in %q", target.String())
+	}
+	if err := cc.UpdateState(state(addrs)); err != nil {
+		return nil, err
+	}
+	return nopResolver{}, nil
+}
+
+type nopResolver struct{}
+
+func (nopResolver) ResolveNow(resolver.ResolveNowOptions) {}
+func (nopResolver) Close()                                {}
+
+type fileBuilder struct{}
+
+func (fileBuilder) Scheme() string { return FileScheme }
+
+func (fileBuilder) Build(target resolver.Target, cc resolver.ClientConn, _ resolver.BuildOptions) (resolver.Resolver, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &fileResolver{path: target.URL.Path, cc: cc, cancel: cancel}
+	if err := r.load(); err != nil {
+		cancel()
+		return nil, err
+	}
+	go filewatch.Watch(ctx, FilePollInterval, r.load, r.path)
+	return r, nil
+}
+
+type fileResolver struct {
+	path   string
+	cc     resolver.ClientConn
+	cancel context.CancelFunc
+}
+
+func (r *fileResolver) load() error {
+	addrs, err := readAddrs(r.path)
+	if err != nil {
+		r.cc.ReportError(err)
+		return err
+	}
+	return r.cc.UpdateState(state(addrs))
+}
+
+func (r *fileResolver) ResolveNow(resolver.ResolveNowOptions) {}
+
+func (r *fileResolver) Close() { r.cancel() }
+
+func readAddrs(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var addrs []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		addrs = append(addrs, line)
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("grpclb: no addresses in %s", path)
+	}
+	return addrs, nil
+}
+
+// state lists addrs as Addresses, which grpc also turns into Endpoints;
+// balancers built on balancer/base read only the former.
+func state(addrs []string) resolver.State {
+	var s resolver.State
+	for _, a := range addrs {
+		s.Addresses = append(s.Addresses, resolver.Address{Addr: a})
+	}
+	return s
+}
@@ -0,0 +1,233 @@
+// Package health tracks whether a server is ready to take traffic. It
+// runs dependency checks in the background and publishes the result
+// through the standard grpc.health.v1 service and as a Report that HTTP
+// servers can serve from /readyz.
+package health
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// Methods matches the methods of the grpc.health.v1.Health service, for
+// exempting probes from authentication.
+const Methods = "grpc.health.v1.Health.*"
+
+// Check reports whether a dependency is usable.
+type Check func(ctx context.Context) error
+
+// Monitor holds the serving status of a set of services. Every service,
+// and the server as a whole (the empty service name), is SERVING while
+// all checks pass and NOT_SERVING otherwise or after Shutdown.
+type Monitor struct {
+	services []string
+	grpc     *health.Server
+
+	mu       sync.RWMutex
+	checks   map[string]Check
+	failures map[string]string // check name -> last error
+	shutdown bool
+}
+
+// New returns a Monitor for services, all initially SERVING.
+func New(services ...string) *Monitor {
+	m := &Monitor{
+		services: append([]string{""}, services...),
+		grpc:     health.NewServer(),
+		checks:   make(map[string]Check),
+		failures: make(map[string]string),
+	}
+	m.publish()
+	return m
+}
+
+// GRPC returns the grpc.health.v1 implementation to register with
+// healthpb.RegisterHealthServer.
+func (m *Monitor) GRPC() *health.Server {
+	return m.grpc
+}
+
+// AddCheck registers a dependency check. Call it before Run.
+func (m *Monitor) AddCheck(name string, c Check) {
+	m.mu.Lock()
+	m.checks[name] = c
+	m.mu.Unlock()
+}
+
+// Run evaluates the checks every interval until ctx is done.
+func (m *Monitor) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		m.runChecks(ctx, interval)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (m *Monitor) runChecks(ctx context.Context, timeout time.Duration) {
+	m.mu.RLock()
+	checks := make(map[string]Check, len(m.checks))
+	for name, c := range m.checks {
+		checks[name] = c
+	}
+	m.mu.RUnlock()
+
+	failures := make(map[string]string)
+	for name, c := range checks {
+		cctx, cancel := context.WithTimeout(ctx, timeout)
+		if err := c(cctx); err != nil {
+			failures[name] = err.Error()
+		}
+		cancel()
+	}
+
+	m.mu.Lock()
+	for name, msg := range failures {
+		if _, ok := m.failures[name]; !ok {
+			log.Printf("health: check %s failed: %s", name, msg)
+		}
+	}
+	for name := range m.failures {
+		if _, ok := failures[name]; !ok {
+			log.Printf("health: check %s recovered", name)
+		}
+	}
+	m.failures = failures
+	m.mu.Unlock()
+	m.publish()
+}
+
+// Shutdown marks every service NOT_SERVING for good, so load balancers
+// stop sending new calls while in-flight ones finish.
+func (m *Monitor) Shutdown() {
+	m.mu.Lock()
+	m.shutdown = true
+	m.mu.Unlock()
+	m.grpc.Shutdown()
+}
+
+func (m *Monitor) publish() {
+	status := healthpb.HealthCheckResponse_SERVING
+	if !m.Report().Ready {
+		status = healthpb.HealthCheckResponse_NOT_SERVING
+	}
+	for _, s := range m.services {
+		m.grpc.SetServingStatus(s, status)
+	}
+}
+
+// Report is the readiness summary served by /readyz.
+type Report struct {
+	Ready    bool              `json:"ready"`
+	Status   string            `json:"status"`
+	Services []string          `json:"services,omitempty"`
+	Failures map[string]string `json:"failures,omitempty"`
+}
+
+// Report returns the current readiness.
+func (m *Monitor) Report() Report {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	r := Report{Ready: !m.shutdown && len(m.failures) == 0, Services: m.services[1:]}
+	switch {
+	case m.shutdown:
+		r.Status = "shutting down"
+	case len(m.failures) > 0:
+		r.Status = "dependency check failed"
+		r.Failures = make(map[string]string, len(m.failures))
+		for k, v := range m.failures {
+			r.Failures[k] = v
+		}
+	default:
+		r.Status = "serving"
+	}
+	return r
+}
+
+// TCPCheck succeeds when a TCP connection to addr can be opened.
+func TCPCheck(addr string) Check {
+	return func(ctx context.Context) error {
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+}
+
+// FileCheck succeeds when path exists and is readable.
+func FileCheck(path string) Check {
+	return func(context.Context) error {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		return f.Close()
+	}
+}
+
+// Config lists dependency checks given on the command line.
+type Config struct {
+	// Deps maps a check name to "tcp:host:port" or "file:/path".
+	Deps     map[string]string
+	Interval time.Duration
+}
+
+// RegisterFlags binds the Config fields to command-line flags on fs.
+func (c *Config) RegisterFlags(fs *flag.FlagSet) {
+	fs.Func("health-dep", "dependency check name=tcp:host:port or name=file:/path (repeatable)", func(s string) error {
+		name, target, ok := strings.Cut(s, "=")
+		if !ok || name == "" {
+			return fmt.Errorf("want name=tcp:host:port or name=file:/path")
+		}
+		if _, err := checkFor(target); err != nil {
+			return err
+		}
+		if c.Deps == nil {
+			c.Deps = make(map[string]string)
+		}
+		c.Deps[name] = target
+		return nil
+	})
+	fs.DurationVar(&c.Interval, "health-interval", 5*time.Second, "how often to run dependency checks")
+}
+
+func checkFor(target string) (Check, error) {
+	switch kind, arg, _ := strings.Cut(target, ":"); kind {
+	case "tcp":
+		return TCPCheck(arg), nil
+	case "file":
+		return FileCheck(arg), nil
+	}
+	return nil, fmt.Errorf("unknown dependency %q: want tcp:host:port or file:/path", target)
+}
+
+// Start returns a Monitor for services with the checks in cfg, running
+// them until ctx is done.
+func Start(ctx context.Context, cfg Config, services ...string) *Monitor {
+	m := New(services...)
+	for name, target := range cfg.Deps {
+		c, _ := checkFor(target) // validated when the flag was parsed
+		m.AddCheck(name, c)
+	}
+	go m.Run(ctx, cfg.Interval)
+	return m
+}
@@ -0,0 +1,121 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestMonitor(t *testing.T) {
+	ctx := context.Background()
+	m := New("Greeter")
+	var dbErr error
+	m.AddCheck("db", func(context.Context) error { return dbErr })
+
+	steps := []struct {
+		name     string
+		act      func()
+		want     Report
+		wantGRPC healthpb.HealthCheckResponse_ServingStatus
+	}{
+		{"initial", func() {},
+			Report{Ready: true, Status: "serving", Services: []string{"Greeter"}}, healthpb.HealthCheckResponse_SERVING},
+		{"failing", func() { dbErr = errors.New("down"); m.runChecks(ctx, 0) },
+			Report{Status: "dependency check failed", Services: []string{"Greeter"}, Failures: map[string]string{"db": "down"}},
+			healthpb.HealthCheckResponse_NOT_SERVING},
+		{"recovered", func() { dbErr = nil; m.runChecks(ctx, 0) },
+			Report{Ready: true, Status: "serving", Services: []string{"Greeter"}}, healthpb.HealthCheckResponse_SERVING},
+		{"shutdown", m.Shutdown,
+			Report{Status: "shutting down", Services: []string{"Greeter"}}, healthpb.HealthCheckResponse_NOT_SERVING},
+		{"checks pass after shutdown", func() { m.runChecks(ctx, 0) },
+			Report{Status: "shutting down", Services: []string{"Greeter"}}, healthpb.HealthCheckResponse_NOT_SERVING},
+	}
+	for _, s := range steps {
+		s.act()
+		if got := m.Report(); !reflect.DeepEqual(got, s.want) {
+			t.Fatalf("%s: Report = %+v, want %+v", s.name, got, s.want)
+		}
+		for _, service := range []string{"", "Greeter"} {
+			resp, err := m.GRPC().Check(ctx, &healthpb.HealthCheckRequest{Service: service})
+			if err != nil || resp.GetStatus() != s.wantGRPC {
+				t.Fatalf("%s: Check(%q) = %v, %v; want %v", s.name, service, resp.GetStatus(), err, s.wantGRPC)
+			}
+		}
+	}
+}
+
+func TestChecks(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	open := l.Addr().String()
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedAddr := closed.Addr().String()
+	closed.Close()
+	defer l.Close()
+
+	file := filepath.Join(t.TempDir(), "ready")
+	if err := os.WriteFile(file, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		target  string
+		wantErr bool
+	}{
+		{"tcp:" + open, false},
+		{"tcp:" + closedAddr, true},
+		{"file:" + file, false},
+		{"file:" + file + ".missing", true},
+	}
+	for _, tt := range tests {
+		c, err := checkFor(tt.target)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := c(context.Background()); (err != nil) != tt.wantErr {
+			t.Errorf("%s: err = %v, want error %v", tt.target, err, tt.wantErr)
+		}
+	}
+}
+
+func TestRegisterFlags(t *testing.T) {
+	tests := []struct {
+		args    []string
+		want    map[string]string
+		wantErr bool
+	}{
+		{nil, nil, false},
+		{[]string{"-health-dep", "db=tcp:db:5432", "-health-dep", "cert=file:/tls/cert.pem"},
+			map[string]string{"db": "tcp:db:5432", "cert": "file:/tls/cert.pem"}, false},
+		{[]string{"-health-dep", "tcp:db:5432"}, nil, true},
+		{[]string{"-health-dep", "=tcp:db:5432"}, nil, true},
+		{[]string{"-health-dep", "db=http://db"}, nil, true},
+	}
+	for _, tt := range tests {
+		var cfg Config
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		fs.SetOutput(io.Discard)
+		cfg.RegisterFlags(fs)
+		err := fs.Parse(tt.args)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q: err = %v, want error %v", tt.args, err, tt.wantErr)
+			continue
+		}
+		if err == nil && !reflect.DeepEqual(cfg.Deps, tt.want) {
+			t.Errorf("%q: Deps = %v, want %v", tt.args, cfg.Deps, tt.want)
+		}
+	}
+}
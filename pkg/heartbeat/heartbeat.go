@@ -0,0 +1,144 @@
+// Package heartbeat serves long-lived bidirectional streams that receive
+// client messages while sending heartbeats. A stream ends normally once
+// the client has closed its side and the heartbeats are sent; otherwise
+// it ends with Canceled when the client cancels, or DeadlineExceeded when
+// the client deadline or the stream lifetime passes or the client stops
+// sending.
+package heartbeat
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"io"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Config controls a stream. The zero value is invalid: Interval must be
+// positive.
+type Config struct {
+	// Lifetime is the longest a stream may last; 0 leaves only the client
+	// deadline.
+	Lifetime time.Duration
+	Interval time.Duration // pause between heartbeats
+	Count    int           // heartbeats per stream; 0 = until the client closes
+	// IdleTimeout ends a stream whose client sends nothing for this long;
+	// 0 = never.
+	IdleTimeout time.Duration
+}
+
+// DefaultConfig returns the settings used by serverStream.go.
+func DefaultConfig() Config {
+	return Config{
+		Lifetime:    20 * time.Second,
+		Interval:    2 * time.Second,
+		Count:       5,
+		IdleTimeout: 30 * time.Second,
+	}
+}
+
+// RegisterFlags binds the Config fields to flags on fs, using the current
+// values as defaults.
+func (c *Config) RegisterFlags(fs *flag.FlagSet) {
+	fs.DurationVar(&c.Lifetime, "stream-lifetime", c.Lifetime, "longest a stream may last; the client deadline applies as well (0 = client deadline only)")
+	fs.DurationVar(&c.Interval, "heartbeat-interval", c.Interval, "pause between heartbeats")
+	fs.IntVar(&c.Count, "heartbeat-count", c.Count, "heartbeats per stream (0 = until the client closes)")
+	fs.DurationVar(&c.IdleTimeout, "idle-timeout", c.IdleTimeout, "end a stream whose client sends nothing for this long (0 = never)")
+}
+
+// Stream is the server side of a bidirectional gRPC stream, such as
+// grpc.BidiStreamingServer[Req, Resp].
+type Stream[Req, Resp any] interface {
+	Context() context.Context
+	Recv() (*Req, error)
+	Send(*Resp) error
+}
+
+// Serve runs stream as cfg says and returns its final status. beat builds
+// the n-th heartbeat, counting from 1; received, if not nil, is called
+// with each client message. A client resets the stream at its deadline,
+// so past the client deadline the status may be Canceled instead of
+// DeadlineExceeded; the client sees DeadlineExceeded either way.
+func Serve[Req, Resp any](stream Stream[Req, Resp], cfg Config, beat func(n int) *Resp, received func(*Req)) error {
+	// The client deadline is already in the stream context; the lifetime
+	// can only bring it forward.
+	ctx := stream.Context()
+	if cfg.Lifetime > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.Lifetime)
+		defer cancel()
+	}
+
+	// Recv blocks; it returns once Serve does and the stream is cancelled.
+	messages := make(chan struct{})
+	recvDone := make(chan error, 1)
+	go func() {
+		for {
+			msg, err := stream.Recv()
+			if err != nil {
+				if errors.Is(err, io.EOF) {
+					err = nil // the client closed its side
+				}
+				recvDone <- err
+				return
+			}
+			if received != nil {
+				received(msg)
+			}
+			select {
+			case messages <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	heartbeat := time.NewTicker(cfg.Interval)
+	defer heartbeat.Stop()
+	var idle <-chan time.Time
+	var idleTimer *time.Timer
+	if cfg.IdleTimeout > 0 {
+		idleTimer = time.NewTimer(cfg.IdleTimeout)
+		defer idleTimer.Stop()
+		idle = idleTimer.C
+	}
+
+	sent := 0
+	beating := cfg.Count != 0
+	clientOpen := true
+	for clientOpen || beating {
+		select {
+		case <-ctx.Done():
+			if err := stream.Context().Err(); err != nil {
+				return status.FromContextError(err).Err() // cancelled or past the client deadline
+			}
+			return status.Errorf(codes.DeadlineExceeded, "stream lifetime of %v reached", cfg.Lifetime)
+		case <-idle:
+			return status.Errorf(codes.DeadlineExceeded, "no message from client for %v", cfg.IdleTimeout)
+		case <-messages:
+			if idleTimer != nil {
+				idleTimer.Reset(cfg.IdleTimeout)
+			}
+		case err := <-recvDone:
+			if err != nil {
+				return err
+			}
+			clientOpen, idle = false, nil // a client that closed its side is not idle
+			if cfg.Count == 0 {
+				beating = false
+			}
+		case <-heartbeat.C:
+			sent++
+			if err := stream.Send(beat(sent)); err != nil {
+				return err
+			}
+			if cfg.Count > 0 && sent >= cfg.Count {
+				beating = false
+			}
+		}
+	}
+	return nil
+}
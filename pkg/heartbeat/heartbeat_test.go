@@ -0,0 +1,193 @@
+package heartbeat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"awesomeproject/proto"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// greeter serves AllStream with Serve and reports what Serve returned.
+type greeter struct {
+	proto.UnimplementedGreeterServer
+	cfg    Config
+	served chan error
+}
+
+func (g *greeter) AllStream(stream proto.Greeter_AllStreamServer) error {
+	err := Serve(stream, g.cfg, func(n int) *proto.StreamResData {
+		return &proto.StreamResData{Data: fmt.Sprint(n)}
+	}, nil)
+	g.served <- err
+	return err
+}
+
+// dial serves g in process and returns a client for it.
+func dial(t *testing.T, g *greeter) proto.GreeterClient {
+	t.Helper()
+	l := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	proto.RegisterGreeterServer(srv, g)
+	go srv.Serve(l)
+	t.Cleanup(srv.Stop)
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return l.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return proto.NewGreeterClient(conn)
+}
+
+// drain counts the heartbeats until the stream ends.
+func drain(stream proto.Greeter_AllStreamClient) (int, error) {
+	n := 0
+	for {
+		if _, err := stream.Recv(); err != nil {
+			if errors.Is(err, io.EOF) {
+				err = nil
+			}
+			return n, err
+		}
+		n++
+	}
+}
+
+func TestServe(t *testing.T) {
+	const beat = 10 * time.Millisecond
+	tests := []struct {
+		name    string
+		cfg     Config
+		timeout time.Duration // client deadline
+		// client drives the stream; cancel cancels its context.
+		client    func(stream proto.Greeter_AllStreamClient, cancel context.CancelFunc) error
+		wantCode  codes.Code
+		wantMsg   string
+		wantBeats int // heartbeats received, checked when the client drains the stream
+		// orCanceled also accepts Canceled: the client resets the stream
+		// at its deadline, which may reach the server before its own.
+		orCanceled bool
+	}{
+		{
+			name: "heartbeats then closed",
+			cfg:  Config{Interval: beat, Count: 3},
+			client: func(s proto.Greeter_AllStreamClient, _ context.CancelFunc) error {
+				return s.CloseSend()
+			},
+			wantBeats: 3,
+		},
+		{
+			name: "until the client closes",
+			cfg:  Config{Interval: beat},
+			client: func(s proto.Greeter_AllStreamClient, _ context.CancelFunc) error {
+				for range 2 {
+					if _, err := s.Recv(); err != nil {
+						return err
+					}
+				}
+				return s.CloseSend()
+			},
+			wantBeats: -1,
+		},
+		{
+			name: "messages keep an idle stream open",
+			cfg:  Config{Interval: beat, IdleTimeout: 60 * time.Millisecond},
+			client: func(s proto.Greeter_AllStreamClient, _ context.CancelFunc) error {
+				for range 8 {
+					time.Sleep(20 * time.Millisecond)
+					if err := s.Send(&proto.StreamReqData{Data: "ping"}); err != nil {
+						return err
+					}
+				}
+				return s.CloseSend()
+			},
+			wantBeats: -1,
+		},
+		{
+			name:     "lifetime",
+			cfg:      Config{Interval: beat, Lifetime: 50 * time.Millisecond},
+			client:   func(proto.Greeter_AllStreamClient, context.CancelFunc) error { return nil },
+			wantCode: codes.DeadlineExceeded,
+			wantMsg:  "lifetime",
+		},
+		{
+			name:     "idle client",
+			cfg:      Config{Interval: beat, IdleTimeout: 50 * time.Millisecond},
+			client:   func(proto.Greeter_AllStreamClient, context.CancelFunc) error { return nil },
+			wantCode: codes.DeadlineExceeded,
+			wantMsg:  "no message from client",
+		},
+		{
+			name:       "client deadline",
+			cfg:        Config{Interval: beat, Lifetime: time.Minute},
+			timeout:    50 * time.Millisecond,
+			client:     func(proto.Greeter_AllStreamClient, context.CancelFunc) error { return nil },
+			wantCode:   codes.DeadlineExceeded,
+			orCanceled: true,
+		},
+		{
+			name: "client cancels",
+			cfg:  Config{Interval: beat},
+			client: func(s proto.Greeter_AllStreamClient, cancel context.CancelFunc) error {
+				if _, err := s.Recv(); err != nil {
+					return err
+				}
+				cancel()
+				return nil
+			},
+			wantCode: codes.Canceled,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := &greeter{cfg: tt.cfg, served: make(chan error, 1)}
+			c := dial(t, g)
+			timeout := tt.timeout
+			if timeout == 0 {
+				timeout = 5 * time.Second
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+			stream, err := c.AllStream(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := tt.client(stream, cancel); err != nil {
+				t.Fatalf("client: %v", err)
+			}
+			n, err := drain(stream)
+			if tt.wantCode == codes.OK && (err != nil || tt.wantBeats >= 0 && n != tt.wantBeats) {
+				t.Fatalf("client received %d heartbeats, then %v; want %d", n, err, tt.wantBeats)
+			}
+			if status.Code(err) != tt.wantCode {
+				t.Fatalf("client stream ended with %v, want %v", err, tt.wantCode)
+			}
+			var served error
+			select {
+			case served = <-g.served:
+			case <-time.After(5 * time.Second):
+				t.Fatal("Serve did not return")
+			}
+			st := status.Convert(served)
+			if tt.orCanceled && st.Code() == codes.Canceled {
+				return
+			}
+			if st.Code() != tt.wantCode || !strings.Contains(st.Message(), tt.wantMsg) {
+				t.Fatalf("Serve = %v, want %v %q", served, tt.wantCode, tt.wantMsg)
+			}
+		})
+	}
+}
@@ -0,0 +1,28 @@
+package hertzrpc
+
+import (
+	"context"
+
+	"awesomeproject/pkg/auth"
+)
+
+// Authenticate returns an Interceptor that verifies the caller's bearer
+// token from the Authorization or X-API-Key header and stores the
+// Principal in the context passed to the method. Methods the Authenticator
+// marks public may be called without a token.
+func Authenticate(a *auth.Authenticator) Interceptor {
+	return func(ctx context.Context, info *CallInfo, arg interface{}, next Handler) (interface{}, error) {
+		token := auth.BearerToken(info.Header.Get("Authorization"))
+		if token == "" {
+			token = info.Header.Get(auth.APIKeyHeader)
+		}
+		if token == "" && a.IsPublic(info.Method) {
+			return next(ctx, arg)
+		}
+		p, err := a.Verify(ctx, token)
+		if err != nil {
+			return nil, &Error{Code: CodeUnauthenticated, Message: "Unauthenticated: " + err.Error()}
+		}
+		return next(auth.NewContext(ctx, p), arg)
+	}
+}
@@ -0,0 +1,26 @@
+package hertzrpc
+
+import (
+	"context"
+
+	"awesomeproject/pkg/auth"
+	"awesomeproject/pkg/authz"
+)
+
+// Authorize returns an Interceptor enforcing the policy engine. Place it
+// after Authenticate so the caller's Principal is in the context. The
+// decision is made before params are decoded, so a denied caller learns
+// nothing about their shape.
+func Authorize(e *authz.Engine) Interceptor {
+	return func(ctx context.Context, info *CallInfo, arg interface{}, next Handler) (interface{}, error) {
+		caller, _ := auth.FromContext(ctx)
+		d := e.Authorize(info.Method, caller)
+		switch d.Outcome {
+		case authz.OutcomeAllow:
+			return next(ctx, arg)
+		case authz.OutcomeUnauthenticated:
+			return nil, &Error{Code: CodeUnauthenticated, Message: "Unauthenticated: " + d.Reason}
+		}
+		return nil, &Error{Code: CodePermissionDenied, Message: "Permission denied: " + d.Reason}
+	}
+}
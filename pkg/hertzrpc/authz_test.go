@@ -0,0 +1,34 @@
+package hertzrpc
+
+import (
+	"testing"
+
+	"awesomeproject/pkg/authz"
+)
+
+func TestAuthorizeBeforeParams(t *testing.T) {
+	policy := &authz.Policy{Default: authz.Deny, Rules: []authz.Rule{
+		{Method: "Echo.Say", Roles: []string{"admin"}},
+	}}
+	d := newTestDispatcher(t, WithInterceptors(Authorize(authz.New(policy))))
+	tests := []struct {
+		name   string
+		params string
+	}{
+		{"valid", `[{"name":"a"}]`},
+		{"missing field", `[{"name":""}]`},
+		{"wrong type", `[{"name":7}]`},
+		{"no params", `[]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := serveJSON(d, `{"id":1,"method":"Echo.Say","params":`+tt.params+`}`)
+			if resp.Error == nil || resp.Error.Code != CodeUnauthenticated {
+				t.Fatalf("error = %+v, want code %d", resp.Error, CodeUnauthenticated)
+			}
+			if resp.Error.Data != nil {
+				t.Fatalf("denied call leaked data %+v", resp.Error.Data)
+			}
+		})
+	}
+}
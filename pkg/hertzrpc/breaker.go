@@ -0,0 +1,41 @@
+package hertzrpc
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"awesomeproject/pkg/breaker"
+
+	"google.golang.org/grpc/status"
+)
+
+// BreakerFailure reports whether a Client error counts against a circuit
+// breaker: transport failures, and errors whose gRPC code, as GRPCStatus
+// maps the JSON-RPC code, counts for breaker.GRPCFailure. The caller's own
+// cancellation and requests the server found invalid do not count.
+func BreakerFailure(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	if _, ok := status.FromError(err); !ok {
+		return true // no JSON-RPC error or HTTP status: the call never got an answer
+	}
+	return breaker.GRPCFailure(err)
+}
+
+// BreakerInterceptor guards each method with its breaker in g. While the
+// circuit is open calls fail with a *breaker.OpenError, whose gRPC status
+// is codes.Unavailable.
+func BreakerInterceptor(g *breaker.Group) ClientInterceptor {
+	return func(ctx context.Context, method string, args, reply any, invoker ClientInvoker) error {
+		done, err := g.Get(method).Allow()
+		if err != nil {
+			return err
+		}
+		start := time.Now()
+		err = invoker(ctx, method, args, reply)
+		done(BreakerFailure(err), time.Since(start))
+		return err
+	}
+}
@@ -0,0 +1,47 @@
+package hertzrpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestBreakerFailure(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"success", nil, false},
+		{"canceled", context.Canceled, false},
+		{"deadline", context.DeadlineExceeded, true},
+		{"transport", io.ErrUnexpectedEOF, true},
+		{"dial", errors.New("connection refused"), true},
+		{"invalid params", &Error{Code: CodeInvalidParams}, false},
+		{"method not found", &Error{Code: CodeMethodNotFound}, false},
+		{"parse error", &Error{Code: CodeParseError}, false},
+		{"internal error", &Error{Code: CodeInternalError}, true},
+		{"server error", &Error{Code: CodeServerError}, true},
+		{"rate limited", &Error{Code: CodeRateLimited}, true},
+		{"unavailable", &Error{Code: CodeUnavailable}, true},
+		{"deadline exceeded", &Error{Code: -32004}, true},
+		{"unknown", &Error{Code: -32002}, true},
+		{"data loss", &Error{Code: -32015}, true},
+		{"not found", &Error{Code: -32005}, false},
+		{"permission denied", &Error{Code: CodePermissionDenied}, false},
+		{"unauthenticated", &Error{Code: CodeUnauthenticated}, false},
+		{"wrapped", fmt.Errorf("greet: %w", &Error{Code: CodeUnavailable}), true},
+		{"HTTP 503", status.Error(codes.Unavailable, "503 Service Unavailable"), true},
+		{"HTTP 404", status.Error(codes.NotFound, "404 Not Found"), false},
+	}
+	for _, tt := range tests {
+		if got := BreakerFailure(tt.err); got != tt.want {
+			t.Errorf("%s: BreakerFailure(%v) = %v, want %v", tt.name, tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package hertzrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+
+	"awesomeproject/pkg/rpcerr"
+
+	"google.golang.org/grpc/status"
+)
+
+// Client calls the methods of a Dispatcher with JSON-RPC 2.0 over HTTP
+// POST. It is safe for concurrent use.
+type Client struct {
+	// URL is the endpoint the Dispatcher is mounted on, e.g.
+	// "http://127.0.0.1:8082/jsonRpc".
+	URL string
+	// HTTPClient defaults to http.DefaultClient.
+	HTTPClient *http.Client
+	// Header is added to every request, e.g. Authorization.
+	Header http.Header
+	// Interceptors wrap every Call; the first is the outermost.
+	Interceptors []ClientInterceptor
+
+	id atomic.Uint64
+}
+
+// ClientInvoker sends one call, or passes it to the next ClientInterceptor.
+type ClientInvoker func(ctx context.Context, method string, args, reply any) error
+
+// ClientInterceptor wraps Client.Call, in the style of
+// grpc.UnaryClientInterceptor.
+type ClientInterceptor func(ctx context.Context, method string, args, reply any, invoker ClientInvoker) error
+
+// NewClient returns a Client for the Dispatcher at url.
+func NewClient(url string) *Client {
+	return &Client{URL: url, Header: make(http.Header)}
+}
+
+// Call invokes method, a "Service.Method" name, with args as its single
+// param and decodes the result into reply. A JSON-RPC error is returned
+// as an *Error, whose GRPCStatus recovers the gRPC code and details a
+// proxied call failed with, whatever the HTTP status it came with. A
+// non-JSON HTTP error is returned as a status error with the code
+// rpcerr.CodeFromHTTPStatus gives.
+func (c *Client) Call(ctx context.Context, method string, args, reply any) error {
+	invoke := c.call
+	for i := len(c.Interceptors) - 1; i >= 0; i-- {
+		next, interceptor := invoke, c.Interceptors[i]
+		invoke = func(ctx context.Context, method string, args, reply any) error {
+			return interceptor(ctx, method, args, reply, next)
+		}
+	}
+	return invoke(ctx, method, args, reply)
+}
+
+func (c *Client) call(ctx context.Context, method string, args, reply any) error {
+	param, err := json.Marshal(args)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(Request{
+		JsonRpc: "2.0",
+		Method:  method,
+		Params:  []RawMessage{param},
+		Id:      c.id.Add(1),
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for k, v := range c.Header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", defaultContentType)
+	req.Header.Set("Accept", defaultContentType)
+
+	hc := c.HTTPClient
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	var envelope struct {
+		Result json.RawMessage `json:"result"`
+		Error  *Error          `json:"error"`
+	}
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		if resp.StatusCode != http.StatusOK {
+			return status.Errorf(rpcerr.CodeFromHTTPStatus(resp.StatusCode), "hertzrpc: %s: %s", method, resp.Status)
+		}
+		return fmt.Errorf("hertzrpc: %s: decode response: %w", method, err)
+	}
+	if envelope.Error != nil {
+		return envelope.Error
+	}
+	if reply == nil || len(envelope.Result) == 0 {
+		return nil
+	}
+	return json.Unmarshal(envelope.Result, reply)
+}
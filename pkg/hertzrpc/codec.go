@@ -0,0 +1,212 @@
+package hertzrpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"mime"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+const defaultContentType = "application/json"
+
+// Codec converts JSON-RPC envelopes to and from one wire format.
+// All codecs decode into the same ArgType values, so struct fields keep
+// using their `json` tags regardless of the format on the wire.
+type Codec interface {
+	// Name identifies the codec, e.g. as a WebSocket subprotocol.
+	Name() string
+	// ContentType is the media type matched against Content-Type and Accept.
+	ContentType() string
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// RawMessage holds one encoded param in the wire format of the codec that
+// decoded the request, so it can be unmarshaled once the ArgType is known.
+type RawMessage []byte
+
+func (m RawMessage) MarshalJSON() ([]byte, error) {
+	if m == nil {
+		return []byte("null"), nil
+	}
+	return m, nil
+}
+
+func (m *RawMessage) UnmarshalJSON(data []byte) error {
+	if m == nil {
+		return errors.New("hertzrpc: UnmarshalJSON on nil pointer")
+	}
+	*m = append((*m)[0:0], data...)
+	return nil
+}
+
+func (m RawMessage) MarshalMsgpack() ([]byte, error) {
+	if m == nil {
+		return msgpack.Marshal(nil)
+	}
+	return m, nil
+}
+
+func (m *RawMessage) UnmarshalMsgpack(data []byte) error {
+	if m == nil {
+		return errors.New("hertzrpc: UnmarshalMsgpack on nil pointer")
+	}
+	*m = append((*m)[0:0], data...)
+	return nil
+}
+
+func (m RawMessage) MarshalCBOR() ([]byte, error) {
+	if m == nil {
+		return cbor.Marshal(nil)
+	}
+	return m, nil
+}
+
+func (m *RawMessage) UnmarshalCBOR(data []byte) error {
+	if m == nil {
+		return errors.New("hertzrpc: UnmarshalCBOR on nil pointer")
+	}
+	*m = append((*m)[0:0], data...)
+	return nil
+}
+
+// JSONCodec is the default codec, using encoding/json.
+type JSONCodec struct{}
+
+func (JSONCodec) Name() string        { return "json" }
+func (JSONCodec) ContentType() string { return defaultContentType }
+
+func (JSONCodec) Marshal(v interface{}) ([]byte, error) { return json.Marshal(v) }
+
+func (JSONCodec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+
+// MsgpackCodec encodes MessagePack, honoring `json` struct tags.
+type MsgpackCodec struct{}
+
+func (MsgpackCodec) Name() string        { return "msgpack" }
+func (MsgpackCodec) ContentType() string { return "application/msgpack" }
+
+func (MsgpackCodec) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (MsgpackCodec) Unmarshal(data []byte, v interface{}) error {
+	dec := msgpack.NewDecoder(bytes.NewReader(data))
+	dec.SetCustomStructTag("json")
+	dec.UseLooseInterfaceDecoding(true)
+	return dec.Decode(v)
+}
+
+// CBORCodec encodes CBOR (RFC 8949). fxamacker/cbor falls back to `json`
+// struct tags when no `cbor` tag is present.
+type CBORCodec struct{}
+
+// cborDecMode decodes maps inside interface{} values the way encoding/json
+// does, so Id and untyped params look the same across codecs.
+var cborDecMode, _ = cbor.DecOptions{
+	DefaultMapType: reflect.TypeOf(map[string]interface{}(nil)),
+}.DecMode()
+
+func (CBORCodec) Name() string        { return "cbor" }
+func (CBORCodec) ContentType() string { return "application/cbor" }
+
+func (CBORCodec) Marshal(v interface{}) ([]byte, error) { return cbor.Marshal(v) }
+
+func (CBORCodec) Unmarshal(data []byte, v interface{}) error { return cborDecMode.Unmarshal(data, v) }
+
+// mediaAliases maps non-canonical media types onto the codec content type.
+var mediaAliases = map[string]string{
+	"application/x-msgpack":   "application/msgpack",
+	"application/vnd.msgpack": "application/msgpack",
+	"text/json":               defaultContentType,
+}
+
+func mediaType(s string) string {
+	mt, _, err := mime.ParseMediaType(s)
+	if err != nil {
+		return strings.ToLower(strings.TrimSpace(s))
+	}
+	if alias, ok := mediaAliases[mt]; ok {
+		return alias
+	}
+	return mt
+}
+
+// codecFor returns the codec registered for the given media type.
+func (d *Dispatcher) codecFor(contentType string) (Codec, bool) {
+	mt := mediaType(contentType)
+	for _, c := range d.codecs {
+		if c.ContentType() == mt {
+			return c, true
+		}
+	}
+	return nil, false
+}
+
+// codecByName returns the codec with the given Name.
+func (d *Dispatcher) codecByName(name string) (Codec, bool) {
+	for _, c := range d.codecs {
+		if c.Name() == name {
+			return c, true
+		}
+	}
+	return nil, false
+}
+
+// requestCodec picks the codec for the request body from Content-Type,
+// falling back to the default codec.
+func (d *Dispatcher) requestCodec(contentType string) Codec {
+	if c, ok := d.codecFor(contentType); ok {
+		return c
+	}
+	return d.codecs[0]
+}
+
+// responseCodec picks the codec for the reply from the Accept header,
+// preferring higher q-values. Wildcards and unknown types fall back to
+// the request codec.
+func (d *Dispatcher) responseCodec(accept string, in Codec) Codec {
+	type candidate struct {
+		codec Codec
+		q     float64
+	}
+	var candidates []candidate
+	for _, part := range strings.Split(accept, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		q := 1.0
+		if _, params, err := mime.ParseMediaType(part); err == nil {
+			if v, ok := params["q"]; ok {
+				if f, err := strconv.ParseFloat(v, 64); err == nil {
+					q = f
+				}
+			}
+		}
+		if q <= 0 {
+			continue
+		}
+		if c, ok := d.codecFor(part); ok {
+			candidates = append(candidates, candidate{c, q})
+		}
+	}
+	if len(candidates) == 0 {
+		return in
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].q > candidates[j].q })
+	return candidates[0].codec
+}
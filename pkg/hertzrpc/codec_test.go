@@ -0,0 +1,127 @@
+package hertzrpc
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+var testCodecs = []Codec{JSONCodec{}, MsgpackCodec{}, CBORCodec{}}
+
+func TestCodecRoundTrip(t *testing.T) {
+	for _, codec := range testCodecs {
+		t.Run(codec.Name(), func(t *testing.T) {
+			param, err := codec.Marshal(EchoArgs{Name: "a"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			in := Request{JsonRpc: "2.0", Method: "Echo.Say", Params: []RawMessage{param}, Id: "x"}
+			data, err := codec.Marshal(in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var out Request
+			if err := codec.Unmarshal(data, &out); err != nil {
+				t.Fatal(err)
+			}
+			if out.Method != in.Method || out.Id != "x" || len(out.Params) != 1 {
+				t.Fatalf("decoded %+v, want %+v", out, in)
+			}
+			// Params stay in the wire format until the ArgType is known
+			var args EchoArgs
+			if err := codec.Unmarshal(out.Params[0], &args); err != nil || args.Name != "a" {
+				t.Fatalf("param decoded to %+v, %v", args, err)
+			}
+		})
+	}
+}
+
+// TestCodecJSONTags checks that every codec names fields by their json tag.
+func TestCodecJSONTags(t *testing.T) {
+	for _, codec := range testCodecs {
+		t.Run(codec.Name(), func(t *testing.T) {
+			data, err := codec.Marshal(EchoReply{Message: "hi"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var m map[string]interface{}
+			if err := codec.Unmarshal(data, &m); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(m, map[string]interface{}{"message": "hi"}) {
+				t.Fatalf("decoded %#v", m)
+			}
+		})
+	}
+}
+
+func TestDispatcherCodecs(t *testing.T) {
+	d := newTestDispatcher(t)
+	for _, codec := range testCodecs {
+		t.Run(codec.Name(), func(t *testing.T) {
+			param, _ := codec.Marshal(EchoArgs{Name: "a"})
+			body, _ := codec.Marshal(Request{JsonRpc: "2.0", Method: "Echo.Say", Params: []RawMessage{param}, Id: 1})
+			info := &CallInfo{Header: http.Header{}}
+			resp := d.serve(context.Background(), codec, body, info).(Response)
+			if resp.Error != nil || resp.Result.(*EchoReply).Message != "hello a" {
+				t.Fatalf("response %+v", resp)
+			}
+		})
+	}
+}
+
+func TestRequestCodec(t *testing.T) {
+	d := NewDispatcher()
+	tests := []struct {
+		contentType, want string
+	}{
+		{"application/json", "json"},
+		{"application/json; charset=utf-8", "json"},
+		{"text/json", "json"},
+		{"application/msgpack", "msgpack"},
+		{"application/x-msgpack", "msgpack"},
+		{"application/vnd.msgpack", "msgpack"},
+		{"Application/CBOR", "cbor"},
+		{"", "json"},
+		{"text/plain", "json"},
+	}
+	for _, tt := range tests {
+		if got := d.requestCodec(tt.contentType).Name(); got != tt.want {
+			t.Errorf("requestCodec(%q) = %s, want %s", tt.contentType, got, tt.want)
+		}
+	}
+}
+
+func TestResponseCodec(t *testing.T) {
+	d := NewDispatcher()
+	tests := []struct {
+		accept string
+		in     Codec
+		want   string
+	}{
+		{"", MsgpackCodec{}, "msgpack"},
+		{"*/*", CBORCodec{}, "cbor"},
+		{"application/cbor", JSONCodec{}, "cbor"},
+		{"application/json;q=0.5, application/msgpack", JSONCodec{}, "msgpack"},
+		{"application/msgpack;q=0.2, application/cbor;q=0.9", JSONCodec{}, "cbor"},
+		{"application/cbor;q=0, application/json", MsgpackCodec{}, "json"},
+		{"text/html, application/xml", CBORCodec{}, "cbor"},
+	}
+	for _, tt := range tests {
+		if got := d.responseCodec(tt.accept, tt.in).Name(); got != tt.want {
+			t.Errorf("responseCodec(%q, %s) = %s, want %s", tt.accept, tt.in.Name(), got, tt.want)
+		}
+	}
+}
+
+func TestWithCodecReplaces(t *testing.T) {
+	type customJSON struct{ JSONCodec }
+	d := NewDispatcher(WithCodec(customJSON{}))
+	if len(d.codecs) != 3 {
+		t.Fatalf("%d codecs, want 3", len(d.codecs))
+	}
+	if _, ok := d.requestCodec("application/json").(customJSON); !ok {
+		t.Fatal("WithCodec did not replace the JSON codec")
+	}
+}
@@ -0,0 +1,219 @@
+package hertzrpc
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Content codings understood by the Dispatcher.
+const (
+	EncodingGzip   = "gzip"
+	EncodingZstd   = "zstd"
+	EncodingBrotli = "br"
+)
+
+// DefaultMinCompressSize is the reply size below which compression is skipped.
+const DefaultMinCompressSize = 1024
+
+var zstdEncoder, _ = zstd.NewWriter(nil)
+
+type compression struct {
+	encodings []string // preference order when the client has no preference
+	minSize   int
+}
+
+// WithCompression enables response compression for replies of at least
+// minSize bytes, negotiated through Accept-Encoding. Encodings lists the
+// codings to offer in preference order and defaults to br, zstd and gzip.
+// Compressed request bodies are always accepted.
+func WithCompression(minSize int, encodings ...string) Option {
+	if minSize <= 0 {
+		minSize = DefaultMinCompressSize
+	}
+	if len(encodings) == 0 {
+		encodings = []string{EncodingBrotli, EncodingZstd, EncodingGzip}
+	}
+	return func(d *Dispatcher) {
+		d.compression = &compression{encodings: encodings, minSize: minSize}
+	}
+}
+
+// negotiate picks a coding from an Accept-Encoding header. Higher q-values
+// win; ties go to the earlier coding in c.encodings.
+func (c *compression) negotiate(acceptEncoding string) string {
+	best, bestQ, bestRank := "", 0.0, len(c.encodings)
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, q := parseQuality(part)
+		for rank, enc := range c.encodings {
+			if (name != enc && name != "*") || q <= 0 {
+				continue
+			}
+			if q > bestQ || (q == bestQ && rank < bestRank) {
+				best, bestQ, bestRank = enc, q, rank
+			}
+		}
+	}
+	return best
+}
+
+func parseQuality(part string) (string, float64) {
+	name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+	q := 1.0
+	for _, p := range strings.Split(params, ";") {
+		k, v, ok := strings.Cut(strings.TrimSpace(p), "=")
+		if ok && strings.EqualFold(k, "q") {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+	}
+	return strings.ToLower(strings.TrimSpace(name)), q
+}
+
+func compress(encoding string, data []byte) ([]byte, error) {
+	if encoding == EncodingZstd {
+		return zstdEncoder.EncodeAll(data, nil), nil
+	}
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch encoding {
+	case EncodingGzip:
+		w = gzip.NewWriter(&buf)
+	case EncodingBrotli:
+		w = brotli.NewWriterLevel(&buf, brotli.DefaultCompression)
+	default:
+		return nil, fmt.Errorf("hertzrpc: unsupported content coding %q", encoding)
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decompress decodes a request body according to its Content-Encoding.
+// Stacked codings are undone in reverse order. A positive limit caps the
+// decoded size and yields errBodyTooLarge when exceeded.
+func decompress(contentEncoding string, body []byte, limit int) ([]byte, error) {
+	codings := strings.Split(contentEncoding, ",")
+	for i := len(codings) - 1; i >= 0; i-- {
+		var r io.Reader
+		src := bytes.NewReader(body)
+		switch enc := strings.ToLower(strings.TrimSpace(codings[i])); enc {
+		case "", "identity":
+			continue
+		case EncodingGzip, "x-gzip":
+			zr, err := gzip.NewReader(src)
+			if err != nil {
+				return nil, err
+			}
+			r = zr
+		case "deflate":
+			r = flate.NewReader(src)
+		case EncodingBrotli:
+			r = brotli.NewReader(src)
+		case EncodingZstd:
+			zr, err := zstd.NewReader(src, zstd.WithDecoderConcurrency(1))
+			if err != nil {
+				return nil, err
+			}
+			defer zr.Close()
+			r = zr
+		default:
+			return nil, fmt.Errorf("unsupported Content-Encoding %q", enc)
+		}
+		if limit > 0 {
+			r = io.LimitReader(r, int64(limit)+1)
+		}
+		out, err := io.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		if limit > 0 && len(out) > limit {
+			return nil, errBodyTooLarge
+		}
+		body = out
+	}
+	return body, nil
+}
+
+// CompressionStat summarises the traffic sent with one coding.
+type CompressionStat struct {
+	Messages  int64   `json:"messages"`
+	RawBytes  int64   `json:"rawBytes"`
+	WireBytes int64   `json:"wireBytes"`
+	Ratio     float64 `json:"ratio"` // WireBytes / RawBytes
+}
+
+type compressionCounter struct {
+	messages, raw, wire atomic.Int64
+}
+
+// CompressionMetrics counts raw and on-the-wire bytes per coding.
+// The zero value is ready to use.
+type CompressionMetrics struct {
+	counters sync.Map // coding -> *compressionCounter
+}
+
+// Observe records one message of raw bytes that went out as wire bytes.
+func (m *CompressionMetrics) Observe(encoding string, raw, wire int) {
+	v, _ := m.counters.LoadOrStore(encoding, new(compressionCounter))
+	c := v.(*compressionCounter)
+	c.messages.Add(1)
+	c.raw.Add(int64(raw))
+	c.wire.Add(int64(wire))
+}
+
+// ObserveDeflate records a WebSocket message sent with permessage-deflate
+// at level. The websocket package does not expose frame sizes, so the
+// compressed size is recomputed with compress/flate, which is deterministic
+// and matches the no-context-takeover frames on the wire.
+func (m *CompressionMetrics) ObserveDeflate(data []byte, level int) {
+	var n countingWriter
+	fw, err := flate.NewWriter(&n, level)
+	if err != nil {
+		return
+	}
+	_, _ = fw.Write(data)
+	_ = fw.Flush()
+	// permessage-deflate strips the trailing 0x00 0x00 0xff 0xff.
+	m.Observe("permessage-deflate", len(data), int(n)-4)
+}
+
+// Snapshot returns the current totals keyed by coding.
+func (m *CompressionMetrics) Snapshot() map[string]CompressionStat {
+	out := make(map[string]CompressionStat)
+	m.counters.Range(func(k, v interface{}) bool {
+		c := v.(*compressionCounter)
+		stat := CompressionStat{
+			Messages:  c.messages.Load(),
+			RawBytes:  c.raw.Load(),
+			WireBytes: c.wire.Load(),
+		}
+		if stat.RawBytes > 0 {
+			stat.Ratio = float64(stat.WireBytes) / float64(stat.RawBytes)
+		}
+		out[k.(string)] = stat
+		return true
+	})
+	return out
+}
+
+type countingWriter int
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	*w += countingWriter(len(p))
+	return len(p), nil
+}
@@ -0,0 +1,137 @@
+package hertzrpc
+
+import (
+	"bytes"
+	"compress/flate"
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/cloudwego/hertz/pkg/app"
+)
+
+func TestNegotiate(t *testing.T) {
+	c := &compression{encodings: []string{EncodingBrotli, EncodingZstd, EncodingGzip}}
+	tests := []struct {
+		accept, want string
+	}{
+		{"", ""},
+		{"identity", ""},
+		{"gzip", "gzip"},
+		{"gzip, zstd", "zstd"},
+		{"gzip, deflate, br", "br"},
+		{"br;q=0.5, gzip", "gzip"},
+		{"br;q=0, zstd;q=0", ""},
+		{"*", "br"},
+		{"*;q=0.1, gzip;q=0.5", "gzip"},
+		{" GZIP ; Q=0.8 ", "gzip"},
+		{"deflate", ""},
+	}
+	for _, tt := range tests {
+		if got := c.negotiate(tt.accept); got != tt.want {
+			t.Errorf("negotiate(%q) = %q, want %q", tt.accept, got, tt.want)
+		}
+	}
+}
+
+func TestCompressRoundTrip(t *testing.T) {
+	data := []byte(strings.Repeat(`{"jsonrpc":"2.0","result":"hello"}`, 100))
+	for _, enc := range []string{EncodingGzip, EncodingZstd, EncodingBrotli} {
+		t.Run(enc, func(t *testing.T) {
+			z, err := compress(enc, data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(z) >= len(data) {
+				t.Fatalf("%d bytes compressed to %d", len(data), len(z))
+			}
+			out, err := decompress(enc, z, 0)
+			if err != nil || !bytes.Equal(out, data) {
+				t.Fatalf("decompress = %d bytes, %v", len(out), err)
+			}
+			if _, err := decompress(enc, z, len(data)-1); !errors.Is(err, errBodyTooLarge) {
+				t.Fatalf("decompress over the limit = %v, want errBodyTooLarge", err)
+			}
+		})
+	}
+	if _, err := compress("deflate", data); err == nil {
+		t.Error("compress accepted deflate")
+	}
+}
+
+func TestDecompress(t *testing.T) {
+	data := []byte("hello, hello, hello")
+	gz, _ := compress(EncodingGzip, data)
+	stacked, _ := compress(EncodingBrotli, gz)
+	var fl bytes.Buffer
+	fw, _ := flate.NewWriter(&fl, flate.BestSpeed)
+	fw.Write(data)
+	fw.Close()
+
+	tests := []struct {
+		name, encoding string
+		body           []byte
+		wantErr        bool
+	}{
+		{"identity", "identity", data, false},
+		{"none", "", data, false},
+		{"x-gzip", "x-gzip", gz, false},
+		{"deflate", "deflate", fl.Bytes(), false},
+		{"stacked", "gzip, br", stacked, false},
+		{"unknown", "compress", data, true},
+		{"corrupt", "gzip", data, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := decompress(tt.encoding, tt.body, 1024)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("no error")
+				}
+				return
+			}
+			if err != nil || !bytes.Equal(out, data) {
+				t.Fatalf("decompress = %q, %v", out, err)
+			}
+		})
+	}
+}
+
+func TestHandleCompression(t *testing.T) {
+	d := NewDispatcher(WithCompression(16))
+	if err := d.RegisterName("Echo", Echo{}); err != nil {
+		t.Fatal(err)
+	}
+	name := strings.Repeat("a", 100)
+	body, _ := compress(EncodingGzip, []byte(`{"id":1,"method":"Echo.Say","params":[{"name":"`+name+`"}]}`))
+
+	c := app.NewContext(0)
+	c.Request.SetMethod(http.MethodPost)
+	c.Request.Header.Set("Content-Encoding", "gzip")
+	c.Request.Header.Set("Accept-Encoding", "zstd;q=0.5, gzip")
+	c.Request.SetBody(body)
+	d.Handle(context.Background(), c)
+
+	if got := string(c.Response.Header.Peek("Content-Encoding")); got != EncodingGzip {
+		t.Fatalf("Content-Encoding %q, want gzip", got)
+	}
+	out, err := decompress(EncodingGzip, c.Response.Body(), 0)
+	if err != nil || !strings.Contains(string(out), "hello "+name) {
+		t.Fatalf("reply %q, %v", out, err)
+	}
+	stat := d.CompressionMetrics().Snapshot()[EncodingGzip]
+	if stat.Messages != 1 || stat.WireBytes != int64(len(c.Response.Body())) || stat.RawBytes != int64(len(out)) {
+		t.Fatalf("metrics %+v", stat)
+	}
+}
+
+func TestObserveDeflate(t *testing.T) {
+	var m CompressionMetrics
+	m.ObserveDeflate([]byte(strings.Repeat("x", 1000)), flate.BestSpeed)
+	stat := m.Snapshot()["permessage-deflate"]
+	if stat.Messages != 1 || stat.RawBytes != 1000 || stat.WireBytes <= 0 || stat.Ratio >= 1 {
+		t.Fatalf("metrics %+v", stat)
+	}
+}
@@ -2,24 +2,29 @@ package hertzrpc
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
 	"reflect"
 	"strings"
 	"sync"
 	"unicode"
 	"unicode/utf8"
 
+	"awesomeproject/pkg/shutdown"
+	"awesomeproject/pkg/tlsutil"
+
 	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/network"
 	"github.com/cloudwego/hertz/pkg/protocol/consts"
 )
 
 // Standard JSON-RPC 2.0 Request
 type Request struct {
-	JsonRpc string            `json:"jsonrpc"`
-	Method  string            `json:"method"`
-	Params  []json.RawMessage `json:"params"`
-	Id      interface{}       `json:"id"`
+	JsonRpc string       `json:"jsonrpc"`
+	Method  string       `json:"method"`
+	Params  []RawMessage `json:"params"`
+	Id      interface{}  `json:"id"`
 }
 
 // Standard JSON-RPC 2.0 Response
@@ -31,8 +36,9 @@ type Response struct {
 }
 
 type Error struct {
-	Code    int    `json:"code"`
-	Message string `json:"message"`
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
 }
 
 type service struct {
@@ -46,17 +52,55 @@ type methodType struct {
 	method    reflect.Method
 	ArgType   reflect.Type
 	ReplyType reflect.Type
+	WithCtx   bool // method takes a leading context.Context
 }
 
+var typeOfContext = reflect.TypeOf((*context.Context)(nil)).Elem()
+
 type Dispatcher struct {
 	mu         sync.RWMutex
 	serviceMap map[string]*service
+	codecs     []Codec // codecs[0] is the default
+
+	compression *compression // nil disables response compression
+	metrics     CompressionMetrics
+	limits      Limits
+
+	interceptors []Interceptor
+
+	// inflight tracks HTTP calls and WebSocket connections for Shutdown
+	inflight shutdown.Group
+}
+
+// Option configures a Dispatcher.
+type Option func(*Dispatcher)
+
+// WithCodec registers an additional codec, replacing any codec that
+// already serves the same content type.
+func WithCodec(c Codec) Option {
+	return func(d *Dispatcher) {
+		for i, existing := range d.codecs {
+			if existing.ContentType() == c.ContentType() {
+				d.codecs[i] = c
+				return
+			}
+		}
+		d.codecs = append(d.codecs, c)
+	}
 }
 
-func NewDispatcher() *Dispatcher {
-	return &Dispatcher{
+// NewDispatcher returns a Dispatcher that speaks JSON by default and also
+// MessagePack and CBOR when the client asks for them.
+func NewDispatcher(opts ...Option) *Dispatcher {
+	d := &Dispatcher{
 		serviceMap: make(map[string]*service),
+		codecs:     []Codec{JSONCodec{}, MsgpackCodec{}, CBORCodec{}},
+		limits:     DefaultLimits,
 	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
 }
 
 // Is this an exported - upper case - name?
@@ -76,7 +120,9 @@ func isExportedOrBuiltinType(t reflect.Type) bool {
 }
 
 // RegisterName registers the service with the given name.
-// It mimics net/rpc's registration logic.
+// It mimics net/rpc's registration logic, and additionally accepts methods
+// of the form Method(ctx context.Context, args T, reply *R) error, whose
+// ctx carries values set by interceptors such as the caller's Principal.
 func (d *Dispatcher) RegisterName(name string, rcvr interface{}) error {
 	d.mu.Lock()
 	defer d.mu.Unlock()
@@ -98,17 +144,22 @@ func (d *Dispatcher) RegisterName(name string, rcvr interface{}) error {
 			continue
 		}
 
-		// Method needs three ins: receiver, *args, *reply.
-		if mtype.NumIn() != 3 {
+		// Method needs three ins: receiver, *args, *reply,
+		// optionally preceded by a context.Context.
+		first := 1
+		if mtype.NumIn() == 4 && mtype.In(1) == typeOfContext {
+			first = 2
+		}
+		if mtype.NumIn() != first+2 {
 			continue
 		}
 		// First arg need not be a pointer.
-		argType := mtype.In(1)
+		argType := mtype.In(first)
 		if !isExportedOrBuiltinType(argType) {
 			continue
 		}
 		// Second arg must be a pointer.
-		replyType := mtype.In(2)
+		replyType := mtype.In(first + 1)
 		if replyType.Kind() != reflect.Ptr {
 			continue
 		}
@@ -123,7 +174,7 @@ func (d *Dispatcher) RegisterName(name string, rcvr interface{}) error {
 		if returnType := mtype.Out(0); returnType != reflect.TypeOf((*error)(nil)).Elem() {
 			continue
 		}
-		s.method[mname] = &methodType{method: method, ArgType: argType, ReplyType: replyType}
+		s.method[mname] = &methodType{method: method, ArgType: argType, ReplyType: replyType, WithCtx: first == 2}
 	}
 
 	if len(s.method) == 0 {
@@ -133,24 +184,119 @@ func (d *Dispatcher) RegisterName(name string, rcvr interface{}) error {
 	return nil
 }
 
-// Handle processes the Hertz request as a JSON-RPC request
+// Handle processes the Hertz request as a JSON-RPC request or batch.
+// The request codec is chosen by Content-Type and the reply codec by Accept.
+// Compressed request bodies are decoded according to Content-Encoding.
 func (d *Dispatcher) Handle(ctx context.Context, c *app.RequestContext) {
-	var req Request
-	// Use BindAndValidate or just Bind. Bind checks Content-Type.
-	if err := c.Bind(&req); err != nil {
-		c.JSON(consts.StatusOK, Response{
-			JsonRpc: "2.0",
-			Error:   &Error{Code: -32700, Message: "Parse error: " + err.Error()},
-			Id:      nil,
-		})
+	in := d.requestCodec(string(c.ContentType()))
+	out := d.responseCodec(string(c.GetHeader("Accept")), in)
+	done, err := d.inflight.Add(nil, nil)
+	if err != nil {
+		d.write(c, out, errorResponse(nil, CodeUnavailable, "Server is shutting down"))
+		return
+	}
+	defer done()
+	if max := d.limits.MaxBodySize; max > 0 && len(c.Request.Body()) > max {
+		d.write(c, out, errorResponse(nil, CodeBodyTooLarge, fmt.Sprintf("Request too large: body exceeds %d bytes", max)))
+		return
+	}
+	body, err := decompress(string(c.GetHeader("Content-Encoding")), c.Request.Body(), d.limits.MaxBodySize)
+	if errors.Is(err, errBodyTooLarge) {
+		d.write(c, out, errorResponse(nil, CodeBodyTooLarge, fmt.Sprintf("Request too large: decoded body exceeds %d bytes", d.limits.MaxBodySize)))
+		return
+	}
+	if err != nil {
+		c.Response.Header.Set("Accept-Encoding", "gzip, deflate, br, zstd")
+		d.write(c, out, errorResponse(nil, CodeParseError, "Parse error: "+err.Error()))
 		return
 	}
+	info := requestInfo(c)
+	d.write(c, out, d.serve(peerContext(ctx, info), in, body, info))
+}
+
+// requestInfo captures the transport details handed to interceptors.
+func requestInfo(c *app.RequestContext) *CallInfo {
+	info := &CallInfo{RemoteAddr: c.RemoteAddr().String(), Header: make(http.Header)}
+	c.Request.Header.VisitAll(func(k, v []byte) {
+		info.Header.Add(string(k), string(v))
+	})
+	if conn, ok := c.GetConn().(network.ConnTLSer); ok {
+		state := conn.ConnectionState()
+		info.Peer = tlsutil.IdentityFromState(&state)
+	}
+	return info
+}
+
+// peerContext makes the client certificate available to methods through
+// tlsutil.PeerFromContext.
+func peerContext(ctx context.Context, info *CallInfo) context.Context {
+	if info.Peer == nil {
+		return ctx
+	}
+	return tlsutil.NewContext(ctx, info.Peer)
+}
+
+// Shutdown refuses new calls and WebSocket connections, sends connected
+// WebSocket peers a going-away close frame once their current call has
+// been answered, and waits for in-flight calls to finish. When ctx is done
+// it closes the connections still open and returns without waiting for
+// calls that are still running.
+func (d *Dispatcher) Shutdown(ctx context.Context) error {
+	return d.inflight.Shutdown(ctx)
+}
 
+// CompressionMetrics reports how much response compression has saved.
+func (d *Dispatcher) CompressionMetrics() *CompressionMetrics {
+	return &d.metrics
+}
+
+// serve decodes a single request or a batch with codec and returns the
+// Response or []Response to send back.
+func (d *Dispatcher) serve(ctx context.Context, codec Codec, body []byte, info *CallInfo) interface{} {
+	if dc, ok := codec.(depthChecker); ok && d.limits.MaxDepth > 0 {
+		if err := dc.checkDepth(body, d.limits.MaxDepth); errors.Is(err, errTooDeep) {
+			return errorResponse(nil, CodeTooDeep, fmt.Sprintf("Request too deep: nesting exceeds %d levels", d.limits.MaxDepth))
+		} else if err != nil {
+			return errorResponse(nil, CodeParseError, "Parse error: "+err.Error())
+		}
+	}
+
+	// A batch is an array of requests; a single request does not decode
+	// as an array in any codec.
+	var batch []RawMessage
+	if err := codec.Unmarshal(body, &batch); err == nil {
+		if len(batch) == 0 {
+			return errorResponse(nil, CodeInvalidRequest, "Invalid Request: empty batch")
+		}
+		if max := d.limits.MaxBatch; max > 0 && len(batch) > max {
+			return errorResponse(nil, CodeBatchTooLarge, fmt.Sprintf("Batch too large: %d requests exceeds %d", len(batch), max))
+		}
+		resps := make([]Response, len(batch))
+		for i, raw := range batch {
+			var req Request
+			if err := codec.Unmarshal(raw, &req); err != nil {
+				resps[i] = errorResponse(nil, CodeInvalidRequest, "Invalid Request: "+err.Error())
+				continue
+			}
+			resps[i] = d.call(ctx, codec, &req, info)
+		}
+		return resps
+	}
+
+	var req Request
+	if err := codec.Unmarshal(body, &req); err != nil {
+		return errorResponse(nil, CodeParseError, "Parse error: "+err.Error())
+	}
+	return d.call(ctx, codec, &req, info)
+}
+
+// call dispatches a decoded request to the registered method through the
+// interceptor chain.
+func (d *Dispatcher) call(ctx context.Context, codec Codec, req *Request, transport *CallInfo) Response {
 	// 1. Parse Method Name (Service.Method)
 	dot := strings.LastIndex(req.Method, ".")
 	if dot < 0 {
-		d.sendError(c, req.Id, -32600, "Invalid Request: Method name format Service.Method")
-		return
+		return errorResponse(req.Id, CodeInvalidRequest, "Invalid Request: Method name format Service.Method")
 	}
 	serviceName := req.Method[:dot]
 	methodName := req.Method[dot+1:]
@@ -160,69 +306,129 @@ func (d *Dispatcher) Handle(ctx context.Context, c *app.RequestContext) {
 	svc, ok := d.serviceMap[serviceName]
 	d.mu.RUnlock()
 	if !ok {
-		d.sendError(c, req.Id, -32601, fmt.Sprintf("Service not found: %s", serviceName))
-		return
+		return errorResponse(req.Id, CodeMethodNotFound, fmt.Sprintf("Service not found: %s", serviceName))
 	}
 
 	// 3. Look up Method
 	mtype, ok := svc.method[methodName]
 	if !ok {
-		d.sendError(c, req.Id, -32601, fmt.Sprintf("Method not found: %s", methodName))
-		return
+		return errorResponse(req.Id, CodeMethodNotFound, fmt.Sprintf("Method not found: %s", methodName))
 	}
 
-	// 4. Parse Args
-	var argv reflect.Value
-	argIsValue := false // if true, need to indirect before calling.
-
-	if mtype.ArgType.Kind() == reflect.Ptr {
-		argv = reflect.New(mtype.ArgType.Elem())
-	} else {
-		argv = reflect.New(mtype.ArgType)
-		argIsValue = true
+	// 4. Bound the params before anything else looks at them
+	if max := d.limits.MaxParamsSize; max > 0 {
+		size := 0
+		for _, p := range req.Params {
+			size += len(p)
+		}
+		if size > max {
+			return errorResponse(req.Id, CodeParamsTooLarge, fmt.Sprintf("Params too large: %d bytes exceeds %d", size, max))
+		}
 	}
 
-	// argument unmarshaling
-	if len(req.Params) > 0 {
-		// We assume params[0] corresponds to the first argument
-		// (This supports positional params with length 1, which matches standard Go RPC)
-		if err := json.Unmarshal(req.Params[0], argv.Interface()); err != nil {
-			d.sendError(c, req.Id, -32602, "Invalid params: "+err.Error())
-			return
+	// 5. Decode, validate and call. Interceptors run first, so that
+	// authentication, authorization and rate limits are decided before
+	// any work is spent on the params or their shape is revealed.
+	invoke := func(ctx context.Context, _ interface{}) (interface{}, error) {
+		var argv reflect.Value
+		argIsValue := false // if true, need to indirect before calling.
+
+		if mtype.ArgType.Kind() == reflect.Ptr {
+			argv = reflect.New(mtype.ArgType.Elem())
+		} else {
+			argv = reflect.New(mtype.ArgType)
+			argIsValue = true
 		}
-	}
 
-	if argIsValue {
-		argv = argv.Elem()
-	}
+		// argument unmarshaling
+		if len(req.Params) > 0 {
+			// We assume params[0] corresponds to the first argument
+			// (This supports positional params with length 1, which matches standard Go RPC)
+			if err := codec.Unmarshal(req.Params[0], argv.Interface()); err != nil {
+				return nil, &Error{Code: CodeInvalidParams, Message: "Invalid params: " + err.Error()}
+			}
+		}
 
-	// 5. Prepare Reply
-	replyv := reflect.New(mtype.ReplyType.Elem())
+		// validate struct tags and the optional Validate method
+		if fieldErrs := validateArg(argv.Interface()); len(fieldErrs) > 0 {
+			return nil, &Error{Code: CodeInvalidParams, Message: "Invalid params: " + fieldErrs.Error(), Data: fieldErrs}
+		}
 
-	// 6. Call
-	function := mtype.method.Func
-	// Func.Call expects [Receiver, Arg, Reply]
-	returnValues := function.Call([]reflect.Value{svc.rcvr, argv, replyv})
+		if argIsValue {
+			argv = argv.Elem()
+		}
 
-	// 7. Check Error
-	errInter := returnValues[0].Interface()
-	if errInter != nil {
-		d.sendError(c, req.Id, -32000, errInter.(error).Error())
-		return
+		replyv := reflect.New(mtype.ReplyType.Elem())
+		function := mtype.method.Func
+		// Func.Call expects [Receiver, (Context,) Arg, Reply]
+		in := []reflect.Value{svc.rcvr, argv, replyv}
+		if mtype.WithCtx {
+			in = []reflect.Value{svc.rcvr, reflect.ValueOf(ctx), argv, replyv}
+		}
+		returnValues := function.Call(in)
+		if errInter := returnValues[0].Interface(); errInter != nil {
+			return nil, errInter.(error)
+		}
+		return replyv.Interface(), nil
 	}
+	var rawArg interface{}
+	if len(req.Params) > 0 {
+		rawArg = req.Params[0]
+	}
+	info := *transport
+	info.Method = req.Method
+	result, err := d.chain(&info, invoke)(ctx, rawArg)
 
-	// 8. Success
-	c.JSON(consts.StatusOK, Response{
+	// 6. Check Error
+	if err != nil {
+		return Response{JsonRpc: "2.0", Error: errorFrom(err), Id: req.Id}
+	}
+
+	// 7. Success
+	return Response{
 		JsonRpc: "2.0",
-		Result:  replyv.Interface(),
+		Result:  result,
 		Id:      req.Id,
-	})
+	}
+}
+
+func (d *Dispatcher) write(c *app.RequestContext, codec Codec, resp interface{}) {
+	data := encode(codec, resp)
+	if d.compression != nil {
+		c.Response.Header.Set("Vary", "Accept-Encoding")
+		if len(data) >= d.compression.minSize {
+			if enc := d.compression.negotiate(string(c.GetHeader("Accept-Encoding"))); enc != "" {
+				if z, err := compress(enc, data); err == nil {
+					d.metrics.Observe(enc, len(data), len(z))
+					c.Response.Header.Set("Content-Encoding", enc)
+					data = z
+				}
+			}
+		}
+	}
+	// Failed calls are answered with 200 too: JSON-RPC clients such as
+	// pyhttpRpcClient.py read the error from the envelope.
+	c.Data(consts.StatusOK, codec.ContentType(), data)
 }
 
-func (d *Dispatcher) sendError(c *app.RequestContext, id interface{}, code int, msg string) {
-	c.JSON(consts.StatusOK, Response{
+// encode marshals a Response or []Response with codec. If the reply itself
+// cannot be encoded, an internal error envelope is returned instead.
+func encode(codec Codec, resp interface{}) []byte {
+	data, err := codec.Marshal(resp)
+	if err != nil {
+		var id interface{}
+		if r, ok := resp.(Response); ok {
+			id = r.Id
+		}
+		data, _ = codec.Marshal(errorResponse(id, CodeInternalError, "Internal error: "+err.Error()))
+	}
+	return data
+}
+
+func errorResponse(id interface{}, code int, msg string) Response {
+	return Response{
 		JsonRpc: "2.0",
 		Error:   &Error{Code: code, Message: msg},
 		Id:      id,
-	})
+	}
 }
@@ -0,0 +1,161 @@
+package hertzrpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type EchoArgs struct {
+	Name string `json:"name" validate:"required"`
+}
+
+type EchoReply struct {
+	Message string `json:"message"`
+}
+
+type Echo struct{}
+
+func (Echo) Say(args *EchoArgs, reply *EchoReply) error {
+	reply.Message = "hello " + args.Name
+	return nil
+}
+
+func (Echo) Fail(args *EchoArgs, reply *EchoReply) error {
+	return errors.New("boom")
+}
+
+func (Echo) Missing(args *EchoArgs, reply *EchoReply) error {
+	return status.Error(codes.NotFound, "no such user")
+}
+
+func newTestDispatcher(t *testing.T, opts ...Option) *Dispatcher {
+	t.Helper()
+	d := NewDispatcher(opts...)
+	if err := d.RegisterName("Echo", Echo{}); err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func serveJSON(d *Dispatcher, body string) Response {
+	info := &CallInfo{RemoteAddr: "192.0.2.1:1234", Header: http.Header{}}
+	return d.serve(context.Background(), JSONCodec{}, []byte(body), info).(Response)
+}
+
+func TestDispatcherInterceptorsRunBeforeParams(t *testing.T) {
+	deny := func(ctx context.Context, info *CallInfo, arg interface{}, next Handler) (interface{}, error) {
+		return nil, &Error{Code: CodeUnauthenticated, Message: "Unauthenticated"}
+	}
+	allow := func(ctx context.Context, info *CallInfo, arg interface{}, next Handler) (interface{}, error) {
+		return next(ctx, arg)
+	}
+	tests := []struct {
+		name        string
+		interceptor Interceptor
+		body        string
+		wantCode    int
+	}{
+		{"denied valid params", deny, `{"id":1,"method":"Echo.Say","params":[{"name":"a"}]}`, CodeUnauthenticated},
+		{"denied invalid params", deny, `{"id":1,"method":"Echo.Say","params":[{"name":""}]}`, CodeUnauthenticated},
+		{"denied undecodable params", deny, `{"id":1,"method":"Echo.Say","params":[{"name":7}]}`, CodeUnauthenticated},
+		{"allowed invalid params", allow, `{"id":1,"method":"Echo.Say","params":[{"name":""}]}`, CodeInvalidParams},
+		{"allowed undecodable params", allow, `{"id":1,"method":"Echo.Say","params":[{"name":7}]}`, CodeInvalidParams},
+		{"allowed valid params", allow, `{"id":1,"method":"Echo.Say","params":[{"name":"a"}]}`, 0},
+		{"unknown method", deny, `{"id":1,"method":"Echo.Shout","params":[]}`, CodeMethodNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newTestDispatcher(t, WithInterceptors(tt.interceptor))
+			resp := serveJSON(d, tt.body)
+			if tt.wantCode == 0 {
+				if resp.Error != nil {
+					t.Fatalf("unexpected error %+v", resp.Error)
+				}
+				if got := resp.Result.(*EchoReply).Message; got != "hello a" {
+					t.Fatalf("result = %q", got)
+				}
+				return
+			}
+			if resp.Error == nil || resp.Error.Code != tt.wantCode {
+				t.Fatalf("error = %+v, want code %d", resp.Error, tt.wantCode)
+			}
+			if tt.wantCode != CodeInvalidParams && resp.Error.Data != nil {
+				t.Fatalf("rejected call leaked data %+v", resp.Error.Data)
+			}
+		})
+	}
+}
+
+func TestDispatcherInterceptorSeesRawParams(t *testing.T) {
+	var got interface{}
+	record := func(ctx context.Context, info *CallInfo, arg interface{}, next Handler) (interface{}, error) {
+		got = arg
+		return nil, errors.New("stop")
+	}
+	d := newTestDispatcher(t, WithInterceptors(record))
+	serveJSON(d, `{"id":1,"method":"Echo.Say","params":[{"name":"a"}]}`)
+	raw, ok := got.(RawMessage)
+	if !ok || string(raw) != `{"name":"a"}` {
+		t.Fatalf("arg = %#v, want raw first param", got)
+	}
+}
+
+// TestHandleStatusOK checks that failed calls are reported in the
+// envelope, with the JSON-RPC code of their gRPC status, under HTTP 200.
+func TestHandleStatusOK(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		code int // JSON-RPC error code; 0 for a result or a batch
+	}{
+		{"result", `{"id":1,"method":"Echo.Say","params":[{"name":"a"}]}`, 0},
+		{"invalid params", `{"id":1,"method":"Echo.Say","params":[{"name":""}]}`, CodeInvalidParams},
+		{"parse error", `{"id":1,`, CodeParseError},
+		{"unknown method", `{"id":1,"method":"Echo.Shout","params":[]}`, CodeMethodNotFound},
+		{"method error", `{"id":1,"method":"Echo.Fail","params":[{"name":"a"}]}`, CodeServerError},
+		{"proxied status", `{"id":1,"method":"Echo.Missing","params":[{"name":"a"}]}`, -32005},
+		{"batch with an error", `[{"id":1,"method":"Echo.Say","params":[{"name":"a"}]},{"id":2,"method":"Echo.Shout"}]`, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newTestDispatcher(t)
+			c := app.NewContext(0)
+			c.Request.SetMethod(http.MethodPost)
+			c.Request.Header.SetContentTypeBytes([]byte("application/json"))
+			c.Request.SetBodyString(tt.body)
+			d.Handle(context.Background(), c)
+			if got := c.Response.StatusCode(); got != http.StatusOK {
+				t.Fatalf("status %d, want 200: %s", got, c.Response.Body())
+			}
+			if tt.code == 0 {
+				return
+			}
+			var resp struct{ Error *Error }
+			if err := json.Unmarshal(c.Response.Body(), &resp); err != nil || resp.Error == nil || resp.Error.Code != tt.code {
+				t.Fatalf("body %s, want error code %d", c.Response.Body(), tt.code)
+			}
+		})
+	}
+}
+
+func TestClientDecodesErrorStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(Response{JsonRpc: "2.0", Id: 1, Error: StatusError(status.New(codes.NotFound, "no such user"))})
+	}))
+	defer srv.Close()
+	err := NewClient(srv.URL).Call(context.Background(), "Echo.Say", EchoArgs{Name: "a"}, nil)
+	var rpcErr *Error
+	if !errors.As(err, &rpcErr) || status.Code(err) != codes.NotFound || status.Convert(err).Message() != "no such user" {
+		t.Fatalf("Call = %v, want the NotFound *Error from the body", err)
+	}
+}
@@ -0,0 +1,69 @@
+package hertzrpc
+
+import (
+	"encoding/json"
+
+	"awesomeproject/pkg/rpcerr"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Error codes defined by the JSON-RPC 2.0 specification.
+const (
+	CodeParseError     = rpcerr.ParseError
+	CodeInvalidRequest = rpcerr.InvalidRequest
+	CodeMethodNotFound = rpcerr.MethodNotFound
+	CodeInvalidParams  = rpcerr.InvalidParams
+	CodeInternalError  = rpcerr.InternalError
+	CodeServerError    = rpcerr.ServerError // generic error returned by a method
+)
+
+// Error codes for requests rejected by the Dispatcher's limits.
+const (
+	CodeBodyTooLarge   = -32050
+	CodeTooDeep        = -32051
+	CodeBatchTooLarge  = -32052
+	CodeParamsTooLarge = -32053
+)
+
+// Error codes produced by interceptors and the Dispatcher itself. Each is
+// rpcerr.JSONRPCCode of the gRPC status code it corresponds to.
+const (
+	CodePermissionDenied = -32007 // codes.PermissionDenied
+	CodeRateLimited      = -32008 // codes.ResourceExhausted
+	CodeUnavailable      = -32014 // codes.Unavailable
+	CodeUnauthenticated  = -32016 // codes.Unauthenticated
+)
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// StatusError converts a gRPC status into a JSON-RPC error with
+// rpcerr.JSONRPCCode of its code, its message, and data holding the
+// google.rpc.Status with its details (see rpcerr.Data).
+func StatusError(st *status.Status) *Error {
+	return &Error{Code: rpcerr.JSONRPCCode(st.Code()), Message: st.Message(), Data: rpcerr.Data(st)}
+}
+
+// GRPCStatus converts e back into a gRPC status, so that status.Code and
+// status.FromError work on errors returned by Client. Details carried in
+// data by StatusError are restored.
+func (e *Error) GRPCStatus() *status.Status {
+	switch e.Code {
+	case CodeBodyTooLarge, CodeTooDeep, CodeBatchTooLarge, CodeParamsTooLarge:
+		return status.New(codes.ResourceExhausted, e.Message)
+	}
+	if fe, ok := e.Data.(FieldErrors); ok {
+		return fe.GRPCStatus()
+	}
+	// A Client sees the FieldErrors of invalid params as decoded JSON
+	if e.Code == CodeInvalidParams && e.Data != nil {
+		var fe FieldErrors
+		if raw, err := json.Marshal(e.Data); err == nil && json.Unmarshal(raw, &fe) == nil && len(fe) > 0 {
+			return fe.GRPCStatus()
+		}
+	}
+	return rpcerr.FromJSONRPC(e.Code, e.Message, e.Data)
+}
@@ -0,0 +1,86 @@
+package hertzrpc
+
+import (
+	"encoding/json"
+	"testing"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// overTheWire returns e as a Client decodes it.
+func overTheWire(t *testing.T, e *Error) *Error {
+	t.Helper()
+	raw, err := json.Marshal(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got Error
+	if err := json.Unmarshal(raw, &got); err != nil {
+		t.Fatal(err)
+	}
+	return &got
+}
+
+func TestStatusErrorRoundTrip(t *testing.T) {
+	invalid, err := status.New(codes.InvalidArgument, "name is required").WithDetails(&errdetails.BadRequest{
+		FieldViolations: []*errdetails.BadRequest_FieldViolation{{Field: "name", Description: "is required"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		st       *status.Status
+		wantCode int
+	}{
+		{invalid, CodeInvalidParams},
+		{status.New(codes.Unimplemented, "no SayHi"), CodeMethodNotFound},
+		{status.New(codes.Internal, "boom"), CodeInternalError},
+		{status.New(codes.NotFound, "no such user"), -32005},
+		{status.New(codes.Unavailable, "down"), CodeUnavailable},
+		{status.New(codes.PermissionDenied, "no"), CodePermissionDenied},
+		{status.New(codes.Unauthenticated, "who"), CodeUnauthenticated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.st.Code().String(), func(t *testing.T) {
+			e := StatusError(tt.st)
+			if e.Code != tt.wantCode {
+				t.Fatalf("code %d, want %d", e.Code, tt.wantCode)
+			}
+			for _, e := range []*Error{e, overTheWire(t, e)} {
+				if got := e.GRPCStatus(); !proto.Equal(got.Proto(), tt.st.Proto()) {
+					t.Fatalf("GRPCStatus = %v, want %v", got.Proto(), tt.st.Proto())
+				}
+			}
+		})
+	}
+}
+
+func TestErrorGRPCStatus(t *testing.T) {
+	fieldErrs := FieldErrors{{Field: "name", Message: "is required"}}
+	tests := []struct {
+		name string
+		err  *Error
+		want codes.Code
+	}{
+		{"field errors", &Error{Code: CodeInvalidParams, Message: "Invalid params", Data: fieldErrs}, codes.InvalidArgument},
+		{"decoded field errors", overTheWire(t, &Error{Code: CodeInvalidParams, Message: "Invalid params", Data: fieldErrs}), codes.InvalidArgument},
+		{"too deep", &Error{Code: CodeTooDeep}, codes.ResourceExhausted},
+		{"rate limited", &Error{Code: CodeRateLimited}, codes.ResourceExhausted},
+		{"parse error", &Error{Code: CodeParseError}, codes.InvalidArgument},
+		{"server error", &Error{Code: CodeServerError}, codes.Unknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := tt.err.GRPCStatus()
+			if st.Code() != tt.want {
+				t.Fatalf("code %s, want %s", st.Code(), tt.want)
+			}
+			if tt.want == codes.InvalidArgument && tt.err.Data != nil && len(st.Details()) != 1 {
+				t.Fatalf("details %v, want a BadRequest", st.Details())
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package hertzrpc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+
+	"awesomeproject/pkg/rpcerr"
+	"awesomeproject/pkg/tlsutil"
+
+	"google.golang.org/grpc/status"
+)
+
+// CallInfo describes the call being dispatched and the transport it
+// arrived on.
+type CallInfo struct {
+	// Method is the full "Service.Method" name.
+	Method string
+	// RemoteAddr is the peer's host:port.
+	RemoteAddr string
+	// Header holds the HTTP request headers, or the headers of the
+	// WebSocket upgrade request.
+	Header http.Header
+	// Peer is the verified client certificate on mutual TLS connections.
+	Peer *tlsutil.Identity
+}
+
+// RemoteIP returns the host part of RemoteAddr.
+func (ci *CallInfo) RemoteIP() string {
+	if host, _, err := net.SplitHostPort(ci.RemoteAddr); err == nil {
+		return host
+	}
+	return ci.RemoteAddr
+}
+
+// Handler invokes the method, or the next interceptor in the chain, and
+// returns the reply. The innermost Handler decodes and validates the
+// params.
+type Handler func(ctx context.Context, arg interface{}) (interface{}, error)
+
+// Interceptor wraps method invocation, in the style of
+// grpc.UnaryServerInterceptor. It runs before params are decoded and
+// validated, so arg is the raw first param (a RawMessage in the request's
+// codec) or nil; a call it rejects costs no decoding and reveals nothing
+// about the params. Returning an *Error sends it to the client unchanged, an
+// error with a gRPC status is converted by StatusError, and any other
+// error is reported as CodeServerError.
+type Interceptor func(ctx context.Context, info *CallInfo, arg interface{}, next Handler) (interface{}, error)
+
+// WithInterceptors appends interceptors to the chain. The first one given
+// is the outermost.
+func WithInterceptors(interceptors ...Interceptor) Option {
+	return func(d *Dispatcher) {
+		d.interceptors = append(d.interceptors, interceptors...)
+	}
+}
+
+// chain wraps h with the interceptors for one call.
+func (d *Dispatcher) chain(info *CallInfo, h Handler) Handler {
+	for i := len(d.interceptors) - 1; i >= 0; i-- {
+		interceptor, next := d.interceptors[i], h
+		h = func(ctx context.Context, arg interface{}) (interface{}, error) {
+			return interceptor(ctx, info, arg, next)
+		}
+	}
+	return h
+}
+
+// errorFrom converts an error returned by a method or interceptor into the
+// error member of a Response. FieldErrors are invalid params, as when the
+// Dispatcher validates. Errors carrying a gRPC status, such as those of a
+// proxied gRPC call, and context errors keep their code and details; see
+// StatusError.
+func errorFrom(err error) *Error {
+	var rpcErr *Error
+	if errors.As(err, &rpcErr) {
+		return rpcErr
+	}
+	var fieldErrs FieldErrors
+	if errors.As(err, &fieldErrs) {
+		return &Error{Code: CodeInvalidParams, Message: "Invalid params: " + fieldErrs.Error(), Data: fieldErrs}
+	}
+	if _, ok := status.FromError(err); ok || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return StatusError(rpcerr.FromError(err))
+	}
+	return &Error{Code: CodeServerError, Message: err.Error()}
+}
@@ -0,0 +1,228 @@
+package hertzrpc
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// Limits bounds the size and shape of incoming requests. Zero fields are
+// unlimited.
+type Limits struct {
+	// MaxBodySize caps the request body in bytes, both as received and
+	// after decompression. It is also the WebSocket read limit.
+	MaxBodySize int
+	// MaxDepth caps how deeply arrays and objects nest, counting the
+	// request envelope and the params array as two levels.
+	MaxDepth int
+	// MaxBatch caps the number of requests in one batch.
+	MaxBatch int
+	// MaxParamsSize caps the encoded params of one request in bytes.
+	MaxParamsSize int
+}
+
+// DefaultLimits are applied by NewDispatcher unless WithLimits is given.
+var DefaultLimits = Limits{
+	MaxBodySize:   4 << 20,
+	MaxDepth:      32,
+	MaxBatch:      100,
+	MaxParamsSize: 1 << 20,
+}
+
+// WithLimits replaces DefaultLimits.
+func WithLimits(l Limits) Option {
+	return func(d *Dispatcher) {
+		d.limits = l
+	}
+}
+
+var (
+	errBodyTooLarge = errors.New("request body too large")
+	errTooDeep      = errors.New("request nested too deeply")
+)
+
+// depthChecker is implemented by codecs that can measure nesting without
+// fully decoding the payload. Codecs that do not implement it skip the
+// MaxDepth check.
+type depthChecker interface {
+	checkDepth(data []byte, limit int) error
+}
+
+// checkDepth scans JSON text, ignoring brackets inside strings.
+func (JSONCodec) checkDepth(data []byte, limit int) error {
+	depth, inString, escaped := 0, false, false
+	for _, b := range data {
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case b == '\\':
+				escaped = true
+			case b == '"':
+				inString = false
+			}
+			continue
+		}
+		switch b {
+		case '"':
+			inString = true
+		case '{', '[':
+			depth++
+			if depth > limit {
+				return errTooDeep
+			}
+		case '}', ']':
+			depth--
+		}
+	}
+	return nil
+}
+
+// checkDepth walks the MessagePack item headers, skipping over payloads.
+func (MsgpackCodec) checkDepth(data []byte, limit int) error {
+	var stack []int // items still expected by each open array or map
+	// complete records that one item finished and reports whether it was
+	// the top-level value.
+	complete := func() bool {
+		for len(stack) > 0 {
+			stack[len(stack)-1]--
+			if stack[len(stack)-1] > 0 {
+				return false
+			}
+			stack = stack[:len(stack)-1]
+		}
+		return true
+	}
+
+	for i := 0; ; {
+		if i >= len(data) {
+			return io.ErrUnexpectedEOF
+		}
+		b := data[i]
+		i++
+
+		items, container, skip := 0, false, 0
+		switch {
+		case b <= 0x7f, b >= 0xe0: // fixint
+		case b <= 0x8f: // fixmap
+			items, container = 2*int(b&0x0f), true
+		case b <= 0x9f: // fixarray
+			items, container = int(b&0x0f), true
+		case b <= 0xbf: // fixstr
+			skip = int(b & 0x1f)
+		case b == 0xc0, b == 0xc2, b == 0xc3: // nil, false, true
+		case b == 0xc1:
+			return fmt.Errorf("msgpack: invalid code %#x", b)
+		default:
+			size, n, err := msgpackHeader(b, data[i:])
+			if err != nil {
+				return err
+			}
+			i += n
+			switch b {
+			case 0xdc, 0xdd: // array 16/32
+				items, container = size, true
+			case 0xde, 0xdf: // map 16/32
+				items, container = 2*size, true
+			default:
+				skip = size
+			}
+		}
+
+		if container {
+			if len(stack)+1 > limit {
+				return errTooDeep
+			}
+			if items > 0 {
+				stack = append(stack, items)
+				continue
+			}
+		}
+		if skip > len(data)-i {
+			return io.ErrUnexpectedEOF
+		}
+		i += skip
+		if complete() {
+			return nil
+		}
+	}
+}
+
+// msgpackHeader decodes the length field that follows code b and returns
+// the payload size (or element count) and the number of header bytes read.
+func msgpackHeader(b byte, rest []byte) (size, n int, err error) {
+	readLen := func(width int) (int, error) {
+		if len(rest) < width {
+			return 0, io.ErrUnexpectedEOF
+		}
+		switch width {
+		case 1:
+			return int(rest[0]), nil
+		case 2:
+			return int(binary.BigEndian.Uint16(rest)), nil
+		default:
+			return int(binary.BigEndian.Uint32(rest)), nil
+		}
+	}
+	switch b {
+	case 0xc4, 0xd9: // bin8, str8
+		size, err = readLen(1)
+		return size, 1, err
+	case 0xc5, 0xda, 0xdc, 0xde: // bin16, str16, array16, map16
+		size, err = readLen(2)
+		return size, 2, err
+	case 0xc6, 0xdb, 0xdd, 0xdf: // bin32, str32, array32, map32
+		size, err = readLen(4)
+		return size, 4, err
+	case 0xc7: // ext8: length, type, data
+		size, err = readLen(1)
+		return size + 1, 1, err
+	case 0xc8:
+		size, err = readLen(2)
+		return size + 1, 2, err
+	case 0xc9:
+		size, err = readLen(4)
+		return size + 1, 4, err
+	case 0xca: // float32
+		return 4, 0, nil
+	case 0xcb: // float64
+		return 8, 0, nil
+	case 0xcc, 0xd0: // uint8, int8
+		return 1, 0, nil
+	case 0xcd, 0xd1:
+		return 2, 0, nil
+	case 0xce, 0xd2:
+		return 4, 0, nil
+	case 0xcf, 0xd3:
+		return 8, 0, nil
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8: // fixext 1/2/4/8/16 plus type byte
+		return 1 + 1<<(b-0xd4), 0, nil
+	}
+	return 0, 0, fmt.Errorf("msgpack: invalid code %#x", b)
+}
+
+var cborDepthModes sync.Map // max depth -> cbor.DecMode
+
+// checkDepth relies on fxamacker/cbor's nesting limit, which cannot go
+// below 4 levels.
+func (CBORCodec) checkDepth(data []byte, limit int) error {
+	v, ok := cborDepthModes.Load(limit)
+	if !ok {
+		levels := min(65535, max(limit, 4))
+		dm, err := cbor.DecOptions{MaxNestedLevels: levels}.DecMode()
+		if err != nil {
+			return err
+		}
+		v, _ = cborDepthModes.LoadOrStore(limit, dm)
+	}
+	err := v.(cbor.DecMode).Wellformed(data)
+	var nested *cbor.MaxNestedLevelError
+	if errors.As(err, &nested) {
+		return errTooDeep
+	}
+	return err
+}
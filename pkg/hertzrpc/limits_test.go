@@ -0,0 +1,173 @@
+package hertzrpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestJSONCheckDepth(t *testing.T) {
+	tests := []struct {
+		name  string
+		data  string
+		limit int
+		want  error
+	}{
+		{"scalar", `42`, 1, nil},
+		{"flat object", `{"a":1,"b":"x"}`, 1, nil},
+		{"at the limit", `[[[1]]]`, 3, nil},
+		{"over the limit", `[[[[1]]]]`, 3, errTooDeep},
+		{"siblings do not add up", `[[1],[2],{"a":[3]}]`, 3, nil},
+		{"mixed nesting", `{"a":[{"b":[1]}]}`, 3, errTooDeep},
+		{"brackets in strings", `{"a":"[[[[{{{{"}`, 1, nil},
+		{"escaped quote in string", `{"a":"\"[[[["}`, 1, nil},
+		{"escaped backslash ends string", `{"a":"\\",  "b":[[1]]}`, 2, errTooDeep},
+		{"unicode escape", `["\u005b[["]`, 1, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := (JSONCodec{}).checkDepth([]byte(tt.data), tt.limit); !errors.Is(err, tt.want) {
+				t.Fatalf("checkDepth(%s, %d) = %v, want %v", tt.data, tt.limit, err, tt.want)
+			}
+		})
+	}
+}
+
+// nest wraps v in depth levels of alternating arrays and maps.
+func nest(v interface{}, depth int) interface{} {
+	for i := 0; i < depth; i++ {
+		if i%2 == 0 {
+			v = []interface{}{"pad", v}
+		} else {
+			v = map[string]interface{}{"k": v}
+		}
+	}
+	return v
+}
+
+func mustMsgpack(t *testing.T, v interface{}) []byte {
+	t.Helper()
+	data, err := msgpack.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestMsgpackCheckDepth(t *testing.T) {
+	// Payloads whose bytes look like container headers must be skipped
+	tricky := string([]byte{0x91, 0x91, 0x91, 0xdc, 0xff, 0xff})
+	ext := &msgpack.RawMessage{}
+	*ext = []byte{0xd5, 0x01, 0x91, 0x91} // fixext2 holding array-like bytes
+
+	tests := []struct {
+		name  string
+		data  []byte
+		limit int
+		want  error
+	}{
+		{"scalar", mustMsgpack(t, 7), 1, nil},
+		{"at the limit", mustMsgpack(t, nest(1, 4)), 4, nil},
+		{"over the limit", mustMsgpack(t, nest(1, 5)), 4, errTooDeep},
+		{"empty containers count", mustMsgpack(t, nest([]interface{}{}, 3)), 3, errTooDeep},
+		{"empty containers at the limit", mustMsgpack(t, nest(map[string]interface{}{}, 2)), 3, nil},
+		{"siblings do not add up", mustMsgpack(t, []interface{}{nest(1, 2), nest(1, 2), nest(1, 2)}), 3, nil},
+		{"string payload skipped", mustMsgpack(t, nest(tricky, 1)), 1, nil},
+		{"long string", mustMsgpack(t, []interface{}{strings.Repeat(tricky, 20000)}), 1, nil},
+		{"binary payload skipped", mustMsgpack(t, []interface{}{[]byte(tricky)}), 1, nil},
+		{"ext payload skipped", mustMsgpack(t, []interface{}{ext}), 1, nil},
+		{"numbers", mustMsgpack(t, []interface{}{-1, 300, 70000, int64(1) << 40, 1.5, float32(2.5), true, nil}), 1, nil},
+		{"large array", mustMsgpack(t, make([]int, 70000)), 1, nil},
+		{"truncated container", mustMsgpack(t, []int{1, 2, 3})[:2], 4, io.ErrUnexpectedEOF},
+		{"truncated string", mustMsgpack(t, "hello")[:3], 4, io.ErrUnexpectedEOF},
+		{"truncated header", []byte{0xdc, 0x00}, 4, io.ErrUnexpectedEOF},
+		{"empty", nil, 4, io.ErrUnexpectedEOF},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := (MsgpackCodec{}).checkDepth(tt.data, tt.limit); !errors.Is(err, tt.want) {
+				t.Fatalf("checkDepth = %v, want %v", err, tt.want)
+			}
+		})
+	}
+	if err := (MsgpackCodec{}).checkDepth([]byte{0xc1}, 4); err == nil {
+		t.Error("checkDepth accepted the never-used code 0xc1")
+	}
+}
+
+// TestCheckDepthAgreesAcrossCodecs encodes the same values with every
+// depth-checking codec and expects the same verdict.
+func TestCheckDepthAgreesAcrossCodecs(t *testing.T) {
+	for depth := 1; depth <= 8; depth++ {
+		v := nest("x", depth)
+		for _, codec := range testCodecs {
+			data, err := codec.Marshal(v)
+			if err != nil {
+				t.Fatal(err)
+			}
+			dc := codec.(depthChecker)
+			// fxamacker/cbor cannot limit below 4 levels
+			if depth > 4 || codec.Name() != "cbor" {
+				if err := dc.checkDepth(data, depth-1); !errors.Is(err, errTooDeep) {
+					t.Errorf("%s depth %d, limit %d: %v, want errTooDeep", codec.Name(), depth, depth-1, err)
+				}
+			}
+			if err := dc.checkDepth(data, depth); err != nil {
+				t.Errorf("%s depth %d, limit %d: %v", codec.Name(), depth, depth, err)
+			}
+		}
+	}
+}
+
+func TestDispatcherLimits(t *testing.T) {
+	limits := Limits{MaxBodySize: 200, MaxDepth: 4, MaxBatch: 2, MaxParamsSize: 40}
+	call := `{"id":1,"method":"Echo.Say","params":[{"name":"a"}]}`
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"within limits", call, 0},
+		{"body too large", `{"id":1,"method":"Echo.Say","params":[{"name":"` + strings.Repeat("a", 200) + `"}]}`, CodeBodyTooLarge},
+		{"too deep", `{"id":1,"method":"Echo.Say","params":[{"name":[[1]]}]}`, CodeTooDeep},
+		{"batch too large", "[" + call + "," + call + "," + call + "]", CodeBatchTooLarge},
+		{"params too large", `{"id":1,"method":"Echo.Say","params":[{"name":"` + strings.Repeat("a", 40) + `"}]}`, CodeParamsTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newTestDispatcher(t, WithLimits(limits))
+			resp := handleJSON(t, d, tt.body)
+			if tt.want == 0 {
+				if resp.Error != nil {
+					t.Fatalf("unexpected error %+v", resp.Error)
+				}
+				return
+			}
+			if resp.Error == nil || resp.Error.Code != tt.want {
+				t.Fatalf("error %+v, want code %d", resp.Error, tt.want)
+			}
+		})
+	}
+}
+
+// handleJSON posts body through Handle and decodes the single Response.
+func handleJSON(t *testing.T, d *Dispatcher, body string) Response {
+	t.Helper()
+	c := app.NewContext(0)
+	c.Request.SetMethod(http.MethodPost)
+	c.Request.Header.SetContentTypeBytes([]byte(defaultContentType))
+	c.Request.SetBodyString(body)
+	d.Handle(context.Background(), c)
+	var resp Response
+	if err := json.Unmarshal(c.Response.Body(), &resp); err != nil {
+		t.Fatalf("decode %s: %v", c.Response.Body(), err)
+	}
+	return resp
+}
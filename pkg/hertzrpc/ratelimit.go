@@ -0,0 +1,77 @@
+package hertzrpc
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"awesomeproject/pkg/auth"
+	"awesomeproject/pkg/ratelimit"
+)
+
+// KeyFunc identifies the client of a call for rate limiting. ctx is the
+// one passed down the interceptor chain.
+type KeyFunc func(ctx context.Context, info *CallInfo) string
+
+// RemoteIPKey keys callers by their IP address.
+func RemoteIPKey(_ context.Context, info *CallInfo) string {
+	return info.RemoteIP()
+}
+
+// PrincipalKey keys callers by the subject Authenticate verified, falling
+// back to the remote IP for anonymous calls. Place RateLimit after
+// Authenticate so the Principal is in the context.
+func PrincipalKey(ctx context.Context, info *CallInfo) string {
+	if p, ok := auth.FromContext(ctx); ok && p.Subject != "" {
+		return "principal:" + p.Subject
+	}
+	return info.RemoteIP()
+}
+
+// HeaderKey keys callers by an HTTP header, falling back to the remote IP
+// when the header is absent. The header is not verified; trust it only
+// when a proxy in front of the server sets it.
+func HeaderKey(name string) KeyFunc {
+	return func(_ context.Context, info *CallInfo) string {
+		if v := info.Header.Get(name); v != "" {
+			return name + ":" + v
+		}
+		return info.RemoteIP()
+	}
+}
+
+// RateLimitKey returns the KeyFunc described by a ratelimit.Config Key spec.
+func RateLimitKey(spec string) (KeyFunc, error) {
+	switch {
+	case spec == "" || spec == "principal" || spec == "api-key":
+		return PrincipalKey, nil
+	case spec == "ip":
+		return RemoteIPKey, nil
+	case strings.HasPrefix(spec, "metadata:"):
+		return HeaderKey(strings.TrimPrefix(spec, "metadata:")), nil
+	}
+	return nil, fmt.Errorf("hertzrpc: unknown rate limit key %q", spec)
+}
+
+// RetryData is the `data` of a CodeRateLimited error.
+type RetryData struct {
+	RetryAfterMs int64 `json:"retryAfterMs"`
+}
+
+// RateLimit returns an Interceptor that rejects calls over the limiter's
+// budget with CodeRateLimited and a retry hint. Place it after
+// Authenticate, so that unauthenticated calls never reach a bucket and
+// PrincipalKey sees the verified caller.
+func RateLimit(l *ratelimit.Limiter, key KeyFunc) Interceptor {
+	return func(ctx context.Context, info *CallInfo, arg interface{}, next Handler) (interface{}, error) {
+		if ok, wait := l.Allow(info.Method, key(ctx, info)); !ok {
+			return nil, &Error{
+				Code:    CodeRateLimited,
+				Message: fmt.Sprintf("Rate limit exceeded for %s, retry after %v", info.Method, wait.Round(time.Millisecond)),
+				Data:    RetryData{RetryAfterMs: wait.Milliseconds() + 1},
+			}
+		}
+		return next(ctx, arg)
+	}
+}
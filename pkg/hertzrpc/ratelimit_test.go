@@ -0,0 +1,87 @@
+package hertzrpc
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"awesomeproject/pkg/auth"
+	"awesomeproject/pkg/ratelimit"
+)
+
+func TestRateLimitKey(t *testing.T) {
+	info := &CallInfo{RemoteAddr: "192.0.2.1:1234", Header: http.Header{"X-Tenant": {"t1"}}}
+	anon := context.Background()
+	alice := auth.NewContext(anon, &auth.Principal{Subject: "alice"})
+	tests := []struct {
+		spec string
+		ctx  context.Context
+		want string
+	}{
+		{"", alice, "principal:alice"},
+		{"principal", alice, "principal:alice"},
+		{"principal", anon, "192.0.2.1"},
+		{"api-key", alice, "principal:alice"},
+		{"ip", alice, "192.0.2.1"},
+		{"metadata:X-Tenant", anon, "X-Tenant:t1"},
+		{"metadata:X-Other", anon, "192.0.2.1"},
+	}
+	for _, tt := range tests {
+		key, err := RateLimitKey(tt.spec)
+		if err != nil {
+			t.Fatalf("RateLimitKey(%q): %v", tt.spec, err)
+		}
+		if got := key(tt.ctx, info); got != tt.want {
+			t.Errorf("RateLimitKey(%q) = %q, want %q", tt.spec, got, tt.want)
+		}
+	}
+	if _, err := RateLimitKey("cookie"); err == nil {
+		t.Error("RateLimitKey accepted an unknown spec")
+	}
+}
+
+// TestRateLimitAfterAuthenticate checks that callers rotating invalid keys
+// are rejected without touching the limiter, and that a verified caller
+// has one budget whatever header carries the key.
+func TestRateLimitAfterAuthenticate(t *testing.T) {
+	keysFile := filepath.Join(t.TempDir(), "keys.json")
+	if err := os.WriteFile(keysFile, []byte(`[{"key":"k1","subject":"alice"}]`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	a, err := auth.New(auth.Config{APIKeysFile: keysFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	limiter := ratelimit.New(ratelimit.Config{Default: ratelimit.Rule{Rate: 0.001, Burst: 2}})
+	d := newTestDispatcher(t, WithInterceptors(Authenticate(a), RateLimit(limiter, PrincipalKey)))
+
+	call := func(header, value string) *Error {
+		info := &CallInfo{RemoteAddr: "192.0.2.1:1234", Header: http.Header{}}
+		info.Header.Set(header, value)
+		resp := d.serve(context.Background(), JSONCodec{}, []byte(`{"id":1,"method":"Echo.Say","params":[{"name":"a"}]}`), info).(Response)
+		return resp.Error
+	}
+	tests := []struct {
+		header, value string
+		wantCode      int
+	}{
+		{auth.APIKeyHeader, "bogus-1", CodeUnauthenticated},
+		{auth.APIKeyHeader, "bogus-2", CodeUnauthenticated},
+		{auth.APIKeyHeader, "bogus-3", CodeUnauthenticated},
+		{auth.APIKeyHeader, "k1", 0},
+		{"Authorization", "Bearer k1", 0},
+		{auth.APIKeyHeader, "k1", CodeRateLimited},
+		{auth.APIKeyHeader, "bogus-4", CodeUnauthenticated},
+	}
+	for i, tt := range tests {
+		err := call(tt.header, tt.value)
+		switch {
+		case tt.wantCode == 0 && err != nil:
+			t.Fatalf("call %d: unexpected error %+v", i, err)
+		case tt.wantCode != 0 && (err == nil || err.Code != tt.wantCode):
+			t.Fatalf("call %d: error = %+v, want code %d", i, err, tt.wantCode)
+		}
+	}
+}
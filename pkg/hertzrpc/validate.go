@@ -0,0 +1,162 @@
+package hertzrpc
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Validator is implemented by argument types that check themselves.
+// Validate runs after struct-tag validation has passed.
+type Validator interface {
+	Validate() error
+}
+
+// FieldError describes one invalid field of the decoded params. Field is
+// empty for errors that concern the params as a whole.
+type FieldError struct {
+	Field   string `json:"field,omitempty"`
+	Rule    string `json:"rule,omitempty"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+}
+
+// FieldErrors is returned as the `data` of an invalid params error.
+// A Validate method may return it to report several fields at once.
+type FieldErrors []FieldError
+
+func (fe FieldErrors) Error() string {
+	msgs := make([]string, len(fe))
+	for i, e := range fe {
+		if e.Field == "" {
+			msgs[i] = e.Message
+		} else {
+			msgs[i] = e.Field + " " + e.Message
+		}
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// GRPCStatus reports fe as InvalidArgument with a BadRequest detail listing
+// each field.
+func (fe FieldErrors) GRPCStatus() *status.Status {
+	br := &errdetails.BadRequest{}
+	for _, e := range fe {
+		br.FieldViolations = append(br.FieldViolations, &errdetails.BadRequest_FieldViolation{
+			Field:       e.Field,
+			Description: e.Message,
+		})
+	}
+	st := status.New(codes.InvalidArgument, fe.Error())
+	if withDetails, err := st.WithDetails(br); err == nil {
+		st = withDetails
+	}
+	return st
+}
+
+var validate = newValidate()
+
+func newValidate() *validator.Validate {
+	v := validator.New(validator.WithRequiredStructEnabled())
+	// Report fields by the name the client used on the wire.
+	v.RegisterTagNameFunc(func(f reflect.StructField) string {
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		switch name {
+		case "-":
+			return ""
+		case "":
+			return f.Name
+		}
+		return name
+	})
+	return v
+}
+
+// Validate applies the Dispatcher's argument checks to arg, a pointer, for
+// other transports serving the same methods. It returns nil or FieldErrors.
+func Validate(arg interface{}) error {
+	if fe := validateArg(arg); fe != nil {
+		return fe
+	}
+	return nil
+}
+
+// validateArg checks the decoded argument, given as a pointer, against its
+// `validate` struct tags and then its Validate method, if any.
+func validateArg(arg interface{}) FieldErrors {
+	if reflect.Indirect(reflect.ValueOf(arg)).Kind() == reflect.Struct {
+		if err := validate.Struct(arg); err != nil {
+			var verrs validator.ValidationErrors
+			if !errors.As(err, &verrs) {
+				return FieldErrors{{Message: err.Error()}}
+			}
+			out := make(FieldErrors, 0, len(verrs))
+			for _, e := range verrs {
+				out = append(out, FieldError{
+					Field:   fieldPath(e.Namespace()),
+					Rule:    e.Tag(),
+					Param:   e.Param(),
+					Message: ruleMessage(e),
+				})
+			}
+			return out
+		}
+	}
+
+	if val, ok := arg.(Validator); ok {
+		if err := val.Validate(); err != nil {
+			var fe FieldErrors
+			if errors.As(err, &fe) {
+				return fe
+			}
+			return FieldErrors{{Message: err.Error()}}
+		}
+	}
+	return nil
+}
+
+// fieldPath drops the top-level type name from a validator namespace,
+// turning "User.address.city" into "address.city".
+func fieldPath(ns string) string {
+	if _, rest, ok := strings.Cut(ns, "."); ok {
+		return rest
+	}
+	return ns
+}
+
+func ruleMessage(e validator.FieldError) string {
+	switch e.Tag() {
+	case "required":
+		return "is required"
+	case "min", "gte":
+		if isLength(e.Kind()) {
+			return fmt.Sprintf("must have at least %s elements or characters", e.Param())
+		}
+		return "must be at least " + e.Param()
+	case "max", "lte":
+		if isLength(e.Kind()) {
+			return fmt.Sprintf("must have at most %s elements or characters", e.Param())
+		}
+		return "must be at most " + e.Param()
+	case "len":
+		return "must have length " + e.Param()
+	case "oneof":
+		return "must be one of [" + e.Param() + "]"
+	case "email":
+		return "must be a valid email address"
+	}
+	if e.Param() != "" {
+		return fmt.Sprintf("failed %s=%s", e.Tag(), e.Param())
+	}
+	return "failed " + e.Tag()
+}
+
+func isLength(k reflect.Kind) bool {
+	return k == reflect.String || k == reflect.Slice || k == reflect.Array || k == reflect.Map
+}
@@ -0,0 +1,121 @@
+package hertzrpc
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type address struct {
+	City string `json:"city" validate:"required"`
+}
+
+type signup struct {
+	Name    string   `json:"name" validate:"required,max=5"`
+	Email   string   `json:"email" validate:"omitempty,email"`
+	Age     int      `json:"age" validate:"min=18,max=130"`
+	Plan    string   `json:"plan" validate:"oneof=free pro"`
+	Tags    []string `json:"tags" validate:"max=2"`
+	Code    string   `json:"code" validate:"omitempty,len=4"`
+	Address address  `json:"address"`
+	Secret  string   `json:"-" validate:"omitempty,alpha"`
+	NoTag   string   `validate:"omitempty,numeric"`
+}
+
+// selfChecked reports problems from its own Validate method.
+type selfChecked struct {
+	From int `json:"from" validate:"min=0"`
+	To   int `json:"to"`
+}
+
+func (s *selfChecked) Validate() error {
+	if s.To < s.From {
+		return FieldErrors{{Field: "to", Message: "must not be before from"}}
+	}
+	if s.To == s.From {
+		return errors.New("empty range")
+	}
+	return nil
+}
+
+func valid() signup {
+	return signup{Name: "ann", Age: 30, Plan: "free", Address: address{City: "Oslo"}}
+}
+
+func TestValidateArg(t *testing.T) {
+	tests := []struct {
+		name   string
+		arg    interface{}
+		want   FieldErrors
+		wantOK bool
+	}{
+		{name: "valid struct", arg: ptr(valid()), wantOK: true},
+		{name: "required", arg: ptr(with(func(s *signup) { s.Name = "" })),
+			want: FieldErrors{{Field: "name", Rule: "required", Message: "is required"}}},
+		{name: "string max", arg: ptr(with(func(s *signup) { s.Name = "annabelle" })),
+			want: FieldErrors{{Field: "name", Rule: "max", Param: "5", Message: "must have at most 5 elements or characters"}}},
+		{name: "number min", arg: ptr(with(func(s *signup) { s.Age = 3 })),
+			want: FieldErrors{{Field: "age", Rule: "min", Param: "18", Message: "must be at least 18"}}},
+		{name: "oneof", arg: ptr(with(func(s *signup) { s.Plan = "gold" })),
+			want: FieldErrors{{Field: "plan", Rule: "oneof", Param: "free pro", Message: "must be one of [free pro]"}}},
+		{name: "email", arg: ptr(with(func(s *signup) { s.Email = "nope" })),
+			want: FieldErrors{{Field: "email", Rule: "email", Message: "must be a valid email address"}}},
+		{name: "slice max", arg: ptr(with(func(s *signup) { s.Tags = []string{"a", "b", "c"} })),
+			want: FieldErrors{{Field: "tags", Rule: "max", Param: "2", Message: "must have at most 2 elements or characters"}}},
+		{name: "len", arg: ptr(with(func(s *signup) { s.Code = "12345" })),
+			want: FieldErrors{{Field: "code", Rule: "len", Param: "4", Message: "must have length 4"}}},
+		{name: "nested path", arg: ptr(with(func(s *signup) { s.Address.City = "" })),
+			want: FieldErrors{{Field: "address.city", Rule: "required", Message: "is required"}}},
+		{name: "untagged field keeps its Go name", arg: ptr(with(func(s *signup) { s.NoTag = "x" })),
+			want: FieldErrors{{Field: "NoTag", Rule: "numeric", Message: "failed numeric"}}},
+		{name: "several fields", arg: ptr(with(func(s *signup) { s.Name, s.Age = "", 0 })),
+			want: FieldErrors{
+				{Field: "name", Rule: "required", Message: "is required"},
+				{Field: "age", Rule: "min", Param: "18", Message: "must be at least 18"},
+			}},
+		{name: "Validate method passes", arg: &selfChecked{From: 1, To: 2}, wantOK: true},
+		{name: "Validate method FieldErrors", arg: &selfChecked{From: 2, To: 1},
+			want: FieldErrors{{Field: "to", Message: "must not be before from"}}},
+		{name: "Validate method error", arg: &selfChecked{From: 1, To: 1},
+			want: FieldErrors{{Message: "empty range"}}},
+		{name: "tags run before Validate", arg: &selfChecked{From: -1, To: -2},
+			want: FieldErrors{{Field: "from", Rule: "min", Param: "0", Message: "must be at least 0"}}},
+		{name: "non-struct", arg: new(int), wantOK: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := validateArg(tt.arg)
+			if tt.wantOK {
+				if got != nil {
+					t.Fatalf("validateArg = %+v, want nil", got)
+				}
+				if err := Validate(tt.arg); err != nil {
+					t.Fatalf("Validate = %v, want nil", err)
+				}
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("validateArg = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFieldErrors(t *testing.T) {
+	fe := FieldErrors{{Field: "name", Message: "is required"}, {Message: "empty range"}}
+	if got, want := fe.Error(), "name is required; empty range"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+	st := fe.GRPCStatus()
+	if len(st.Details()) != 1 {
+		t.Fatalf("details %v, want one BadRequest", st.Details())
+	}
+}
+
+func ptr[T any](v T) *T { return &v }
+
+func with(edit func(*signup)) signup {
+	s := valid()
+	edit(&s)
+	return s
+}
@@ -0,0 +1,128 @@
+package hertzrpc
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/hertz-contrib/websocket"
+)
+
+// Subprotocols returns the codec names in preference order, suitable for
+// websocket.HertzUpgrader.Subprotocols.
+func (d *Dispatcher) Subprotocols() []string {
+	names := make([]string, 0, len(d.codecs))
+	for _, c := range d.codecs {
+		names = append(names, c.Name())
+	}
+	return names
+}
+
+// ServeConn serves JSON-RPC over an upgraded WebSocket connection until the
+// peer goes away. Text frames are always JSON. Binary frames use the codec
+// named by the negotiated subprotocol, or MessagePack when none was agreed.
+// Each reply is sent with the same frame type as its request. Messages
+// larger than Limits.MaxBodySize close the connection.
+func (d *Dispatcher) ServeConn(ctx context.Context, conn *websocket.Conn) {
+	d.serveConn(ctx, conn, &CallInfo{RemoteAddr: conn.RemoteAddr().String(), Header: make(http.Header)})
+}
+
+// HandleWS returns a Hertz handler that upgrades the request with upgrader
+// and serves JSON-RPC on the connection. Unlike ServeConn, interceptors see
+// the headers of the upgrade request.
+func (d *Dispatcher) HandleWS(upgrader *websocket.HertzUpgrader) app.HandlerFunc {
+	return func(ctx context.Context, c *app.RequestContext) {
+		info := requestInfo(c)
+		if err := upgrader.Upgrade(c, func(conn *websocket.Conn) {
+			d.serveConn(peerContext(ctx, info), conn, info)
+		}); err != nil {
+			log.Print("[hertzrpc] upgrade: ", err)
+		}
+	}
+}
+
+func (d *Dispatcher) serveConn(ctx context.Context, conn *websocket.Conn, info *CallInfo) {
+	if d.limits.MaxBodySize > 0 {
+		conn.SetReadLimit(int64(d.limits.MaxBodySize))
+	}
+	// On shutdown the peer gets a going-away close frame, but only once the
+	// call being handled, if any, has been answered. The loop then keeps
+	// reading until the peer acknowledges the close.
+	var (
+		mu      sync.Mutex
+		busy    bool
+		closing bool
+	)
+	done, err := d.inflight.Add(func() {
+		mu.Lock()
+		defer mu.Unlock()
+		closing = true
+		if !busy {
+			goingAway(conn)
+		}
+	}, func() { _ = conn.Close() })
+	if err != nil {
+		goingAway(conn)
+		return
+	}
+	defer done()
+
+	text := d.requestCodec(defaultContentType)
+	binary := d.binaryCodec(conn.Subprotocol())
+	for {
+		mt, message, err := conn.ReadMessage()
+		if err != nil {
+			if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				log.Println("[hertzrpc] ws read:", err)
+			}
+			return
+		}
+
+		var codec Codec
+		switch mt {
+		case websocket.TextMessage:
+			codec = text
+		case websocket.BinaryMessage:
+			codec = binary
+		default:
+			continue
+		}
+
+		mu.Lock()
+		if closing {
+			mu.Unlock()
+			continue // sent after our close frame; the peer should stop
+		}
+		busy = true
+		mu.Unlock()
+
+		err = conn.WriteMessage(mt, encode(codec, d.serve(ctx, codec, message, info)))
+
+		mu.Lock()
+		busy = false
+		if closing {
+			goingAway(conn)
+		}
+		mu.Unlock()
+		if err != nil {
+			log.Println("[hertzrpc] ws write:", err)
+			return
+		}
+	}
+}
+
+// goingAway tells the peer the server is shutting down.
+func goingAway(conn *websocket.Conn) {
+	msg := websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down")
+	_ = conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
+}
+
+func (d *Dispatcher) binaryCodec(subprotocol string) Codec {
+	if c, ok := d.codecByName(subprotocol); ok && c.ContentType() != defaultContentType {
+		return c
+	}
+	return MsgpackCodec{}
+}
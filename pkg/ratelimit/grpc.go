@@ -0,0 +1,107 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"awesomeproject/pkg/auth"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// KeyFunc identifies the client of a gRPC call.
+type KeyFunc func(ctx context.Context) string
+
+// PeerIP keys callers by the IP address of the connection.
+func PeerIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	if host, _, err := net.SplitHostPort(p.Addr.String()); err == nil {
+		return host
+	}
+	return p.Addr.String()
+}
+
+// Principal keys callers by the subject that pkg/auth verified, falling
+// back to the peer IP for anonymous calls. Install the interceptors after
+// authentication so the Principal is in the context.
+func Principal(ctx context.Context) string {
+	if p, ok := auth.FromContext(ctx); ok && p.Subject != "" {
+		return "principal:" + p.Subject
+	}
+	return PeerIP(ctx)
+}
+
+// Metadata keys callers by the first value of an incoming metadata key,
+// falling back to the peer IP when it is absent. The value is whatever the
+// caller sent; trust it only when a proxy in front of the server sets it.
+func Metadata(name string) KeyFunc {
+	return func(ctx context.Context) string {
+		if v := metadata.ValueFromIncomingContext(ctx, name); len(v) > 0 && v[0] != "" {
+			return name + ":" + v[0]
+		}
+		return PeerIP(ctx)
+	}
+}
+
+// GRPCKey returns the KeyFunc described by a Config.Key spec.
+func GRPCKey(spec string) (KeyFunc, error) {
+	switch {
+	case spec == "" || spec == "principal" || spec == "api-key":
+		return Principal, nil
+	case spec == "ip":
+		return PeerIP, nil
+	case strings.HasPrefix(spec, "metadata:"):
+		return Metadata(strings.ToLower(strings.TrimPrefix(spec, "metadata:"))), nil
+	}
+	return nil, fmt.Errorf("ratelimit: unknown key %q", spec)
+}
+
+// UnaryServerInterceptor rejects calls over the limit with
+// codes.ResourceExhausted.
+func UnaryServerInterceptor(l *Limiter, key KeyFunc) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := l.check(ctx, info.FullMethod, key); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor charges one token when a stream is opened.
+func StreamServerInterceptor(l *Limiter, key KeyFunc) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := l.check(ss.Context(), info.FullMethod, key); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
+
+// check returns a ResourceExhausted status carrying a RetryInfo detail and
+// a "retry-after" header (in whole seconds) when the call is over the limit.
+func (l *Limiter) check(ctx context.Context, method string, key KeyFunc) error {
+	ok, wait := l.Allow(method, key(ctx))
+	if ok {
+		return nil
+	}
+	secs := int((wait + time.Second - 1) / time.Second)
+	_ = grpc.SetHeader(ctx, metadata.Pairs("retry-after", strconv.Itoa(secs)))
+	st := status.Newf(codes.ResourceExhausted, "rate limit exceeded for %s, retry after %v", method, wait.Round(time.Millisecond))
+	if withInfo, err := st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(wait)}); err == nil {
+		st = withInfo
+	}
+	return st.Err()
+}
@@ -0,0 +1,184 @@
+// Package ratelimit implements per-client, per-method token buckets shared
+// by the gRPC interceptors in this package and the hertzrpc Dispatcher.
+package ratelimit
+
+import (
+	"encoding/json"
+	"math"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"awesomeproject/pkg/rpcname"
+)
+
+// Rule is a token bucket refilled at Rate tokens per second and holding at
+// most Burst tokens. A zero Rate means unlimited.
+type Rule struct {
+	Rate  float64 `json:"rate"`
+	Burst int     `json:"burst"`
+}
+
+// Config selects a Rule per method. Method names may be written in gRPC
+// form ("/Greeter/SayHello") or net/rpc form ("Greeter.SayHello"), and
+// "Service.*" matches every method of a service.
+type Config struct {
+	Default Rule            `json:"default"`
+	Methods map[string]Rule `json:"methods"`
+	// Key says how callers are told apart: "principal" (the default),
+	// "ip", or "metadata:<name>" (a gRPC metadata key or HTTP header).
+	// "principal" is the authenticated subject, or the IP address of
+	// anonymous callers; "api-key" is an alias for it. A metadata value
+	// is not verified, so use it only behind a proxy that sets it.
+	Key string `json:"key"`
+	// MaxClients caps the number of buckets kept; 0 means
+	// DefaultMaxClients. Once it is reached, new clients share one bucket
+	// per method until idle buckets are dropped.
+	MaxClients int `json:"maxClients"`
+}
+
+// LoadConfig reads a Config from a JSON file.
+func LoadConfig(path string) (Config, error) {
+	var cfg Config
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, err
+	}
+	err = json.Unmarshal(data, &cfg)
+	return cfg, err
+}
+
+// DefaultMaxClients is the bucket cap used when Config.MaxClients is 0.
+const DefaultMaxClients = 10000
+
+// idleAfter is how long a full bucket may sit unused before it is dropped.
+const idleAfter = 10 * time.Minute
+
+// overflowClient keys the bucket shared by clients arriving while the
+// Limiter is full. Keys built by KeyFunc never start with a NUL.
+const overflowClient = "\x00overflow"
+
+type bucketKey struct {
+	method, client string
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Limiter holds the token buckets. It is safe for concurrent use.
+type Limiter struct {
+	mu        sync.Mutex
+	rules     map[string]Rule
+	def       Rule
+	buckets   map[bucketKey]*bucket
+	max       int
+	lastSweep time.Time
+	lastPrune time.Time
+	now       func() time.Time
+}
+
+// New returns a Limiter enforcing cfg.
+func New(cfg Config) *Limiter {
+	l := &Limiter{
+		rules:   make(map[string]Rule, len(cfg.Methods)),
+		def:     cfg.Default,
+		buckets: make(map[bucketKey]*bucket),
+		max:     cfg.MaxClients,
+		now:     time.Now,
+	}
+	if l.max <= 0 {
+		l.max = DefaultMaxClients
+	}
+	for name, rule := range cfg.Methods {
+		l.rules[rpcname.Normalize(name)] = rule
+	}
+	l.lastSweep = l.now()
+	return l
+}
+
+func (l *Limiter) rule(method string) Rule {
+	if r, ok := l.rules[method]; ok {
+		return r
+	}
+	if i := strings.LastIndex(method, "."); i >= 0 {
+		if r, ok := l.rules[method[:i]+".*"]; ok {
+			return r
+		}
+	}
+	return l.def
+}
+
+// Allow takes one token from the bucket of client calling method. When the
+// bucket is empty it reports how long until a token becomes available.
+func (l *Limiter) Allow(method, client string) (bool, time.Duration) {
+	method = rpcname.Normalize(method)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	rule := l.rule(method)
+	if rule.Rate <= 0 {
+		return true, 0
+	}
+	burst := math.Max(float64(rule.Burst), 1)
+
+	now := l.now()
+	l.sweep(now)
+
+	key := bucketKey{method, client}
+	b, ok := l.buckets[key]
+	if !ok && len(l.buckets) >= l.max {
+		l.prune(now)
+		if len(l.buckets) >= l.max {
+			key.client = overflowClient
+			b, ok = l.buckets[key]
+		}
+	}
+	if !ok {
+		b = &bucket{tokens: burst, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(burst, b.tokens+now.Sub(b.last).Seconds()*rule.Rate)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	wait := time.Duration((1 - b.tokens) / rule.Rate * float64(time.Second))
+	return false, wait
+}
+
+// sweep drops buckets idle for idleAfter, by which time any practical rate
+// has refilled them. The caller must hold l.mu.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < idleAfter {
+		return
+	}
+	l.lastSweep = now
+	for k, b := range l.buckets {
+		if now.Sub(b.last) >= idleAfter {
+			delete(l.buckets, k)
+		}
+	}
+}
+
+// prune makes room for new clients by dropping buckets that have refilled
+// to their burst, which a new bucket would equal. It runs at most once a
+// second so a flood of new clients does not rescan the map on every call.
+// The caller must hold l.mu.
+func (l *Limiter) prune(now time.Time) {
+	if now.Sub(l.lastPrune) < time.Second {
+		return
+	}
+	l.lastPrune = now
+	for k, b := range l.buckets {
+		rule := l.rule(k.method)
+		if rule.Rate <= 0 || b.tokens+now.Sub(b.last).Seconds()*rule.Rate >= math.Max(float64(rule.Burst), 1) {
+			delete(l.buckets, k)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"awesomeproject/pkg/auth"
+
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// clock is a manual time source for a Limiter.
+type clock struct{ t time.Time }
+
+func (c *clock) now() time.Time          { return c.t }
+func (c *clock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newTestLimiter(cfg Config) (*Limiter, *clock) {
+	c := &clock{t: time.Unix(1700000000, 0)}
+	l := New(cfg)
+	l.now = c.now
+	l.lastSweep = c.t
+	return l, c
+}
+
+func TestAllow(t *testing.T) {
+	type step struct {
+		advance  time.Duration
+		method   string
+		client   string
+		want     bool
+		wantWait time.Duration
+	}
+	cfg := Config{
+		Default: Rule{Rate: 1, Burst: 2},
+		Methods: map[string]Rule{
+			"/Greeter/SayHello": {Rate: 10, Burst: 1},
+			"Greeter.*":         {Rate: 2, Burst: 3},
+			"Health.Check":      {},
+		},
+	}
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{"burst then empty", []step{
+			{0, "Other.Call", "a", true, 0},
+			{0, "Other.Call", "a", true, 0},
+			{0, "Other.Call", "a", false, time.Second},
+		}},
+		{"refill at rate", []step{
+			{0, "Other.Call", "a", true, 0},
+			{0, "Other.Call", "a", true, 0},
+			{500 * time.Millisecond, "Other.Call", "a", false, 500 * time.Millisecond},
+			{500 * time.Millisecond, "Other.Call", "a", true, 0},
+		}},
+		{"refill capped at burst", []step{
+			{time.Hour, "Other.Call", "a", true, 0},
+			{0, "Other.Call", "a", true, 0},
+			{0, "Other.Call", "a", false, time.Second},
+		}},
+		{"clients are separate", []step{
+			{0, "Other.Call", "a", true, 0},
+			{0, "Other.Call", "a", true, 0},
+			{0, "Other.Call", "b", true, 0},
+			{0, "Other.Call", "a", false, time.Second},
+		}},
+		{"methods are separate", []step{
+			{0, "Other.Call", "a", true, 0},
+			{0, "Other.Call", "a", true, 0},
+			{0, "Other.Ping", "a", true, 0},
+		}},
+		{"exact rule in either form", []step{
+			{0, "Greeter.SayHello", "a", true, 0},
+			{0, "/Greeter/SayHello", "a", false, 100 * time.Millisecond},
+		}},
+		{"service wildcard", []step{
+			{0, "/Greeter/SayBye", "a", true, 0},
+			{0, "/Greeter/SayBye", "a", true, 0},
+			{0, "/Greeter/SayBye", "a", true, 0},
+			{0, "/Greeter/SayBye", "a", false, 500 * time.Millisecond},
+		}},
+		{"zero rate is unlimited", []step{
+			{0, "Health.Check", "a", true, 0},
+			{0, "Health.Check", "a", true, 0},
+			{0, "Health.Check", "a", true, 0},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, c := newTestLimiter(cfg)
+			for i, s := range tt.steps {
+				c.advance(s.advance)
+				ok, wait := l.Allow(s.method, s.client)
+				if ok != s.want || wait != s.wantWait {
+					t.Fatalf("step %d: Allow(%s, %s) = %v, %v; want %v, %v", i, s.method, s.client, ok, wait, s.want, s.wantWait)
+				}
+			}
+		})
+	}
+}
+
+func TestSweep(t *testing.T) {
+	l, c := newTestLimiter(Config{Default: Rule{Rate: 1, Burst: 1}})
+	l.Allow("Svc.M", "a")
+	c.advance(idleAfter)
+	l.Allow("Svc.M", "b")
+	if _, ok := l.buckets[bucketKey{"Svc.M", "a"}]; ok {
+		t.Fatal("idle bucket survived the sweep")
+	}
+	if len(l.buckets) != 1 {
+		t.Fatalf("%d buckets, want 1", len(l.buckets))
+	}
+}
+
+func TestMaxClients(t *testing.T) {
+	l, c := newTestLimiter(Config{Default: Rule{Rate: 1, Burst: 1}, MaxClients: 3})
+	for i := 0; i < 3; i++ {
+		if ok, _ := l.Allow("Svc.M", fmt.Sprint(i)); !ok {
+			t.Fatalf("client %d rejected", i)
+		}
+	}
+
+	// Full, and no bucket has refilled: new clients share the overflow
+	// bucket, so rotating keys does not buy more calls.
+	if ok, _ := l.Allow("Svc.M", "new-1"); !ok {
+		t.Fatal("first overflow call rejected")
+	}
+	if ok, _ := l.Allow("Svc.M", "new-2"); ok {
+		t.Fatal("second overflow call allowed")
+	}
+	if len(l.buckets) > 4 {
+		t.Fatalf("%d buckets, want at most 4", len(l.buckets))
+	}
+	// Known clients keep their own buckets.
+	c.advance(time.Second)
+	if ok, _ := l.Allow("Svc.M", "0"); !ok {
+		t.Fatal("known client rejected after refill")
+	}
+
+	// Refilled buckets are dropped to make room.
+	c.advance(time.Second)
+	if ok, _ := l.Allow("Svc.M", "new-3"); !ok {
+		t.Fatal("new client rejected after buckets refilled")
+	}
+	if _, ok := l.buckets[bucketKey{"Svc.M", "new-3"}]; !ok {
+		t.Fatal("new client did not get its own bucket")
+	}
+}
+
+func TestMaxClientsDefault(t *testing.T) {
+	if l := New(Config{}); l.max != DefaultMaxClients {
+		t.Fatalf("max = %d, want %d", l.max, DefaultMaxClients)
+	}
+}
+
+func TestGRPCKey(t *testing.T) {
+	addr := &net.TCPAddr{IP: net.ParseIP("192.0.2.7"), Port: 4242}
+	base := peer.NewContext(context.Background(), &peer.Peer{Addr: addr})
+	withMD := metadata.NewIncomingContext(base, metadata.Pairs("x-tenant", "t1", "x-api-key", "secret"))
+	withPrincipal := auth.NewContext(withMD, &auth.Principal{Subject: "alice"})
+
+	tests := []struct {
+		spec string
+		ctx  context.Context
+		want string
+	}{
+		{"", withPrincipal, "principal:alice"},
+		{"principal", withPrincipal, "principal:alice"},
+		{"principal", withMD, "192.0.2.7"},
+		{"api-key", withMD, "192.0.2.7"},
+		{"api-key", withPrincipal, "principal:alice"},
+		{"ip", withPrincipal, "192.0.2.7"},
+		{"metadata:X-Tenant", withMD, "x-tenant:t1"},
+		{"metadata:x-other", withMD, "192.0.2.7"},
+		{"ip", context.Background(), ""},
+	}
+	for _, tt := range tests {
+		key, err := GRPCKey(tt.spec)
+		if err != nil {
+			t.Fatalf("GRPCKey(%q): %v", tt.spec, err)
+		}
+		if got := key(tt.ctx); got != tt.want {
+			t.Errorf("GRPCKey(%q) = %q, want %q", tt.spec, got, tt.want)
+		}
+	}
+	if _, err := GRPCKey("cookie"); err == nil {
+		t.Error("GRPCKey accepted an unknown spec")
+	}
+}
@@ -0,0 +1,406 @@
+package rpcclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand/v2"
+	"net"
+	"net/rpc"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Balancers accepted by PoolConfig.Balancer.
+const (
+	RoundRobin       = "round_robin"
+	LeastOutstanding = "least_outstanding"
+	PowerOfTwo       = "p2c" // the less loaded of two random connections
+)
+
+// ErrNoBackends is returned when no healthy backend has a live connection.
+var ErrNoBackends = errors.New("rpcclient: no healthy backend")
+
+// PingProbe calls a method no server defines: any reply, including the
+// "can't find service" error, shows the backend is serving.
+func PingProbe(ctx context.Context, c *rpc.Client) error {
+	call := c.Go("rpcclient.Ping", struct{}{}, new(struct{}), make(chan *rpc.Call, 1))
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-call.Done:
+	}
+	var serverErr rpc.ServerError
+	if call.Error == nil || errors.As(call.Error, &serverErr) {
+		return nil
+	}
+	return call.Error
+}
+
+// PoolConfig configures a Pool.
+type PoolConfig struct {
+	Addrs []string
+	// Dial returns the Dialer for one backend; it defaults to DialTCP.
+	Dial func(addr string) Dialer
+	// ConnsPerBackend is the number of connections kept to each backend.
+	ConnsPerBackend int
+	Balancer        string
+	// ProbeInterval is how often backends are probed and missing
+	// connections redialed.
+	ProbeInterval time.Duration
+	// ProbeTimeout bounds each dial and probe.
+	ProbeTimeout time.Duration
+	// Probe checks a backend over one of its connections. It defaults to
+	// PingProbe.
+	Probe func(ctx context.Context, c *rpc.Client) error
+}
+
+// DefaultPoolConfig returns the defaults for addrs.
+func DefaultPoolConfig(addrs ...string) PoolConfig {
+	return PoolConfig{
+		Addrs:           addrs,
+		Dial:            DialTCP,
+		ConnsPerBackend: 4,
+		Balancer:        RoundRobin,
+		ProbeInterval:   5 * time.Second,
+		ProbeTimeout:    time.Second,
+		Probe:           PingProbe,
+	}
+}
+
+// Pool spreads calls over several connections to each of several
+// backends. Its Call and Go methods match those of *rpc.Client. A
+// connection that fails is closed and redialed by the background prober,
+// and calls that could not be sent are retried on another connection;
+// calls lost in flight are not, since they may have run. It is safe for
+// concurrent use.
+type Pool struct {
+	cfg      PoolConfig
+	backends []*backend
+	next     atomic.Uint64 // round-robin position
+	wake     chan struct{}
+	cancel   context.CancelFunc
+	done     chan struct{}
+}
+
+type backend struct {
+	addr    string
+	dial    Dialer
+	healthy atomic.Bool
+	calls   atomic.Int64
+
+	mu    sync.Mutex
+	conns []*conn // fixed length; a nil client marks an empty slot
+}
+
+type conn struct {
+	backend     *backend
+	client      *rpc.Client
+	outstanding atomic.Int64
+}
+
+// NewPool dials every backend once and starts probing them. Backends that
+// cannot be reached are marked unhealthy and retried by the prober.
+func NewPool(cfg PoolConfig) (*Pool, error) {
+	if len(cfg.Addrs) == 0 {
+		return nil, errors.New("rpcclient: pool has no addresses")
+	}
+	def := DefaultPoolConfig()
+	if cfg.Dial == nil {
+		cfg.Dial = def.Dial
+	}
+	if cfg.ConnsPerBackend <= 0 {
+		cfg.ConnsPerBackend = def.ConnsPerBackend
+	}
+	if cfg.ProbeInterval <= 0 {
+		cfg.ProbeInterval = def.ProbeInterval
+	}
+	if cfg.ProbeTimeout <= 0 {
+		cfg.ProbeTimeout = def.ProbeTimeout
+	}
+	if cfg.Probe == nil {
+		cfg.Probe = def.Probe
+	}
+	switch cfg.Balancer {
+	case "":
+		cfg.Balancer = def.Balancer
+	case RoundRobin, LeastOutstanding, PowerOfTwo:
+	default:
+		return nil, fmt.Errorf("rpcclient: unknown balancer %q", cfg.Balancer)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &Pool{cfg: cfg, wake: make(chan struct{}, 1), cancel: cancel, done: make(chan struct{})}
+	for _, addr := range cfg.Addrs {
+		b := &backend{addr: addr, dial: cfg.Dial(addr), conns: make([]*conn, cfg.ConnsPerBackend)}
+		for i := range b.conns {
+			b.conns[i] = &conn{backend: b}
+		}
+		p.backends = append(p.backends, b)
+	}
+	p.probeAll(ctx)
+	go p.run(ctx)
+	return p, nil
+}
+
+// Close stops the prober and closes every connection. Pending calls fail
+// with rpc.ErrShutdown.
+func (p *Pool) Close() error {
+	p.cancel()
+	<-p.done
+	for _, b := range p.backends {
+		b.mu.Lock()
+		for _, c := range b.conns {
+			if c.client != nil {
+				c.client.Close()
+				c.client = nil
+			}
+		}
+		b.mu.Unlock()
+	}
+	return nil
+}
+
+// Call invokes serviceMethod and waits for it to complete.
+func (p *Pool) Call(serviceMethod string, args, reply any) error {
+	call := <-p.Go(serviceMethod, args, reply, make(chan *rpc.Call, 1)).Done
+	return call.Error
+}
+
+// CallContext is Call that stops waiting when ctx is done. The call
+// itself cannot be cancelled; its reply is discarded.
+func (p *Pool) CallContext(ctx context.Context, serviceMethod string, args, reply any) error {
+	call := p.Go(serviceMethod, args, reply, make(chan *rpc.Call, 1))
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-call.Done:
+		return call.Error
+	}
+}
+
+// Go invokes serviceMethod asynchronously, like rpc.Client.Go. done must
+// be buffered; if nil, a channel is allocated.
+func (p *Pool) Go(serviceMethod string, args, reply any, done chan *rpc.Call) *rpc.Call {
+	if done == nil {
+		done = make(chan *rpc.Call, 10)
+	} else if cap(done) == 0 {
+		log.Panic("rpcclient: done channel is unbuffered")
+	}
+	call := &rpc.Call{ServiceMethod: serviceMethod, Args: args, Reply: reply, Done: done}
+	go p.send(call)
+	return call
+}
+
+// send runs call on a picked connection, trying another one while the
+// request cannot be sent.
+func (p *Pool) send(call *rpc.Call) {
+	for range len(p.backends)*p.cfg.ConnsPerBackend + 1 {
+		c, client := p.pick()
+		if c == nil {
+			call.Error = ErrNoBackends
+			break
+		}
+		c.outstanding.Add(1)
+		inner := <-client.Go(call.ServiceMethod, call.Args, call.Reply, make(chan *rpc.Call, 1)).Done
+		c.outstanding.Add(-1)
+		call.Error = inner.Error
+		if transportError(inner.Error) {
+			p.evict(c, client)
+		}
+		if !notSent(inner.Error) {
+			c.backend.calls.Add(1)
+			break
+		}
+	}
+	call.Done <- call
+}
+
+// pick chooses a live connection on a healthy backend. It returns the
+// client read under the backend's lock: evict and Close may clear the
+// slot as soon as the lock is released.
+func (p *Pool) pick() (*conn, *rpc.Client) {
+	var live []*conn
+	clients := make(map[*conn]*rpc.Client)
+	for _, b := range p.backends {
+		if !b.healthy.Load() {
+			continue
+		}
+		b.mu.Lock()
+		for _, c := range b.conns {
+			if c.client != nil {
+				live = append(live, c)
+				clients[c] = c.client
+			}
+		}
+		b.mu.Unlock()
+	}
+	if len(live) == 0 {
+		return nil, nil
+	}
+	c := p.balance(live)
+	return c, clients[c]
+}
+
+// balance chooses one of live according to the Balancer.
+func (p *Pool) balance(live []*conn) *conn {
+	switch p.cfg.Balancer {
+	case LeastOutstanding:
+		best := live[0]
+		for _, c := range live[1:] {
+			if c.outstanding.Load() < best.outstanding.Load() {
+				best = c
+			}
+		}
+		return best
+	case PowerOfTwo:
+		a, b := live[rand.IntN(len(live))], live[rand.IntN(len(live))]
+		if b.outstanding.Load() < a.outstanding.Load() {
+			return b
+		}
+		return a
+	}
+	return live[p.next.Add(1)%uint64(len(live))]
+}
+
+// evict closes client, a broken connection in slot c, and asks the
+// prober to redial. A slot already redialed keeps its new connection.
+func (p *Pool) evict(c *conn, client *rpc.Client) {
+	b := c.backend
+	b.mu.Lock()
+	if c.client == client {
+		client.Close()
+		c.client = nil
+	}
+	b.mu.Unlock()
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (p *Pool) run(ctx context.Context) {
+	defer close(p.done)
+	t := time.NewTicker(p.cfg.ProbeInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		case <-p.wake:
+		}
+		p.probeAll(ctx)
+	}
+}
+
+func (p *Pool) probeAll(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, b := range p.backends {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.probe(ctx, b)
+		}()
+	}
+	wg.Wait()
+}
+
+// probe redials the empty slots of b and then runs the health probe.
+func (p *Pool) probe(ctx context.Context, b *backend) {
+	ctx, cancel := context.WithTimeout(ctx, p.cfg.ProbeTimeout)
+	defer cancel()
+
+	b.mu.Lock()
+	var empty []*conn
+	var sample *conn
+	for _, c := range b.conns {
+		if c.client == nil {
+			empty = append(empty, c)
+		} else if sample == nil {
+			sample = c
+		}
+	}
+	b.mu.Unlock()
+
+	var dialErr error
+	for _, c := range empty {
+		client, err := b.dial(ctx)
+		if err != nil {
+			dialErr = err
+			break // the backend is down; try again on the next probe
+		}
+		b.mu.Lock()
+		c.client = client
+		b.mu.Unlock()
+		if sample == nil {
+			sample = c
+		}
+	}
+
+	err := dialErr
+	if sample != nil {
+		b.mu.Lock()
+		client := sample.client
+		b.mu.Unlock()
+		if client == nil {
+			err = rpc.ErrShutdown // evicted meanwhile
+		} else if err = p.cfg.Probe(ctx, client); connectionLost(err) || notSent(err) {
+			p.evict(sample, client)
+		}
+	}
+	healthy := err == nil
+	if was := b.healthy.Swap(healthy); was != healthy {
+		if healthy {
+			log.Printf("rpcclient: backend %s is healthy", b.addr)
+		} else {
+			log.Printf("rpcclient: backend %s is unhealthy: %v", b.addr, err)
+		}
+	}
+}
+
+// transportError reports whether err came from the connection rather
+// than from the method.
+func transportError(err error) bool {
+	var serverErr rpc.ServerError
+	return err != nil && !errors.As(err, &serverErr)
+}
+
+// notSent reports whether err means the request never reached the
+// server: net/rpc returns rpc.ErrShutdown without sending, and the
+// error of a failed write as is.
+func notSent(err error) bool {
+	var opErr *net.OpError
+	return errors.Is(err, rpc.ErrShutdown) || errors.Is(err, io.ErrClosedPipe) ||
+		errors.As(err, &opErr) && opErr.Op == "write"
+}
+
+// BackendStats describes one backend of a Pool.
+type BackendStats struct {
+	Addr        string `json:"addr"`
+	Healthy     bool   `json:"healthy"`
+	Conns       int    `json:"conns"` // live connections
+	Outstanding int64  `json:"outstanding"`
+	Calls       int64  `json:"calls"` // completed calls
+}
+
+// Stats returns the state of every backend.
+func (p *Pool) Stats() []BackendStats {
+	out := make([]BackendStats, 0, len(p.backends))
+	for _, b := range p.backends {
+		s := BackendStats{Addr: b.addr, Healthy: b.healthy.Load(), Calls: b.calls.Load()}
+		b.mu.Lock()
+		for _, c := range b.conns {
+			if c.client != nil {
+				s.Conns++
+			}
+			s.Outstanding += c.outstanding.Load()
+		}
+		b.mu.Unlock()
+		out = append(out, s)
+	}
+	return out
+}
@@ -0,0 +1,263 @@
+package rpcclient
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/rpc"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestPool builds a Pool over fake backends with a slow prober, so
+// only evictions trigger a probe during the test.
+func newTestPool(t *testing.T, balancer string, backends ...*fakeBackend) *Pool {
+	t.Helper()
+	byAddr := make(map[string]*fakeBackend)
+	var addrs []string
+	for _, b := range backends {
+		byAddr[b.name] = b
+		addrs = append(addrs, b.name)
+	}
+	p, err := NewPool(PoolConfig{
+		Addrs:           addrs,
+		Dial:            func(addr string) Dialer { return byAddr[addr].Dial },
+		ConnsPerBackend: 2,
+		Balancer:        balancer,
+		ProbeInterval:   time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { p.Close() })
+	return p
+}
+
+func TestNewPoolErrors(t *testing.T) {
+	tests := []struct {
+		cfg  PoolConfig
+		want string
+	}{
+		{PoolConfig{}, "no addresses"},
+		{PoolConfig{Addrs: []string{"a"}, Balancer: "random"}, "unknown balancer"},
+	}
+	for _, tt := range tests {
+		if _, err := NewPool(tt.cfg); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("NewPool(%+v) = %v, want %q", tt.cfg, err, tt.want)
+		}
+	}
+}
+
+func TestPoolBalancing(t *testing.T) {
+	for _, balancer := range []string{RoundRobin, LeastOutstanding, PowerOfTwo} {
+		t.Run(balancer, func(t *testing.T) {
+			a, b := newFakeBackend(t, "a"), newFakeBackend(t, "b")
+			p := newTestPool(t, balancer, a, b)
+			seen := map[string]int{}
+			for range 40 {
+				var reply string
+				if err := p.Call("Echo.Say", "x", &reply); err != nil {
+					t.Fatal(err)
+				}
+				seen[strings.TrimSuffix(reply, ":x")]++
+			}
+			if balancer == RoundRobin && (seen["a"] != 20 || seen["b"] != 20) {
+				t.Fatalf("round robin spread %v", seen)
+			}
+			// Sequential calls leave every connection idle, so the other
+			// balancers may favour one; they must still reach only
+			// healthy backends and count every call.
+			stats := p.Stats()
+			if len(stats) != 2 || stats[0].Calls+stats[1].Calls != 40 ||
+				stats[0].Calls != int64(seen["a"]) || stats[0].Conns != 2 || !stats[0].Healthy {
+				t.Fatalf("Stats = %+v, seen %v", stats, seen)
+			}
+		})
+	}
+}
+
+func TestPoolPick(t *testing.T) {
+	live := func(t *testing.T, loads ...int64) (*Pool, []*conn) {
+		t.Helper()
+		b := &backend{addr: "a"}
+		b.healthy.Store(true)
+		for _, n := range loads {
+			_, client := net.Pipe()
+			c := &conn{backend: b, client: rpc.NewClient(client)}
+			t.Cleanup(func() { c.client.Close() })
+			c.outstanding.Store(n)
+			b.conns = append(b.conns, c)
+		}
+		return &Pool{backends: []*backend{b}}, b.conns
+	}
+
+	p, conns := live(t, 3, 1, 2)
+	p.cfg.Balancer = LeastOutstanding
+	if got, client := p.pick(); got != conns[1] || client != conns[1].client {
+		t.Fatalf("least_outstanding picked load %d", got.outstanding.Load())
+	}
+
+	// p2c returns the busier of two connections only when it drew it
+	// twice, one time in four.
+	p, conns = live(t, 0, 5)
+	p.cfg.Balancer = PowerOfTwo
+	idle := 0
+	for range 1000 {
+		if c, _ := p.pick(); c == conns[0] {
+			idle++
+		}
+	}
+	if idle < 650 {
+		t.Fatalf("p2c picked the idle connection %d times in 1000", idle)
+	}
+
+	p, _ = live(t)
+	if c, client := p.pick(); c != nil || client != nil {
+		t.Fatal("picked from a backend without connections")
+	}
+}
+
+func TestPoolUnhealthyBackend(t *testing.T) {
+	a, b := newFakeBackend(t, "a"), newFakeBackend(t, "b")
+	b.setDown(true)
+	p := newTestPool(t, RoundRobin, a, b)
+	for range 4 {
+		var reply string
+		if err := p.Call("Echo.Say", "x", &reply); err != nil || reply != "a:x" {
+			t.Fatalf("Call = %q, %v", reply, err)
+		}
+	}
+	if st := p.Stats(); st[1].Healthy || st[1].Conns != 0 || st[0].Calls != 4 {
+		t.Fatalf("Stats = %+v", st)
+	}
+
+	a.setDown(true)
+	a.dropConns()
+	var reply string
+	err := p.CallContext(testContext(t, 5*time.Second), "Echo.Say", "x", &reply)
+	if err == nil {
+		t.Fatal("call succeeded with every backend down")
+	}
+	eventually(t, "a to be marked unhealthy", func() bool { return !p.Stats()[0].Healthy })
+	if err := p.Call("Echo.Say", "x", &reply); !errors.Is(err, ErrNoBackends) {
+		t.Fatalf("Call = %v, want ErrNoBackends", err)
+	}
+}
+
+func TestPoolEvictsAndRedials(t *testing.T) {
+	a := newFakeBackend(t, "a")
+	p := newTestPool(t, RoundRobin, a)
+	if n := a.dialCount(); n != 2 {
+		t.Fatalf("NewPool dialed %d connections, want 2", n)
+	}
+	// A call lost in flight fails and is not retried, since it may have
+	// run; the broken connections are redialed in the background.
+	var reply string
+	if err := p.Call("Echo.Hangup", 1, &reply); err == nil {
+		t.Fatal("call lost in flight succeeded")
+	}
+	eventually(t, "the connections to be redialed", func() bool {
+		st := p.Stats()[0]
+		return st.Conns == 2 && st.Healthy && a.dialCount() >= 3
+	})
+	// Calls succeed again once the pool has recovered.
+	for range 4 {
+		if err := p.Call("Echo.Say", "x", &reply); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestPoolEvictStaleClient(t *testing.T) {
+	a := newFakeBackend(t, "a")
+	p := newTestPool(t, RoundRobin, a)
+	c, stale := p.pick()
+	fresh, err := a.Dial(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.backend.mu.Lock()
+	c.client = fresh // redialed by the prober meanwhile
+	c.backend.mu.Unlock()
+	p.evict(c, stale)
+	c.backend.mu.Lock()
+	defer c.backend.mu.Unlock()
+	if c.client != fresh {
+		t.Fatal("evicting a stale client cleared the redialed slot")
+	}
+}
+
+// TestPoolConcurrentEvict runs calls while connections break and the pool
+// closes; run with -race.
+func TestPoolConcurrentEvict(t *testing.T) {
+	a, b := newFakeBackend(t, "a"), newFakeBackend(t, "b")
+	p := newTestPool(t, RoundRobin, a, b)
+	stop := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-stop:
+				return
+			case <-time.After(time.Millisecond):
+				a.dropConns()
+			}
+		}
+	}()
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 50 {
+				var reply string
+				p.Call("Echo.Say", "x", &reply) // errors are expected
+			}
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	p.Close()
+	wg.Wait()
+	close(stop)
+}
+
+func TestPoolCallContext(t *testing.T) {
+	a := newFakeBackend(t, "a")
+	p := newTestPool(t, RoundRobin, a)
+	defer close(a.release)
+	var reply string
+	err := p.CallContext(testContext(t, 50*time.Millisecond), "Echo.Block", "x", &reply)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("CallContext = %v", err)
+	}
+	if st := p.Stats()[0]; st.Outstanding != 1 {
+		t.Fatalf("abandoned call not outstanding: %+v", st)
+	}
+}
+
+func TestPingProbe(t *testing.T) {
+	a := newFakeBackend(t, "a")
+	client, err := a.Dial(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := PingProbe(testContext(t, time.Second), client); err != nil {
+		t.Fatalf("PingProbe on a serving backend = %v", err)
+	}
+	client.Close()
+	if err := PingProbe(testContext(t, time.Second), client); !errors.Is(err, rpc.ErrShutdown) {
+		t.Fatalf("PingProbe on a closed client = %v", err)
+	}
+}
+
+// eventually polls cond for up to two seconds.
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		if cond() {
+			return
+		}
+	}
+	t.Fatalf("timed out waiting for %s", what)
+}
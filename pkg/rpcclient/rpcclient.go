@@ -0,0 +1,211 @@
+// Package rpcclient adds context support and reconnection to net/rpc
+// clients. net/rpc has no notion of a context, so Call runs on top of
+// rpc.Client.Go and gives up waiting when the context is done.
+package rpcclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"net/url"
+	"sync"
+	"time"
+
+	"awesomeproject/pkg/rpchook"
+)
+
+// Dialer opens a new connection. Client calls it again after the previous
+// connection is lost.
+type Dialer func(ctx context.Context) (*rpc.Client, error)
+
+// DialTCP speaks gob over TCP, as served by server.go.
+func DialTCP(addr string) Dialer {
+	return func(ctx context.Context) (*rpc.Client, error) {
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return nil, err
+		}
+		return rpc.NewClient(conn), nil
+	}
+}
+
+// DialJSON speaks JSON-RPC 1.0 over TCP, as served by jsonRpcServer.go.
+func DialJSON(addr string) Dialer {
+	return func(ctx context.Context) (*rpc.Client, error) {
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return nil, err
+		}
+		return jsonrpc.NewClient(conn), nil
+	}
+}
+
+// DialHTTP speaks gob over an HTTP CONNECT tunnel, as served by
+// serverHttp.go.
+func DialHTTP(addr string) Dialer {
+	return func(ctx context.Context) (*rpc.Client, error) {
+		type result struct {
+			c   *rpc.Client
+			err error
+		}
+		// rpc.DialHTTP takes no context; on timeout, close the client
+		// once the dial completes.
+		ch := make(chan result, 1)
+		go func() {
+			c, err := rpc.DialHTTP("tcp", addr)
+			ch <- result{c, err}
+		}()
+		select {
+		case r := <-ch:
+			return r.c, r.err
+		case <-ctx.Done():
+			go func() {
+				if r := <-ch; r.c != nil {
+					r.c.Close()
+				}
+			}()
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Redial backoff: doubles from minBackoff after each failed dial and each
+// connection lost before a call completed on it, up to maxBackoff.
+const (
+	minBackoff = 100 * time.Millisecond
+	maxBackoff = 5 * time.Second
+)
+
+// maxAttempts bounds the dials and sends one Call makes.
+const maxAttempts = 3
+
+// Client is a net/rpc client that honors context deadlines and redials
+// lost connections. When ctx is done Call returns ctx.Err() at once and a
+// late reply is discarded. A call that could not be sent because the
+// connection was lost (rpc.ErrShutdown, a failed write) is sent again on
+// a new connection, up to maxAttempts times in all. A call lost in flight
+// (io.ErrUnexpectedEOF) is not, since the server may have run it. It is
+// safe for concurrent use.
+type Client struct {
+	dial Dialer
+	// Metadata is sent with every call for a server using rpchook, e.g.
+	// an "authorization" token.
+	Metadata url.Values
+
+	mu      sync.Mutex
+	client  *rpc.Client
+	backoff time.Duration
+	retryAt time.Time
+}
+
+// New returns a Client that dials on its first call.
+func New(dial Dialer) *Client {
+	return &Client{dial: dial}
+}
+
+// Close closes the current connection. Later calls dial again.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.client == nil {
+		return nil
+	}
+	err := c.client.Close()
+	c.client = nil
+	return err
+}
+
+// Call invokes serviceMethod and waits for the reply or for ctx to be done.
+func (c *Client) Call(ctx context.Context, serviceMethod string, args, reply any) error {
+	serviceMethod = rpchook.WithMetadata(serviceMethod, c.Metadata)
+	var err error
+	for range maxAttempts {
+		var client *rpc.Client
+		if client, err = c.connect(ctx); err != nil {
+			if ctx.Err() != nil {
+				return err
+			}
+			continue
+		}
+		call := client.Go(serviceMethod, args, reply, make(chan *rpc.Call, 1))
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-call.Done:
+		}
+		if err = call.Error; !connectionLost(err) && !notSent(err) {
+			c.completed()
+			return err
+		}
+		c.drop(client)
+		if !notSent(err) {
+			return err
+		}
+	}
+	return err
+}
+
+// connect returns the current connection or, once the backoff has
+// elapsed, dials a new one.
+func (c *Client) connect(ctx context.Context) (*rpc.Client, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for c.client == nil {
+		if wait := time.Until(c.retryAt); wait > 0 {
+			t := time.NewTimer(wait)
+			c.mu.Unlock()
+			select {
+			case <-ctx.Done():
+				t.Stop()
+				c.mu.Lock()
+				return nil, ctx.Err()
+			case <-t.C:
+			}
+			c.mu.Lock()
+			continue // another call may have connected meanwhile
+		}
+		client, err := c.dial(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			c.backoff = min(max(2*c.backoff, minBackoff), maxBackoff)
+			c.retryAt = time.Now().Add(c.backoff)
+			return nil, err
+		}
+		c.client = client
+	}
+	return c.client, nil
+}
+
+// completed resets the backoff once a call got an answer. A successful
+// dial alone does not, or a server that accepts and drops connections
+// would be redialed without pause.
+func (c *Client) completed() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.backoff = 0
+}
+
+// drop discards a lost connection unless another call already replaced
+// it. The first redial after calls completed is immediate; later ones
+// back off.
+func (c *Client) drop(client *rpc.Client) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.client == client {
+		client.Close()
+		c.client = nil
+		c.retryAt = time.Now().Add(c.backoff)
+		c.backoff = min(max(2*c.backoff, minBackoff), maxBackoff)
+	}
+}
+
+func connectionLost(err error) bool {
+	return errors.Is(err, rpc.ErrShutdown) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
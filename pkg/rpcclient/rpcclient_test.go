@@ -0,0 +1,314 @@
+package rpcclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/rpc"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"awesomeproject/pkg/rpchook"
+	"awesomeproject/pkg/rpcserver"
+)
+
+// Echo is the service the fake backends serve.
+type Echo struct{ b *fakeBackend }
+
+func (e Echo) Say(s string, reply *string) error {
+	*reply = e.b.name + ":" + s
+	return nil
+}
+
+func (e Echo) Fail(s string, reply *string) error {
+	return errors.New("no " + s)
+}
+
+// Hangup drops every connection of the backend while the call is in
+// flight, the first n times it is called.
+func (e Echo) Hangup(n int, reply *string) error {
+	b := e.b
+	b.mu.Lock()
+	hangup := b.hangups < n
+	if hangup {
+		b.hangups++
+	}
+	b.mu.Unlock()
+	if hangup {
+		b.dropConns()
+		return nil
+	}
+	*reply = "ok"
+	return nil
+}
+
+// Block does not reply until the backend's release channel is closed.
+func (e Echo) Block(s string, reply *string) error {
+	<-e.b.release
+	*reply = s
+	return nil
+}
+
+// fakeBackend serves Echo over in-memory pipes and counts the dials.
+type fakeBackend struct {
+	name    string
+	srv     *rpc.Server
+	release chan struct{}
+	// Metadata records the metadata of the last call.
+	metadata url.Values
+
+	mu   sync.Mutex
+	down bool // dials fail while set
+	// dropOnDial closes each connection as soon as it is dialed.
+	dropOnDial bool
+	dials      int
+	hangups    int
+	conns      []net.Conn
+}
+
+func newFakeBackend(t *testing.T, name string) *fakeBackend {
+	t.Helper()
+	b := &fakeBackend{name: name, srv: rpc.NewServer(), release: make(chan struct{})}
+	if err := b.srv.RegisterName("Echo", Echo{b}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		b.setDown(true)
+		b.dropConns()
+	})
+	return b
+}
+
+func (b *fakeBackend) Dial(ctx context.Context) (*rpc.Client, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.dials++
+	if b.down {
+		return nil, errors.New(b.name + " is down")
+	}
+	server, client := net.Pipe()
+	if b.dropOnDial {
+		server.Close()
+	}
+	b.conns = append(b.conns, server)
+	record := rpchook.Hooks{Before: func(c *rpchook.Call) error {
+		b.mu.Lock()
+		b.metadata = c.Metadata
+		b.mu.Unlock()
+		return nil
+	}}
+	go b.srv.ServeCodec(rpchook.NewServerCodec(rpcserver.NewGobServerCodec(server), record))
+	return rpc.NewClient(client), nil
+}
+
+func (b *fakeBackend) setDown(down bool) {
+	b.mu.Lock()
+	b.down = down
+	b.mu.Unlock()
+}
+
+func (b *fakeBackend) dropConns() {
+	b.mu.Lock()
+	conns := b.conns
+	b.conns = nil
+	b.mu.Unlock()
+	for _, c := range conns {
+		c.Close()
+	}
+}
+
+func (b *fakeBackend) dialCount() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.dials
+}
+
+func testContext(t *testing.T, d time.Duration) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+func TestClientCall(t *testing.T) {
+	b := newFakeBackend(t, "a")
+	c := New(b.Dial)
+	defer c.Close()
+	c.Metadata = url.Values{"token": {"t"}}
+	ctx := testContext(t, 5*time.Second)
+
+	tests := []struct {
+		method, arg string
+		want        string
+		wantErr     string
+	}{
+		{"Echo.Say", "x", "a:x", ""},
+		{"Echo.Fail", "x", "", "no x"},
+		{"Echo.Shout", "x", "", "can't find method"},
+	}
+	for _, tt := range tests {
+		var reply string
+		err := c.Call(ctx, tt.method, tt.arg, &reply)
+		if tt.wantErr != "" {
+			var serverErr rpc.ServerError
+			if !errors.As(err, &serverErr) || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: err = %v, want server error %q", tt.method, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || reply != tt.want {
+			t.Errorf("%s = %q, %v; want %q", tt.method, reply, err, tt.want)
+		}
+	}
+	if n := b.dialCount(); n != 1 {
+		t.Fatalf("dialed %d times; method errors must not redial", n)
+	}
+	if got := b.metadata.Get("token"); got != "t" {
+		t.Fatalf("server saw metadata %v", b.metadata)
+	}
+}
+
+func TestClientLostConnection(t *testing.T) {
+	b := newFakeBackend(t, "a")
+	c := New(b.Dial)
+	defer c.Close()
+	ctx := testContext(t, 5*time.Second)
+
+	// Lost in flight: the server may have run the call, so it is not
+	// sent again.
+	var reply string
+	if err := c.Call(ctx, "Echo.Hangup", 1, &reply); !errors.Is(err, io.ErrUnexpectedEOF) || b.dialCount() != 1 {
+		t.Fatalf("Call = %v after %d dials, want io.ErrUnexpectedEOF after 1", err, b.dialCount())
+	}
+	if err := c.Call(ctx, "Echo.Hangup", 1, &reply); err != nil || reply != "ok" || b.dialCount() != 2 {
+		t.Fatalf("Call = %q, %v after %d dials", reply, err, b.dialCount())
+	}
+
+	// Lost while idle: the call is not sent on the dead connection, so
+	// it is sent again on a new one.
+	b.dropConns()
+	if err := c.Call(ctx, "Echo.Say", "x", &reply); err != nil || reply != "a:x" || b.dialCount() != 3 {
+		t.Fatalf("Call = %q, %v after %d dials", reply, err, b.dialCount())
+	}
+	if c.backoff != 0 {
+		t.Fatalf("backoff %v after a completed call", c.backoff)
+	}
+}
+
+// TestClientGivesUp checks that Call stops after maxAttempts dials, and
+// that connections closed before a call completes back off like failed
+// dials: 100ms and 200ms between the attempts.
+func TestClientGivesUp(t *testing.T) {
+	tests := []struct {
+		name       string
+		down       bool // dials fail
+		dropOnDial bool // dials succeed but the connection is closed at once
+	}{
+		{"dials fail", true, false},
+		{"connections dropped", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newFakeBackend(t, "a")
+			b.setDown(tt.down)
+			b.dropOnDial = tt.dropOnDial
+			c := New(b.Dial)
+			defer c.Close()
+			var reply string
+			err := c.Call(testContext(t, 5*time.Second), "Echo.Say", "x", &reply)
+			if err == nil || errors.Is(err, context.DeadlineExceeded) || b.dialCount() != maxAttempts {
+				t.Fatalf("Call = %v after %d dials, want the last error after %d", err, b.dialCount(), maxAttempts)
+			}
+			if c.backoff != 4*minBackoff {
+				t.Fatalf("backoff %v, want %v", c.backoff, 4*minBackoff)
+			}
+		})
+	}
+}
+
+func TestClientBackoff(t *testing.T) {
+	b := newFakeBackend(t, "a")
+	b.setDown(true)
+	c := New(b.Dial)
+	defer c.Close()
+
+	// Every dial fails: the call gives up with the context, after
+	// backing off 100ms and then 200ms.
+	var reply string
+	err := c.Call(testContext(t, 250*time.Millisecond), "Echo.Say", "x", &reply)
+	if !errors.Is(err, context.DeadlineExceeded) || b.dialCount() != 2 || c.backoff != 2*minBackoff {
+		t.Fatalf("Call = %v after %d dials, backoff %v", err, b.dialCount(), c.backoff)
+	}
+
+	// The backend returns: the next dial waits out the backoff and the
+	// backoff is reset.
+	b.setDown(false)
+	start := time.Now()
+	if err := c.Call(testContext(t, 5*time.Second), "Echo.Say", "x", &reply); err != nil {
+		t.Fatal(err)
+	}
+	if b.dialCount() != 3 || c.backoff != 0 || time.Since(start) > 2*minBackoff+100*time.Millisecond {
+		t.Fatalf("%d dials, backoff %v after %v", b.dialCount(), c.backoff, time.Since(start))
+	}
+
+	// The backoff is capped.
+	c.backoff = maxBackoff
+	c.Close()
+	b.setDown(true)
+	c.Call(testContext(t, 10*time.Millisecond), "Echo.Say", "x", &reply)
+	if c.backoff != maxBackoff {
+		t.Fatalf("backoff %v, want the cap %v", c.backoff, maxBackoff)
+	}
+}
+
+func TestClientContextDuringCall(t *testing.T) {
+	b := newFakeBackend(t, "a")
+	c := New(b.Dial)
+	defer c.Close()
+	defer close(b.release)
+	start := time.Now()
+	var reply string
+	err := c.Call(testContext(t, 50*time.Millisecond), "Echo.Block", "x", &reply)
+	if !errors.Is(err, context.DeadlineExceeded) || time.Since(start) > time.Second {
+		t.Fatalf("Call = %v after %v", err, time.Since(start))
+	}
+}
+
+func TestDialers(t *testing.T) {
+	srv := rpc.NewServer()
+	if err := srv.RegisterName("Echo", Echo{&fakeBackend{name: "tcp"}}); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		codec string
+		dial  func(string) Dialer
+	}{
+		{rpcserver.CodecGob, DialTCP},
+		{rpcserver.CodecJSON, DialJSON},
+	}
+	for _, tt := range tests {
+		t.Run(tt.codec, func(t *testing.T) {
+			s, err := rpcserver.New(rpcserver.Config{Codec: tt.codec, Server: srv})
+			if err != nil {
+				t.Fatal(err)
+			}
+			l, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go s.Serve(ctx, l)
+
+			c := New(tt.dial(l.Addr().String()))
+			defer c.Close()
+			var reply string
+			if err := c.Call(testContext(t, 5*time.Second), "Echo.Say", "x", &reply); err != nil || reply != "tcp:x" {
+				t.Fatalf("Call = %q, %v", reply, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package rpccreds
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Config selects a TokenSource from command-line style settings. At most
+// one of Token, TokenFile and a JWT signing key may be set.
+type Config struct {
+	Token         string
+	TokenFile     string
+	MetadataKey   string
+	AllowInsecure bool
+	JWT           JWTConfig
+}
+
+// RegisterFlags binds the Config fields to command-line flags on fs.
+func (c *Config) RegisterFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.Token, "token", "", "static bearer token or API key")
+	fs.StringVar(&c.TokenFile, "token-file", "", "file holding the token, reloaded on change")
+	fs.StringVar(&c.MetadataKey, "token-key", "authorization", "metadata key for the token, e.g. x-api-key")
+	fs.BoolVar(&c.AllowInsecure, "insecure-token", false, "allow sending the token over a plaintext connection")
+	fs.StringVar(&c.JWT.HS256Secret, "jwt-hs256-secret", "", "file holding the HS256 secret for minting JWTs")
+	fs.StringVar(&c.JWT.RS256KeyFile, "jwt-rs256-key", "", "PEM RSA private key for minting RS256 JWTs")
+	fs.StringVar(&c.JWT.KeyID, "jwt-kid", "", "kid header of minted JWTs")
+	fs.StringVar(&c.JWT.Subject, "jwt-sub", "", "sub claim of minted JWTs")
+	fs.StringVar(&c.JWT.Issuer, "jwt-iss", "", "iss claim of minted JWTs")
+	fs.StringVar(&c.JWT.Audience, "jwt-aud", "", "aud claim of minted JWTs")
+	fs.Func("jwt-roles", "comma-separated roles claim of minted JWTs", listFlag(&c.JWT.Roles))
+	fs.Func("jwt-scopes", "comma-separated scopes of minted JWTs", listFlag(&c.JWT.Scopes))
+	fs.DurationVar(&c.JWT.TTL, "jwt-ttl", time.Hour, "lifetime of minted JWTs")
+}
+
+func listFlag(dst *[]string) func(string) error {
+	return func(s string) error {
+		for _, v := range strings.Split(s, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				*dst = append(*dst, v)
+			}
+		}
+		return nil
+	}
+}
+
+func (c *Config) mintsJWT() bool {
+	return c.JWT.HS256Secret != "" || c.JWT.RS256KeyFile != ""
+}
+
+// Enabled reports whether any token source was configured.
+func (c *Config) Enabled() bool {
+	return c.Token != "" || c.TokenFile != "" || c.mintsJWT()
+}
+
+// FromConfig builds Credentials for cfg. A token file is watched until
+// ctx is done.
+func FromConfig(ctx context.Context, cfg Config) (*Credentials, error) {
+	sources := 0
+	for _, set := range []bool{cfg.Token != "", cfg.TokenFile != "", cfg.mintsJWT()} {
+		if set {
+			sources++
+		}
+	}
+	if sources != 1 {
+		return nil, fmt.Errorf("rpccreds: set exactly one of -token, -token-file and a JWT signing key")
+	}
+
+	var src TokenSource
+	switch {
+	case cfg.Token != "":
+		src = Static(cfg.Token)
+	case cfg.TokenFile != "":
+		f, err := NewFile(cfg.TokenFile)
+		if err != nil {
+			return nil, err
+		}
+		go f.Watch(ctx, 0)
+		src = f
+	default:
+		j, err := NewJWT(cfg.JWT)
+		if err != nil {
+			return nil, err
+		}
+		src = j
+	}
+
+	var opts []Option
+	if cfg.MetadataKey != "" {
+		opts = append(opts, WithMetadataKey(cfg.MetadataKey))
+	}
+	if cfg.AllowInsecure {
+		opts = append(opts, AllowInsecure())
+	}
+	return New(src, opts...), nil
+}
@@ -0,0 +1,114 @@
+package rpccreds
+
+import (
+	"context"
+	"crypto/rsa"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"awesomeproject/pkg/auth"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// JWTConfig describes the tokens minted by a JWT source. Exactly one of
+// HS256Secret and RS256KeyFile must be set.
+type JWTConfig struct {
+	HS256Secret  string // file holding the shared HMAC secret
+	RS256KeyFile string // PEM-encoded RSA private key
+	KeyID        string // "kid" header, for servers using a JWKS
+	Subject      string
+	Issuer       string
+	Audience     string
+	Roles        []string
+	Scopes       []string
+	TTL          time.Duration // lifetime of each token; one hour if zero
+}
+
+// JWT is a TokenSource that signs its own short-lived tokens. A new token
+// is minted once the current one enters the last fifth of its lifetime,
+// so calls never carry a token that is about to expire.
+type JWT struct {
+	cfg    JWTConfig
+	method jwt.SigningMethod
+	key    interface{}
+
+	mu      sync.Mutex
+	token   string
+	refresh time.Time
+}
+
+// NewJWT loads the signing key named in cfg.
+func NewJWT(cfg JWTConfig) (*JWT, error) {
+	if cfg.Subject == "" {
+		return nil, fmt.Errorf("rpccreds: JWT needs a subject")
+	}
+	if cfg.TTL <= 0 {
+		cfg.TTL = time.Hour
+	}
+	j := &JWT{cfg: cfg}
+	switch {
+	case cfg.HS256Secret != "" && cfg.RS256KeyFile != "":
+		return nil, fmt.Errorf("rpccreds: set only one of the HS256 secret and the RS256 key")
+	case cfg.HS256Secret != "":
+		secret, err := auth.LoadHS256Secret(cfg.HS256Secret)
+		if err != nil {
+			return nil, err
+		}
+		j.method, j.key = jwt.SigningMethodHS256, secret
+	case cfg.RS256KeyFile != "":
+		pem, err := os.ReadFile(cfg.RS256KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		var key *rsa.PrivateKey
+		if key, err = jwt.ParseRSAPrivateKeyFromPEM(pem); err != nil {
+			return nil, fmt.Errorf("rpccreds: %s: %w", cfg.RS256KeyFile, err)
+		}
+		j.method, j.key = jwt.SigningMethodRS256, key
+	default:
+		return nil, fmt.Errorf("rpccreds: JWT needs an HS256 secret or an RS256 key")
+	}
+	return j, nil
+}
+
+// Token implements TokenSource.
+func (j *JWT) Token(context.Context) (string, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	now := time.Now()
+	if j.token != "" && now.Before(j.refresh) {
+		return j.token, nil
+	}
+	token, err := j.mint(now)
+	if err != nil {
+		return "", err
+	}
+	j.token, j.refresh = token, now.Add(j.cfg.TTL*4/5)
+	return token, nil
+}
+
+func (j *JWT) mint(now time.Time) (string, error) {
+	claims := auth.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   j.cfg.Subject,
+			Issuer:    j.cfg.Issuer,
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now.Add(-30 * time.Second)),
+			ExpiresAt: jwt.NewNumericDate(now.Add(j.cfg.TTL)),
+		},
+		Roles: j.cfg.Roles,
+		Scope: strings.Join(j.cfg.Scopes, " "),
+	}
+	if j.cfg.Audience != "" {
+		claims.Audience = jwt.ClaimStrings{j.cfg.Audience}
+	}
+	t := jwt.NewWithClaims(j.method, claims)
+	if j.cfg.KeyID != "" {
+		t.Header["kid"] = j.cfg.KeyID
+	}
+	return t.SignedString(j.key)
+}
@@ -0,0 +1,138 @@
+// Package rpccreds attaches bearer tokens to outgoing gRPC calls. A
+// TokenSource supplies the token, which may be static, read from a file
+// that is reloaded on change, or a JWT minted and refreshed locally;
+// Credentials adapts it to credentials.PerRPCCredentials.
+package rpccreds
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"awesomeproject/pkg/filewatch"
+
+	"google.golang.org/grpc/credentials"
+)
+
+// TokenSource returns the token to present on the next call.
+type TokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// Static is a TokenSource for a fixed token.
+type Static string
+
+// Token implements TokenSource.
+func (s Static) Token(context.Context) (string, error) {
+	if s == "" {
+		return "", errors.New("rpccreds: empty token")
+	}
+	return string(s), nil
+}
+
+// File is a TokenSource that reads its token from a file, e.g. one kept
+// up to date by a secret manager. Call Watch to pick up later edits.
+type File struct {
+	path  string
+	mu    sync.RWMutex
+	token string
+}
+
+// NewFile reads the token at path.
+func NewFile(path string) (*File, error) {
+	f := &File{path: path}
+	if err := f.Reload(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Reload re-reads the token file. On error the current token is kept.
+func (f *File) Reload() error {
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return err
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return fmt.Errorf("rpccreds: %s is empty", f.path)
+	}
+	f.mu.Lock()
+	f.token = token
+	f.mu.Unlock()
+	return nil
+}
+
+// Watch reloads the token whenever its file changes, until ctx is done.
+func (f *File) Watch(ctx context.Context, interval time.Duration) {
+	filewatch.Watch(ctx, interval, f.Reload, f.path)
+}
+
+// Token implements TokenSource.
+func (f *File) Token(context.Context) (string, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.token, nil
+}
+
+// Credentials implements credentials.PerRPCCredentials for a TokenSource.
+type Credentials struct {
+	src           TokenSource
+	key           string
+	allowInsecure bool
+}
+
+// Option configures Credentials.
+type Option func(*Credentials)
+
+// WithMetadataKey sends the token under key instead of "authorization".
+// Only the "authorization" key gets the "Bearer " prefix.
+func WithMetadataKey(key string) Option {
+	return func(c *Credentials) {
+		c.key = strings.ToLower(key)
+	}
+}
+
+// AllowInsecure permits sending the token over a plaintext connection.
+// Without it, calls on insecure transports fail before anything is sent.
+func AllowInsecure() Option {
+	return func(c *Credentials) {
+		c.allowInsecure = true
+	}
+}
+
+// New returns Credentials that present the token from src on every call.
+func New(src TokenSource, opts ...Option) *Credentials {
+	c := &Credentials{src: src, key: "authorization"}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// GetRequestMetadata implements credentials.PerRPCCredentials.
+func (c *Credentials) GetRequestMetadata(ctx context.Context, _ ...string) (map[string]string, error) {
+	if !c.allowInsecure {
+		ri, _ := credentials.RequestInfoFromContext(ctx)
+		if err := credentials.CheckSecurityLevel(ri.AuthInfo, credentials.PrivacyAndIntegrity); err != nil {
+			return nil, fmt.Errorf("rpccreds: refusing to send token: %w", err)
+		}
+	}
+	token, err := c.src.Token(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if c.key == "authorization" {
+		token = "Bearer " + token
+	}
+	return map[string]string{c.key: token}, nil
+}
+
+// RequireTransportSecurity implements credentials.PerRPCCredentials.
+func (c *Credentials) RequireTransportSecurity() bool {
+	return !c.allowInsecure
+}
@@ -0,0 +1,291 @@
+package rpccreds
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"flag"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"awesomeproject/pkg/auth"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+)
+
+func writeFile(t *testing.T, name, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestStatic(t *testing.T) {
+	if tok, err := Static("t").Token(context.Background()); tok != "t" || err != nil {
+		t.Fatalf("Token = %q, %v", tok, err)
+	}
+	if _, err := Static("").Token(context.Background()); err == nil {
+		t.Fatal("empty Static gave a token")
+	}
+}
+
+func TestFile(t *testing.T) {
+	path := writeFile(t, "token", " first\n")
+	f, err := NewFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	steps := []struct {
+		data    string // written before Reload; "" removes the file
+		wantErr bool
+		want    string
+	}{
+		{"second\n", false, "second"},
+		{"  \n", true, "second"},
+		{"", true, "second"},
+		{"third", false, "third"},
+	}
+	for _, s := range steps {
+		if s.data == "" {
+			os.Remove(path)
+		} else if err := os.WriteFile(path, []byte(s.data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := f.Reload(); (err != nil) != s.wantErr {
+			t.Fatalf("Reload(%q) = %v, want error %v", s.data, err, s.wantErr)
+		}
+		if tok, _ := f.Token(context.Background()); tok != s.want {
+			t.Fatalf("after %q: token %q, want %q", s.data, tok, s.want)
+		}
+	}
+	if _, err := NewFile(writeFile(t, "empty", "\n")); err == nil {
+		t.Fatal("NewFile accepted an empty file")
+	}
+}
+
+func TestGetRequestMetadata(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    []Option
+		want    map[string]string
+		wantErr bool
+	}{
+		{"plaintext refused", nil, nil, true},
+		{"bearer", []Option{AllowInsecure()}, map[string]string{"authorization": "Bearer t"}, false},
+		{"api key", []Option{AllowInsecure(), WithMetadataKey("X-API-Key")}, map[string]string{"x-api-key": "t"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New(Static("t"), tt.opts...)
+			md, err := c.GetRequestMetadata(context.Background())
+			if (err != nil) != tt.wantErr || !reflect.DeepEqual(md, tt.want) {
+				t.Fatalf("GetRequestMetadata = %v, %v", md, err)
+			}
+			if c.RequireTransportSecurity() != (len(tt.opts) == 0) {
+				t.Fatal("RequireTransportSecurity disagrees with AllowInsecure")
+			}
+		})
+	}
+}
+
+// TestCredentialsOnCall checks the token reaches the server, and that
+// without AllowInsecure gRPC refuses to send it in plaintext.
+func TestCredentialsOnCall(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(chan metadata.MD, 1)
+	srv := grpc.NewServer(grpc.UnaryInterceptor(func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		got <- md
+		return handler(ctx, req)
+	}))
+	healthpb.RegisterHealthServer(srv, health.NewServer())
+	go srv.Serve(l)
+	defer srv.Stop()
+
+	call := func(creds *Credentials) error {
+		conn, err := grpc.NewClient(l.Addr().String(),
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+			grpc.WithPerRPCCredentials(creds))
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_, err = healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+		return err
+	}
+
+	if err := call(New(Static("t"), AllowInsecure())); err != nil {
+		t.Fatal(err)
+	}
+	if md := <-got; !reflect.DeepEqual(md.Get("authorization"), []string{"Bearer t"}) {
+		t.Fatalf("server saw %v", md)
+	}
+	if err := call(New(Static("t"))); err == nil {
+		t.Fatal("token was sent over plaintext")
+	}
+	select {
+	case md := <-got:
+		t.Fatalf("refused call reached the server with %v", md)
+	default:
+	}
+}
+
+func TestNewJWTErrors(t *testing.T) {
+	secret := writeFile(t, "secret", strings.Repeat("k", auth.MinHS256SecretLen))
+	tests := []struct {
+		name string
+		cfg  JWTConfig
+		want string
+	}{
+		{"no subject", JWTConfig{HS256Secret: secret}, "subject"},
+		{"no key", JWTConfig{Subject: "svc"}, "needs an HS256 secret"},
+		{"two keys", JWTConfig{Subject: "svc", HS256Secret: secret, RS256KeyFile: secret}, "only one"},
+		{"missing secret", JWTConfig{Subject: "svc", HS256Secret: secret + ".missing"}, "no such file"},
+		{"empty secret", JWTConfig{Subject: "svc", HS256Secret: writeFile(t, "empty", "")}, "HS256 secret is 0 bytes"},
+		{"blank secret", JWTConfig{Subject: "svc", HS256Secret: writeFile(t, "blank", " \n\t\n")}, "HS256 secret is 0 bytes"},
+		{"short secret", JWTConfig{Subject: "svc", HS256Secret: writeFile(t, "short", "s3cret\n")}, "HS256 secret is 6 bytes"},
+		{"bad PEM", JWTConfig{Subject: "svc", RS256KeyFile: secret}, secret},
+	}
+	for _, tt := range tests {
+		if _, err := NewJWT(tt.cfg); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: err = %v, want %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+// TestJWTVerifies mints tokens with both algorithms and checks that
+// pkg/auth accepts them and recovers the configured claims.
+func TestJWTVerifies(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret := writeFile(t, "secret", strings.Repeat("k", auth.MinHS256SecretLen)+"\n")
+	private := writeFile(t, "key.pem", string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})))
+	public := writeFile(t, "pub.pem", string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pub})))
+
+	base := JWTConfig{Subject: "svc", Issuer: "iss", Audience: "greeter", Roles: []string{"admin"}, Scopes: []string{"a", "b"}}
+	tests := []struct {
+		name   string
+		sign   func(*JWTConfig)
+		verify auth.Config
+	}{
+		{"HS256", func(c *JWTConfig) { c.HS256Secret = secret }, auth.Config{HS256Secret: secret}},
+		{"RS256", func(c *JWTConfig) { c.RS256KeyFile = private }, auth.Config{RS256KeyFile: public}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := base
+			tt.sign(&cfg)
+			j, err := NewJWT(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.verify.Issuer, tt.verify.Audience = "iss", "greeter"
+			v, err := auth.NewJWTVerifier(tt.verify)
+			if err != nil {
+				t.Fatal(err)
+			}
+			token, err := j.Token(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			p, err := v.Verify(context.Background(), token)
+			want := &auth.Principal{Subject: "svc", Roles: []string{"admin"}, Scopes: []string{"a", "b"}, Via: "jwt"}
+			if err != nil || !reflect.DeepEqual(p, want) {
+				t.Fatalf("Verify = %+v, %v", p, err)
+			}
+			if again, _ := j.Token(context.Background()); again != token {
+				t.Fatal("token was minted again well before expiry")
+			}
+		})
+	}
+}
+
+func TestJWTRefresh(t *testing.T) {
+	j, err := NewJWT(JWTConfig{Subject: "svc", HS256Secret: writeFile(t, "secret", strings.Repeat("k", auth.MinHS256SecretLen)), TTL: 5 * time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	j.Token(context.Background())
+	if j.refresh.Sub(time.Now()) > 4*time.Second {
+		t.Fatalf("refresh at %v, want within the first four fifths of the TTL", j.refresh)
+	}
+	j.token = "stale"
+	j.refresh = time.Now().Add(-time.Second) // past the refresh point
+	if tok, _ := j.Token(context.Background()); tok == "stale" || !j.refresh.After(time.Now()) {
+		t.Fatal("token was not refreshed")
+	}
+}
+
+func TestFromConfig(t *testing.T) {
+	secret := writeFile(t, "secret", strings.Repeat("k", auth.MinHS256SecretLen))
+	tokenFile := writeFile(t, "token", "from-file")
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr bool
+		wantMD  map[string]string
+	}{
+		{"none", nil, true, nil},
+		{"two", []string{"-token", "t", "-token-file", tokenFile}, true, nil},
+		{"static", []string{"-token", "t", "-insecure-token"}, false, map[string]string{"authorization": "Bearer t"}},
+		{"file with key", []string{"-token-file", tokenFile, "-token-key", "x-api-key", "-insecure-token"}, false, map[string]string{"x-api-key": "from-file"}},
+		{"jwt", []string{"-jwt-hs256-secret", secret, "-jwt-sub", "svc", "-jwt-roles", "a, b,,", "-insecure-token"}, false, nil},
+		{"jwt without subject", []string{"-jwt-hs256-secret", secret}, true, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cfg Config
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			cfg.RegisterFlags(fs)
+			if err := fs.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Enabled() != (len(tt.args) > 0) {
+				t.Fatalf("Enabled = %v", cfg.Enabled())
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			c, err := FromConfig(ctx, cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("FromConfig = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			md, err := c.GetRequestMetadata(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantMD != nil && !reflect.DeepEqual(md, tt.wantMD) {
+				t.Fatalf("metadata %v, want %v", md, tt.wantMD)
+			}
+			if tt.name == "jwt" && !reflect.DeepEqual(cfg.JWT.Roles, []string{"a", "b"}) {
+				t.Fatalf("roles %q", cfg.JWT.Roles)
+			}
+		})
+	}
+}
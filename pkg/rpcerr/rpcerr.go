@@ -0,0 +1,193 @@
+// Package rpcerr maps failures between gRPC status codes, JSON-RPC error
+// codes and HTTP status codes, so that every bridge between the transports
+// in this repository reports an error the same way and keeps its message
+// and google.rpc details.
+//
+// InvalidArgument, Unimplemented and Internal become the JSON-RPC codes
+// the specification defines for them (InvalidParams, MethodNotFound and
+// InternalError); any other gRPC code c becomes ServerError - c, e.g.
+// -32005 for NotFound. The JSON-RPC error data holds the google.rpc.Status
+// in its JSON form. Every gRPC code survives the round trip through
+// JSONRPCCode and CodeFromJSONRPC.
+package rpcerr
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	_ "google.golang.org/genproto/googleapis/rpc/errdetails" // resolves google.rpc detail types
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// Error codes defined by the JSON-RPC 2.0 specification.
+const (
+	ParseError     = -32700
+	InvalidRequest = -32600
+	MethodNotFound = -32601
+	InvalidParams  = -32602
+	InternalError  = -32603
+	// ServerError is the generic error returned by a method. The codes
+	// from ServerError-1 to ServerError-16 carry a gRPC code; those of
+	// InvalidArgument, Unimplemented and Internal are only read, for
+	// peers that send them.
+	ServerError = -32000
+)
+
+// maxCode is the highest gRPC code, codes.Unauthenticated.
+const maxCode = codes.Unauthenticated
+
+// JSONRPCCode returns the JSON-RPC error code for c, or 0 for codes.OK.
+func JSONRPCCode(c codes.Code) int {
+	if c == codes.OK {
+		return 0
+	}
+	switch c {
+	case codes.InvalidArgument:
+		return InvalidParams
+	case codes.Unimplemented:
+		return MethodNotFound
+	case codes.Internal:
+		return InternalError
+	}
+	if c > maxCode {
+		c = codes.Unknown
+	}
+	return ServerError - int(c)
+}
+
+// CodeFromJSONRPC returns the gRPC code for a JSON-RPC error code. Codes
+// outside the specification and the ServerError range are Unknown.
+func CodeFromJSONRPC(code int) codes.Code {
+	switch code {
+	case 0:
+		return codes.OK
+	case ParseError, InvalidRequest, InvalidParams:
+		return codes.InvalidArgument
+	case MethodNotFound:
+		return codes.Unimplemented
+	case InternalError:
+		return codes.Internal
+	}
+	if c := ServerError - code; c > 0 && c <= int(maxCode) {
+		return codes.Code(c)
+	}
+	return codes.Unknown
+}
+
+// HTTPStatus returns the HTTP status that reports c, following
+// google.api.http transcoding.
+func HTTPStatus(c codes.Code) int {
+	switch c {
+	case codes.OK:
+		return http.StatusOK
+	case codes.Canceled:
+		return 499 // client closed request
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+// CodeFromHTTPStatus returns the gRPC code for an HTTP status, the reverse
+// of HTTPStatus where statuses are shared by several codes.
+func CodeFromHTTPStatus(s int) codes.Code {
+	switch s {
+	case http.StatusOK:
+		return codes.OK
+	case 499:
+		return codes.Canceled
+	case http.StatusBadRequest:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusConflict:
+		return codes.Aborted
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case http.StatusNotImplemented:
+		return codes.Unimplemented
+	case http.StatusBadGateway, http.StatusServiceUnavailable:
+		return codes.Unavailable
+	case http.StatusGatewayTimeout:
+		return codes.DeadlineExceeded
+	}
+	if s >= 500 {
+		return codes.Internal
+	}
+	return codes.Unknown
+}
+
+// FromError returns the status of err: its own if it has one, Canceled or
+// DeadlineExceeded for context errors, and Unknown otherwise. It returns
+// nil for a nil err.
+func FromError(err error) *status.Status {
+	if err == nil {
+		return nil
+	}
+	if st, ok := status.FromError(err); ok {
+		return st
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return status.FromContextError(err)
+	}
+	return status.New(codes.Unknown, err.Error())
+}
+
+// Data returns st as the JSON form of google.rpc.Status, decoded into
+// plain maps so that any JSON-RPC codec can encode it:
+//
+//	{"code": 3, "message": "...", "details": [{"@type": "type.googleapis.com/google.rpc.BadRequest", ...}]}
+func Data(st *status.Status) map[string]any {
+	raw, err := protojson.Marshal(st.Proto())
+	if err != nil {
+		return nil
+	}
+	var data map[string]any
+	if json.Unmarshal(raw, &data) != nil {
+		return nil
+	}
+	return data
+}
+
+// FromJSONRPC rebuilds the status of a JSON-RPC error. When data holds a
+// google.rpc.Status, as produced by Data, its code, message and details
+// are restored; otherwise the code is mapped with CodeFromJSONRPC.
+func FromJSONRPC(code int, message string, data any) *status.Status {
+	if m, ok := data.(map[string]any); ok {
+		if _, ok := m["code"]; ok {
+			if raw, err := json.Marshal(m); err == nil {
+				var p spb.Status
+				opts := protojson.UnmarshalOptions{DiscardUnknown: true}
+				if opts.Unmarshal(raw, &p) == nil && p.GetCode() != 0 {
+					return status.FromProto(&p)
+				}
+			}
+		}
+	}
+	return status.New(CodeFromJSONRPC(code), message)
+}
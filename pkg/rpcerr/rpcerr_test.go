@@ -0,0 +1,191 @@
+package rpcerr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestJSONRPCCode(t *testing.T) {
+	tests := []struct {
+		code codes.Code
+		want int
+	}{
+		{codes.OK, 0},
+		{codes.Canceled, -32001},
+		{codes.Unknown, -32002},
+		{codes.InvalidArgument, InvalidParams},
+		{codes.NotFound, -32005},
+		{codes.PermissionDenied, -32007},
+		{codes.ResourceExhausted, -32008},
+		{codes.Unimplemented, MethodNotFound},
+		{codes.Internal, InternalError},
+		{codes.Unavailable, -32014},
+		{codes.Unauthenticated, -32016},
+		{codes.Code(99), -32002},
+	}
+	for _, tt := range tests {
+		if got := JSONRPCCode(tt.code); got != tt.want {
+			t.Errorf("JSONRPCCode(%s) = %d, want %d", tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestCodeFromJSONRPC(t *testing.T) {
+	tests := []struct {
+		code int
+		want codes.Code
+	}{
+		{0, codes.OK},
+		{ParseError, codes.InvalidArgument},
+		{InvalidRequest, codes.InvalidArgument},
+		{InvalidParams, codes.InvalidArgument},
+		{MethodNotFound, codes.Unimplemented},
+		{InternalError, codes.Internal},
+		{ServerError, codes.Unknown},
+		{-32003, codes.InvalidArgument},
+		{-32012, codes.Unimplemented},
+		{-32013, codes.Internal},
+		{-32016, codes.Unauthenticated},
+		{-32017, codes.Unknown},
+		{-32050, codes.Unknown},
+		{42, codes.Unknown},
+	}
+	for _, tt := range tests {
+		if got := CodeFromJSONRPC(tt.code); got != tt.want {
+			t.Errorf("CodeFromJSONRPC(%d) = %s, want %s", tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestJSONRPCRoundTrip(t *testing.T) {
+	for c := codes.OK; c <= maxCode; c++ {
+		if got := CodeFromJSONRPC(JSONRPCCode(c)); got != c {
+			t.Errorf("%s -> %d -> %s", c, JSONRPCCode(c), got)
+		}
+	}
+}
+
+func TestHTTPStatus(t *testing.T) {
+	tests := []struct {
+		code codes.Code
+		want int
+	}{
+		{codes.OK, http.StatusOK},
+		{codes.Canceled, 499},
+		{codes.InvalidArgument, http.StatusBadRequest},
+		{codes.FailedPrecondition, http.StatusBadRequest},
+		{codes.OutOfRange, http.StatusBadRequest},
+		{codes.DeadlineExceeded, http.StatusGatewayTimeout},
+		{codes.NotFound, http.StatusNotFound},
+		{codes.AlreadyExists, http.StatusConflict},
+		{codes.Aborted, http.StatusConflict},
+		{codes.PermissionDenied, http.StatusForbidden},
+		{codes.Unauthenticated, http.StatusUnauthorized},
+		{codes.ResourceExhausted, http.StatusTooManyRequests},
+		{codes.Unimplemented, http.StatusNotImplemented},
+		{codes.Unavailable, http.StatusServiceUnavailable},
+		{codes.Unknown, http.StatusInternalServerError},
+		{codes.Internal, http.StatusInternalServerError},
+		{codes.DataLoss, http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		if got := HTTPStatus(tt.code); got != tt.want {
+			t.Errorf("HTTPStatus(%s) = %d, want %d", tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestCodeFromHTTPStatus(t *testing.T) {
+	tests := []struct {
+		status int
+		want   codes.Code
+	}{
+		{http.StatusOK, codes.OK},
+		{499, codes.Canceled},
+		{http.StatusBadRequest, codes.InvalidArgument},
+		{http.StatusUnauthorized, codes.Unauthenticated},
+		{http.StatusForbidden, codes.PermissionDenied},
+		{http.StatusNotFound, codes.NotFound},
+		{http.StatusConflict, codes.Aborted},
+		{http.StatusTooManyRequests, codes.ResourceExhausted},
+		{http.StatusNotImplemented, codes.Unimplemented},
+		{http.StatusBadGateway, codes.Unavailable},
+		{http.StatusServiceUnavailable, codes.Unavailable},
+		{http.StatusGatewayTimeout, codes.DeadlineExceeded},
+		{http.StatusInternalServerError, codes.Internal},
+		{http.StatusHTTPVersionNotSupported, codes.Internal},
+		{http.StatusTeapot, codes.Unknown},
+	}
+	for _, tt := range tests {
+		if got := CodeFromHTTPStatus(tt.status); got != tt.want {
+			t.Errorf("CodeFromHTTPStatus(%d) = %s, want %s", tt.status, got, tt.want)
+		}
+	}
+	// Codes with a status of their own come back unchanged
+	for _, c := range []codes.Code{codes.OK, codes.Canceled, codes.InvalidArgument, codes.DeadlineExceeded,
+		codes.NotFound, codes.PermissionDenied, codes.Unauthenticated, codes.ResourceExhausted,
+		codes.Unimplemented, codes.Unavailable, codes.Internal} {
+		if got := CodeFromHTTPStatus(HTTPStatus(c)); got != c {
+			t.Errorf("%s -> %d -> %s", c, HTTPStatus(c), got)
+		}
+	}
+}
+
+func TestFromError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want codes.Code
+	}{
+		{status.Error(codes.NotFound, "x"), codes.NotFound},
+		{fmt.Errorf("wrapped: %w", status.Error(codes.Aborted, "x")), codes.Aborted},
+		{context.Canceled, codes.Canceled},
+		{fmt.Errorf("call: %w", context.DeadlineExceeded), codes.DeadlineExceeded},
+		{errors.New("plain"), codes.Unknown},
+	}
+	for _, tt := range tests {
+		if got := FromError(tt.err).Code(); got != tt.want {
+			t.Errorf("FromError(%v) = %s, want %s", tt.err, got, tt.want)
+		}
+	}
+	if FromError(nil) != nil {
+		t.Error("FromError(nil) != nil")
+	}
+}
+
+func TestFromJSONRPC(t *testing.T) {
+	st, err := status.New(codes.InvalidArgument, "bad name").WithDetails(&errdetails.BadRequest{
+		FieldViolations: []*errdetails.BadRequest_FieldViolation{{Field: "name", Description: "required"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		code    int
+		message string
+		data    any
+		want    *status.Status
+	}{
+		{"status in data", JSONRPCCode(st.Code()), st.Message(), Data(st), st},
+		{"code only", -32005, "missing", nil, status.New(codes.NotFound, "missing")},
+		{"spec code", MethodNotFound, "no such method", nil, status.New(codes.Unimplemented, "no such method")},
+		{"foreign data", InternalError, "boom", map[string]any{"trace": "x"}, status.New(codes.Internal, "boom")},
+		{"data without a code", -32014, "down", map[string]any{"code": 0}, status.New(codes.Unavailable, "down")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := FromJSONRPC(tt.code, tt.message, tt.data)
+			if !proto.Equal(got.Proto(), tt.want.Proto()) {
+				t.Fatalf("FromJSONRPC = %v, want %v", got.Proto(), tt.want.Proto())
+			}
+		})
+	}
+}
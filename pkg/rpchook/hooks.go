@@ -0,0 +1,108 @@
+package rpchook
+
+import (
+	"context"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"awesomeproject/pkg/auth"
+)
+
+// Logging logs every call with its duration and error, if any.
+func Logging(l *log.Logger) Hooks {
+	return Hooks{After: func(c *Call) {
+		who := ""
+		if c.Principal != nil {
+			who = " by " + c.Principal.Subject
+		}
+		if c.Error != "" {
+			l.Printf("rpc %s seq=%d from %s%s failed in %v: %s", c.ServiceMethod, c.Seq, c.Peer, who, c.Duration, c.Error)
+			return
+		}
+		l.Printf("rpc %s seq=%d from %s%s ok in %v", c.ServiceMethod, c.Seq, c.Peer, who, c.Duration)
+	}}
+}
+
+// Auth verifies the token in the "authorization" metadata key, falling
+// back to "x-api-key" and "token", and records the caller in
+// Call.Principal. Methods the Authenticator marks public may be called
+// without a token.
+func Auth(a *auth.Authenticator) Hooks {
+	return Hooks{Before: func(c *Call) error {
+		token := auth.BearerToken(c.Metadata.Get("authorization"))
+		if token == "" {
+			token = c.Metadata.Get(apiKeyKey)
+		}
+		if token == "" {
+			token = c.Metadata.Get("token")
+		}
+		if token == "" && a.IsPublic(c.ServiceMethod) {
+			return nil
+		}
+		p, err := a.Verify(context.Background(), token)
+		if err != nil {
+			return err
+		}
+		c.Principal = p
+		return nil
+	}}
+}
+
+// apiKeyKey is the metadata key for an API key. Query keys are case
+// sensitive, so it is fixed in lower case like gRPC metadata keys.
+var apiKeyKey = strings.ToLower(auth.APIKeyHeader)
+
+// MethodStats are the counters Metrics keeps per method.
+type MethodStats struct {
+	Calls  int64         `json:"calls"`
+	Errors int64         `json:"errors"`
+	Total  time.Duration `json:"totalNanos"`
+	Max    time.Duration `json:"maxNanos"`
+}
+
+// Metrics counts calls per method. The zero value is ready to use.
+type Metrics struct {
+	mu      sync.Mutex
+	methods map[string]*MethodStats
+}
+
+func (m *Metrics) stats(method string) *MethodStats {
+	if m.methods == nil {
+		m.methods = make(map[string]*MethodStats)
+	}
+	s, ok := m.methods[method]
+	if !ok {
+		s = &MethodStats{}
+		m.methods[method] = s
+	}
+	return s
+}
+
+// Hooks returns the hooks that feed m. Calls rejected before the method
+// ran are counted as errors.
+func (m *Metrics) Hooks() Hooks {
+	return Hooks{After: func(c *Call) {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		s := m.stats(c.ServiceMethod)
+		s.Calls++
+		if c.Error != "" {
+			s.Errors++
+		}
+		s.Total += c.Duration
+		s.Max = max(s.Max, c.Duration)
+	}}
+}
+
+// Snapshot returns a copy of the counters keyed by method.
+func (m *Metrics) Snapshot() map[string]MethodStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make(map[string]MethodStats, len(m.methods))
+	for name, s := range m.methods {
+		out[name] = *s
+	}
+	return out
+}
@@ -0,0 +1,59 @@
+package rpchook
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/rpc"
+
+	"awesomeproject/pkg/auth"
+	"awesomeproject/pkg/rpcserver"
+)
+
+// connected is the reply rpc.DialHTTP expects to its CONNECT request.
+const connected = "200 Connected to Go RPC"
+
+// Handler is the hooked equivalent of rpc.Server.ServeHTTP: it answers a
+// CONNECT request and serves gob calls on the hijacked connection. The
+// Authorization and X-API-Key headers of the CONNECT request become
+// metadata of every call on it.
+func Handler(srv *rpc.Server, hooks ...Hooks) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodConnect {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			io.WriteString(w, "405 must CONNECT\n")
+			return
+		}
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			log.Print("rpc hijacking ", r.RemoteAddr, ": ", err.Error())
+			return
+		}
+		io.WriteString(conn, "HTTP/1.0 "+connected+"\n\n")
+		codec := NewServerCodec(rpcserver.NewGobServerCodec(conn), hooks...)
+		codec.Peer = r.RemoteAddr
+		copyHeaders(codec, r.Header)
+		srv.ServeCodec(codec)
+	})
+}
+
+// copyHeaders adds the credential headers of r to the codec's metadata.
+func copyHeaders(c *ServerCodec, h http.Header) {
+	if v := h.Get("Authorization"); v != "" {
+		c.Metadata.Set("authorization", v)
+	}
+	if v := h.Get(auth.APIKeyHeader); v != "" {
+		c.Metadata.Set(apiKeyKey, v)
+	}
+}
+
+// ServeRequest serves the single JSON-RPC or gob request in r's body
+// through codec, taking credentials from r's headers, like
+// rpc.Server.ServeRequest.
+func ServeRequest(srv *rpc.Server, r *http.Request, codec rpc.ServerCodec, hooks ...Hooks) error {
+	c := NewServerCodec(codec, hooks...)
+	c.Peer = r.RemoteAddr
+	copyHeaders(c, r.Header)
+	return srv.ServeRequest(c)
+}
@@ -0,0 +1,198 @@
+// Package rpchook adds per-call hooks to net/rpc by wrapping its
+// rpc.ServerCodec, which is the only extension point net/rpc offers. It
+// works with any codec, including the gob codec of rpc.ServeConn and
+// jsonrpc.NewServerCodec.
+//
+// net/rpc requests carry no headers, so metadata such as a bearer token
+// travels as a URL query appended to the service method, e.g.
+// "HelloService.Hello?authorization=Bearer+xyz". The codec strips it
+// before net/rpc looks the method up; see WithMetadata.
+package rpchook
+
+import (
+	"fmt"
+	"log"
+	"net/rpc"
+	"net/url"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	"awesomeproject/pkg/auth"
+)
+
+// Call describes one request as it passes through the hooks.
+type Call struct {
+	ServiceMethod string
+	Seq           uint64
+	// Metadata holds the query decoded from the service method, merged
+	// over the codec's own Metadata.
+	Metadata url.Values
+	// Peer is the remote address, if the codec's creator supplied it.
+	Peer string
+	// Principal is set by the Auth hook.
+	Principal *auth.Principal
+	// Args points at the decoded argument; it is nil if the body could
+	// not be read.
+	Args any
+	// Reply and Error are set before After runs.
+	Reply    any
+	Error    string
+	Start    time.Time
+	Duration time.Duration
+}
+
+// Hooks run around each call. Either function may be nil.
+type Hooks struct {
+	// Before runs once the args are decoded. A non-nil error is returned
+	// to the client instead of invoking the method.
+	Before func(c *Call) error
+	// After runs before the response is written.
+	After func(c *Call)
+}
+
+// ServerCodec wraps an rpc.ServerCodec with Hooks. Before hooks run in
+// order and After hooks in reverse order, so the first Hooks given is the
+// outermost. A panicking hook fails the call rather than the server.
+type ServerCodec struct {
+	rpc.ServerCodec
+	// Metadata is added to every call, e.g. from HTTP request headers.
+	Metadata url.Values
+	// Peer is copied into every Call.
+	Peer string
+
+	hooks []Hooks
+	mu    sync.Mutex
+	calls map[uint64]*Call
+	cur   *Call // read side only: the call whose body comes next
+}
+
+// NewServerCodec wraps codec with hooks.
+func NewServerCodec(codec rpc.ServerCodec, hooks ...Hooks) *ServerCodec {
+	return &ServerCodec{
+		ServerCodec: codec,
+		Metadata:    url.Values{},
+		hooks:       hooks,
+		calls:       make(map[uint64]*Call),
+	}
+}
+
+// ReadRequestHeader strips the metadata from the service method.
+func (c *ServerCodec) ReadRequestHeader(r *rpc.Request) error {
+	c.cur = nil
+	if err := c.ServerCodec.ReadRequestHeader(r); err != nil {
+		return err
+	}
+	call := &Call{Seq: r.Seq, Peer: c.Peer, Start: time.Now(), Metadata: url.Values{}}
+	for k, v := range c.Metadata {
+		call.Metadata[k] = v
+	}
+	method, query, found := strings.Cut(r.ServiceMethod, "?")
+	if found {
+		r.ServiceMethod = method
+		md, err := url.ParseQuery(query)
+		if err != nil {
+			// The body still has to be consumed; reject the call there.
+			call.Error = "rpchook: bad metadata: " + err.Error()
+		}
+		for k, v := range md {
+			call.Metadata[k] = v
+		}
+	}
+	call.ServiceMethod = method
+	c.cur = call
+	c.mu.Lock()
+	c.calls[r.Seq] = call
+	c.mu.Unlock()
+	return nil
+}
+
+// ReadRequestBody decodes the args and runs the Before hooks. net/rpc
+// passes a nil body to discard requests for unknown methods; those skip
+// the hooks and are only seen by After.
+func (c *ServerCodec) ReadRequestBody(body any) error {
+	if err := c.ServerCodec.ReadRequestBody(body); err != nil || body == nil {
+		return err
+	}
+	call := c.cur
+	if call == nil {
+		return nil
+	}
+	if call.Error != "" {
+		return fmt.Errorf("%s", call.Error)
+	}
+	call.Args = body
+	for _, h := range c.hooks {
+		if h.Before == nil {
+			continue
+		}
+		if err := before(h.Before, call); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteResponse runs the After hooks. net/rpc writes exactly one response
+// for every header it read, whether or not the method ran.
+func (c *ServerCodec) WriteResponse(r *rpc.Response, body any) error {
+	c.mu.Lock()
+	call := c.calls[r.Seq]
+	delete(c.calls, r.Seq)
+	c.mu.Unlock()
+	if call != nil {
+		call.Reply, call.Error = body, r.Error
+		call.Duration = time.Since(call.Start)
+		for i := len(c.hooks) - 1; i >= 0; i-- {
+			if c.hooks[i].After != nil {
+				after(c.hooks[i].After, call)
+			}
+		}
+	}
+	return c.ServerCodec.WriteResponse(r, body)
+}
+
+func before(fn func(*Call) error, call *Call) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			log.Printf("rpchook: panic in before hook for %s: %v\n%s", call.ServiceMethod, p, debug.Stack())
+			err = fmt.Errorf("rpchook: internal error")
+		}
+	}()
+	return fn(call)
+}
+
+func after(fn func(*Call), call *Call) {
+	defer func() {
+		if p := recover(); p != nil {
+			log.Printf("rpchook: panic in after hook for %s: %v\n%s", call.ServiceMethod, p, debug.Stack())
+		}
+	}()
+	fn(call)
+}
+
+// WithMetadata appends md to serviceMethod for a server using ServerCodec,
+// e.g. client.Call(rpchook.WithMetadata("HelloService.Hello", md), ...).
+func WithMetadata(serviceMethod string, md url.Values) string {
+	if len(md) == 0 {
+		return serviceMethod
+	}
+	return serviceMethod + "?" + md.Encode()
+}
+
+// Recover turns a panic in a net/rpc method into an error. net/rpc runs
+// methods on goroutines of its own, so a codec cannot catch their panics;
+// the registration functions generated by cmd/rpcgen wrap every method
+// with a deferred call:
+//
+//	func (c recoverT) M(args A, reply *R) (err error) {
+//		defer rpchook.Recover(&err)
+//		return c.service.M(args, reply)
+//	}
+func Recover(errp *error) {
+	if p := recover(); p != nil {
+		log.Printf("rpchook: panic: %v\n%s", p, debug.Stack())
+		*errp = fmt.Errorf("internal error: %v", p)
+	}
+}
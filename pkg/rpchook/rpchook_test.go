@@ -0,0 +1,280 @@
+package rpchook
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"awesomeproject/pkg/auth"
+)
+
+type Greeter struct{}
+
+func (Greeter) Hello(name string, reply *string) error {
+	*reply = "hello " + name
+	return nil
+}
+
+func (Greeter) Fail(name string, reply *string) error {
+	return errors.New("boom")
+}
+
+// serve runs srv's calls through a hooked JSON-RPC codec on one end of a
+// pipe and returns a client on the other.
+func serve(t *testing.T, codec func(rpc.ServerCodec) *ServerCodec) *rpc.Client {
+	t.Helper()
+	srv := rpc.NewServer()
+	if err := srv.Register(Greeter{}); err != nil {
+		t.Fatal(err)
+	}
+	server, client := net.Pipe()
+	go srv.ServeCodec(codec(jsonrpc.NewServerCodec(server)))
+	c := jsonrpc.NewClient(client)
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestWithMetadata(t *testing.T) {
+	tests := []struct {
+		md   url.Values
+		want string
+	}{
+		{nil, "Greeter.Hello"},
+		{url.Values{}, "Greeter.Hello"},
+		{url.Values{"authorization": {"Bearer a b"}}, "Greeter.Hello?authorization=Bearer+a+b"},
+		{url.Values{"b": {"2"}, "a": {"1", "&"}}, "Greeter.Hello?a=1&a=%26&b=2"},
+	}
+	for _, tt := range tests {
+		if got := WithMetadata("Greeter.Hello", tt.md); got != tt.want {
+			t.Errorf("WithMetadata(%v) = %q, want %q", tt.md, got, tt.want)
+		}
+	}
+}
+
+func TestServerCodecMetadata(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		codecMD    url.Values
+		wantMethod string
+		wantMD     url.Values
+		wantErr    string
+	}{
+		{name: "plain", method: "Greeter.Hello", wantMethod: "Greeter.Hello", wantMD: url.Values{}},
+		{name: "stripped", method: WithMetadata("Greeter.Hello", url.Values{"token": {"t"}}),
+			wantMethod: "Greeter.Hello", wantMD: url.Values{"token": {"t"}}},
+		{name: "merged over codec", method: WithMetadata("Greeter.Hello", url.Values{"token": {"call"}}),
+			codecMD:    url.Values{"token": {"conn"}, "authorization": {"Bearer x"}},
+			wantMethod: "Greeter.Hello", wantMD: url.Values{"token": {"call"}, "authorization": {"Bearer x"}}},
+		{name: "bad query", method: "Greeter.Hello?a=%zz", wantMethod: "Greeter.Hello", wantErr: "rpchook: bad metadata"},
+		{name: "unknown method", method: "Greeter.Shout?token=t", wantMethod: "Greeter.Shout", wantErr: "can't find method"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var seen *Call
+			before := 0
+			hooks := Hooks{
+				Before: func(c *Call) error { before++; return nil },
+				After:  func(c *Call) { seen = c },
+			}
+			client := serve(t, func(codec rpc.ServerCodec) *ServerCodec {
+				c := NewServerCodec(codec, hooks)
+				for k, v := range tt.codecMD {
+					c.Metadata[k] = v
+				}
+				return c
+			})
+			var reply string
+			err := client.Call(tt.method, "a", &reply)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Call = %v, want error containing %q", err, tt.wantErr)
+				}
+				if before != 0 {
+					t.Fatal("Before ran for a rejected call")
+				}
+			} else if err != nil || reply != "hello a" {
+				t.Fatalf("Call = %q, %v", reply, err)
+			}
+			if seen == nil || seen.ServiceMethod != tt.wantMethod {
+				t.Fatalf("After saw %+v, want method %q", seen, tt.wantMethod)
+			}
+			if tt.wantMD != nil && !reflect.DeepEqual(seen.Metadata, tt.wantMD) {
+				t.Fatalf("metadata %v, want %v", seen.Metadata, tt.wantMD)
+			}
+		})
+	}
+}
+
+func TestServerCodecHooks(t *testing.T) {
+	var order []string
+	hook := func(name string, err error) Hooks {
+		return Hooks{
+			Before: func(c *Call) error { order = append(order, "before "+name); return err },
+			After:  func(c *Call) { order = append(order, "after "+name) },
+		}
+	}
+	tests := []struct {
+		name      string
+		hooks     []Hooks
+		want      []string
+		wantErr   string
+		wantReply string
+	}{
+		{name: "order", hooks: []Hooks{hook("a", nil), hook("b", nil)},
+			want: []string{"before a", "before b", "after b", "after a"}, wantReply: "hello a"},
+		{name: "rejected", hooks: []Hooks{hook("a", errors.New("denied")), hook("b", nil)},
+			want: []string{"before a", "after b", "after a"}, wantErr: "denied"},
+		{name: "panicking before", hooks: []Hooks{{Before: func(*Call) error { panic("x") }}, hook("b", nil)},
+			want: []string{"after b"}, wantErr: "rpchook: internal error"},
+		{name: "panicking after", hooks: []Hooks{hook("a", nil), {After: func(*Call) { panic("x") }}},
+			want: []string{"before a", "after a"}, wantReply: "hello a"},
+		{name: "nil functions", hooks: []Hooks{{}, hook("a", nil)},
+			want: []string{"before a", "after a"}, wantReply: "hello a"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order = nil
+			client := serve(t, func(codec rpc.ServerCodec) *ServerCodec { return NewServerCodec(codec, tt.hooks...) })
+			var reply string
+			err := client.Call("Greeter.Hello", "a", &reply)
+			if tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr) {
+				t.Fatalf("Call = %v, want %q", err, tt.wantErr)
+			}
+			if tt.wantErr == "" && (err != nil || reply != tt.wantReply) {
+				t.Fatalf("Call = %q, %v", reply, err)
+			}
+			if !reflect.DeepEqual(order, tt.want) {
+				t.Fatalf("hooks ran %q, want %q", order, tt.want)
+			}
+		})
+	}
+}
+
+func newAuthenticator(t *testing.T) *auth.Authenticator {
+	t.Helper()
+	keys := filepath.Join(t.TempDir(), "keys.json")
+	if err := os.WriteFile(keys, []byte(`[{"key":"k1","subject":"alice"}]`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	a, err := auth.New(auth.Config{APIKeysFile: keys, Public: []string{"Greeter.Hello"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func TestAuth(t *testing.T) {
+	a := newAuthenticator(t)
+	tests := []struct {
+		name, method string
+		md           url.Values
+		wantSubject  string
+		wantErr      bool
+	}{
+		{"bearer", "Greeter.Fail", url.Values{"authorization": {"Bearer k1"}}, "alice", false},
+		{"api key", "Greeter.Fail", url.Values{"x-api-key": {"k1"}}, "alice", false},
+		{"legacy token", "Greeter.Fail", url.Values{"token": {"k1"}}, "alice", false},
+		{"missing", "Greeter.Fail", nil, "", true},
+		{"invalid", "Greeter.Fail", url.Values{"token": {"k2"}}, "", true},
+		{"public", "Greeter.Hello", nil, "", false},
+		{"public with a token", "Greeter.Hello", url.Values{"token": {"k1"}}, "alice", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Call{ServiceMethod: tt.method, Metadata: tt.md}
+			err := Auth(a).Before(c)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Before = %v, want error %v", err, tt.wantErr)
+			}
+			subject := ""
+			if c.Principal != nil {
+				subject = c.Principal.Subject
+			}
+			if subject != tt.wantSubject {
+				t.Fatalf("subject %q, want %q", subject, tt.wantSubject)
+			}
+		})
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	var m Metrics
+	client := serve(t, func(codec rpc.ServerCodec) *ServerCodec { return NewServerCodec(codec, m.Hooks()) })
+	var reply string
+	for _, method := range []string{"Greeter.Hello", "Greeter.Hello", "Greeter.Fail", "Greeter.Shout"} {
+		client.Call(method, "a", &reply)
+	}
+	got := m.Snapshot()
+	want := map[string][2]int64{"Greeter.Hello": {2, 0}, "Greeter.Fail": {1, 1}, "Greeter.Shout": {1, 1}}
+	if len(got) != len(want) {
+		t.Fatalf("Snapshot = %+v", got)
+	}
+	for method, w := range want {
+		s := got[method]
+		if s.Calls != w[0] || s.Errors != w[1] || s.Max > s.Total {
+			t.Errorf("%s: %+v, want %d calls and %d errors", method, s, w[0], w[1])
+		}
+	}
+}
+
+func TestHandler(t *testing.T) {
+	srv := rpc.NewServer()
+	if err := srv.Register(Greeter{}); err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(Handler(srv, Auth(newAuthenticator(t))))
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Fatalf("GET status %d, want 405", resp.StatusCode)
+	}
+
+	tests := []struct {
+		name    string
+		header  http.Header
+		wantErr bool
+	}{
+		{"API key header", http.Header{"X-Api-Key": {"k1"}}, false},
+		{"authorization header", http.Header{"Authorization": {"Bearer k1"}}, false},
+		{"no credentials", http.Header{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, err := net.Dial("tcp", ts.Listener.Addr().String())
+			if err != nil {
+				t.Fatal(err)
+			}
+			req, _ := http.NewRequest(http.MethodConnect, ts.URL, nil)
+			req.Header = tt.header
+			if err := req.Write(conn); err != nil {
+				t.Fatal(err)
+			}
+			buf := make([]byte, len("HTTP/1.0 "+connected+"\n\n"))
+			if _, err := conn.Read(buf); err != nil || !strings.Contains(string(buf), connected) {
+				t.Fatalf("CONNECT reply %q, %v", buf, err)
+			}
+			client := rpc.NewClient(conn)
+			defer client.Close()
+			var reply string
+			err = client.Call("Greeter.Fail", "a", &reply)
+			if denied := err != nil && err.Error() != "boom"; denied != tt.wantErr {
+				t.Fatalf("Call = %v, want auth error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+// Package rpcname normalises method names across transports, so gRPC's
+// "/Greeter/SayHello" and net/rpc's "Greeter.SayHello" can share one
+// configuration key.
+package rpcname
+
+import "strings"
+
+// Normalize converts a gRPC full method name ("/Greeter/SayHello") to the
+// "Greeter.SayHello" form. Other names are returned unchanged.
+func Normalize(method string) string {
+	if !strings.HasPrefix(method, "/") {
+		return method
+	}
+	method = method[1:]
+	if i := strings.LastIndex(method, "/"); i >= 0 {
+		method = method[:i] + "." + method[i+1:]
+	}
+	return method
+}
+
+// Match reports whether method matches pattern. Both are normalised first.
+// A pattern of "*" matches everything, "Service.*" matches every method
+// of Service and "*.Method" matches Method on any service.
+func Match(pattern, method string) bool {
+	pattern, method = Normalize(pattern), Normalize(method)
+	if pattern == "*" || pattern == method {
+		return true
+	}
+	dot := strings.LastIndex(method, ".")
+	if dot < 0 {
+		return false
+	}
+	if svc, ok := strings.CutSuffix(pattern, ".*"); ok && svc == method[:dot] {
+		return true
+	}
+	if m, ok := strings.CutPrefix(pattern, "*."); ok && m == method[dot+1:] {
+		return true
+	}
+	return false
+}
@@ -0,0 +1,49 @@
+package rpcname
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"/Greeter/SayHello", "Greeter.SayHello"},
+		{"/pkg.Greeter/SayHello", "pkg.Greeter.SayHello"},
+		{"Greeter.SayHello", "Greeter.SayHello"},
+		{"/Greeter", "Greeter"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := Normalize(tt.in); got != tt.want {
+			t.Errorf("Normalize(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern, method string
+		want            bool
+	}{
+		{"*", "Greeter.SayHello", true},
+		{"*", "/Greeter/SayHello", true},
+		{"Greeter.SayHello", "Greeter.SayHello", true},
+		{"Greeter.SayHello", "/Greeter/SayHello", true},
+		{"/Greeter/SayHello", "Greeter.SayHello", true},
+		{"Greeter.SayHello", "Greeter.SayBye", false},
+		{"Greeter.*", "Greeter.SayBye", true},
+		{"Greeter.*", "/Greeter/SayBye", true},
+		{"Greeter.*", "Greeting.SayBye", false},
+		{"Greeter.*", "pkg.Greeter.SayBye", false},
+		{"pkg.Greeter.*", "/pkg.Greeter/SayBye", true},
+		{"*.SayBye", "Greeter.SayBye", true},
+		{"*.SayBye", "/pkg.Greeter/SayBye", true},
+		{"*.SayBye", "Greeter.SayHello", false},
+		{"Greeter*", "Greeter.SayHello", false},
+		{"Greeter.*", "Greeter", false},
+	}
+	for _, tt := range tests {
+		if got := Match(tt.pattern, tt.method); got != tt.want {
+			t.Errorf("Match(%q, %q) = %v, want %v", tt.pattern, tt.method, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,220 @@
+package rpcproto
+
+import (
+	"fmt"
+	"reflect"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// isWrapped reports whether values of t travel in a wrapper message.
+func isWrapped(t reflect.Type) bool {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t.Kind() != reflect.Struct
+}
+
+// toMessage copies the Go value v into m.
+func toMessage(v reflect.Value, m protoreflect.Message) error {
+	if isWrapped(v.Type()) {
+		return setField(m, WrapperField, v)
+	}
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		if name := FieldName(sf.Name, string(sf.Tag)); name != "" {
+			if err := setField(m, name, v.Field(i)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func setField(m protoreflect.Message, name string, v reflect.Value) error {
+	fd := m.Descriptor().Fields().ByName(protoreflect.Name(name))
+	if fd == nil {
+		return fmt.Errorf("%s has no field %s", m.Descriptor().FullName(), name)
+	}
+	switch {
+	case fd.IsList():
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil
+		}
+		l := m.Mutable(fd).List()
+		for i := 0; i < v.Len(); i++ {
+			pv, err := toValue(fd, v.Index(i))
+			if err != nil {
+				return err
+			}
+			l.Append(pv)
+		}
+	case fd.IsMap():
+		if v.IsNil() {
+			return nil
+		}
+		mp := m.Mutable(fd).Map()
+		iter := v.MapRange()
+		for iter.Next() {
+			k, err := toValue(fd.MapKey(), iter.Key())
+			if err != nil {
+				return err
+			}
+			val, err := toValue(fd.MapValue(), iter.Value())
+			if err != nil {
+				return err
+			}
+			mp.Set(k.MapKey(), val)
+		}
+	default:
+		if v.Kind() == reflect.Pointer && v.IsNil() {
+			return nil
+		}
+		pv, err := toValue(fd, v)
+		if err != nil {
+			return err
+		}
+		m.Set(fd, pv)
+	}
+	return nil
+}
+
+// toValue converts one scalar or message value.
+func toValue(fd protoreflect.FieldDescriptor, v reflect.Value) (protoreflect.Value, error) {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		return protoreflect.ValueOfBool(v.Bool()), nil
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		return protoreflect.ValueOfInt32(int32(v.Int())), nil
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return protoreflect.ValueOfInt64(v.Int()), nil
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return protoreflect.ValueOfUint32(uint32(v.Uint())), nil
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return protoreflect.ValueOfUint64(v.Uint()), nil
+	case protoreflect.FloatKind:
+		return protoreflect.ValueOfFloat32(float32(v.Float())), nil
+	case protoreflect.DoubleKind:
+		return protoreflect.ValueOfFloat64(v.Float()), nil
+	case protoreflect.StringKind:
+		return protoreflect.ValueOfString(v.String()), nil
+	case protoreflect.BytesKind:
+		return protoreflect.ValueOfBytes(v.Bytes()), nil
+	case protoreflect.MessageKind:
+		msg := dynamicpb.NewMessage(fd.Message())
+		if err := toMessage(v, msg); err != nil {
+			return protoreflect.Value{}, err
+		}
+		return protoreflect.ValueOfMessage(msg), nil
+	}
+	return protoreflect.Value{}, fmt.Errorf("field %s: unsupported kind %s", fd.FullName(), fd.Kind())
+}
+
+// fromMessage copies m into v, which must be settable.
+func fromMessage(m protoreflect.Message, v reflect.Value) error {
+	if isWrapped(v.Type()) {
+		return getField(m, WrapperField, v)
+	}
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		if name := FieldName(sf.Name, string(sf.Tag)); name != "" {
+			if err := getField(m, name, v.Field(i)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func getField(m protoreflect.Message, name string, v reflect.Value) error {
+	fd := m.Descriptor().Fields().ByName(protoreflect.Name(name))
+	if fd == nil {
+		return fmt.Errorf("%s has no field %s", m.Descriptor().FullName(), name)
+	}
+	if !m.Has(fd) {
+		return nil
+	}
+	switch {
+	case fd.IsList():
+		l := m.Get(fd).List()
+		if v.Kind() == reflect.Slice {
+			v.Set(reflect.MakeSlice(v.Type(), l.Len(), l.Len()))
+		}
+		for i := 0; i < l.Len() && i < v.Len(); i++ {
+			if err := fromValue(fd, l.Get(i), v.Index(i)); err != nil {
+				return err
+			}
+		}
+	case fd.IsMap():
+		mp := m.Get(fd).Map()
+		v.Set(reflect.MakeMapWithSize(v.Type(), mp.Len()))
+		var err error
+		mp.Range(func(k protoreflect.MapKey, val protoreflect.Value) bool {
+			key := reflect.New(v.Type().Key()).Elem()
+			elem := reflect.New(v.Type().Elem()).Elem()
+			if err = fromValue(fd.MapKey(), k.Value(), key); err != nil {
+				return false
+			}
+			if err = fromValue(fd.MapValue(), val, elem); err != nil {
+				return false
+			}
+			v.SetMapIndex(key, elem)
+			return true
+		})
+		return err
+	default:
+		return fromValue(fd, m.Get(fd), v)
+	}
+	return nil
+}
+
+func fromValue(fd protoreflect.FieldDescriptor, pv protoreflect.Value, v reflect.Value) error {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		v.SetBool(pv.Bool())
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind,
+		protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		if v.OverflowInt(pv.Int()) {
+			return fmt.Errorf("field %s: %d overflows %s", fd.FullName(), pv.Int(), v.Type())
+		}
+		v.SetInt(pv.Int())
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind,
+		protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		if v.OverflowUint(pv.Uint()) {
+			return fmt.Errorf("field %s: %d overflows %s", fd.FullName(), pv.Uint(), v.Type())
+		}
+		v.SetUint(pv.Uint())
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		v.SetFloat(pv.Float())
+	case protoreflect.StringKind:
+		v.SetString(pv.String())
+	case protoreflect.BytesKind:
+		v.SetBytes(append([]byte(nil), pv.Bytes()...))
+	case protoreflect.MessageKind:
+		return fromMessage(pv.Message(), v)
+	default:
+		return fmt.Errorf("field %s: unsupported kind %s", fd.FullName(), fd.Kind())
+	}
+	return nil
+}
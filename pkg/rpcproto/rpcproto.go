@@ -0,0 +1,224 @@
+// Package rpcproto serves net/rpc style methods, Method(args T, reply *R)
+// error with an optional leading context.Context, over gRPC. The messages
+// come from a descriptor generated by cmd/rpcproto, and values are
+// converted between dynamic messages and the Go types by reflection, so
+// the methods need not be rewritten.
+//
+// A struct maps to a message with one field per exported struct field,
+// named after its json tag. Any other argument or reply type is wrapped
+// in a message whose only field is named WrapperField.
+package rpcproto
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"unicode"
+
+	"awesomeproject/pkg/hertzrpc"
+	"awesomeproject/pkg/rpcerr"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// WrapperField names the single field of a wrapper message.
+const WrapperField = "value"
+
+// FieldName returns the proto field name of a struct field: the name in
+// its json tag, else the Go name in snake_case. It returns "" for fields
+// tagged json:"-".
+func FieldName(goName, tag string) string {
+	name, _, _ := strings.Cut(reflect.StructTag(tag).Get("json"), ",")
+	switch name {
+	case "-":
+		return ""
+	case "":
+		return snakeCase(goName)
+	}
+	return name
+}
+
+func snakeCase(s string) string {
+	var b strings.Builder
+	runes := []rune(s)
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			// "UserID" -> "user_id", "HTTPServer" -> "http_server"
+			if i > 0 && (unicode.IsLower(runes[i-1]) || i+1 < len(runes) && unicode.IsLower(runes[i+1])) {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// Register exposes the methods of rcvr with s as the service named
+// service in the serialized FileDescriptorProto rawDesc. The file is also
+// added to protoregistry.GlobalFiles so server reflection can describe it.
+// Argument structs are validated as hertzrpc does.
+func Register(s grpc.ServiceRegistrar, rawDesc []byte, service string, rcvr any) error {
+	fd, err := registerFile(rawDesc)
+	if err != nil {
+		return err
+	}
+	sd := fd.Services().ByName(protoreflect.Name(service))
+	if sd == nil {
+		return fmt.Errorf("rpcproto: %s does not define service %s", fd.Path(), service)
+	}
+	v := reflect.ValueOf(rcvr)
+	desc := &grpc.ServiceDesc{
+		ServiceName: string(sd.FullName()),
+		HandlerType: (*any)(nil),
+		Metadata:    fd.Path(),
+	}
+	for i := 0; i < sd.Methods().Len(); i++ {
+		md := sd.Methods().Get(i)
+		if md.IsStreamingClient() || md.IsStreamingServer() {
+			return fmt.Errorf("rpcproto: %s is streaming", md.FullName())
+		}
+		m, err := newMethod(v, md)
+		if err != nil {
+			return err
+		}
+		desc.Methods = append(desc.Methods, grpc.MethodDesc{
+			MethodName: string(md.Name()),
+			Handler:    m.handle,
+		})
+	}
+	s.RegisterService(desc, rcvr)
+	return nil
+}
+
+func registerFile(rawDesc []byte) (protoreflect.FileDescriptor, error) {
+	var fdp descriptorpb.FileDescriptorProto
+	if err := proto.Unmarshal(rawDesc, &fdp); err != nil {
+		return nil, fmt.Errorf("rpcproto: bad descriptor: %w", err)
+	}
+	if fd, err := protoregistry.GlobalFiles.FindFileByPath(fdp.GetName()); err == nil {
+		return fd, nil
+	}
+	fd, err := protodesc.NewFile(&fdp, protoregistry.GlobalFiles)
+	if err != nil {
+		return nil, fmt.Errorf("rpcproto: %s: %w", fdp.GetName(), err)
+	}
+	// RegisterFile panics on conflicts by default; report them instead.
+	for _, d := range topLevel(fd) {
+		if _, err := protoregistry.GlobalFiles.FindDescriptorByName(d.FullName()); err == nil {
+			return nil, fmt.Errorf("rpcproto: %s: %s is already defined; set a proto package", fdp.GetName(), d.FullName())
+		}
+	}
+	if err := protoregistry.GlobalFiles.RegisterFile(fd); err != nil {
+		return nil, fmt.Errorf("rpcproto: %s: %w", fdp.GetName(), err)
+	}
+	return fd, nil
+}
+
+func topLevel(fd protoreflect.FileDescriptor) []protoreflect.Descriptor {
+	var ds []protoreflect.Descriptor
+	for i := 0; i < fd.Messages().Len(); i++ {
+		ds = append(ds, fd.Messages().Get(i))
+	}
+	for i := 0; i < fd.Enums().Len(); i++ {
+		ds = append(ds, fd.Enums().Get(i))
+	}
+	for i := 0; i < fd.Services().Len(); i++ {
+		ds = append(ds, fd.Services().Get(i))
+	}
+	return ds
+}
+
+var (
+	typeOfContext = reflect.TypeOf((*context.Context)(nil)).Elem()
+	typeOfError   = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+type method struct {
+	fn        reflect.Value // bound to the receiver
+	desc      protoreflect.MethodDescriptor
+	withCtx   bool
+	argType   reflect.Type
+	replyType reflect.Type // the type reply points at
+}
+
+// newMethod checks fn against the signatures hertzrpc.Dispatcher accepts.
+func newMethod(rcvr reflect.Value, md protoreflect.MethodDescriptor) (*method, error) {
+	fn := rcvr.MethodByName(string(md.Name()))
+	if !fn.IsValid() {
+		return nil, fmt.Errorf("rpcproto: %s has no method %s", rcvr.Type(), md.Name())
+	}
+	t := fn.Type()
+	m := &method{fn: fn, desc: md}
+	first := 0
+	if t.NumIn() == 3 && t.In(0) == typeOfContext {
+		m.withCtx, first = true, 1
+	}
+	if t.NumIn() != first+2 || t.In(first+1).Kind() != reflect.Pointer ||
+		t.NumOut() != 1 || t.Out(0) != typeOfError {
+		return nil, fmt.Errorf("rpcproto: %s.%s has signature %s; want (args T, reply *R) error", rcvr.Type(), md.Name(), t)
+	}
+	m.argType, m.replyType = t.In(first), t.In(first+1).Elem()
+	return m, nil
+}
+
+func (m *method) handle(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+	in := dynamicpb.NewMessage(m.desc.Input())
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return m.call(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: fmt.Sprintf("/%s/%s", m.desc.Parent().FullName(), m.desc.Name()),
+	}
+	return interceptor(ctx, in, info, func(ctx context.Context, req any) (any, error) {
+		return m.call(ctx, req.(*dynamicpb.Message))
+	})
+}
+
+func (m *method) call(ctx context.Context, in *dynamicpb.Message) (any, error) {
+	// Like net/rpc, pass a pointer argument as a pointer and anything
+	// else by value.
+	argp := reflect.New(m.argType)
+	if m.argType.Kind() == reflect.Pointer {
+		argp.Elem().Set(reflect.New(m.argType.Elem()))
+		argp = argp.Elem()
+	}
+	if err := fromMessage(in, argp.Elem()); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "%s: %v", m.desc.Input().FullName(), err)
+	}
+	if err := hertzrpc.Validate(argp.Interface()); err != nil {
+		return nil, rpcerr.FromError(err).Err() // InvalidArgument with a BadRequest
+	}
+	arg := argp
+	if m.argType.Kind() != reflect.Pointer {
+		arg = argp.Elem()
+	}
+
+	reply := reflect.New(m.replyType)
+	args := []reflect.Value{arg, reply}
+	if m.withCtx {
+		args = append([]reflect.Value{reflect.ValueOf(ctx)}, args...)
+	}
+	if errv := m.fn.Call(args)[0]; !errv.IsNil() {
+		return nil, rpcerr.FromError(errv.Interface().(error)).Err()
+	}
+
+	out := dynamicpb.NewMessage(m.desc.Output())
+	if err := toMessage(reply.Elem(), out); err != nil {
+		return nil, status.Errorf(codes.Internal, "%s: %v", m.desc.Output().FullName(), err)
+	}
+	return out, nil
+}
@@ -0,0 +1,337 @@
+package rpcproto
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+type Item struct {
+	Name  string `json:"name"`
+	Count int8   `json:"count"`
+}
+
+type Order struct {
+	UserID string           `validate:"required"`
+	Items  []Item           `json:"items"`
+	Tags   map[string]int64 `json:"tags"`
+	Note   []byte           `json:"note,omitempty"`
+	Skip   string           `json:"-"`
+	secret string
+}
+
+type Calc struct{}
+
+func (Calc) Place(o *Order, reply *string) error {
+	keys := make([]string, 0, len(o.Tags))
+	for k, v := range o.Tags {
+		keys = append(keys, fmt.Sprintf("%s=%d", k, v))
+	}
+	sort.Strings(keys)
+	total := 0
+	for _, it := range o.Items {
+		total += int(it.Count)
+	}
+	*reply = fmt.Sprintf("%s: %d items %v %q", o.UserID, total, keys, o.Note)
+	return nil
+}
+
+func (Calc) Double(n int, reply *int) error {
+	if n < 0 {
+		return status.Error(codes.OutOfRange, "negative")
+	}
+	*reply = 2 * n
+	return nil
+}
+
+func (Calc) Who(ctx context.Context, _ string, reply *string) error {
+	*reply = strings.Join(metadata.ValueFromIncomingContext(ctx, "user"), ",")
+	return nil
+}
+
+func (Calc) Plain(s string, reply *string) error {
+	return errors.New("plain " + s)
+}
+
+func (Calc) NoReply(s string) error { return nil }
+
+const pkg = "rpcprototest"
+
+func field(pkg, name string, n int32, typ descriptorpb.FieldDescriptorProto_Type, typeName string, repeated bool) *descriptorpb.FieldDescriptorProto {
+	f := &descriptorpb.FieldDescriptorProto{
+		Name:     proto.String(name),
+		JsonName: proto.String(name),
+		Number:   proto.Int32(n),
+		Type:     typ.Enum(),
+		Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+	}
+	if typeName != "" {
+		f.TypeName = proto.String("." + pkg + "." + typeName)
+	}
+	if repeated {
+		f.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+	}
+	return f
+}
+
+// rpc declares a method whose types are resolved relative to the file's
+// package.
+func rpc(name, in, out string) *descriptorpb.MethodDescriptorProto {
+	return &descriptorpb.MethodDescriptorProto{Name: proto.String(name), InputType: proto.String(in), OutputType: proto.String(out)}
+}
+
+// calcFile is the descriptor cmd/rpcproto would write for Calc in package
+// pkg, declaring service with methods.
+func calcFile(file, pkg, service string, methods ...*descriptorpb.MethodDescriptorProto) []byte {
+	const (
+		str   = descriptorpb.FieldDescriptorProto_TYPE_STRING
+		i32   = descriptorpb.FieldDescriptorProto_TYPE_INT32
+		i64   = descriptorpb.FieldDescriptorProto_TYPE_INT64
+		bytes = descriptorpb.FieldDescriptorProto_TYPE_BYTES
+		msg   = descriptorpb.FieldDescriptorProto_TYPE_MESSAGE
+	)
+	fdp := &descriptorpb.FileDescriptorProto{
+		Name:    proto.String(file),
+		Package: proto.String(pkg),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{
+			{Name: proto.String("Item"), Field: []*descriptorpb.FieldDescriptorProto{
+				field(pkg, "name", 1, str, "", false), field(pkg, "count", 2, i32, "", false)}},
+			{Name: proto.String("Order"), Field: []*descriptorpb.FieldDescriptorProto{
+				field(pkg, "user_id", 1, str, "", false),
+				field(pkg, "items", 2, msg, "Item", true),
+				field(pkg, "tags", 3, msg, "Order.TagsEntry", true),
+				field(pkg, "note", 4, bytes, "", false),
+			}, NestedType: []*descriptorpb.DescriptorProto{
+				{Name: proto.String("TagsEntry"), Options: &descriptorpb.MessageOptions{MapEntry: proto.Bool(true)},
+					Field: []*descriptorpb.FieldDescriptorProto{field(pkg, "key", 1, str, "", false), field(pkg, "value", 2, i64, "", false)}},
+			}},
+			{Name: proto.String("StringValue"), Field: []*descriptorpb.FieldDescriptorProto{field(pkg, "value", 1, str, "", false)}},
+			{Name: proto.String("IntValue"), Field: []*descriptorpb.FieldDescriptorProto{field(pkg, "value", 1, i64, "", false)}},
+		},
+		Service: []*descriptorpb.ServiceDescriptorProto{{Name: proto.String(service), Method: methods}},
+	}
+	raw, err := proto.Marshal(fdp)
+	if err != nil {
+		panic(err)
+	}
+	return raw
+}
+
+var calcMethods = []*descriptorpb.MethodDescriptorProto{
+	rpc("Place", "Order", "StringValue"),
+	rpc("Double", "IntValue", "IntValue"),
+	rpc("Who", "StringValue", "StringValue"),
+	rpc("Plain", "StringValue", "StringValue"),
+}
+
+func dialCalc(t *testing.T) *grpc.ClientConn {
+	t.Helper()
+	l := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	if err := Register(srv, calcFile("rpcprototest/calc.proto", pkg, "Calc", calcMethods...), "Calc", Calc{}); err != nil {
+		t.Fatal(err)
+	}
+	go srv.Serve(l)
+	t.Cleanup(srv.Stop)
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return l.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func message(t *testing.T, name string, fields map[string]any) *dynamicpb.Message {
+	t.Helper()
+	d, err := protoregistry.GlobalFiles.FindDescriptorByName(protoreflect.FullName(pkg + "." + name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := dynamicpb.NewMessage(d.(protoreflect.MessageDescriptor))
+	for k, v := range fields {
+		fd := m.Descriptor().Fields().ByName(protoreflect.Name(k))
+		switch v := v.(type) {
+		case []map[string]any:
+			l := m.Mutable(fd).List()
+			for _, item := range v {
+				l.Append(protoreflect.ValueOfMessage(message(t, "Item", item)))
+			}
+		case map[string]int64:
+			mp := m.Mutable(fd).Map()
+			for mk, mv := range v {
+				mp.Set(protoreflect.ValueOfString(mk).MapKey(), protoreflect.ValueOfInt64(mv))
+			}
+		default:
+			m.Set(fd, protoreflect.ValueOf(v))
+		}
+	}
+	return m
+}
+
+func TestServe(t *testing.T) {
+	conn := dialCalc(t)
+	tests := []struct {
+		name     string
+		method   string
+		in       func() *dynamicpb.Message
+		out      string
+		md       []string
+		want     any
+		wantCode codes.Code
+	}{
+		{name: "struct", method: "Place", out: "StringValue",
+			in: func() *dynamicpb.Message {
+				return message(t, "Order", map[string]any{
+					"user_id": "u1",
+					"items":   []map[string]any{{"name": "a", "count": int32(2)}, {"name": "b", "count": int32(3)}},
+					"tags":    map[string]int64{"x": 1, "y": 2},
+					"note":    []byte("hi"),
+				})
+			},
+			want: `u1: 5 items [x=1 y=2] "hi"`},
+		{name: "validation", method: "Place", out: "StringValue",
+			in:       func() *dynamicpb.Message { return message(t, "Order", nil) },
+			wantCode: codes.InvalidArgument},
+		{name: "overflow", method: "Place", out: "StringValue",
+			in: func() *dynamicpb.Message {
+				return message(t, "Order", map[string]any{"user_id": "u1", "items": []map[string]any{{"count": int32(300)}}})
+			},
+			wantCode: codes.InvalidArgument},
+		{name: "wrapped", method: "Double", out: "IntValue",
+			in:   func() *dynamicpb.Message { return message(t, "IntValue", map[string]any{"value": int64(21)}) },
+			want: int64(42)},
+		{name: "status error", method: "Double", out: "IntValue",
+			in:       func() *dynamicpb.Message { return message(t, "IntValue", map[string]any{"value": int64(-1)}) },
+			wantCode: codes.OutOfRange},
+		{name: "plain error", method: "Plain", out: "StringValue",
+			in:       func() *dynamicpb.Message { return message(t, "StringValue", map[string]any{"value": "x"}) },
+			wantCode: codes.Unknown},
+		{name: "context", method: "Who", out: "StringValue", md: []string{"user", "alice"},
+			in:   func() *dynamicpb.Message { return message(t, "StringValue", nil) },
+			want: "alice"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			ctx = metadata.AppendToOutgoingContext(ctx, tt.md...)
+			out := message(t, tt.out, nil)
+			err := conn.Invoke(ctx, "/"+pkg+".Calc/"+tt.method, tt.in(), out)
+			if status.Code(err) != tt.wantCode {
+				t.Fatalf("Invoke = %v, want %s", err, tt.wantCode)
+			}
+			if err == nil {
+				got := out.Get(out.Descriptor().Fields().ByName(WrapperField)).Interface()
+				if got != tt.want {
+					t.Fatalf("reply %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestRegisterErrors(t *testing.T) {
+	streaming := rpc("Double", "IntValue", "IntValue")
+	streaming.ServerStreaming = proto.Bool(true)
+	tests := []struct {
+		name    string
+		rawDesc []byte
+		service string
+		want    string
+	}{
+		{"bad descriptor", []byte("\xff"), "Calc", "bad descriptor"},
+		{"unknown service", calcFile("rpcprototest/e1.proto", "rpcprototest.e1", "Calc", calcMethods...), "Other", "does not define service Other"},
+		{"missing method", calcFile("rpcprototest/e2.proto", "rpcprototest.e2", "Calc", rpc("Triple", "IntValue", "IntValue")), "Calc", "has no method Triple"},
+		{"bad signature", calcFile("rpcprototest/e3.proto", "rpcprototest.e3", "Calc", rpc("NoReply", "StringValue", "StringValue")), "Calc", "want (args T, reply *R) error"},
+		{"streaming", calcFile("rpcprototest/e4.proto", "rpcprototest.e4", "Calc", streaming), "Calc", "is streaming"},
+		{"conflict", calcFile("rpcprototest/other.proto", pkg, "Calc", calcMethods...), "Calc", "already defined; set a proto package"},
+	}
+	if err := Register(grpc.NewServer(), calcFile("rpcprototest/calc.proto", pkg, "Calc", calcMethods...), "Calc", Calc{}); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		err := Register(grpc.NewServer(), tt.rawDesc, tt.service, Calc{})
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: err = %v, want %q", tt.name, err, tt.want)
+		}
+	}
+	// Registering the same file again reuses the registered descriptor.
+	if err := Register(grpc.NewServer(), calcFile("rpcprototest/calc.proto", pkg, "Calc", calcMethods...), "Calc", Calc{}); err != nil {
+		t.Fatalf("registering a file again: %v", err)
+	}
+}
+
+func TestFieldName(t *testing.T) {
+	tests := []struct {
+		goName, tag, want string
+	}{
+		{"Name", `json:"name"`, "name"},
+		{"Name", `json:"full_name,omitempty"`, "full_name"},
+		{"Name", `json:",omitempty"`, "name"},
+		{"Skip", `json:"-"`, ""},
+		{"UserID", ``, "user_id"},
+		{"HTTPServer", ``, "http_server"},
+		{"ID", ``, "id"},
+		{"MaxConnsPerIP", ``, "max_conns_per_ip"},
+	}
+	for _, tt := range tests {
+		if got := FieldName(tt.goName, tt.tag); got != tt.want {
+			t.Errorf("FieldName(%q, %q) = %q, want %q", tt.goName, tt.tag, got, tt.want)
+		}
+	}
+}
+
+func TestConvertRoundTrip(t *testing.T) {
+	dialCalc(t) // registers the descriptor
+	in := &Order{
+		UserID: "u1",
+		Items:  []Item{{"a", 1}, {"b", -2}},
+		Tags:   map[string]int64{"x": 1},
+		Note:   []byte{0, 1},
+		Skip:   "not sent",
+		secret: "not sent",
+	}
+	m := message(t, "Order", nil)
+	if err := toMessage(reflect.ValueOf(in), m); err != nil {
+		t.Fatal(err)
+	}
+	var out *Order
+	if err := fromMessage(m, reflect.ValueOf(&out).Elem()); err != nil {
+		t.Fatal(err)
+	}
+	want := *in
+	want.Skip, want.secret = "", ""
+	if !reflect.DeepEqual(out, &want) {
+		t.Fatalf("round trip gave %+v, want %+v", out, &want)
+	}
+
+	var n int
+	w := message(t, "IntValue", map[string]any{"value": int64(7)})
+	if err := fromMessage(w, reflect.ValueOf(&n).Elem()); err != nil || n != 7 {
+		t.Fatalf("wrapped = %d, %v", n, err)
+	}
+	if err := toMessage(reflect.ValueOf(struct{ Missing int }{}), w); err == nil {
+		t.Fatal("toMessage ignored a field the message lacks")
+	}
+}
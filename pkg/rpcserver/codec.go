@@ -0,0 +1,82 @@
+package rpcserver
+
+import (
+	"bufio"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+)
+
+// Wire formats accepted by Config.Codec.
+const (
+	CodecGob  = "gob"  // net/rpc's native format, as used by rpc.Dial
+	CodecJSON = "json" // JSON-RPC 1.0, as used by jsonrpc.Dial
+)
+
+// NewServerCodec returns a ServerCodec speaking the named format on conn.
+func NewServerCodec(name string, conn io.ReadWriteCloser) (rpc.ServerCodec, error) {
+	switch name {
+	case CodecGob, "":
+		return NewGobServerCodec(conn), nil
+	case CodecJSON:
+		return jsonrpc.NewServerCodec(conn), nil
+	}
+	return nil, fmt.Errorf("rpcserver: unknown codec %q", name)
+}
+
+// gobServerCodec is the codec rpc.ServeConn uses, which net/rpc does not
+// export.
+type gobServerCodec struct {
+	rwc    io.ReadWriteCloser
+	dec    *gob.Decoder
+	enc    *gob.Encoder
+	encBuf *bufio.Writer
+	closed bool
+}
+
+// NewGobServerCodec returns the gob ServerCodec used by rpc.ServeConn.
+func NewGobServerCodec(conn io.ReadWriteCloser) rpc.ServerCodec {
+	buf := bufio.NewWriter(conn)
+	return &gobServerCodec{
+		rwc:    conn,
+		dec:    gob.NewDecoder(conn),
+		enc:    gob.NewEncoder(buf),
+		encBuf: buf,
+	}
+}
+
+func (c *gobServerCodec) ReadRequestHeader(r *rpc.Request) error {
+	return c.dec.Decode(r)
+}
+
+func (c *gobServerCodec) ReadRequestBody(body any) error {
+	return c.dec.Decode(body)
+}
+
+func (c *gobServerCodec) WriteResponse(r *rpc.Response, body any) (err error) {
+	if err = c.enc.Encode(r); err != nil {
+		if c.encBuf.Flush() == nil {
+			// Gob couldn't encode the header; the stream is unusable.
+			c.Close()
+		}
+		return err
+	}
+	if err = c.enc.Encode(body); err != nil {
+		if c.encBuf.Flush() == nil {
+			c.Close()
+		}
+		return err
+	}
+	return c.encBuf.Flush()
+}
+
+func (c *gobServerCodec) Close() error {
+	if c.closed {
+		// Only call c.rwc.Close once; otherwise the semantics are undefined.
+		return nil
+	}
+	c.closed = true
+	return c.rwc.Close()
+}
@@ -0,0 +1,275 @@
+// Package rpcserver serves a net/rpc Server over TCP with the limits a
+// long-running service needs: a cap on concurrent connections, idle and
+// read timeouts, TCP keepalive, gob or JSON-RPC framing, connection
+// metrics and graceful shutdown.
+package rpcserver
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"io"
+	"log"
+	"net"
+	"net/rpc"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"awesomeproject/pkg/shutdown"
+)
+
+// Config controls a Server. The zero value serves gob on rpc.DefaultServer
+// without limits.
+type Config struct {
+	Addr     string
+	Codec    string // CodecGob or CodecJSON
+	MaxConns int    // connections beyond this are closed on accept; 0 = unlimited
+	// IdleTimeout closes a connection that has no call in flight and sends
+	// nothing for this long.
+	IdleTimeout time.Duration
+	// ReadTimeout bounds reading a request body once its header arrived.
+	ReadTimeout time.Duration
+	// KeepAlive is the TCP keepalive period; negative disables it.
+	KeepAlive time.Duration
+	// Server dispatches the calls; nil means rpc.DefaultServer, where
+	// serverStub.RegisterHelloService registers.
+	Server *rpc.Server
+	// WrapCodec, if set, wraps the codec of every connection, e.g. with
+	// rpchook.NewServerCodec.
+	WrapCodec func(conn net.Conn, codec rpc.ServerCodec) rpc.ServerCodec
+}
+
+// DefaultConfig returns the settings used by the command-line servers.
+func DefaultConfig() Config {
+	return Config{
+		Addr:        ":1234",
+		Codec:       CodecGob,
+		MaxConns:    1024,
+		IdleTimeout: 5 * time.Minute,
+		ReadTimeout: 30 * time.Second,
+		KeepAlive:   30 * time.Second,
+	}
+}
+
+// RegisterFlags binds the Config fields to flags on fs, using the current
+// values as defaults.
+func (c *Config) RegisterFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.Addr, "addr", c.Addr, "listen address")
+	fs.StringVar(&c.Codec, "codec", c.Codec, "wire format: gob or json")
+	fs.IntVar(&c.MaxConns, "max-conns", c.MaxConns, "maximum concurrent connections (0 = unlimited)")
+	fs.DurationVar(&c.IdleTimeout, "idle-timeout", c.IdleTimeout, "close connections idle for this long (0 = never)")
+	fs.DurationVar(&c.ReadTimeout, "read-timeout", c.ReadTimeout, "deadline for reading a request body (0 = none)")
+	fs.DurationVar(&c.KeepAlive, "keepalive", c.KeepAlive, "TCP keepalive period (negative disables)")
+}
+
+// Stats is a snapshot of a Server's connection metrics.
+type Stats struct {
+	Active   int64 `json:"active"`   // connections open now
+	Accepted int64 `json:"accepted"` // connections served since start
+	Rejected int64 `json:"rejected"` // connections refused by MaxConns
+	TimedOut int64 `json:"timedOut"` // connections closed by a timeout
+	Requests int64 `json:"requests"` // requests read
+	InFlight int64 `json:"inFlight"` // requests not yet answered
+}
+
+// Server serves net/rpc connections. Create it with New.
+type Server struct {
+	cfg   Config
+	rpc   *rpc.Server
+	conns shutdown.Group
+
+	active, accepted, rejected, timedOut, requests, inFlight atomic.Int64
+}
+
+// New returns a Server for cfg. It checks the codec name up front.
+func New(cfg Config) (*Server, error) {
+	if _, err := NewServerCodec(cfg.Codec, nopConn{}); err != nil {
+		return nil, err
+	}
+	s := &Server{cfg: cfg, rpc: cfg.Server}
+	if s.rpc == nil {
+		s.rpc = rpc.DefaultServer
+	}
+	return s, nil
+}
+
+// ListenAndServe listens on cfg.Addr and serves until ctx is done. Call
+// Shutdown afterwards to drain open connections.
+func (s *Server) ListenAndServe(ctx context.Context) error {
+	lc := net.ListenConfig{KeepAlive: s.cfg.KeepAlive}
+	l, err := lc.Listen(ctx, "tcp", s.cfg.Addr)
+	if err != nil {
+		return err
+	}
+	log.Printf("rpcserver: serving %s on %s", s.cfg.Codec, l.Addr())
+	return s.Serve(ctx, l)
+}
+
+// Serve accepts connections on l until ctx is done, then closes l. If l
+// is closed by someone else, Serve returns net.ErrClosed.
+func (s *Server) Serve(ctx context.Context, l net.Listener) error {
+	stop := context.AfterFunc(ctx, func() { l.Close() })
+	defer stop()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			if errors.Is(err, net.ErrClosed) {
+				return err
+			}
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
+				continue
+			}
+			// e.g. EMFILE: back off instead of spinning
+			log.Printf("rpcserver: accept: %v", err)
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(100 * time.Millisecond):
+			}
+			continue
+		}
+		if s.cfg.MaxConns > 0 && s.active.Load() >= int64(s.cfg.MaxConns) {
+			s.rejected.Add(1)
+			conn.Close()
+			continue
+		}
+		s.serveConn(conn)
+	}
+}
+
+// Shutdown stops reading new requests, waits for in-flight calls to be
+// answered and closes every connection. Connections still busy when ctx
+// is done are closed anyway.
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.conns.Shutdown(ctx)
+}
+
+// Stats returns the current connection metrics.
+func (s *Server) Stats() Stats {
+	return Stats{
+		Active:   s.active.Load(),
+		Accepted: s.accepted.Load(),
+		Rejected: s.rejected.Load(),
+		TimedOut: s.timedOut.Load(),
+		Requests: s.requests.Load(),
+		InFlight: s.inFlight.Load(),
+	}
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	tc := &timeoutCodec{srv: s, conn: conn}
+	done, err := s.conns.Add(tc.stop, func() { conn.Close() })
+	if err != nil {
+		conn.Close()
+		return
+	}
+	codec, _ := NewServerCodec(s.cfg.Codec, conn) // validated in New
+	if s.cfg.WrapCodec != nil {
+		codec = s.cfg.WrapCodec(conn, codec)
+	}
+	tc.ServerCodec = codec
+	s.active.Add(1)
+	s.accepted.Add(1)
+	go func() {
+		defer done()
+		defer s.active.Add(-1)
+		// ServeCodec returns after the codec fails to read and every call
+		// already read has been answered.
+		s.rpc.ServeCodec(tc)
+	}()
+}
+
+// timeoutCodec applies the idle and read timeouts and counts requests.
+type timeoutCodec struct {
+	rpc.ServerCodec
+	srv  *Server
+	conn net.Conn
+
+	mu      sync.Mutex
+	pending int
+	closing bool
+}
+
+// stop makes the next or current header read fail so ServeCodec winds
+// down after answering the calls it already read.
+func (c *timeoutCodec) stop() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closing = true
+	_ = c.conn.SetReadDeadline(time.Now())
+}
+
+func (c *timeoutCodec) ReadRequestHeader(r *rpc.Request) error {
+	c.mu.Lock()
+	if c.closing {
+		c.mu.Unlock()
+		return io.EOF
+	}
+	var deadline time.Time
+	if idle := c.srv.cfg.IdleTimeout; idle > 0 && c.pending == 0 {
+		deadline = time.Now().Add(idle)
+	}
+	_ = c.conn.SetReadDeadline(deadline)
+	c.mu.Unlock()
+
+	err := c.ServerCodec.ReadRequestHeader(r)
+	if err != nil {
+		c.countTimeout(err)
+		return err
+	}
+	c.srv.requests.Add(1)
+	return nil
+}
+
+func (c *timeoutCodec) ReadRequestBody(body any) error {
+	c.mu.Lock()
+	if t := c.srv.cfg.ReadTimeout; t > 0 && !c.closing {
+		_ = c.conn.SetReadDeadline(time.Now().Add(t))
+	}
+	c.pending++
+	c.mu.Unlock()
+	c.srv.inFlight.Add(1)
+
+	err := c.ServerCodec.ReadRequestBody(body)
+	if err != nil {
+		c.countTimeout(err)
+	}
+	return err
+}
+
+func (c *timeoutCodec) WriteResponse(r *rpc.Response, body any) error {
+	// net/rpc writes a response for every header it read, including ones
+	// whose body failed to decode, so this balances ReadRequestBody.
+	c.mu.Lock()
+	if c.pending > 0 {
+		c.pending--
+		c.srv.inFlight.Add(-1)
+	}
+	// net/rpc is usually already blocked reading the next header, which
+	// started without a deadline while this call was pending.
+	if idle := c.srv.cfg.IdleTimeout; idle > 0 && c.pending == 0 && !c.closing {
+		_ = c.conn.SetReadDeadline(time.Now().Add(idle))
+	}
+	c.mu.Unlock()
+	return c.ServerCodec.WriteResponse(r, body)
+}
+
+func (c *timeoutCodec) countTimeout(err error) {
+	c.mu.Lock()
+	closing := c.closing
+	c.mu.Unlock()
+	if ne, ok := err.(net.Error); ok && ne.Timeout() && !closing {
+		c.srv.timedOut.Add(1)
+	}
+}
+
+// nopConn lets New validate the codec name without a connection.
+type nopConn struct{}
+
+func (nopConn) Read([]byte) (int, error)    { return 0, io.EOF }
+func (nopConn) Write(p []byte) (int, error) { return len(p), nil }
+func (nopConn) Close() error                { return nil }
@@ -0,0 +1,220 @@
+package rpcserver
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"testing"
+	"time"
+)
+
+type Arith struct {
+	// release, if set, holds Slow until it is closed.
+	release chan struct{}
+	started chan struct{}
+}
+
+func (a *Arith) Double(n int, reply *int) error {
+	*reply = 2 * n
+	return nil
+}
+
+func (a *Arith) Slow(n int, reply *int) error {
+	a.started <- struct{}{}
+	<-a.release
+	*reply = n
+	return nil
+}
+
+// start serves cfg on a loopback port until the test ends.
+func start(t *testing.T, cfg Config) (*Server, *Arith, string) {
+	t.Helper()
+	arith := &Arith{release: make(chan struct{}), started: make(chan struct{}, 1)}
+	cfg.Server = rpc.NewServer()
+	if err := cfg.Server.Register(arith); err != nil {
+		t.Fatal(err)
+	}
+	s, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() { served <- s.Serve(ctx, l) }()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-served; err != nil {
+			t.Error(err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		s.Shutdown(ctx)
+	})
+	return s, arith, l.Addr().String()
+}
+
+func dial(t *testing.T, codec, addr string) *rpc.Client {
+	t.Helper()
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var c *rpc.Client
+	if codec == CodecJSON {
+		c = jsonrpc.NewClient(conn)
+	} else {
+		c = rpc.NewClient(conn)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+// eventually polls cond for up to a second.
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		if cond() {
+			return
+		}
+	}
+	t.Fatalf("timed out waiting for %s", what)
+}
+
+func TestServeListenerClosed(t *testing.T) {
+	s, err := New(Config{Server: rpc.NewServer()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	served := make(chan error, 1)
+	go func() { served <- s.Serve(context.Background(), l) }()
+	l.Close()
+	select {
+	case err := <-served:
+		if !errors.Is(err, net.ErrClosed) {
+			t.Fatalf("Serve = %v, want net.ErrClosed", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Serve kept running after its listener was closed")
+	}
+}
+
+func TestNewCodec(t *testing.T) {
+	tests := []struct {
+		codec   string
+		wantErr bool
+	}{
+		{"", false},
+		{CodecGob, false},
+		{CodecJSON, false},
+		{"xml", true},
+	}
+	for _, tt := range tests {
+		if _, err := New(Config{Codec: tt.codec}); (err != nil) != tt.wantErr {
+			t.Errorf("New(%q) = %v, want error %v", tt.codec, err, tt.wantErr)
+		}
+	}
+}
+
+func TestCodecs(t *testing.T) {
+	for _, codec := range []string{CodecGob, CodecJSON} {
+		t.Run(codec, func(t *testing.T) {
+			s, _, addr := start(t, Config{Codec: codec})
+			c := dial(t, codec, addr)
+			for i := 1; i <= 3; i++ {
+				var reply int
+				if err := c.Call("Arith.Double", i, &reply); err != nil || reply != 2*i {
+					t.Fatalf("Double(%d) = %d, %v", i, reply, err)
+				}
+			}
+			var reply int
+			if err := c.Call("Arith.Triple", 1, &reply); err == nil {
+				t.Fatal("unknown method succeeded")
+			}
+			if st := s.Stats(); st.Accepted != 1 || st.Active != 1 || st.Requests != 4 || st.InFlight != 0 {
+				t.Fatalf("Stats = %+v", st)
+			}
+		})
+	}
+}
+
+func TestMaxConns(t *testing.T) {
+	s, _, addr := start(t, Config{MaxConns: 1})
+	first := dial(t, CodecGob, addr)
+	var reply int
+	if err := first.Call("Arith.Double", 1, &reply); err != nil {
+		t.Fatal(err)
+	}
+	second := dial(t, CodecGob, addr)
+	if err := second.Call("Arith.Double", 1, &reply); err == nil {
+		t.Fatal("connection over the limit was served")
+	}
+	eventually(t, "the rejection to be counted", func() bool { return s.Stats().Rejected == 1 })
+	first.Close()
+	eventually(t, "the first connection to close", func() bool { return s.Stats().Active == 0 })
+	third := dial(t, CodecGob, addr)
+	if err := third.Call("Arith.Double", 1, &reply); err != nil {
+		t.Fatalf("connection under the limit: %v", err)
+	}
+}
+
+func TestIdleTimeout(t *testing.T) {
+	s, arith, addr := start(t, Config{IdleTimeout: 50 * time.Millisecond})
+	c := dial(t, CodecGob, addr)
+
+	// A call in flight keeps the connection open past the idle timeout.
+	call := c.Go("Arith.Slow", 7, new(int), nil)
+	<-arith.started
+	time.Sleep(100 * time.Millisecond)
+	close(arith.release)
+	if r := <-call.Done; r.Error != nil || *r.Reply.(*int) != 7 {
+		t.Fatalf("slow call = %v, %v", *r.Reply.(*int), r.Error)
+	}
+
+	eventually(t, "the idle connection to close", func() bool { return s.Stats().Active == 0 })
+	if st := s.Stats(); st.TimedOut != 1 {
+		t.Fatalf("Stats = %+v, want one timeout", st)
+	}
+	var reply int
+	if err := c.Call("Arith.Double", 1, &reply); err == nil {
+		t.Fatal("call on a timed-out connection succeeded")
+	}
+}
+
+func TestShutdownDrains(t *testing.T) {
+	s, arith, addr := start(t, Config{})
+	c := dial(t, CodecGob, addr)
+	call := c.Go("Arith.Slow", 7, new(int), nil)
+	<-arith.started
+
+	shut := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		shut <- s.Shutdown(ctx)
+	}()
+	select {
+	case err := <-shut:
+		t.Fatalf("Shutdown returned %v with a call in flight", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(arith.release)
+	if r := <-call.Done; r.Error != nil {
+		t.Fatalf("in-flight call failed: %v", r.Error)
+	}
+	if err := <-shut; err != nil {
+		t.Fatal(err)
+	}
+	if st := s.Stats(); st.Active != 0 || st.TimedOut != 0 {
+		t.Fatalf("Stats = %+v", st)
+	}
+}
@@ -0,0 +1,188 @@
+// Package shutdown coordinates stopping servers on SIGINT or SIGTERM:
+// new work is refused, long-lived connections are asked to wind down,
+// in-flight calls get until a deadline to finish, and whatever is left
+// after that is closed.
+package shutdown
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// DefaultTimeout bounds how long a server waits for in-flight work.
+const DefaultTimeout = 10 * time.Second
+
+// OnSignal returns a context that is cancelled on SIGINT or SIGTERM.
+func OnSignal() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+}
+
+// Graceful calls stop and waits for it to return. If ctx is done first,
+// force is called to cut the remaining work short and ctx.Err() returned.
+func Graceful(ctx context.Context, stop, force func()) error {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		stop()
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		force()
+		<-done
+		return ctx.Err()
+	}
+}
+
+// ErrClosing is returned by Group.Add once Shutdown has begun.
+var ErrClosing = errors.New("shutdown: server is shutting down")
+
+// Group tracks in-flight calls and connections. It is safe for
+// concurrent use; the zero value is ready.
+type Group struct {
+	mu      sync.Mutex
+	closing bool
+	next    int
+	members map[int]member
+	wg      sync.WaitGroup
+}
+
+type member struct {
+	stop, kill func()
+}
+
+// Add registers one unit of work. stop, if not nil, is called when
+// Shutdown begins and should make the work finish soon, e.g. by ending a
+// read loop; kill, if not nil, is called if it is still running at the
+// deadline. The caller must call done when the work ends.
+func (g *Group) Add(stop, kill func()) (done func(), err error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.closing {
+		return nil, ErrClosing
+	}
+	if g.members == nil {
+		g.members = make(map[int]member)
+	}
+	id := g.next
+	g.next++
+	g.members[id] = member{stop, kill}
+	g.wg.Add(1)
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			g.mu.Lock()
+			delete(g.members, id)
+			g.mu.Unlock()
+			g.wg.Done()
+		})
+	}, nil
+}
+
+// AddConn registers a connection whose read loop ends on a read error.
+// Shutdown expires its read deadline, so the loop stops after the request
+// it is handling, and closes it at the deadline.
+func (g *Group) AddConn(conn net.Conn) (done func(), err error) {
+	return g.Add(
+		func() { _ = conn.SetReadDeadline(time.Now()) },
+		func() { _ = conn.Close() },
+	)
+}
+
+// Closing reports whether Shutdown has begun.
+func (g *Group) Closing() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.closing
+}
+
+// Len returns the number of registered units of work.
+func (g *Group) Len() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return len(g.members)
+}
+
+// Shutdown refuses further Adds, calls every stop function and waits for
+// all work to call done. If ctx ends first, the kill functions are called
+// and ctx.Err() is returned at once: work without a kill function, or
+// that ignores it, is abandoned rather than allowed to hold up the exit.
+func (g *Group) Shutdown(ctx context.Context) error {
+	g.mu.Lock()
+	g.closing = true
+	members := make([]member, 0, len(g.members))
+	for _, m := range g.members {
+		members = append(members, m)
+	}
+	g.mu.Unlock()
+
+	for _, m := range members {
+		if m.stop != nil {
+			m.stop()
+		}
+	}
+	done := make(chan struct{})
+	go func() {
+		g.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for _, m := range g.members {
+		if m.kill != nil {
+			m.kill()
+		}
+	}
+	return ctx.Err()
+}
+
+// Hijacked wraps h so that connections it hijacks, such as net/rpc's
+// CONNECT tunnel, are tracked by g. http.Server.Shutdown does not wait
+// for hijacked connections.
+func (g *Group) Hijacked(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hw := &hijackWriter{ResponseWriter: w, group: g}
+		defer func() {
+			if hw.done != nil {
+				hw.done()
+			}
+		}()
+		h.ServeHTTP(hw, r)
+	})
+}
+
+type hijackWriter struct {
+	http.ResponseWriter
+	group *Group
+	done  func()
+}
+
+func (w *hijackWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("shutdown: response does not support hijacking")
+	}
+	conn, rw, err := hj.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	if w.done, err = w.group.AddConn(conn); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	return conn, rw, nil
+}
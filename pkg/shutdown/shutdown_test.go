@@ -0,0 +1,93 @@
+package shutdown
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGroupShutdown(t *testing.T) {
+	tests := []struct {
+		name string
+		// whether the work ends when stop or kill is called, and
+		// whether it has a kill func at all
+		endsOnStop, endsOnKill, hasKill bool
+		wantErr                         error
+		wantKilled                      bool
+	}{
+		{name: "stops in time", endsOnStop: true, hasKill: true},
+		{name: "killed at deadline", endsOnKill: true, hasKill: true, wantErr: context.DeadlineExceeded, wantKilled: true},
+		{name: "ignores kill", hasKill: true, wantErr: context.DeadlineExceeded, wantKilled: true},
+		{name: "no kill func", wantErr: context.DeadlineExceeded},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var g Group
+			end := make(chan struct{})
+			var ended, killed atomic.Bool
+			finish := func() {
+				if ended.CompareAndSwap(false, true) {
+					close(end)
+				}
+			}
+			var kill func()
+			if tt.hasKill {
+				kill = func() {
+					killed.Store(true)
+					if tt.endsOnKill {
+						finish()
+					}
+				}
+			}
+			done, err := g.Add(func() {
+				if tt.endsOnStop {
+					finish()
+				}
+			}, kill)
+			if err != nil {
+				t.Fatal(err)
+			}
+			go func() {
+				<-end
+				done()
+			}()
+			defer finish()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			start := time.Now()
+			err = g.Shutdown(ctx)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Shutdown = %v, want %v", err, tt.wantErr)
+			}
+			if elapsed := time.Since(start); elapsed > time.Second {
+				t.Fatalf("Shutdown took %v past its deadline", elapsed)
+			}
+			if killed.Load() != tt.wantKilled {
+				t.Fatalf("killed = %v, want %v", killed.Load(), tt.wantKilled)
+			}
+			if _, err := g.Add(nil, nil); !errors.Is(err, ErrClosing) {
+				t.Fatalf("Add after Shutdown = %v, want ErrClosing", err)
+			}
+		})
+	}
+}
+
+func TestGraceful(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	release := make(chan struct{})
+	var forced atomic.Bool
+	err := Graceful(ctx, func() { <-release }, func() {
+		forced.Store(true)
+		close(release)
+	})
+	if !errors.Is(err, context.DeadlineExceeded) || !forced.Load() {
+		t.Fatalf("Graceful = %v, forced %v", err, forced.Load())
+	}
+	if err := Graceful(context.Background(), func() {}, func() { t.Fatal("forced") }); err != nil {
+		t.Fatalf("Graceful = %v", err)
+	}
+}
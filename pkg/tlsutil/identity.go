@@ -0,0 +1,75 @@
+package tlsutil
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+// Identity describes the certificate a client authenticated with.
+type Identity struct {
+	CommonName   string   `json:"common_name"`
+	Organization []string `json:"organization,omitempty"`
+	DNSNames     []string `json:"dns_names,omitempty"`
+	URIs         []string `json:"uris,omitempty"`
+	Serial       string   `json:"serial"`
+	Issuer       string   `json:"issuer"`
+	// Fingerprint is the hex SHA-256 of the DER certificate.
+	Fingerprint string `json:"fingerprint"`
+}
+
+func (id *Identity) String() string {
+	return fmt.Sprintf("CN=%s serial=%s", id.CommonName, id.Serial)
+}
+
+// IdentityFromState returns the identity of the peer certificate in cs,
+// or nil if the peer presented none. Servers built with ServerConfig only
+// complete handshakes whose client certificate verified.
+func IdentityFromState(cs *tls.ConnectionState) *Identity {
+	if cs == nil || len(cs.PeerCertificates) == 0 {
+		return nil
+	}
+	leaf := cs.PeerCertificates[0]
+	sum := sha256.Sum256(leaf.Raw)
+	id := &Identity{
+		CommonName:   leaf.Subject.CommonName,
+		Organization: leaf.Subject.Organization,
+		DNSNames:     leaf.DNSNames,
+		Serial:       leaf.SerialNumber.Text(16),
+		Issuer:       leaf.Issuer.CommonName,
+		Fingerprint:  hex.EncodeToString(sum[:]),
+	}
+	for _, u := range leaf.URIs {
+		id.URIs = append(id.URIs, u.String())
+	}
+	return id
+}
+
+type identityKey struct{}
+
+// NewContext returns a copy of ctx carrying id.
+func NewContext(ctx context.Context, id *Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, id)
+}
+
+// PeerFromContext returns the client certificate identity for the call
+// in ctx. It understands both contexts prepared with NewContext and gRPC
+// server contexts.
+func PeerFromContext(ctx context.Context) (*Identity, bool) {
+	if id, ok := ctx.Value(identityKey{}).(*Identity); ok && id != nil {
+		return id, true
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		if info, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+			if id := IdentityFromState(&info.State); id != nil {
+				return id, true
+			}
+		}
+	}
+	return nil, false
+}
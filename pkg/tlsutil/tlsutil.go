@@ -0,0 +1,264 @@
+// Package tlsutil builds TLS configurations for the gRPC and Hertz servers
+// and their clients. Certificates are reloaded from disk when the files
+// change, and the identity of a verified client certificate is made
+// available to handlers and interceptors.
+package tlsutil
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"awesomeproject/pkg/filewatch"
+)
+
+// Config names the PEM files used for TLS.
+type Config struct {
+	CertFile string // certificate chain presented to the peer
+	KeyFile  string // private key for CertFile
+	// CAFile holds the CAs that verify the peer. On a server it enables
+	// mutual TLS: clients must present a certificate signed by one of them.
+	// On a client it replaces the system roots.
+	CAFile     string
+	ServerName string // client only: name to verify instead of the dial host
+}
+
+// RegisterServerFlags binds the server-side fields to flags on fs.
+func (c *Config) RegisterServerFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.CertFile, "tls-cert", "", "PEM server certificate; enables TLS")
+	fs.StringVar(&c.KeyFile, "tls-key", "", "PEM server private key")
+	fs.StringVar(&c.CAFile, "tls-client-ca", "", "PEM CA bundle for client certificates; enables mutual TLS")
+}
+
+// RegisterClientFlags binds the client-side fields to flags on fs.
+func (c *Config) RegisterClientFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.CAFile, "tls-ca", "", "PEM CA bundle to verify the server; enables TLS")
+	fs.StringVar(&c.CertFile, "tls-cert", "", "PEM client certificate for mutual TLS")
+	fs.StringVar(&c.KeyFile, "tls-key", "", "PEM client private key")
+	fs.StringVar(&c.ServerName, "tls-server-name", "", "server name to verify, if not the dial host")
+}
+
+// DefaultServerName sets ServerName, if unset, to the host shared by the
+// comma-separated host:port addresses in addrs. A client verifying with
+// CAFile only learns the dial host from the handshake when it is a DNS
+// name, so clients dialing an IP address need this.
+func (c *Config) DefaultServerName(addrs string) {
+	if c.ServerName != "" {
+		return
+	}
+	var name string
+	for _, addr := range strings.Split(addrs, ",") {
+		host, _, err := net.SplitHostPort(strings.TrimSpace(addr))
+		if err != nil || host == "" || (name != "" && host != name) {
+			return
+		}
+		name = host
+	}
+	c.ServerName = name
+}
+
+// ServerEnabled reports whether a server certificate was configured.
+func (c *Config) ServerEnabled() bool {
+	return c.CertFile != ""
+}
+
+// ClientEnabled reports whether the client should dial with TLS.
+func (c *Config) ClientEnabled() bool {
+	return c.CAFile != "" || c.CertFile != "" || c.ServerName != ""
+}
+
+// Reloader holds the certificate and CA pool loaded from a Config and
+// swaps in new ones when the files change. Connections already
+// established keep the certificate they were made with.
+type Reloader struct {
+	cfg Config
+
+	mu   sync.RWMutex
+	cert *tls.Certificate
+	pool *x509.CertPool
+}
+
+// NewReloader loads the files named in cfg.
+func NewReloader(cfg Config) (*Reloader, error) {
+	if (cfg.CertFile == "") != (cfg.KeyFile == "") {
+		return nil, errors.New("tlsutil: a certificate and its key must be given together")
+	}
+	r := &Reloader{cfg: cfg}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload re-reads the certificate, key and CA files. On error the current
+// ones are kept.
+func (r *Reloader) Reload() error {
+	var cert *tls.Certificate
+	if r.cfg.CertFile != "" {
+		c, err := tls.LoadX509KeyPair(r.cfg.CertFile, r.cfg.KeyFile)
+		if err != nil {
+			return fmt.Errorf("tlsutil: %w", err)
+		}
+		cert = &c
+	}
+	var pool *x509.CertPool
+	if r.cfg.CAFile != "" {
+		pem, err := os.ReadFile(r.cfg.CAFile)
+		if err != nil {
+			return err
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("tlsutil: %s: no certificates found", r.cfg.CAFile)
+		}
+	}
+	r.mu.Lock()
+	r.cert, r.pool = cert, pool
+	r.mu.Unlock()
+	if cert != nil {
+		if leaf, err := x509.ParseCertificate(cert.Certificate[0]); err == nil {
+			log.Printf("tlsutil: loaded %s (%s, expires %s)", r.cfg.CertFile, leaf.Subject.CommonName, leaf.NotAfter.Format(time.DateOnly))
+		}
+	}
+	return nil
+}
+
+// Watch reloads the files whenever one of them changes, until ctx is done.
+func (r *Reloader) Watch(ctx context.Context, interval time.Duration) {
+	var paths []string
+	for _, p := range []string{r.cfg.CertFile, r.cfg.KeyFile, r.cfg.CAFile} {
+		if p != "" {
+			paths = append(paths, p)
+		}
+	}
+	filewatch.Watch(ctx, interval, r.Reload, paths...)
+}
+
+func (r *Reloader) current() (*tls.Certificate, *x509.CertPool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, r.pool
+}
+
+// ServerConfig returns a server tls.Config that always presents the
+// current certificate. With a CA file, client certificates are required
+// and verified against the current pool.
+func (r *Reloader) ServerConfig() *tls.Config {
+	cfg := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			cert, _ := r.current()
+			if cert == nil {
+				return nil, errors.New("tlsutil: no server certificate")
+			}
+			return cert, nil
+		},
+	}
+	if r.cfg.CAFile != "" {
+		// The standard verifier only sees the ClientCAs the config was
+		// built with, so verify here to honour a reloaded pool.
+		cfg.ClientAuth = tls.RequireAnyClientCert
+		cfg.VerifyPeerCertificate = func(raw [][]byte, _ [][]*x509.Certificate) error {
+			_, pool := r.current()
+			return verify(raw, pool, x509.ExtKeyUsageClientAuth, "")
+		}
+	}
+	return cfg
+}
+
+// ClientConfig returns a client tls.Config that verifies the server with
+// the current CA pool, or the system roots, and presents the current
+// client certificate when the server asks for one.
+func (r *Reloader) ClientConfig() *tls.Config {
+	cfg := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: r.cfg.ServerName,
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			if cert, _ := r.current(); cert != nil {
+				return cert, nil
+			}
+			return &tls.Certificate{}, nil
+		},
+	}
+	if r.cfg.CAFile != "" {
+		// RootCAs would pin the pool the config was built with, so skip
+		// the standard verifier and check the server name and chain here.
+		cfg.InsecureSkipVerify = true
+		cfg.VerifyConnection = func(cs tls.ConnectionState) error {
+			// cs.ServerName is the SNI name, which is empty when dialing
+			// an IP address; see DefaultServerName.
+			name := r.cfg.ServerName
+			if name == "" {
+				name = cs.ServerName
+			}
+			if name == "" {
+				return errors.New("tlsutil: no server name to verify; set the server name when dialing an IP address")
+			}
+			raw := make([][]byte, len(cs.PeerCertificates))
+			for i, c := range cs.PeerCertificates {
+				raw[i] = c.Raw
+			}
+			_, pool := r.current()
+			return verify(raw, pool, x509.ExtKeyUsageServerAuth, name)
+		}
+	}
+	return cfg
+}
+
+// verify checks the chain in raw against roots for usage and, unless
+// dnsName is empty, that the leaf is valid for dnsName.
+func verify(raw [][]byte, roots *x509.CertPool, usage x509.ExtKeyUsage, dnsName string) error {
+	if len(raw) == 0 {
+		return errors.New("tlsutil: no peer certificate")
+	}
+	certs := make([]*x509.Certificate, len(raw))
+	for i, der := range raw {
+		c, err := x509.ParseCertificate(der)
+		if err != nil {
+			return fmt.Errorf("tlsutil: bad peer certificate: %w", err)
+		}
+		certs[i] = c
+	}
+	opts := x509.VerifyOptions{
+		Roots:         roots,
+		DNSName:       dnsName,
+		Intermediates: x509.NewCertPool(),
+		KeyUsages:     []x509.ExtKeyUsage{usage},
+	}
+	for _, c := range certs[1:] {
+		opts.Intermediates.AddCert(c)
+	}
+	_, err := certs[0].Verify(opts)
+	return err
+}
+
+// Server loads cfg, starts watching its files until ctx is done and
+// returns the server tls.Config.
+func Server(ctx context.Context, cfg Config) (*tls.Config, error) {
+	r, err := NewReloader(cfg)
+	if err != nil {
+		return nil, err
+	}
+	go r.Watch(ctx, 0)
+	return r.ServerConfig(), nil
+}
+
+// Client loads cfg, starts watching its files until ctx is done and
+// returns the client tls.Config.
+func Client(ctx context.Context, cfg Config) (*tls.Config, error) {
+	r, err := NewReloader(cfg)
+	if err != nil {
+		return nil, err
+	}
+	go r.Watch(ctx, 0)
+	return r.ClientConfig(), nil
+}
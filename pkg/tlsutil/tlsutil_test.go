@@ -0,0 +1,378 @@
+package tlsutil
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+// pki issues test certificates and writes them as PEM files to dir.
+type pki struct {
+	t      *testing.T
+	dir    string
+	serial int64
+}
+
+type issued struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	// CertFile and KeyFile are the PEM files.
+	CertFile, KeyFile string
+}
+
+func (p *pki) issue(name string, tmpl *x509.Certificate, parent *issued) *issued {
+	p.t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		p.t.Fatal(err)
+	}
+	p.serial++
+	tmpl.SerialNumber = big.NewInt(p.serial)
+	tmpl.Subject.CommonName = name
+	tmpl.NotBefore = time.Now().Add(-time.Hour)
+	tmpl.NotAfter = time.Now().Add(time.Hour)
+	parentCert, parentKey := tmpl, key
+	if parent != nil {
+		parentCert, parentKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parentCert, &key.PublicKey, parentKey)
+	if err != nil {
+		p.t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+	is := &issued{cert: cert, key: key,
+		CertFile: filepath.Join(p.dir, name+".pem"), KeyFile: filepath.Join(p.dir, name+"-key.pem")}
+	p.write(is.CertFile, "CERTIFICATE", der)
+	p.write(is.KeyFile, "EC PRIVATE KEY", keyDER)
+	return is
+}
+
+func (p *pki) write(path, typ string, der []byte) {
+	p.t.Helper()
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0o600); err != nil {
+		p.t.Fatal(err)
+	}
+}
+
+func (p *pki) ca(name string) *issued {
+	return p.issue(name, &x509.Certificate{IsCA: true, BasicConstraintsValid: true, KeyUsage: x509.KeyUsageCertSign}, nil)
+}
+
+func (p *pki) leaf(name string, usage x509.ExtKeyUsage, ca *issued) *issued {
+	return p.issue(name, &x509.Certificate{
+		Subject:     pkix.Name{Organization: []string{"test"}},
+		DNSNames:    []string{"localhost"},
+		IPAddresses: []net.IP{net.IPv4(127, 0, 0, 1)},
+		URIs:        []*url.URL{{Scheme: "spiffe", Host: "test", Path: "/" + name}},
+		ExtKeyUsage: []x509.ExtKeyUsage{usage},
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+	}, ca)
+}
+
+// handshake runs a TLS handshake over loopback TCP and returns the
+// server's view of the connection, or the first error either side saw.
+func handshake(t *testing.T, server, client *tls.Config) (tls.ConnectionState, error) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	type result struct {
+		state tls.ConnectionState
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			done <- result{err: err}
+			return
+		}
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+		s := tls.Server(conn, server)
+		err = s.Handshake()
+		done <- result{s.ConnectionState(), err}
+	}()
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	c := tls.Client(conn, client)
+	err = c.Handshake()
+	if err == nil {
+		// A TLS 1.3 client finishes before the server has checked its
+		// certificate; a rejection arrives as an alert on the next read,
+		// success as EOF once the server closes.
+		if _, err = c.Read(make([]byte, 1)); err == io.EOF {
+			err = nil
+		}
+	}
+	r := <-done
+	if r.err != nil {
+		return r.state, r.err
+	}
+	return r.state, err
+}
+
+func TestMutualTLS(t *testing.T) {
+	p := &pki{t: t, dir: t.TempDir()}
+	ca, rogue := p.ca("ca"), p.ca("rogue")
+	server := p.leaf("server", x509.ExtKeyUsageServerAuth, ca)
+	alice := p.leaf("alice", x509.ExtKeyUsageClientAuth, ca)
+	mallory := p.leaf("mallory", x509.ExtKeyUsageClientAuth, rogue)
+	wrongUsage := p.leaf("server2", x509.ExtKeyUsageServerAuth, ca)
+
+	srv, err := NewReloader(Config{CertFile: server.CertFile, KeyFile: server.KeyFile, CAFile: ca.CertFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverConfig := srv.ServerConfig()
+	clientConfig := func(c *issued) *tls.Config {
+		t.Helper()
+		cfg := Config{CAFile: ca.CertFile, ServerName: "localhost"}
+		if c != nil {
+			cfg.CertFile, cfg.KeyFile = c.CertFile, c.KeyFile
+		}
+		r, err := NewReloader(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return r.ClientConfig()
+	}
+
+	tests := []struct {
+		name   string
+		client *issued
+		wantCN string // "" means the handshake must fail
+	}{
+		{"trusted client", alice, "alice"},
+		{"no client certificate", nil, ""},
+		{"untrusted CA", mallory, ""},
+		{"server-only key usage", wrongUsage, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state, err := handshake(t, serverConfig, clientConfig(tt.client))
+			if tt.wantCN == "" {
+				if err == nil {
+					t.Fatal("handshake succeeded")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			id := IdentityFromState(&state)
+			if id == nil || id.CommonName != tt.wantCN || id.Issuer != "ca" ||
+				len(id.URIs) != 1 || id.URIs[0] != "spiffe://test/"+tt.wantCN || len(id.Fingerprint) != 64 {
+				t.Fatalf("identity %+v", id)
+			}
+		})
+	}
+
+	// Trusting the rogue CA instead takes effect without a restart.
+	data, err := os.ReadFile(rogue.CertFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(ca.CertFile, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := handshake(t, serverConfig, clientConfig(alice)); err == nil {
+		t.Fatal("client of the old CA accepted after reload")
+	}
+}
+
+func TestClientReloadsCA(t *testing.T) {
+	p := &pki{t: t, dir: t.TempDir()}
+	oldCA, newCA := p.ca("old-ca"), p.ca("new-ca")
+	serverConfig := func(ca *issued) *tls.Config {
+		t.Helper()
+		cert := p.leaf("server-"+ca.cert.Subject.CommonName, x509.ExtKeyUsageServerAuth, ca)
+		r, err := NewReloader(Config{CertFile: cert.CertFile, KeyFile: cert.KeyFile})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return r.ServerConfig()
+	}
+	oldServer, newServer := serverConfig(oldCA), serverConfig(newCA)
+	trust := func(ca *issued) {
+		t.Helper()
+		data, err := os.ReadFile(ca.CertFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(p.dir, "ca.pem"), data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	trust(oldCA)
+	r, err := NewReloader(Config{CAFile: filepath.Join(p.dir, "ca.pem"), ServerName: "localhost"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := r.ClientConfig()
+	// check hands client, built once, to servers of both CAs.
+	check := func(step string, wantOld, wantNew bool) {
+		t.Helper()
+		if _, err := handshake(t, oldServer, client); (err == nil) != wantOld {
+			t.Errorf("%s: old CA server: err %v, want success %v", step, err, wantOld)
+		}
+		if _, err := handshake(t, newServer, client); (err == nil) != wantNew {
+			t.Errorf("%s: new CA server: err %v, want success %v", step, err, wantNew)
+		}
+	}
+	check("before rotation", true, false)
+	trust(newCA)
+	if err := r.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	check("after rotation", false, true)
+}
+
+func TestClientVerifiesServerName(t *testing.T) {
+	p := &pki{t: t, dir: t.TempDir()}
+	ca := p.ca("ca")
+	cert := p.leaf("server", x509.ExtKeyUsageServerAuth, ca)
+	srv, err := NewReloader(Config{CertFile: cert.CertFile, KeyFile: cert.KeyFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name       string
+		serverName string // Config.ServerName
+		dialName   string // tls.Config.ServerName, as set by gRPC from the target
+		wantErr    bool
+	}{
+		{"configured", "localhost", "", false},
+		{"configured IP", "127.0.0.1", "", false},
+		{"from dial host", "", "localhost", false},
+		{"wrong name", "example.com", "", true},
+		{"wrong dial host", "", "example.com", true},
+		{"IP dial host", "", "127.0.0.1", true}, // not sent as SNI, so unknown to VerifyConnection
+		{"none", "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := NewReloader(Config{CAFile: ca.CertFile, ServerName: tt.serverName})
+			if err != nil {
+				t.Fatal(err)
+			}
+			client := r.ClientConfig()
+			if tt.dialName != "" {
+				client.ServerName = tt.dialName
+			}
+			if _, err := handshake(t, srv.ServerConfig(), client); (err != nil) != tt.wantErr {
+				t.Fatalf("handshake err %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDefaultServerName(t *testing.T) {
+	tests := []struct {
+		name, preset, addrs, want string
+	}{
+		{"single", "", "127.0.0.1:50051", "127.0.0.1"},
+		{"same host", "", "127.0.0.1:50051, 127.0.0.1:50052", "127.0.0.1"},
+		{"different hosts", "", "10.0.0.1:1,10.0.0.2:1", ""},
+		{"resolver target", "", "static:///127.0.0.1:50051", ""},
+		{"IPv6", "", "[::1]:50051", "::1"},
+		{"already set", "localhost", "127.0.0.1:50051", "localhost"},
+	}
+	for _, tt := range tests {
+		c := Config{ServerName: tt.preset}
+		c.DefaultServerName(tt.addrs)
+		if c.ServerName != tt.want {
+			t.Errorf("%s: ServerName = %q, want %q", tt.name, c.ServerName, tt.want)
+		}
+	}
+}
+
+func TestNewReloaderErrors(t *testing.T) {
+	p := &pki{t: t, dir: t.TempDir()}
+	ca := p.ca("ca")
+	server := p.leaf("server", x509.ExtKeyUsageServerAuth, ca)
+	tests := []struct {
+		name string
+		cfg  Config
+		want string
+	}{
+		{"cert without key", Config{CertFile: server.CertFile}, "together"},
+		{"key without cert", Config{KeyFile: server.KeyFile}, "together"},
+		{"mismatched key", Config{CertFile: server.CertFile, KeyFile: ca.KeyFile}, "private key does not match"},
+		{"missing CA", Config{CAFile: ca.CertFile + ".missing"}, "no such file"},
+		{"CA without certificates", Config{CAFile: server.KeyFile}, "no certificates found"},
+	}
+	for _, tt := range tests {
+		if _, err := NewReloader(tt.cfg); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: err = %v, want %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestReloadKeepsCertificateOnError(t *testing.T) {
+	p := &pki{t: t, dir: t.TempDir()}
+	ca := p.ca("ca")
+	server := p.leaf("server", x509.ExtKeyUsageServerAuth, ca)
+	r, err := NewReloader(Config{CertFile: server.CertFile, KeyFile: server.KeyFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	before, _ := r.current()
+	os.WriteFile(server.CertFile, []byte("garbage"), 0o600)
+	if err := r.Reload(); err == nil {
+		t.Fatal("Reload accepted a bad certificate")
+	}
+	if after, _ := r.current(); after != before {
+		t.Fatal("bad reload replaced the certificate")
+	}
+}
+
+func TestPeerFromContext(t *testing.T) {
+	p := &pki{t: t, dir: t.TempDir()}
+	alice := p.leaf("alice", x509.ExtKeyUsageClientAuth, p.ca("ca"))
+	state := tls.ConnectionState{PeerCertificates: []*x509.Certificate{alice.cert}}
+	tests := []struct {
+		name   string
+		ctx    context.Context
+		wantCN string
+	}{
+		{"empty", context.Background(), ""},
+		{"NewContext", NewContext(context.Background(), &Identity{CommonName: "bob"}), "bob"},
+		{"gRPC peer", peer.NewContext(context.Background(), &peer.Peer{AuthInfo: credentials.TLSInfo{State: state}}), "alice"},
+		{"gRPC peer without certificate", peer.NewContext(context.Background(), &peer.Peer{AuthInfo: credentials.TLSInfo{}}), ""},
+		{"plaintext gRPC peer", peer.NewContext(context.Background(), &peer.Peer{}), ""},
+	}
+	for _, tt := range tests {
+		id, ok := PeerFromContext(tt.ctx)
+		if ok != (tt.wantCN != "") || (ok && id.CommonName != tt.wantCN) {
+			t.Errorf("%s: PeerFromContext = %+v, %v; want %q", tt.name, id, ok, tt.wantCN)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net/rpc"
+	"strings"
+	"sync"
+	"time"
+
+	"awesomeproject/pkg/rpcclient"
+	"awesomeproject/serverStub"
+)
+
+func main() {
+	cfg := rpcclient.DefaultPoolConfig()
+	addrs := flag.String("addrs", "127.0.0.1:1234", "comma-separated server addresses")
+	flag.IntVar(&cfg.ConnsPerBackend, "conns", cfg.ConnsPerBackend, "connections per server")
+	flag.StringVar(&cfg.Balancer, "balancer", cfg.Balancer, "round_robin, least_outstanding or p2c")
+	flag.DurationVar(&cfg.ProbeInterval, "probe-interval", cfg.ProbeInterval, "health probe interval")
+	calls := flag.Int("calls", 10000, "number of calls")
+	concurrency := flag.Int("concurrency", 64, "concurrent callers")
+	flag.Parse()
+	cfg.Addrs = strings.Split(*addrs, ",")
+
+	// 用 Hello 做健康探测, 比默认的 Ping 更能说明服务可用
+	cfg.Probe = func(ctx context.Context, c *rpc.Client) error {
+		var reply string
+		call := c.Go(serverStub.HelloService_Hello_FullMethodName, "probe", &reply, make(chan *rpc.Call, 1))
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-call.Done:
+			return call.Error
+		}
+	}
+	pool, err := rpcclient.NewPool(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer pool.Close()
+
+	// 多个 goroutine 并发调用, 请求分摊到所有服务端的所有连接上
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		failed int
+		jobs   = make(chan int)
+	)
+	start := time.Now()
+	for range *concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				var reply string
+				if err := pool.Call(serverStub.HelloService_Hello_FullMethodName, fmt.Sprint(i), &reply); err != nil {
+					mu.Lock()
+					if failed++; failed <= 5 {
+						log.Println("call failed:", err)
+					}
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for i := range *calls {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	fmt.Printf("%d calls (%d failed) in %v, %.0f calls/s\n", *calls, failed, elapsed.Round(time.Millisecond), float64(*calls)/elapsed.Seconds())
+	for _, s := range pool.Stats() {
+		fmt.Printf("  %-20s healthy=%-5v conns=%d calls=%d\n", s.Addr, s.Healthy, s.Conns, s.Calls)
+	}
+}
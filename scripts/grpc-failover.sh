@@ -0,0 +1,49 @@
+#!/usr/bin/env bash
+# Starts three Greeter replicas and calls them through one balanced
+# grpcclient while replica 2 reports NOT_SERVING, replica 3 is killed and
+# replica 2 recovers. Every call logs the backend that served it.
+#
+#   scripts/grpc-failover.sh [round_robin|least_request|pick_first]
+set -euo pipefail
+
+lb=${1:-round_robin}
+cd "$(dirname "$0")/.."
+dir=$(mktemp -d)
+pids=()
+cleanup() {
+	kill "${pids[@]}" 2>/dev/null || true
+	wait 2>/dev/null || true
+	rm -rf "$dir"
+}
+trap cleanup EXIT
+
+go build -o "$dir/grpcserver" ./cmd/grpcserver
+go build -o "$dir/grpcclient" ./cmd/grpcclient
+
+addrs=()
+for i in 1 2 3; do
+	addr=127.0.0.1:$((50060 + i))
+	touch "$dir/up$i"
+	"$dir/grpcserver" -addr "$addr" -health-dep "up=file:$dir/up$i" -health-interval 300ms \
+		>"$dir/replica$i.log" 2>&1 &
+	pids+=($!)
+	addrs+=("$addr")
+done
+sleep 1
+
+target=$(IFS=,; echo "${addrs[*]}")
+echo "== calling $target with $lb"
+"$dir/grpcclient" -addr "$target" -lb "$lb" -attempts -repeat 40 -interval 200ms hello &
+client=$!
+
+sleep 2
+echo "== replica 2 (${addrs[1]}) reports NOT_SERVING"
+rm "$dir/up2"
+sleep 2
+echo "== replica 3 (${addrs[2]}) is killed"
+kill "${pids[2]}"
+sleep 2
+echo "== replica 2 (${addrs[1]}) is SERVING again"
+touch "$dir/up2"
+
+wait "$client"
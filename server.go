@@ -1,24 +1,75 @@
 package main
 
-import "net"
-import "net/rpc"
+import (
+	"context"
+	"expvar"
+	"flag"
+	"log"
+	"net"
+	"net/http"
+	"net/rpc"
 
-type HelloService struct{}
-
-func (s *HelloService) Hello(request string, reply *string) error {
-	*reply = "Hello " + request
-	return nil
-}
+	"awesomeproject/handler"
+	"awesomeproject/pkg/auth"
+	"awesomeproject/pkg/rpchook"
+	"awesomeproject/pkg/rpcserver"
+	"awesomeproject/pkg/shutdown"
+	"awesomeproject/serverStub"
+)
 
 func main() {
-	_ = rpc.RegisterName("HelloService", &HelloService{})
-	listener, err := net.Listen("tcp", ":1234")
-	if err != nil {
-		panic("failed to listen: " + err.Error())
+	cfg := rpcserver.DefaultConfig()
+	cfg.RegisterFlags(flag.CommandLine)
+	var authCfg auth.Config
+	authCfg.RegisterFlags(flag.CommandLine)
+	debugAddr := flag.String("debug-addr", "", "serve connection metrics at /debug/vars on this address")
+	drainTimeout := flag.Duration("shutdown-timeout", shutdown.DefaultTimeout, "how long to wait for in-flight calls on shutdown")
+	flag.Parse()
+
+	if err := serverStub.RegisterHelloService(&handler.HelloService{}); err != nil {
+		log.Fatal(err)
+	}
+
+	// 每个调用经过的钩子: 日志、按方法统计, 以及配置了密钥时的 Token 校验
+	var metrics rpchook.Metrics
+	hooks := []rpchook.Hooks{rpchook.Logging(log.Default()), metrics.Hooks()}
+	if authCfg.Enabled() {
+		authenticator, err := auth.New(authCfg)
+		if err != nil {
+			log.Fatalf("auth: %v", err)
+		}
+		hooks = append(hooks, rpchook.Auth(authenticator))
+	}
+	cfg.WrapCodec = func(conn net.Conn, codec rpc.ServerCodec) rpc.ServerCodec {
+		c := rpchook.NewServerCodec(codec, hooks...)
+		c.Peer = conn.RemoteAddr().String()
+		return c
 	}
-	conn, err := listener.Accept()
+	srv, err := rpcserver.New(cfg)
 	if err != nil {
-		panic("failed to accept: " + err.Error())
+		log.Fatal(err)
+	}
+
+	// 连接与调用统计通过 expvar 暴露, 例如 curl localhost:6060/debug/vars
+	expvar.Publish("rpcserver", expvar.Func(func() any { return srv.Stats() }))
+	expvar.Publish("rpcmethods", expvar.Func(func() any { return metrics.Snapshot() }))
+	if *debugAddr != "" {
+		go func() {
+			log.Println("debug:", http.ListenAndServe(*debugAddr, nil))
+		}()
+	}
+
+	// 收到 SIGINT/SIGTERM 后停止 Accept, 等待进行中的调用完成再退出
+	ctx, stop := shutdown.OnSignal()
+	defer stop()
+	if err := srv.ListenAndServe(ctx); err != nil {
+		log.Fatal(err)
+	}
+
+	log.Printf("shutting down, draining %d connections", srv.Stats().Active)
+	drainCtx, cancel := context.WithTimeout(context.Background(), *drainTimeout)
+	defer cancel()
+	if err := srv.Shutdown(drainCtx); err != nil {
+		log.Println("drain:", err)
 	}
-	rpc.ServeConn(conn)
 }
@@ -1,21 +1,62 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"log"
 	"net/http"
 	"net/rpc"
 
 	"awesomeproject/handler"
+	"awesomeproject/pkg/auth"
+	"awesomeproject/pkg/rpchook"
+	"awesomeproject/pkg/shutdown"
 	"awesomeproject/serverStub"
 )
 
 func main() {
+	var authCfg auth.Config
+	authCfg.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+
 	if err := serverStub.RegisterHelloService(&handler.HelloService{}); err != nil {
 		log.Fatal("failed to register hello service:", err)
 	}
 
-	rpc.HandleHTTP()
+	// net/rpc hijacks the connection after CONNECT, so http.Server.Shutdown
+	// cannot see it; track those connections separately
+	var tunnels shutdown.Group
+	mux := http.NewServeMux()
+	hooks := []rpchook.Hooks{rpchook.Logging(log.Default())}
+	if authCfg.Enabled() {
+		authenticator, err := auth.New(authCfg)
+		if err != nil {
+			log.Fatalf("auth: %v", err)
+		}
+		hooks = append(hooks, rpchook.Auth(authenticator))
+	}
+	mux.Handle(rpc.DefaultRPCPath, tunnels.Hijacked(rpchook.Handler(rpc.DefaultServer, hooks...)))
+
 	addr := ":8081"
-	log.Printf("net/rpc over HTTP listening on %s (path: %s)", addr, rpc.DefaultRPCPath)
-	log.Fatal(http.ListenAndServe(addr, nil))
+	srv := &http.Server{Addr: addr, Handler: mux}
+	ctx, stop := shutdown.OnSignal()
+	defer stop()
+	go func() {
+		log.Printf("net/rpc over HTTP listening on %s (path: %s)", addr, rpc.DefaultRPCPath)
+		if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal(err)
+		}
+	}()
+
+	<-ctx.Done()
+	log.Printf("shutting down, draining %d connections", tunnels.Len())
+	drainCtx, cancel := context.WithTimeout(context.Background(), shutdown.DefaultTimeout)
+	defer cancel()
+	if err := srv.Shutdown(drainCtx); err != nil {
+		log.Println("http shutdown:", err)
+	}
+	if err := tunnels.Shutdown(drainCtx); err != nil {
+		log.Println("drain:", err)
+	}
 }
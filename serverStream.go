@@ -2,17 +2,27 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
-	"io"
 	"log"
 	"net"
-	"sync"
 	"time"
 
+	"awesomeproject/pkg/auth"
+	"awesomeproject/pkg/authz"
+	"awesomeproject/pkg/health"
+	"awesomeproject/pkg/heartbeat"
+	"awesomeproject/pkg/ratelimit"
+	"awesomeproject/pkg/shutdown"
+	"awesomeproject/pkg/tlsutil"
 	"awesomeproject/proto"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/metadata" // 导入 metadata 包
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
 )
 
 const ADDR = ":50052"
@@ -22,7 +32,7 @@ const ADDR = ":50052"
 // UnaryInterceptor: 处理普通非流式请求的“管家”
 func myUnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
 	log.Printf("【拦截器】收到普通请求: %s", info.FullMethod)
-	// 在这里可以统一检查 Token
+	// Token 由后面的 auth 拦截器统一校验
 	return handler(ctx, req)
 }
 
@@ -43,65 +53,51 @@ func myStreamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.Strea
 	wrapper := &wrappedStream{ServerStream: ss}
 	// 继续执行后续逻辑
 	err := handler(srv, wrapper)
-	log.Printf("[Filtering end] %d messages processed", wrapper.count)
+	log.Printf("[Filtering end] %d messages processed, status %s", wrapper.count, status.Code(err))
 	return err
 }
 
 type serverStream struct {
 	proto.UnimplementedGreeterServer
+
+	cfg heartbeat.Config // AllStream 的持续时间、心跳和静默超时
 }
 
+// AllStream 双向流: 边收客户端消息边发心跳.
+// 客户端关闭发送端且心跳发完后正常结束; 否则以对应的状态结束:
+// 客户端取消为 Canceled, 超过客户端 deadline 或 lifetime、客户端长时间静默为 DeadlineExceeded.
 func (s *serverStream) AllStream(allStr proto.Greeter_AllStreamServer) error {
 	// --- 魔法 2: 提取 Metadata (小纸条) ---
 	// 从流的 Context 中获取客户端传来的元数据
 	md, ok := metadata.FromIncomingContext(allStr.Context())
 	if ok {
 		fmt.Printf("【服务端】收到小纸条 Metadata: %v\n", md)
-		if tokens, exists := md["token"]; exists {
-			fmt.Printf("【服务端】验证 Token: %s\n", tokens[0])
-		}
+	}
+	// Token 已由 auth 拦截器验证, 这里直接取出调用者身份
+	if p, ok := auth.FromContext(allStr.Context()); ok {
+		fmt.Printf("【服务端】调用者: %s (roles=%v)\n", p.Subject, p.Roles)
+	}
+	// mTLS 时可以拿到客户端证书身份
+	if id, ok := tlsutil.PeerFromContext(allStr.Context()); ok {
+		fmt.Printf("【服务端】客户端证书: %s\n", id)
+	}
+	if deadline, ok := allStr.Context().Deadline(); ok {
+		fmt.Printf("【服务端】客户端 deadline 在 %v 后\n", time.Until(deadline).Round(time.Millisecond))
 	}
 
-	// 保留原有的 Context 逻辑
-	ctx, cancel := context.WithTimeout(allStr.Context(), 20*time.Second)
-	defer cancel()
-
-	wg := sync.WaitGroup{}
-	wg.Add(2)
-
-	go func() {
-		defer wg.Done()
-		for {
-			select {
-			case <-ctx.Done():
-				return
-			default:
-				data, err := allStr.Recv()
-				if err == io.EOF {
-					return
-				}
-				if err != nil {
-					return
-				}
-				fmt.Println("收到客户端消息: " + data.Data)
-			}
-		}
-	}()
-
-	go func() {
-		defer wg.Done()
-		for i := 0; i < 5; i++ {
-			select {
-			case <-ctx.Done():
-				return
-			case <-time.After(time.Second * 2):
-				_ = allStr.Send(&proto.StreamResData{Data: "服务端心跳包"})
-			}
-		}
-	}()
+	// 收消息、发心跳和各种超时都由 heartbeat.Serve 处理
+	return heartbeat.Serve(allStr, s.cfg, s.beat, func(data *proto.StreamReqData) {
+		fmt.Println("收到客户端消息: " + data.Data)
+	})
+}
 
-	wg.Wait()
-	return nil
+// beat 生成第 n 个心跳包
+func (s *serverStream) beat(n int) *proto.StreamResData {
+	msg := fmt.Sprintf("服务端心跳包 %d", n)
+	if s.cfg.Count > 0 {
+		msg = fmt.Sprintf("服务端心跳包 %d/%d", n, s.cfg.Count)
+	}
+	return &proto.StreamResData{Data: msg}
 }
 
 // 实现必要的接口
@@ -110,15 +106,117 @@ func (s *serverStream) SayHello(ctx context.Context, req *proto.HelloRequest) (*
 }
 
 func main() {
-	listen, _ := net.Listen("tcp", ADDR)
+	// 例如: go run serverStream.go -auth-keys config/apikeys.example.json
+	var authCfg auth.Config
+	authCfg.RegisterFlags(flag.CommandLine)
+	var authzCfg authz.Config
+	authzCfg.RegisterFlags(flag.CommandLine)
+	var tlsCfg tlsutil.Config
+	tlsCfg.RegisterServerFlags(flag.CommandLine) // 例如 -tls-cert certs/server.pem -tls-key certs/server-key.pem -tls-client-ca certs/ca.pem
+	var healthCfg health.Config
+	healthCfg.RegisterFlags(flag.CommandLine)
+	srv := &serverStream{cfg: heartbeat.DefaultConfig()}
+	srv.cfg.RegisterFlags(flag.CommandLine)
+	// 限流: 默认不限; 例如 -rate 5 -burst 10 为每个客户端每个方法每秒 5 次, 突发 10 次, 按方法配置见 -ratelimit-config
+	rate := flag.Float64("rate", 0, "default calls per second per client (0 = unlimited)")
+	burst := flag.Int("burst", 10, "default rate limit burst")
+	rateKey := flag.String("ratelimit-key", "principal", "rate limit clients by: principal, ip or metadata:<key>")
+	rateMaxClients := flag.Int("ratelimit-max-clients", ratelimit.DefaultMaxClients, "most clients to keep rate limit buckets for")
+	rateConfig := flag.String("ratelimit-config", "", "JSON file with per-method rate limits (overrides -rate/-burst/-ratelimit-key)")
+	flag.Parse()
+	if srv.cfg.Interval <= 0 {
+		log.Fatal("-heartbeat-interval must be positive")
+	}
+
+	listen, err := net.Listen("tcp", ADDR)
+	if err != nil {
+		log.Fatalf("监听 %s 失败: %v", ADDR, err)
+	}
+
+	rlCfg := ratelimit.Config{Default: ratelimit.Rule{Rate: *rate, Burst: *burst}, Key: *rateKey, MaxClients: *rateMaxClients}
+	if *rateConfig != "" {
+		if rlCfg, err = ratelimit.LoadConfig(*rateConfig); err != nil {
+			log.Fatalf("load rate limit config: %v", err)
+		}
+	}
+	limitKey, err := ratelimit.GRPCKey(rlCfg.Key)
+	if err != nil {
+		log.Fatal(err)
+	}
+	limiter := ratelimit.New(rlCfg)
+
+	unary := []grpc.UnaryServerInterceptor{myUnaryInterceptor}
+	stream := []grpc.StreamServerInterceptor{myStreamInterceptor}
+	var policy *authz.Engine
+	if authzCfg.Enabled() {
+		if policy, err = authz.Start(context.Background(), authzCfg); err != nil {
+			log.Fatalf("authz: %v", err)
+		}
+	}
+	if authCfg.Enabled() {
+		authCfg.Public = append(authCfg.Public, health.Methods) // 健康检查无需 Token
+		authenticator, err := auth.New(authCfg)
+		if err != nil {
+			log.Fatalf("auth: %v", err)
+		}
+		if policy != nil {
+			authenticator.AllowAnonymous(policy.IsPublic) // 策略里 public 的方法无需 Token
+		}
+		unary = append(unary, auth.UnaryServerInterceptor(authenticator))
+		stream = append(stream, auth.StreamServerInterceptor(authenticator))
+	} else {
+		log.Printf("未配置 -auth-keys 等参数, 不校验 Token")
+	}
+	// 限流放在认证之后: 未通过认证的请求不占用令牌桶
+	unary = append(unary, ratelimit.UnaryServerInterceptor(limiter, limitKey))
+	stream = append(stream, ratelimit.StreamServerInterceptor(limiter, limitKey))
+	if policy != nil {
+		unary = append(unary, authz.UnaryServerInterceptor(policy))
+		stream = append(stream, authz.StreamServerInterceptor(policy))
+	}
 
 	// 在创建服务器时，注册拦截器
-	s := grpc.NewServer(
-		grpc.UnaryInterceptor(myUnaryInterceptor),   // 注册普通拦截器
-		grpc.StreamInterceptor(myStreamInterceptor), // 注册流式拦截器
-	)
+	opts := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(unary...),   // 注册普通拦截器
+		grpc.ChainStreamInterceptor(stream...), // 注册流式拦截器
+	}
+	if tlsCfg.ServerEnabled() {
+		// 证书文件变化时自动重新加载, 无需重启
+		tc, err := tlsutil.Server(context.Background(), tlsCfg)
+		if err != nil {
+			log.Fatalf("tls: %v", err)
+		}
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tc)))
+	}
+	s := grpc.NewServer(opts...)
+
+	proto.RegisterGreeterServer(s, srv)
+
+	// 健康检查: grpc.health.v1, 依赖检查失败或退出时变为 NOT_SERVING
+	monitor := health.Start(context.Background(), healthCfg, proto.Greeter_ServiceDesc.ServiceName)
+	healthpb.RegisterHealthServer(s, monitor.GRPC())
+	reflection.Register(s) // 支持 rpcurl / grpcurl 动态发现服务
+
+	// 优雅退出: 停止接收新连接, 健康状态置为 NOT_SERVING,
+	// 等待进行中的调用结束, 超时后强制关闭剩余的流
+	ctx, stop := shutdown.OnSignal()
+	defer stop()
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		<-ctx.Done()
+		log.Printf("收到退出信号, 健康状态置为 NOT_SERVING")
+		monitor.Shutdown()
+		drainCtx, cancel := context.WithTimeout(context.Background(), shutdown.DefaultTimeout)
+		defer cancel()
+		if err := shutdown.Graceful(drainCtx, s.GracefulStop, s.Stop); err != nil {
+			log.Printf("等待超时, 已强制关闭剩余的流: %v", err)
+		}
+	}()
 
-	proto.RegisterGreeterServer(s, &serverStream{})
 	log.Printf("流式服务(带拦截器)已启动: %s", ADDR)
-	s.Serve(listen)
+	if err := s.Serve(listen); err != nil {
+		log.Fatalf("服务异常退出: %v", err)
+	}
+	<-stopped
 }
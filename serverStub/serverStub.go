@@ -1,16 +1,26 @@
 package serverStub
 
 import (
+	"context"
+
 	"awesomeproject/handler"
-	"net/rpc"
 )
 
-// 鸭子模型： 实现了Hello 方法的Struct 都可以堪称 HelloServicer
+//go:generate go run awesomeproject/cmd/rpcgen
+//go:generate go run awesomeproject/cmd/rpcproto -type HelloServicer -service HelloService -package helloservice -proto ../proto/helloservice.proto
+
+// 鸭子模型： 实现了这些方法的 Struct 都可以堪称 HelloServicer。
+// 注册函数、客户端和方法名常量都由 rpcgen 生成 (serverStub_rpcgen.go),
+// 增加方法后运行 go generate ./serverStub 即可。
+// rpcproto 另外生成 proto/helloservice.proto 和 gRPC 适配器 (helloservice_grpc.go),
+// 不改写 handler 就能通过 gRPC 调用这些方法。
+// 带 context 的方法只有 hertzrpc 支持, net/rpc 会跳过它们。
+//
+//rpcgen:service HelloService
 type HelloServicer interface {
 	Hello(request string, reply *string) error
+	Greet(args handler.GreetArgs, reply *string) error
+	WhoAmI(ctx context.Context, args struct{}, reply *string) error
 }
 
-func RegisterHelloService(service HelloServicer) error {
-	err := rpc.RegisterName(handler.HelloServiceName, service)
-	return err
-}
+var _ HelloServicer = (*handler.HelloService)(nil)
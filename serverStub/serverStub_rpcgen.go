@@ -0,0 +1,110 @@
+// Code generated by rpcgen from serverStub.go; DO NOT EDIT.
+
+package serverStub
+
+import (
+	"context"
+	"net/rpc"
+
+	"awesomeproject/handler"
+	"awesomeproject/pkg/hertzrpc"
+	"awesomeproject/pkg/rpcclient"
+	"awesomeproject/pkg/rpchook"
+)
+
+// Service and method names of HelloServicer.
+const (
+	HelloServiceName                   = "HelloService"
+	HelloService_Hello_FullMethodName  = "HelloService.Hello"
+	HelloService_Greet_FullMethodName  = "HelloService.Greet"
+	HelloService_WhoAmI_FullMethodName = "HelloService.WhoAmI"
+)
+
+// RegisterHelloService registers service with rpc.DefaultServer as HelloServiceName.
+// A panic in one of its methods fails the call instead of the server.
+func RegisterHelloService(service HelloServicer) error {
+	return rpc.RegisterName(HelloServiceName, recoverHelloService{service})
+}
+
+// RegisterHelloServiceOn registers service with srv as HelloServiceName.
+// A panic in one of its methods fails the call instead of the server.
+func RegisterHelloServiceOn(srv *rpc.Server, service HelloServicer) error {
+	return srv.RegisterName(HelloServiceName, recoverHelloService{service})
+}
+
+// recoverHelloService serves the net/rpc methods of a HelloServicer, turning
+// their panics into errors. net/rpc calls methods on goroutines of its
+// own, out of reach of an rpchook codec.
+type recoverHelloService struct {
+	service HelloServicer
+}
+
+func (c recoverHelloService) Hello(request string, reply *string) (err error) {
+	defer rpchook.Recover(&err)
+	return c.service.Hello(request, reply)
+}
+
+func (c recoverHelloService) Greet(args handler.GreetArgs, reply *string) (err error) {
+	defer rpchook.Recover(&err)
+	return c.service.Greet(args, reply)
+}
+
+// RegisterHelloServiceHertz registers service with a hertzrpc Dispatcher as HelloServiceName.
+func RegisterHelloServiceHertz(d *hertzrpc.Dispatcher, service HelloServicer) error {
+	return d.RegisterName(HelloServiceName, service)
+}
+
+// HelloServiceClient is a typed net/rpc client for HelloService.
+type HelloServiceClient struct {
+	*rpcclient.Client
+}
+
+// NewHelloServiceClient returns a client that dials on its first call.
+func NewHelloServiceClient(dial rpcclient.Dialer) *HelloServiceClient {
+	return &HelloServiceClient{rpcclient.New(dial)}
+}
+
+// Hello calls HelloService.Hello.
+func (c *HelloServiceClient) Hello(ctx context.Context, request string) (string, error) {
+	var reply string
+	err := c.Call(ctx, HelloService_Hello_FullMethodName, request, &reply)
+	return reply, err
+}
+
+// Greet calls HelloService.Greet.
+func (c *HelloServiceClient) Greet(ctx context.Context, args handler.GreetArgs) (string, error) {
+	var reply string
+	err := c.Call(ctx, HelloService_Greet_FullMethodName, args, &reply)
+	return reply, err
+}
+
+// HelloServiceHertzClient is a typed hertzrpc client for HelloService.
+type HelloServiceHertzClient struct {
+	*hertzrpc.Client
+}
+
+// NewHelloServiceHertzClient returns a client for the Dispatcher at url.
+func NewHelloServiceHertzClient(url string) *HelloServiceHertzClient {
+	return &HelloServiceHertzClient{hertzrpc.NewClient(url)}
+}
+
+// Hello calls HelloService.Hello.
+func (c *HelloServiceHertzClient) Hello(ctx context.Context, request string) (string, error) {
+	var reply string
+	err := c.Call(ctx, HelloService_Hello_FullMethodName, request, &reply)
+	return reply, err
+}
+
+// Greet calls HelloService.Greet.
+func (c *HelloServiceHertzClient) Greet(ctx context.Context, args handler.GreetArgs) (string, error) {
+	var reply string
+	err := c.Call(ctx, HelloService_Greet_FullMethodName, args, &reply)
+	return reply, err
+}
+
+// WhoAmI calls HelloService.WhoAmI.
+func (c *HelloServiceHertzClient) WhoAmI(ctx context.Context, args struct{}) (string, error) {
+	var reply string
+	err := c.Call(ctx, HelloService_WhoAmI_FullMethodName, args, &reply)
+	return reply, err
+}
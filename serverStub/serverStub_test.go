@@ -0,0 +1,34 @@
+package serverStub
+
+import (
+	"net"
+	"net/rpc"
+	"strings"
+	"testing"
+
+	"awesomeproject/handler"
+)
+
+// panicky panics in Hello and answers Greet normally.
+type panicky struct{ *handler.HelloService }
+
+func (panicky) Hello(string, *string) error { panic("boom") }
+
+func TestRegisterHelloServiceOnRecovers(t *testing.T) {
+	srv := rpc.NewServer()
+	if err := RegisterHelloServiceOn(srv, panicky{new(handler.HelloService)}); err != nil {
+		t.Fatal(err)
+	}
+	serverConn, clientConn := net.Pipe()
+	go srv.ServeConn(serverConn)
+	client := rpc.NewClient(clientConn)
+	defer client.Close()
+
+	var reply string
+	if err := client.Call(HelloService_Hello_FullMethodName, "a", &reply); err == nil || !strings.Contains(err.Error(), "internal error: boom") {
+		t.Fatalf("Hello = %v, want the recovered panic", err)
+	}
+	if err := client.Call(HelloService_Greet_FullMethodName, handler.GreetArgs{Name: "b"}, &reply); err != nil || reply != "Hello b" {
+		t.Fatalf("Greet after a panic = %q, %v", reply, err)
+	}
+}
@@ -8,6 +8,8 @@ import (
 	"sync"
 	"time"
 
+	"awesomeproject/pkg/breaker"
+	"awesomeproject/pkg/rpccreds"
 	"awesomeproject/proto"
 
 	"google.golang.org/grpc"
@@ -32,12 +34,25 @@ func clientStreamInterceptor(ctx context.Context, desc *grpc.StreamDesc, cc *grp
 }
 
 func main() {
+	// Token 通过 PerRPCCredentials 在每次调用时自动附加;
+	// 沿用 "token" 这个 key, 本地演示走明文连接, 需显式允许
+	creds := rpccreds.New(rpccreds.Static("my-secret-token"),
+		rpccreds.WithMetadataKey("token"),
+		rpccreds.AllowInsecure(),
+	)
+
+	// 熔断器: 按方法统计失败率, 熔断期间直接返回 Unavailable
+	breakerCfg := breaker.DefaultConfig()
+	breakerCfg.OnStateChange = breaker.LogEvents(nil)
+	breakers := breaker.New(breakerCfg)
+
 	// 1. 建立连接时注册拦截器
 	conn, err := grpc.NewClient(
 		"localhost:50052",
 		grpc.WithTransportCredentials(insecure.NewCredentials()),
-		grpc.WithChainUnaryInterceptor(clientUnaryInterceptor),   // 注册普通拦截器
-		grpc.WithChainStreamInterceptor(clientStreamInterceptor), // 注册流式拦截器
+		grpc.WithPerRPCCredentials(creds),
+		grpc.WithChainUnaryInterceptor(clientUnaryInterceptor, breaker.UnaryClientInterceptor(breakers)),    // 注册普通拦截器
+		grpc.WithChainStreamInterceptor(clientStreamInterceptor, breaker.StreamClientInterceptor(breakers)), // 注册流式拦截器
 	)
 	if err != nil {
 		log.Fatalf("连接失败: %v", err)
@@ -49,7 +64,7 @@ func main() {
 	// ==========================================
 	// 2. 准备 Metadata (小纸条)
 	// ==========================================
-	md := metadata.Pairs("token", "my-secret-token", "client-version", "1.0.0")
+	md := metadata.Pairs("client-version", "1.0.0")
 
 	// ==========================================
 	// 3. 结合 Context 取消逻辑
@@ -1,19 +1,33 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
 	"log"
-	"net/rpc"
+	"net/url"
+	"time"
+
+	"awesomeproject/pkg/rpcclient"
+	"awesomeproject/serverStub"
 )
 
 func main() {
-	client, err := rpc.Dial("tcp", "127.0.0.1:1234")
-	if err != nil {
-		log.Fatal("dialing:", err)
+	addr := flag.String("addr", "127.0.0.1:1234", "server address")
+	token := flag.String("token", "", "bearer token, sent as call metadata")
+	timeout := flag.Duration("timeout", 5*time.Second, "call timeout")
+	flag.Parse()
+
+	client := serverStub.NewHelloServiceClient(rpcclient.DialTCP(*addr))
+	defer client.Close()
+	// net/rpc 没有请求头, Token 附在方法名后面, 由服务端的 rpchook 取出
+	if *token != "" {
+		client.Metadata = url.Values{"authorization": {"Bearer " + *token}}
 	}
 
-	var reply string
-	err = client.Call("HelloService.Hello", "Xc", &reply)
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	reply, err := client.Hello(ctx, "Xc")
 	if err != nil {
 		log.Fatal("call failed:", err)
 	}